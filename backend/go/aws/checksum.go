@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errChecksumMismatch means the object in S3 doesn't hold the bytes we uploaded
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// localDigest is the size and SHA-256 of a local file
+type localDigest struct {
+	size int64
+	// sha256 is base64 encoded, as S3 reports checksums
+	sha256 string
+	// hex is the same digest hex encoded, as sha256sum prints it
+	hex string
+}
+
+// fileDigest hashes a file with SHA-256
+func fileDigest(filePath string) (localDigest, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return localDigest{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return localDigest{}, err
+	}
+
+	sum := hash.Sum(nil)
+	return localDigest{
+		size:   size,
+		sha256: base64.StdEncoding.EncodeToString(sum),
+		hex:    hex.EncodeToString(sum),
+	}, nil
+}
+
+// verify compares an object's size and checksum with the local digest.
+// Multipart objects have a composite checksum ("<hash>-<parts>") that can't be
+// compared with a whole-file hash; S3 already validated each part, so only the size is checked.
+func (d localDigest) verify(remoteSize int64, remoteSHA256 string) error {
+	if remoteSize != d.size {
+		return fmt.Errorf("%w: object is %d bytes, local file is %d bytes", errChecksumMismatch, remoteSize, d.size)
+	}
+	if remoteSHA256 == "" || strings.Contains(remoteSHA256, "-") {
+		return nil
+	}
+	if remoteSHA256 != d.sha256 {
+		return fmt.Errorf("%w: object SHA-256 %s, local file %s", errChecksumMismatch, remoteSHA256, d.sha256)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// Time allowed for reading an SSM parameter, including retries
+const ssmFetchTimeout = 30 * time.Second
+
+// privateKeySource supplies the PEM-encoded CloudFront private key. SSM is used in
+// deployments; a local file or inline PEM suits development and CI, where no
+// SSM parameter is provisioned.
+type privateKeySource interface {
+	// fetch returns the current PEM
+	fetch(ctx context.Context) (string, error)
+	// check verifies the key is still available without fetching it in full
+	check(ctx context.Context) error
+	// String describes the source for logs
+	String() string
+}
+
+// newPrivateKeySource picks the key source: an inline PEM, then a file, then SSM
+func newPrivateKeySource(opts CloudFrontSignerOptions, ssmClient *ssm.Client) (privateKeySource, error) {
+	switch {
+	case opts.PrivateKeyPEM != "" && opts.PrivateKeyFile != "":
+		return nil, fmt.Errorf("set either an inline CloudFront private key or a key file, not both")
+	case opts.PrivateKeyPEM != "":
+		return inlineKeySource(opts.PrivateKeyPEM), nil
+	case opts.PrivateKeyFile != "":
+		return fileKeySource(opts.PrivateKeyFile), nil
+	default:
+		return &ssmKeySource{client: ssmClient, param: cloudFrontPrivateKeyParam}, nil
+	}
+}
+
+// ssmKeySource reads the key from an encrypted SSM parameter
+type ssmKeySource struct {
+	client *ssm.Client
+	param  string
+}
+
+func (s *ssmKeySource) fetch(ctx context.Context) (string, error) {
+	value, err := getParameter(ctx, s.client, &ssm.GetParameterInput{
+		Name:           &s.param,
+		WithDecryption: boolPtr(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get private key from SSM: %w", err)
+	}
+	return value, nil
+}
+
+// check reads the parameter without decrypting it; refreshes do that
+func (s *ssmKeySource) check(ctx context.Context) error {
+	if _, err := s.client.GetParameter(ctx, &ssm.GetParameterInput{Name: &s.param}); err != nil {
+		return fmt.Errorf("parameter %s is not accessible: %w", s.param, err)
+	}
+	return nil
+}
+
+func (s *ssmKeySource) String() string {
+	return "SSM parameter " + s.param
+}
+
+// getParameter reads an SSM parameter, retrying transient failures within
+// ssmFetchTimeout. At boot the network is often not up yet, and failing here
+// would stop the process.
+func getParameter(ctx context.Context, client *ssm.Client, input *ssm.GetParameterInput) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, ssmFetchTimeout)
+	defer cancel()
+
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("SSM get parameter %s", *input.Name))
+	retryConfig.IsRetryable = IsRetryableError
+
+	var value string
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		result, err := client.GetParameter(ctx, input)
+		if err != nil {
+			return err
+		}
+		value = *result.Parameter.Value
+		return nil
+	})
+	return value, err
+}
+
+// fileKeySource reads the key from a local PEM file, re-read on each refresh
+type fileKeySource string
+
+func (f fileKeySource) fetch(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key file: %w", err)
+	}
+	return string(data), nil
+}
+
+func (f fileKeySource) check(ctx context.Context) error {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return fmt.Errorf("private key file is not accessible: %w", err)
+	}
+	return file.Close()
+}
+
+func (f fileKeySource) String() string {
+	return "file " + string(f)
+}
+
+// inlineKeySource is a PEM passed directly, e.g. from an environment variable
+type inlineKeySource string
+
+func (k inlineKeySource) fetch(ctx context.Context) (string, error) {
+	// Single-line env values often carry escaped newlines
+	return strings.ReplaceAll(string(k), `\n`, "\n"), nil
+}
+
+func (k inlineKeySource) check(ctx context.Context) error {
+	return nil
+}
+
+func (k inlineKeySource) String() string {
+	return "inline PEM"
+}
@@ -9,10 +9,12 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -23,22 +25,61 @@ const (
 	// SSM parameter name for CloudFront private key
 	cloudFrontPrivateKeyParam = "/eyeseeyou/cloudfront-private-key"
 
-	// CloudFront public key ID
-	cloudFrontKeyPairID = "KB3JCDFGZQN4L"
+	// Default URL expiration duration (30 days - matches S3 lifecycle)
+	DefaultURLExpiration = 30 * 24 * time.Hour
 
-	// URL expiration duration (30 days - matches S3 lifecycle)
-	urlExpirationDuration = 30 * 24 * time.Hour
+	// Policy signed and verified at startup to prove the key is usable
+	selfTestPolicy = `{"Statement":[{"Resource":"https://example.com/self-test","Condition":{"DateLessThan":{"AWS:EpochTime":0}}}]}`
 )
 
+// CloudFrontSignerOptions holds CloudFront signer settings
+type CloudFrontSignerOptions struct {
+	// Expiration is how long URLs from SignURL stay valid
+	Expiration time.Duration
+
+	// KeyPairID is the ID of the CloudFront public key matching the private key.
+	// When KeyPairIDParameter names an SSM parameter, the ID is read from it
+	// instead, and re-read with the key on refresh so both rotate together.
+	KeyPairID          string
+	KeyPairIDParameter string
+
+	// PrivateKeyFile or PrivateKeyPEM supply the private key locally, e.g. for
+	// development and CI; when neither is set it is read from SSM
+	PrivateKeyFile string
+	PrivateKeyPEM  string
+}
+
+// DefaultCloudFrontSignerOptions returns the default CloudFront signer options
+func DefaultCloudFrontSignerOptions() CloudFrontSignerOptions {
+	return CloudFrontSignerOptions{
+		Expiration: DefaultURLExpiration,
+	}
+}
+
 // CloudFrontSigner handles signing CloudFront URLs
 type CloudFrontSigner struct {
-	privateKey  *rsa.PrivateKey
-	keyPairID   string
-	ssmClient   *ssm.Client
+	// mu guards privateKey and keyPairID, which are swapped on key refresh
+	mu         sync.RWMutex
+	privateKey *rsa.PrivateKey
+	keyPairID  string
+	ssmClient  *ssm.Client
+	keySource  privateKeySource
+	expiration time.Duration
+
+	// SSM parameter holding the key pair ID; empty when it is configured directly
+	keyPairIDParam string
 }
 
-// NewCloudFrontSigner creates a new CloudFront URL signer
-func NewCloudFrontSigner(ctx context.Context, awsRegion string) (*CloudFrontSigner, error) {
+// NewCloudFrontSigner creates a new CloudFront URL signer whose URLs expire
+// after opts.Expiration unless SignURLWithExpiry is used
+func NewCloudFrontSigner(ctx context.Context, awsRegion string, opts CloudFrontSignerOptions) (*CloudFrontSigner, error) {
+	if opts.Expiration <= 0 {
+		return nil, fmt.Errorf("URL expiration must be positive, got %v", opts.Expiration)
+	}
+	if opts.KeyPairID == "" && opts.KeyPairIDParameter == "" {
+		return nil, fmt.Errorf("a CloudFront key pair ID or the SSM parameter holding it is required")
+	}
+
 	// Load AWS SDK config
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(awsRegion),
@@ -48,47 +89,191 @@ func NewCloudFrontSigner(ctx context.Context, awsRegion string) (*CloudFrontSign
 	}
 
 	ssmClient := ssm.NewFromConfig(cfg)
+	keySource, err := newPrivateKeySource(opts, ssmClient)
+	if err != nil {
+		return nil, err
+	}
 
-	// Fetch private key from SSM
-	log.Printf("Fetching CloudFront private key from SSM parameter: %s", cloudFrontPrivateKeyParam)
-	paramName := cloudFrontPrivateKeyParam
-	result, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
-		Name:           &paramName,
-		WithDecryption: boolPtr(true),
-	})
+	signer := &CloudFrontSigner{
+		keyPairID:      opts.KeyPairID,
+		ssmClient:      ssmClient,
+		keySource:      keySource,
+		expiration:     opts.Expiration,
+		keyPairIDParam: opts.KeyPairIDParameter,
+	}
+
+	// Fetch private key, and key pair ID if it lives in SSM
+	privateKey, err := signer.fetchPrivateKey(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get private key from SSM: %w", err)
+		return nil, err
+	}
+	signer.privateKey = privateKey
+	if signer.keyPairIDParam != "" {
+		if signer.keyPairID, err = signer.fetchKeyPairID(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("CloudFront signer initialized", "key_pair_id", signer.keyPairID)
+
+	return signer, nil
+}
+
+// fetchPrivateKey fetches and parses the private key from its source
+func (s *CloudFrontSigner) fetchPrivateKey(ctx context.Context) (*rsa.PrivateKey, error) {
+	slog.Info("Fetching CloudFront private key", "source", s.keySource.String())
+	pemData, err := s.keySource.fetch(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse private key PEM
-	privateKey, err := parsePrivateKey(*result.Parameter.Value)
+	privateKey, err := parsePrivateKey(pemData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	log.Printf("CloudFront signer initialized with key pair ID: %s", cloudFrontKeyPairID)
+	// Catch truncated or mismatched keys before signing any real URLs
+	if err := verifySigningKey(privateKey); err != nil {
+		return nil, fmt.Errorf("private key failed self-test: %w", err)
+	}
 
-	return &CloudFrontSigner{
-		privateKey: privateKey,
-		keyPairID:  cloudFrontKeyPairID,
-		ssmClient:  ssmClient,
-	}, nil
+	return privateKey, nil
+}
+
+// fetchKeyPairID fetches the key pair ID from its SSM parameter
+func (s *CloudFrontSigner) fetchKeyPairID(ctx context.Context) (string, error) {
+	paramName := s.keyPairIDParam
+	value, err := getParameter(ctx, s.ssmClient, &ssm.GetParameterInput{Name: &paramName})
+	if err != nil {
+		return "", fmt.Errorf("failed to get key pair ID from SSM: %w", err)
+	}
+
+	keyPairID := strings.TrimSpace(value)
+	if keyPairID == "" {
+		return "", fmt.Errorf("SSM parameter %s holds an empty key pair ID", paramName)
+	}
+	return keyPairID, nil
+}
+
+// CheckSSM verifies the private key is still readable from its source: the SSM
+// parameter, or the local file when one is configured. An SSM value is not
+// decrypted; refreshes do that.
+func (s *CloudFrontSigner) CheckSSM(ctx context.Context) error {
+	return s.keySource.check(ctx)
+}
+
+// CheckSigning verifies the key in use can still produce valid CloudFront signatures
+func (s *CloudFrontSigner) CheckSigning(ctx context.Context) error {
+	s.mu.RLock()
+	privateKey := s.privateKey
+	s.mu.RUnlock()
+
+	if err := verifySigningKey(privateKey); err != nil {
+		return fmt.Errorf("signing key failed self-test: %w", err)
+	}
+	return nil
 }
 
-// SignURL creates a signed CloudFront URL that expires after urlExpirationDuration
+// StartKeyRefresh re-fetches the private key from SSM every interval until ctx
+// is cancelled, so a key rotated in SSM is picked up without a restart.
+// If a refresh fails, the last good key stays in use.
+func (s *CloudFrontSigner) StartKeyRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refreshKey(ctx)
+			}
+		}
+	}()
+}
+
+// refreshKey fetches the current key, and its ID when that is in SSM, and swaps
+// them in if either has changed
+func (s *CloudFrontSigner) refreshKey(ctx context.Context) {
+	privateKey, err := s.fetchPrivateKey(ctx)
+	if err != nil {
+		slog.Warn("CloudFront key refresh failed, keeping current key", "error", err)
+		return
+	}
+	keyPairID := s.currentKeyPairID()
+	if s.keyPairIDParam != "" {
+		// Never pair a new key with a stale ID, or vice versa
+		if keyPairID, err = s.fetchKeyPairID(ctx); err != nil {
+			slog.Warn("CloudFront key refresh failed, keeping current key", "error", err)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.privateKey.Equal(privateKey) && s.keyPairID == keyPairID {
+		return
+	}
+	s.privateKey = privateKey
+	s.keyPairID = keyPairID
+	slog.Info("CloudFront private key rotation detected, now signing with the new key", "key_pair_id", keyPairID)
+}
+
+// SignURL creates a signed CloudFront URL that expires after the signer's default expiration
 func (s *CloudFrontSigner) SignURL(rawURL string) (string, error) {
-	// Parse the URL
+	return s.SignURLWithExpiry(rawURL, s.expiration)
+}
+
+// PolicyOptions controls the policy a signed URL is issued with
+type PolicyOptions struct {
+	// Expires is when the URL stops being valid
+	Expires time.Time
+
+	// SourceIP optionally restricts the URL to clients in a CIDR range
+	// (e.g. "203.0.113.0/24"); setting it switches to a custom policy
+	SourceIP string
+}
+
+// SignURLWithExpiry creates a signed CloudFront URL that expires after d
+func (s *CloudFrontSigner) SignURLWithExpiry(rawURL string, d time.Duration) (string, error) {
+	return s.SignURLWithPolicy(rawURL, PolicyOptions{Expires: time.Now().Add(d)})
+}
+
+// SignURLWithPolicy creates a signed CloudFront URL using the given policy options.
+// Without a source IP restriction the URL carries Expires (canned policy); with one
+// it carries the full base64url-encoded custom Policy instead.
+//
+// The URL is canonicalized once (its path percent-encoded) and that exact string is
+// both signed as the policy's Resource and returned with the signing parameters
+// appended, so the URL CloudFront receives is the one that was signed.
+func (s *CloudFrontSigner) SignURLWithPolicy(rawURL string, opts PolicyOptions) (string, error) {
+	// Parse and canonicalize the URL
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse URL: %w", err)
 	}
+	// Fragments are never sent to CloudFront, and would end up ahead of the query
+	parsedURL.Fragment, parsedURL.RawFragment = "", ""
+	canonicalURL := parsedURL.String()
 
 	// Calculate expiration timestamp
-	expirationTime := time.Now().Add(urlExpirationDuration).Unix()
+	expirationTime := opts.Expires.Unix()
 
 	// Create the policy statement
-	policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d}}}]}`,
-		rawURL, expirationTime)
+	var policy string
+	if opts.SourceIP != "" {
+		if _, _, err := net.ParseCIDR(opts.SourceIP); err != nil {
+			return "", fmt.Errorf("invalid source IP CIDR %q: %w", opts.SourceIP, err)
+		}
+		policy = fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d},"IpAddress":{"AWS:SourceIp":"%s"}}}]}`,
+			canonicalURL, expirationTime, opts.SourceIP)
+	} else {
+		policy = fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d}}}]}`,
+			canonicalURL, expirationTime)
+	}
 
 	// Sign the policy
 	signature, err := s.signPolicy(policy)
@@ -96,34 +281,77 @@ func (s *CloudFrontSigner) SignURL(rawURL string) (string, error) {
 		return "", fmt.Errorf("failed to sign policy: %w", err)
 	}
 
-	// Build the signed URL
-	query := parsedURL.Query()
-	query.Set("Expires", strconv.FormatInt(expirationTime, 10))
-	query.Set("Signature", signature)
-	query.Set("Key-Pair-Id", s.keyPairID)
-	parsedURL.RawQuery = query.Encode()
+	// Build the signed URL by appending to the canonical URL. Re-encoding the whole
+	// query would reorder or re-escape any existing parameters, and then the URL
+	// requested would no longer match the signed Resource.
+	signing := url.Values{}
+	if opts.SourceIP != "" {
+		signing.Set("Policy", encodeURLSafeBase64([]byte(policy)))
+	} else {
+		signing.Set("Expires", strconv.FormatInt(expirationTime, 10))
+	}
+	signing.Set("Signature", signature)
+	signing.Set("Key-Pair-Id", s.currentKeyPairID())
 
-	return parsedURL.String(), nil
+	separator := "?"
+	if parsedURL.RawQuery != "" || parsedURL.ForceQuery {
+		separator = "&"
+	}
+	return canonicalURL + separator + signing.Encode(), nil
 }
 
-// signPolicy signs the CloudFront policy using RSA-SHA1
+// signPolicy signs the CloudFront policy using RSA-SHA1, the only hash CloudFront verifies
 func (s *CloudFrontSigner) signPolicy(policy string) (string, error) {
-	// Hash the policy
-	hash := sha1.Sum([]byte(policy))
+	s.mu.RLock()
+	privateKey := s.privateKey
+	s.mu.RUnlock()
 
-	// Sign the hash
-	signature, err := rsa.SignPKCS1v15(nil, s.privateKey, crypto.SHA1, hash[:])
+	signature, err := signPKCS1v15(privateKey, []byte(policy))
 	if err != nil {
 		return "", fmt.Errorf("failed to sign: %w", err)
 	}
 
-	// Base64 encode and make URL-safe
-	encoded := base64.StdEncoding.EncodeToString(signature)
+	return encodeURLSafeBase64(signature), nil
+}
+
+// currentKeyPairID returns the key pair ID matching the current private key
+func (s *CloudFrontSigner) currentKeyPairID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keyPairID
+}
+
+// encodeURLSafeBase64 base64-encodes data using CloudFront's URL-safe character substitutions
+func encodeURLSafeBase64(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
 	encoded = strings.ReplaceAll(encoded, "+", "-")
 	encoded = strings.ReplaceAll(encoded, "=", "_")
 	encoded = strings.ReplaceAll(encoded, "/", "~")
+	return encoded
+}
 
-	return encoded, nil
+// signPKCS1v15 signs the SHA1 digest of data with RSA PKCS#1 v1.5
+func signPKCS1v15(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	digest := sha1.Sum(data)
+	return rsa.SignPKCS1v15(nil, privateKey, crypto.SHA1, digest[:])
+}
+
+// verifySigningKey signs a dummy policy and verifies it with the key's public half
+func verifySigningKey(privateKey *rsa.PrivateKey) error {
+	if err := privateKey.Validate(); err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+
+	signature, err := signPKCS1v15(privateKey, []byte(selfTestPolicy))
+	if err != nil {
+		return fmt.Errorf("failed to sign test policy: %w", err)
+	}
+
+	digest := sha1.Sum([]byte(selfTestPolicy))
+	if err := rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA1, digest[:], signature); err != nil {
+		return fmt.Errorf("test signature did not verify: %w", err)
+	}
+	return nil
 }
 
 // parsePrivateKey parses a PEM-encoded RSA private key
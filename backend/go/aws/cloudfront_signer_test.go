@@ -0,0 +1,261 @@
+package aws
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKeyPairID = "K2JCJMDEHXQW5F"
+
+// newTestSigner creates a signer with a freshly generated key supplied inline,
+// so no SSM parameter is read
+func newTestSigner(t *testing.T) (*CloudFrontSigner, *rsa.PublicKey) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultCloudFrontSignerOptions()
+	opts.KeyPairID = testKeyPairID
+	opts.PrivateKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	signer, err := NewCloudFrontSigner(context.Background(), "us-east-1", opts)
+	if err != nil {
+		t.Fatalf("NewCloudFrontSigner: %v", err)
+	}
+	return signer, &key.PublicKey
+}
+
+// splitSignedURL separates a signed URL into the resource that was signed and
+// the signing parameters appended to it, which start at firstParam
+func splitSignedURL(t *testing.T, signedURL, firstParam string) (string, url.Values) {
+	t.Helper()
+	i := strings.LastIndex(signedURL, firstParam+"=")
+	if i < 1 || (signedURL[i-1] != '?' && signedURL[i-1] != '&') {
+		t.Fatalf("no %s parameter in %s", firstParam, signedURL)
+	}
+	params, err := url.ParseQuery(signedURL[i:])
+	if err != nil {
+		t.Fatalf("parse signing parameters of %s: %v", signedURL, err)
+	}
+	return signedURL[:i-1], params
+}
+
+// decodeURLSafeBase64 reverses CloudFront's base64 character substitutions
+func decodeURLSafeBase64(t *testing.T, s string) []byte {
+	t.Helper()
+	s = strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(s)
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return data
+}
+
+// verifySignature checks a CloudFront signature over policy the way CloudFront does
+func verifySignature(t *testing.T, publicKey *rsa.PublicKey, policy, signature string) {
+	t.Helper()
+	digest := sha1.Sum([]byte(policy))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA1, digest[:], decodeURLSafeBase64(t, signature)); err != nil {
+		t.Errorf("signature does not verify for policy %s: %v", policy, err)
+	}
+}
+
+var signerTests = []struct {
+	name     string
+	rawURL   string
+	resource string // the URL CloudFront will be asked for, and must be signed
+}{
+	{"plain", "https://cdn.example.com/videos/clip.mp4", "https://cdn.example.com/videos/clip.mp4"},
+	{"spaces", "https://cdn.example.com/videos/front door/clip 1.mp4", "https://cdn.example.com/videos/front%20door/clip%201.mp4"},
+	{"reserved characters", "https://cdn.example.com/videos/a+b=c&d.mp4", "https://cdn.example.com/videos/a+b=c&d.mp4"},
+	{"reserved characters and spaces", "https://cdn.example.com/videos/a+b c=d&e.mp4", "https://cdn.example.com/videos/a+b%20c=d&e.mp4"},
+	{"existing query", "https://cdn.example.com/videos/clip.mp4?camera=front%20door&tag=a+b", "https://cdn.example.com/videos/clip.mp4?camera=front%20door&tag=a+b"},
+	{"query with signing names", "https://cdn.example.com/videos/clip.mp4?Expires=soon", "https://cdn.example.com/videos/clip.mp4?Expires=soon"},
+	{"fragment", "https://cdn.example.com/videos/clip.mp4#t=10", "https://cdn.example.com/videos/clip.mp4"},
+	{"unicode", "https://cdn.example.com/videos/caméra/entrée 1.mp4", "https://cdn.example.com/videos/cam%C3%A9ra/entr%C3%A9e%201.mp4"},
+	{"unicode already encoded", "https://cdn.example.com/videos/cam%C3%A9ra.mp4", "https://cdn.example.com/videos/cam%C3%A9ra.mp4"},
+	{"emoji", "https://cdn.example.com/videos/🎥 clip.mp4", "https://cdn.example.com/videos/%F0%9F%8E%A5%20clip.mp4"},
+}
+
+func TestSignURLWithExpiry(t *testing.T) {
+	signer, publicKey := newTestSigner(t)
+
+	for _, tt := range signerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			signedURL, err := signer.SignURLWithExpiry(tt.rawURL, time.Hour)
+			if err != nil {
+				t.Fatalf("SignURLWithExpiry: %v", err)
+			}
+
+			resource, params := splitSignedURL(t, signedURL, "Expires")
+			if resource != tt.resource {
+				t.Errorf("signed resource = %s, want %s", resource, tt.resource)
+			}
+			if got := params.Get("Key-Pair-Id"); got != testKeyPairID {
+				t.Errorf("Key-Pair-Id = %q, want %q", got, testKeyPairID)
+			}
+			expires, err := strconv.ParseInt(params.Get("Expires"), 10, 64)
+			if err != nil {
+				t.Fatalf("Expires %q: %v", params.Get("Expires"), err)
+			}
+			if want := before.Add(time.Hour).Unix(); expires < want || expires > want+1 {
+				t.Errorf("Expires = %d, want about %d", expires, want)
+			}
+
+			policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d}}}]}`, resource, expires)
+			verifySignature(t, publicKey, policy, params.Get("Signature"))
+		})
+	}
+}
+
+func TestSignURLWithPolicy(t *testing.T) {
+	signer, publicKey := newTestSigner(t)
+	expires := time.Unix(1893456000, 0)
+
+	for _, tt := range signerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedURL, err := signer.SignURLWithPolicy(tt.rawURL, PolicyOptions{Expires: expires, SourceIP: "203.0.113.0/24"})
+			if err != nil {
+				t.Fatalf("SignURLWithPolicy: %v", err)
+			}
+
+			resource, params := splitSignedURL(t, signedURL, "Key-Pair-Id")
+			if resource != tt.resource {
+				t.Errorf("signed resource = %s, want %s", resource, tt.resource)
+			}
+			if params.Has("Expires") {
+				t.Errorf("custom policy URL carries Expires: %s", signedURL)
+			}
+
+			policy := string(decodeURLSafeBase64(t, params.Get("Policy")))
+			want := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d},"IpAddress":{"AWS:SourceIp":"203.0.113.0/24"}}}]}`,
+				tt.resource, expires.Unix())
+			if policy != want {
+				t.Errorf("policy = %s\nwant %s", policy, want)
+			}
+			verifySignature(t, publicKey, policy, params.Get("Signature"))
+		})
+	}
+}
+
+func TestSignURLWithPolicyCannedWithoutSourceIP(t *testing.T) {
+	signer, publicKey := newTestSigner(t)
+	expires := time.Unix(1893456000, 0)
+
+	signedURL, err := signer.SignURLWithPolicy("https://cdn.example.com/videos/a b.mp4", PolicyOptions{Expires: expires})
+	if err != nil {
+		t.Fatalf("SignURLWithPolicy: %v", err)
+	}
+	resource, params := splitSignedURL(t, signedURL, "Expires")
+	if params.Has("Policy") {
+		t.Errorf("canned policy URL carries Policy: %s", signedURL)
+	}
+	if got := params.Get("Expires"); got != "1893456000" {
+		t.Errorf("Expires = %s, want 1893456000", got)
+	}
+	policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":1893456000}}}]}`, resource)
+	verifySignature(t, publicKey, policy, params.Get("Signature"))
+}
+
+func TestSignURLWithPolicyRejectsInvalidSourceIP(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	if _, err := signer.SignURLWithPolicy("https://cdn.example.com/clip.mp4", PolicyOptions{Expires: time.Now(), SourceIP: "203.0.113.7"}); err == nil {
+		t.Error("SignURLWithPolicy accepted a source IP without a prefix length")
+	}
+}
+
+// edgeVerifier checks requests the way CloudFront does for canned policies:
+// the URL as received, less the signing parameters, must be the signed Resource
+type edgeVerifier struct {
+	publicKey *rsa.PublicKey
+}
+
+func (e edgeVerifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	received := "https://" + r.Host + r.RequestURI
+	i := strings.LastIndex(received, "Expires=")
+	if i < 1 {
+		http.Error(w, "missing Expires", http.StatusForbidden)
+		return
+	}
+	params, err := url.ParseQuery(received[i:])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%s}}}]}`,
+		received[:i-1], params.Get("Expires"))
+	signature, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(params.Get("Signature")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	digest := sha1.Sum([]byte(policy))
+	if err := rsa.VerifyPKCS1v15(e.publicKey, crypto.SHA1, digest[:], signature); err != nil {
+		http.Error(w, "signature does not match "+received[:i-1], http.StatusForbidden)
+		return
+	}
+	// The object requested, as the origin would look it up
+	w.Write([]byte(r.URL.Path))
+}
+
+func TestSignedURLValidatesAtTheEdge(t *testing.T) {
+	signer, publicKey := newTestSigner(t)
+	edge := httptest.NewTLSServer(edgeVerifier{publicKey})
+	t.Cleanup(edge.Close)
+	domain := strings.TrimPrefix(edge.URL, "https://")
+
+	keys := []string{
+		"videos/clip.mp4",
+		"videos/front door/clip 1.mp4",
+		"videos/caméra/entrée.mp4",
+		"videos/日本語 ファイル.mp4",
+		"videos/🎥/clip.mp4",
+		"videos/a+b=c&d.mp4",
+		"videos/100%.mp4",
+		"videos/what?.mp4",
+		"videos/take#2.mp4",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			signedURL, err := signer.SignURLWithExpiry(cloudFrontObjectURL(domain, key), time.Hour)
+			if err != nil {
+				t.Fatalf("SignURLWithExpiry: %v", err)
+			}
+
+			resp, err := edge.Client().Get(signedURL)
+			if err != nil {
+				t.Fatalf("GET %s: %v", signedURL, err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s = %s: %s", signedURL, resp.Status, body)
+			}
+			if string(body) != "/"+key {
+				t.Errorf("edge looked up %q, want %q", body, "/"+key)
+			}
+		})
+	}
+}
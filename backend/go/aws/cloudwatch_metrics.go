@@ -0,0 +1,232 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+const (
+	// CloudWatch Query API version PutMetricData is called with
+	cloudWatchAPIVersion = "2010-08-01"
+
+	// Time allowed for one PutMetricData request
+	cloudWatchRequestTimeout = 10 * time.Second
+)
+
+// cloudWatchMetricNames maps the CloudWatch metrics published to the application
+// counters they are taken from
+var cloudWatchMetricNames = map[string]string{
+	"VideosDetected":  metrics.VideosDetected,
+	"UploadFailures":  metrics.UploadsFailed,
+	"PublishFailures": metrics.PublishFailed,
+}
+
+// CloudWatchMetricsOptions configures the CloudWatch metrics publisher
+type CloudWatchMetricsOptions struct {
+	// Namespace the metrics are published under
+	Namespace string
+
+	// FlushInterval is how often the counts accumulated since the last flush are sent
+	FlushInterval time.Duration
+}
+
+// DefaultCloudWatchMetricsOptions returns the default CloudWatch metrics options
+func DefaultCloudWatchMetricsOptions() CloudWatchMetricsOptions {
+	return CloudWatchMetricsOptions{
+		Namespace:     "EyeSeeYou",
+		FlushInterval: time.Minute,
+	}
+}
+
+// metricDatum is one value sent to CloudWatch
+type metricDatum struct {
+	Name      string
+	Value     float64
+	Timestamp time.Time
+}
+
+// CloudWatchMetrics publishes detection, upload failure and publish failure counts
+// to CloudWatch, so they can be graphed and alarmed on without scraping /metrics.
+// Each flush sends how much each counter grew since the previous successful flush;
+// a failed flush is made up for by the next one.
+type CloudWatchMetrics struct {
+	client cloudWatchAPI
+	opts   CloudWatchMetricsOptions
+
+	// Serialises flushes; last holds the counter values last sent
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+// NewCloudWatchMetrics creates a CloudWatch metrics publisher
+func NewCloudWatchMetrics(ctx context.Context, awsRegion string, opts CloudWatchMetricsOptions) (*CloudWatchMetrics, error) {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(awsRegion),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+
+	return newCloudWatchMetrics(newCloudWatchQueryClient(cfg), opts), nil
+}
+
+// newCloudWatchMetrics creates a CloudWatch metrics publisher on the given client
+func newCloudWatchMetrics(client cloudWatchAPI, opts CloudWatchMetricsOptions) *CloudWatchMetrics {
+	defaults := DefaultCloudWatchMetricsOptions()
+	if opts.Namespace == "" {
+		opts.Namespace = defaults.Namespace
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaults.FlushInterval
+	}
+
+	// Counts from before the publisher started are not sent
+	last := make(map[string]int64, len(cloudWatchMetricNames))
+	for _, counter := range cloudWatchMetricNames {
+		last[counter] = metrics.Default.Counter(counter).Value()
+	}
+
+	return &CloudWatchMetrics{
+		client: client,
+		opts:   opts,
+		last:   last,
+	}
+}
+
+// Run flushes the metrics every FlushInterval until ctx is cancelled.
+// Call it in a goroutine, and Flush once more at shutdown.
+func (m *CloudWatchMetrics) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Flush(ctx); err != nil {
+				slog.Warn("Failed to publish CloudWatch metrics, will include them in the next flush", "error", err)
+			}
+		}
+	}
+}
+
+// Flush sends each counter's growth since the last successful flush
+func (m *CloudWatchMetrics) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	current := make(map[string]int64, len(cloudWatchMetricNames))
+	data := make([]metricDatum, 0, len(cloudWatchMetricNames))
+	for name, counter := range cloudWatchMetricNames {
+		value := metrics.Default.Counter(counter).Value()
+		current[counter] = value
+
+		delta := value - m.last[counter]
+		if delta < 0 {
+			// The counters were reset (POST /metrics/reset) since the last flush
+			delta = value
+		}
+		// Zeros are sent too, so graphs and alarms see a continuous series
+		data = append(data, metricDatum{Name: name, Value: float64(delta), Timestamp: now})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cloudWatchRequestTimeout)
+	defer cancel()
+	if err := m.client.PutMetricData(ctx, m.opts.Namespace, data); err != nil {
+		return err
+	}
+
+	m.last = current
+	slog.Debug("Published CloudWatch metrics", "namespace", m.opts.Namespace, "metrics", len(data))
+	return nil
+}
+
+// cloudWatchQueryClient calls PutMetricData through CloudWatch's Query API,
+// signing requests with the SDK's SigV4 signer and credential chain
+type cloudWatchQueryClient struct {
+	httpClient  *http.Client
+	signer      *v4.Signer
+	credentials aws.CredentialsProvider
+	region      string
+	endpoint    string
+}
+
+// newCloudWatchQueryClient creates a CloudWatch client for the config's region,
+// credentials and custom endpoint (AWS_ENDPOINT_URL), if any
+func newCloudWatchQueryClient(cfg aws.Config) *cloudWatchQueryClient {
+	host := "monitoring." + cfg.Region + ".amazonaws.com"
+	if strings.HasPrefix(cfg.Region, "cn-") {
+		host += ".cn"
+	}
+	endpoint := "https://" + host + "/"
+	if cfg.BaseEndpoint != nil {
+		endpoint = strings.TrimSuffix(*cfg.BaseEndpoint, "/") + "/"
+	}
+	return &cloudWatchQueryClient{
+		httpClient:  &http.Client{},
+		signer:      v4.NewSigner(),
+		credentials: cfg.Credentials,
+		region:      cfg.Region,
+		endpoint:    endpoint,
+	}
+}
+
+// PutMetricData sends metric values as Count data points
+func (c *cloudWatchQueryClient) PutMetricData(ctx context.Context, namespace string, data []metricDatum) error {
+	form := url.Values{}
+	form.Set("Action", "PutMetricData")
+	form.Set("Version", cloudWatchAPIVersion)
+	form.Set("Namespace", namespace)
+	for i, datum := range data {
+		prefix := fmt.Sprintf("MetricData.member.%d.", i+1)
+		form.Set(prefix+"MetricName", datum.Name)
+		form.Set(prefix+"Value", strconv.FormatFloat(datum.Value, 'f', -1, 64))
+		form.Set(prefix+"Unit", "Count")
+		form.Set(prefix+"Timestamp", datum.Timestamp.Format(time.RFC3339))
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build PutMetricData request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	credentials, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	payloadHash := sha256.Sum256([]byte(body))
+	if err := c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]), "monitoring", c.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign PutMetricData request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("PutMetricData request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("PutMetricData returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultVideoContentType is used when a video's type can't be determined
+	DefaultVideoContentType = "video/mp4"
+
+	// Bytes http.DetectContentType considers
+	contentSniffSize = 512
+)
+
+// videoContentTypes maps video extensions to the content type they're served with,
+// so CloudFront hands browsers a type they can play
+var videoContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".avi":  "video/x-msvideo",
+	".ts":   "video/mp2t",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+}
+
+// contentType picks a video's content type: a CONTENT_TYPES override for its
+// extension, then the built-in map, then sniffing its first bytes, falling
+// back to DefaultVideoContentType
+func (u *S3Uploader) contentType(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if contentType, ok := u.opts.ContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType, ok := videoContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := sniffContentType(filePath); strings.HasPrefix(contentType, "video/") {
+		return contentType
+	}
+	return DefaultVideoContentType
+}
+
+// sniffContentType detects a file's content type from its first bytes; empty if
+// the file can't be read
+func sniffContentType(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	header := make([]byte, contentSniffSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ""
+	}
+	return http.DetectContentType(header[:n])
+}
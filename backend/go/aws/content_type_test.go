@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+)
+
+// webmHeader is the EBML magic number http.DetectContentType reports as video/webm
+var webmHeader = []byte{0x1A, 0x45, 0xDF, 0xA3, 0x01, 0x00, 0x00, 0x00}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      []byte
+		overrides map[string]string
+		want      string
+	}{
+		{"clip.mp4", nil, nil, "video/mp4"},
+		{"clip.m4v", nil, nil, "video/mp4"},
+		{"clip.mov", nil, nil, "video/quicktime"},
+		{"clip.mkv", nil, nil, "video/x-matroska"},
+		{"clip.webm", nil, nil, "video/webm"},
+		{"clip.avi", nil, nil, "video/x-msvideo"},
+		{"clip.ts", nil, nil, "video/mp2t"},
+		{"clip.mpg", nil, nil, "video/mpeg"},
+		{"clip.mpeg", nil, nil, "video/mpeg"},
+		{"CLIP.MKV", nil, nil, "video/x-matroska"},
+		{"clip.mkv", nil, map[string]string{".mkv": "video/webm"}, "video/webm"},
+		{"clip.h264", nil, map[string]string{".h264": "video/h264"}, "video/h264"},
+		{"clip.dat", webmHeader, nil, "video/webm"},
+		{"clip.dat", []byte("not a video"), nil, DefaultVideoContentType},
+		{"clip", nil, nil, DefaultVideoContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, tt.body, 0644); err != nil {
+				t.Fatal(err)
+			}
+			uploader := &S3Uploader{opts: S3UploaderOptions{ContentTypes: tt.overrides}}
+			if got := uploader.contentType(path); got != tt.want {
+				t.Errorf("contentType(%s) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentTypeMissingFile(t *testing.T) {
+	uploader := &S3Uploader{}
+	if got := uploader.contentType(filepath.Join(t.TempDir(), "gone.dat")); got != DefaultVideoContentType {
+		t.Errorf("contentType of a missing file = %q, want %q", got, DefaultVideoContentType)
+	}
+}
+
+func TestUploadContentType(t *testing.T) {
+	for ext, want := range videoContentTypes {
+		t.Run(ext, func(t *testing.T) {
+			server := fakes3.New(t)
+			uploader := newTestUploader(t, server, DefaultS3UploaderOptions())
+
+			result, err := uploader.Upload(context.Background(), writeTestFile(t, "clip"+ext, 1024), nil)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			object := server.Object(testBucket, result.Key)
+			if object == nil {
+				t.Fatalf("no object stored at %s", result.Key)
+			}
+			if got := object.Header.Get("Content-Type"); got != want {
+				t.Errorf("uploaded with Content-Type %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DedupEntry records where a clip with a given content hash was uploaded
+type DedupEntry struct {
+	SHA256     string    `json:"sha256"`
+	Key        string    `json:"key"`
+	VersionID  string    `json:"version_id,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// DedupIndex remembers the content hashes of recent uploads in a local JSON file,
+// so a clip re-emitted under a new name (cameras do this after reconnecting) reuses
+// the first upload's key instead of being stored and alerted on twice. Entries
+// older than the window are dropped, and past maxEntries the oldest go first.
+// The methods are safe on a nil index, which never finds anything.
+type DedupIndex struct {
+	path       string
+	window     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]DedupEntry
+}
+
+// LoadDedupIndex opens the index at path, starting empty if the file doesn't
+// exist yet or can't be parsed
+func LoadDedupIndex(path string, window time.Duration, maxEntries int) (*DedupIndex, error) {
+	x := &DedupIndex{
+		path:       path,
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[string]DedupEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dedup index %s: %w", path, err)
+	}
+
+	var entries []DedupEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// Losing the index only costs a possible duplicate upload
+		slog.Warn("Dedup index is corrupt, starting empty", "path", path, "error", err)
+		return x, nil
+	}
+	for _, entry := range entries {
+		x.entries[entry.SHA256] = entry
+	}
+	x.prune(time.Now())
+	slog.Info("Loaded dedup index", "path", path, "entries", len(x.entries))
+	return x, nil
+}
+
+// Lookup returns the upload of a clip with the hex SHA-256 hash, if it was
+// uploaded within the window
+func (x *DedupIndex) Lookup(sha256 string) (DedupEntry, bool) {
+	if x == nil {
+		return DedupEntry{}, false
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	entry, ok := x.entries[sha256]
+	if !ok || time.Since(entry.UploadedAt) > x.window {
+		return DedupEntry{}, false
+	}
+	return entry, true
+}
+
+// Record adds an upload to the index and saves it
+func (x *DedupIndex) Record(sha256 string, upload UploadResult) error {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := time.Now()
+	x.entries[sha256] = DedupEntry{
+		SHA256:     sha256,
+		Key:        upload.Key,
+		VersionID:  upload.VersionID,
+		UploadedAt: now.UTC(),
+	}
+	x.prune(now)
+	return x.save()
+}
+
+// prune drops entries outside the window, then the oldest until at most
+// maxEntries remain. Callers hold x.mu.
+func (x *DedupIndex) prune(now time.Time) {
+	for hash, entry := range x.entries {
+		if now.Sub(entry.UploadedAt) > x.window {
+			delete(x.entries, hash)
+		}
+	}
+	if x.maxEntries <= 0 || len(x.entries) <= x.maxEntries {
+		return
+	}
+	for _, entry := range x.sorted()[:len(x.entries)-x.maxEntries] {
+		delete(x.entries, entry.SHA256)
+	}
+}
+
+// sorted returns the entries oldest first. Callers hold x.mu.
+func (x *DedupIndex) sorted() []DedupEntry {
+	entries := make([]DedupEntry, 0, len(x.entries))
+	for _, entry := range x.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UploadedAt.Before(entries[j].UploadedAt) })
+	return entries
+}
+
+// save writes the index to a temporary file and renames it into place, so a
+// crash mid-write leaves the previous index intact. Callers hold x.mu.
+func (x *DedupIndex) save() error {
+	data, err := json.Marshal(x.sorted())
+	if err != nil {
+		return fmt.Errorf("failed to marshal dedup index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0755); err != nil {
+		return fmt.Errorf("failed to create dedup index directory: %w", err)
+	}
+
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dedup index: %w", err)
+	}
+	if err := os.Rename(tmp, x.path); err != nil {
+		return fmt.Errorf("failed to replace dedup index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// DynamoDB operation timeout, per attempt
+const dynamoDBWriteTimeout = 10 * time.Second
+
+// DynamoDBRecorderOptions bounds the recorder's writes so a burst of detections
+// stays within the table's write capacity
+type DynamoDBRecorderOptions struct {
+	// MaxConcurrentWrites bounds writes in flight; 0 leaves them unbounded
+	MaxConcurrentWrites int
+	// WritesPerSecond paces the start of writes; 0 leaves them unpaced
+	WritesPerSecond int
+}
+
+// DefaultDynamoDBRecorderOptions returns the default recorder options, within
+// the capacity of a table provisioned with 5 write units
+func DefaultDynamoDBRecorderOptions() DynamoDBRecorderOptions {
+	return DynamoDBRecorderOptions{
+		MaxConcurrentWrites: 4,
+		WritesPerSecond:     5,
+	}
+}
+
+// DynamoDBRecord is the item written for an uploaded video
+type DynamoDBRecord struct {
+	Key        string
+	Filename   string
+	SHA256     string
+	Size       int64
+	VersionID  string
+	CameraID   string
+	UploadedAt string
+}
+
+// DynamoDBRecorder records each uploaded video as an item in a DynamoDB table,
+// keyed by its S3 key. Writes go through a limiter, and throttling
+// (ProvisionedThroughputExceededException) is retried with backoff.
+type DynamoDBRecorder struct {
+	client  dynamoDBAPI
+	table   string
+	limiter *utils.Limiter
+	retry   utils.RetryConfig
+}
+
+// NewDynamoDBRecorder creates a recorder writing to table
+func NewDynamoDBRecorder(ctx context.Context, awsRegion, table string, opts DynamoDBRecorderOptions) (*DynamoDBRecorder, error) {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(awsRegion),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+
+	return newDynamoDBRecorder(dynamodb.NewFromConfig(cfg), table, opts), nil
+}
+
+// newDynamoDBRecorder creates a recorder on the given client
+func newDynamoDBRecorder(client dynamoDBAPI, table string, opts DynamoDBRecorderOptions) *DynamoDBRecorder {
+	retryConfig := utils.DefaultRetryConfig("DynamoDB put " + table)
+	retryConfig.IsRetryable = IsRetryableError
+	return &DynamoDBRecorder{
+		client:  client,
+		table:   table,
+		limiter: utils.NewLimiter(opts.MaxConcurrentWrites, opts.WritesPerSecond),
+		retry:   retryConfig,
+	}
+}
+
+// Record writes an uploaded video's item, waiting for the limiter first.
+// Writing the same key again replaces its item.
+func (r *DynamoDBRecorder) Record(ctx context.Context, record DynamoDBRecord) error {
+	if record.UploadedAt == "" {
+		record.UploadedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	if err := r.limiter.Acquire(ctx); err != nil {
+		return fmt.Errorf("waiting to record %s: %w", record.Key, err)
+	}
+	defer r.limiter.Release()
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.table),
+		Item:      record.item(),
+	}
+	err := utils.RetryWithBackoff(ctx, r.retry, func() error {
+		writeCtx, cancel := context.WithTimeout(ctx, dynamoDBWriteTimeout)
+		defer cancel()
+		_, err := r.client.PutItem(writeCtx, input)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record %s in %s: %w", record.Key, r.table, err)
+	}
+
+	slog.Debug("Recorded upload in DynamoDB", "table", r.table, "s3_key", record.Key)
+	return nil
+}
+
+// item converts a record to DynamoDB attributes, omitting empty optional ones
+func (record DynamoDBRecord) item() map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"s3_key":      &types.AttributeValueMemberS{Value: record.Key},
+		"size":        &types.AttributeValueMemberN{Value: strconv.FormatInt(record.Size, 10)},
+		"uploaded_at": &types.AttributeValueMemberS{Value: record.UploadedAt},
+	}
+	for name, value := range map[string]string{
+		"filename":   record.Filename,
+		"sha256":     record.SHA256,
+		"version_id": record.VersionID,
+		"camera_id":  record.CameraID,
+	} {
+		if value != "" {
+			item[name] = &types.AttributeValueMemberS{Value: value}
+		}
+	}
+	return item
+}
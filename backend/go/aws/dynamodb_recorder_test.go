@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeDynamoDB stores put items, failing the first failures calls with err
+type fakeDynamoDB struct {
+	err      error
+	failures int32
+	delay    time.Duration
+
+	calls   atomic.Int32
+	running atomic.Int32
+	peak    atomic.Int32
+
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+	times []time.Time
+}
+
+func (f *fakeDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		p := f.peak.Load()
+		if n <= p || f.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+
+	f.mu.Lock()
+	f.times = append(f.times, time.Now())
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+
+	if f.calls.Add(1) <= f.failures {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.items == nil {
+		f.items = make(map[string]map[string]types.AttributeValue)
+	}
+	key := params.Item["s3_key"].(*types.AttributeValueMemberS).Value
+	f.items[aws.ToString(params.TableName)+"/"+key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// newTestRecorder creates a recorder on a fake client with quick retries
+func newTestRecorder(client *fakeDynamoDB, opts DynamoDBRecorderOptions) *DynamoDBRecorder {
+	recorder := newDynamoDBRecorder(client, "uploads", opts)
+	recorder.retry.InitialDelay = time.Millisecond
+	recorder.retry.MaxDelay = 5 * time.Millisecond
+	recorder.retry.MaxElapsed = 0
+	return recorder
+}
+
+func TestDynamoDBRecorderRetries(t *testing.T) {
+	throttled := &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")}
+	invalid := &smithy.GenericAPIError{Code: "ValidationException", Message: "bad item", Fault: smithy.FaultClient}
+
+	tests := []struct {
+		name      string
+		err       error
+		failures  int32
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"first write succeeds", nil, 0, 1, false},
+		{"throttled then succeeds", throttled, 2, 3, false},
+		{"throttled past every retry", throttled, 100, 5, true},
+		{"not retryable", invalid, 100, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeDynamoDB{err: tt.err, failures: tt.failures}
+			recorder := newTestRecorder(client, DynamoDBRecorderOptions{})
+
+			err := recorder.Record(context.Background(), DynamoDBRecord{Key: "videos/clip.mp4", Size: 1024, SHA256: "abc"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Record = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("Record = %v, want it to wrap %v", err, tt.err)
+			}
+			if got := client.calls.Load(); got != tt.wantCalls {
+				t.Errorf("PutItem calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDynamoDBRecorderItem(t *testing.T) {
+	client := &fakeDynamoDB{}
+	recorder := newTestRecorder(client, DynamoDBRecorderOptions{})
+	record := DynamoDBRecord{Key: "videos/front/clip.mp4", Filename: "clip.mp4", SHA256: "abc", Size: 2048, CameraID: "front"}
+	if err := recorder.Record(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	item := client.items["uploads/videos/front/clip.mp4"]
+	want := map[string]string{"s3_key": "videos/front/clip.mp4", "filename": "clip.mp4", "sha256": "abc", "size": "2048", "camera_id": "front"}
+	for name, value := range want {
+		var got string
+		switch attribute := item[name].(type) {
+		case *types.AttributeValueMemberS:
+			got = attribute.Value
+		case *types.AttributeValueMemberN:
+			got = attribute.Value
+		}
+		if got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if _, ok := item["version_id"]; ok {
+		t.Error("empty version_id was written")
+	}
+	if _, ok := item["uploaded_at"]; !ok {
+		t.Error("uploaded_at missing")
+	}
+}
+
+func TestDynamoDBRecorderBoundsWrites(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     DynamoDBRecorderOptions
+		wantPeak int32
+		minGap   time.Duration
+	}{
+		{"concurrency", DynamoDBRecorderOptions{MaxConcurrentWrites: 2}, 2, 0},
+		{"rate", DynamoDBRecorderOptions{WritesPerSecond: 50}, 1, 15 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeDynamoDB{delay: 5 * time.Millisecond}
+			if tt.minGap == 0 {
+				client.delay = 20 * time.Millisecond
+			}
+			recorder := newTestRecorder(client, tt.opts)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 6; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if err := recorder.Record(context.Background(), DynamoDBRecord{Key: fmt.Sprintf("videos/clip-%d.mp4", i)}); err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if got := client.peak.Load(); got > tt.wantPeak {
+				t.Errorf("peak concurrent writes = %d, want at most %d", got, tt.wantPeak)
+			}
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			if len(client.items) != 6 {
+				t.Errorf("items written = %d, want 6", len(client.items))
+			}
+			var first, last time.Time
+			for i, at := range client.times {
+				if i == 0 || at.Before(first) {
+					first = at
+				}
+				if at.After(last) {
+					last = at
+				}
+			}
+			if want := 5 * tt.minGap; last.Sub(first) < want {
+				t.Errorf("6 writes started within %s, want them paced over at least %s", last.Sub(first), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+// retryableErrorCodes are client-side (4xx) AWS error codes that are still worth retrying
+var retryableErrorCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottledException":              true,
+	"RequestThrottled":                       true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"BandwidthLimitExceeded":                 true,
+	"SlowDown":                               true,
+	"PriorRequestNotComplete":                true,
+	"RequestTimeout":                         true,
+	"RequestTimeoutException":                true,
+	"TransactionInProgressException":         true,
+}
+
+// Error codes configured with SetErrorCodeOverrides
+var (
+	extraRetryableCodes = map[string]bool{}
+	nonRetryableCodes   = map[string]bool{}
+)
+
+// SetErrorCodeOverrides adjusts retry classification by AWS error code: retryable
+// codes are retried in addition to the built-in ones, and nonRetryable codes are
+// never retried, even when they are also listed as retryable. It must be called
+// before any AWS operations start.
+func SetErrorCodeOverrides(retryable, nonRetryable []string) {
+	extraRetryableCodes = make(map[string]bool, len(retryable))
+	for _, code := range retryable {
+		extraRetryableCodes[code] = true
+	}
+	nonRetryableCodes = make(map[string]bool, len(nonRetryable))
+	for _, code := range nonRetryable {
+		nonRetryableCodes[code] = true
+	}
+}
+
+// IsRetryableError reports whether an error from an AWS operation is worth retrying.
+// Network errors, 5xx responses, throttling and timeouts are retryable; other
+// 4xx client errors (e.g. AccessDenied, NoSuchBucket) are permanent. Error codes
+// set with SetErrorCodeOverrides take precedence, non-retryable ones first.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// A missing local file won't appear by retrying
+	if errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		if nonRetryableCodes[code] {
+			return false
+		}
+		if retryableErrorCodes[code] || extraRetryableCodes[code] {
+			return true
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		status := respErr.HTTPStatusCode()
+		if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+			return true
+		}
+		if status >= 400 && status < 500 {
+			return false
+		}
+		return true
+	}
+
+	if apiErr != nil && apiErr.ErrorFault() == smithy.FaultClient {
+		return false
+	}
+
+	// Network failures and anything unrecognised are treated as transient
+	return true
+}
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"testing"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// apiError builds an error shaped like the SDK's: an operation error wrapping
+// an HTTP response error that wraps the service's error code
+func apiError(code string, status int) error {
+	fault := smithy.FaultClient
+	if status >= 500 {
+		fault = smithy.FaultServer
+	}
+	return &smithy.OperationError{
+		ServiceID:     "S3",
+		OperationName: "PutObject",
+		Err: &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+				Err:      &smithy.GenericAPIError{Code: code, Fault: fault},
+			},
+		},
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		retryable    []string
+		nonRetryable []string
+		want         bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "missing file", err: fmt.Errorf("open clip.mp4: %w", fs.ErrNotExist), want: false},
+		{name: "network", err: errors.New("connection reset by peer"), want: true},
+
+		{name: "built-in throttling code", err: apiError("SlowDown", http.StatusServiceUnavailable), want: true},
+		{name: "built-in code on a 4xx", err: apiError("ThrottlingException", http.StatusBadRequest), want: true},
+		{name: "access denied", err: apiError("AccessDenied", http.StatusForbidden), want: false},
+		{name: "request timeout status", err: apiError("Unknown", http.StatusRequestTimeout), want: true},
+		{name: "too many requests status", err: apiError("Unknown", http.StatusTooManyRequests), want: true},
+		{name: "server error", err: apiError("InternalError", http.StatusInternalServerError), want: true},
+		{name: "client fault without a response", err: &smithy.GenericAPIError{Code: "ValidationError", Fault: smithy.FaultClient}, want: false},
+
+		{name: "configured retryable", err: apiError("KMS.ThrottlingException", http.StatusBadRequest),
+			retryable: []string{"KMS.ThrottlingException"}, want: true},
+		{name: "configured codes match exactly", err: apiError("kms.throttlingexception", http.StatusBadRequest),
+			retryable: []string{"KMS.ThrottlingException"}, want: false},
+		{name: "configured non-retryable server error", err: apiError("InternalError", http.StatusInternalServerError),
+			nonRetryable: []string{"InternalError"}, want: false},
+		{name: "configured non-retryable built-in code", err: apiError("SlowDown", http.StatusServiceUnavailable),
+			nonRetryable: []string{"SlowDown"}, want: false},
+		{name: "non-retryable wins over retryable", err: apiError("AccessDenied", http.StatusForbidden),
+			retryable: []string{"AccessDenied"}, nonRetryable: []string{"AccessDenied"}, want: false},
+		{name: "other codes unaffected", err: apiError("SlowDown", http.StatusServiceUnavailable),
+			retryable: []string{"AccessDenied"}, nonRetryable: []string{"InternalError"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetErrorCodeOverrides(tt.retryable, tt.nonRetryable)
+			t.Cleanup(func() { SetErrorCodeOverrides(nil, nil) })
+
+			if got := IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
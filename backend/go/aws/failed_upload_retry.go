@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RetriedUploadHandler is called after a failed-upload file is uploaded, typically
+// to publish its notification. The local copy is only removed if it returns nil.
+type RetriedUploadHandler func(ctx context.Context, filePath string, upload UploadResult) error
+
+// RetryFailedUploads re-attempts uploads of the files in the failed upload
+// directory every interval until ctx is cancelled. Call it in a goroutine.
+func (u *S3Uploader) RetryFailedUploads(ctx context.Context, interval time.Duration, onUploaded RetriedUploadHandler) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.retryFailedUploadsOnce(ctx, onUploaded)
+		}
+	}
+}
+
+// retryFailedUploadsOnce makes one pass over the failed upload directory
+func (u *S3Uploader) retryFailedUploadsOnce(ctx context.Context, onUploaded RetriedUploadHandler) {
+	entries, err := os.ReadDir(u.opts.FailedUploadDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read failed upload directory", "error", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		u.retryFailedUpload(ctx, filepath.Join(u.opts.FailedUploadDir, entry.Name()), onUploaded)
+	}
+}
+
+// retryFailedUpload re-uploads one file, skipping it if a retry is already in flight
+func (u *S3Uploader) retryFailedUpload(ctx context.Context, filePath string, onUploaded RetriedUploadHandler) {
+	u.retryMu.Lock()
+	if u.retrying[filePath] {
+		u.retryMu.Unlock()
+		return
+	}
+	u.retrying[filePath] = true
+	u.retryMu.Unlock()
+
+	defer func() {
+		u.retryMu.Lock()
+		delete(u.retrying, filePath)
+		u.retryMu.Unlock()
+	}()
+
+	slog.Info("Retrying failed upload", "path", filePath)
+	upload, err := u.Upload(ctx, filePath, nil)
+	if err != nil {
+		slog.Error("Retry of failed upload failed, will try again later", "path", filePath, "error", err)
+		return
+	}
+
+	if onUploaded != nil {
+		if err := onUploaded(ctx, filePath, upload); err != nil {
+			slog.Error("Retried upload succeeded but follow-up failed, keeping local copy", "path", filePath, "s3_key", upload.Key, "error", err)
+			return
+		}
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		slog.Error("Failed to delete retried file", "path", filePath, "error", err)
+		return
+	}
+	slog.Info("Recovered failed upload", "path", filePath, "s3_key", upload.Key)
+}
+
+// EvictFailedUpload deletes a file from the failed upload directory to free disk
+// space, unless a retry of it is in flight. It reports whether the file is gone.
+func (u *S3Uploader) EvictFailedUpload(filePath string) bool {
+	u.retryMu.Lock()
+	defer u.retryMu.Unlock()
+	if u.retrying[filePath] {
+		return false
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Failed to evict failed upload", "path", filePath, "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// FilenameIndexEntry records one upload of a file under its original name
+type FilenameIndexEntry struct {
+	Key        string `json:"key"`
+	VersionID  string `json:"version_id,omitempty"`
+	UploadedAt string `json:"uploaded_at"`
+}
+
+// FilenameIndex maps original filenames to the S3 keys they were uploaded as,
+// so a clip can be found by its original name however its key was derived.
+// Each filename has its own index object (<prefix><escaped name>.json) holding
+// every upload of that name, oldest first; names that collide keep all their keys.
+type FilenameIndex struct {
+	client s3API
+	bucket string
+	prefix string
+
+	// Serialises updates from this process so they don't conflict with each other
+	mu sync.Mutex
+}
+
+// NewFilenameIndex creates a filename index
+func NewFilenameIndex(client s3API, bucket, prefix string) *FilenameIndex {
+	return &FilenameIndex{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+// Record adds an upload of originalName to the index. Recording the same key
+// and version again is a no-op.
+func (x *FilenameIndex) Record(ctx context.Context, originalName string, entry FilenameIndexEntry) error {
+	if entry.UploadedAt == "" {
+		entry.UploadedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	key := x.indexKey(originalName)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	retryConfig := utils.RetryConfig{
+		MaxRetries:    maxConditionalWriteAttempts - 1,
+		InitialDelay:  100 * time.Millisecond,
+		MaxDelay:      2 * time.Second,
+		OperationName: fmt.Sprintf("filename index update %s", key),
+		Jitter:        true,
+		IsRetryable: func(err error) bool {
+			return errors.Is(err, errWriteConflict) || IsRetryableError(err)
+		},
+	}
+
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		body, etag, err := readObject(ctx, x.client, x.bucket, key)
+		if err != nil {
+			return err
+		}
+		entries, err := decodeFilenameIndex(body)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range entries {
+			if existing.Key == entry.Key && existing.VersionID == entry.VersionID {
+				return nil
+			}
+		}
+
+		updated, err := json.Marshal(append(entries, entry))
+		if err != nil {
+			return fmt.Errorf("failed to marshal filename index: %w", err)
+		}
+		return writeObjectIfUnchanged(ctx, x.client, x.bucket, key, updated, etag, "application/json")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index %s as %s: %w", originalName, entry.Key, err)
+	}
+
+	slog.Info("Indexed original filename", "filename", originalName, "s3_key", entry.Key, "index", key)
+	return nil
+}
+
+// Lookup returns every upload recorded under originalName, oldest first.
+// A name that was never indexed returns no entries and no error.
+func (x *FilenameIndex) Lookup(ctx context.Context, originalName string) ([]FilenameIndexEntry, error) {
+	key := x.indexKey(originalName)
+
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("filename index lookup %s", key))
+	retryConfig.IsRetryable = IsRetryableError
+
+	var body []byte
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		var err error
+		body, _, err = readObject(ctx, x.client, x.bucket, key)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", originalName, err)
+	}
+	return decodeFilenameIndex(body)
+}
+
+// indexKey returns the index object key for a filename. The name is escaped so
+// any filename maps to exactly one key.
+func (x *FilenameIndex) indexKey(originalName string) string {
+	return x.prefix + url.PathEscape(originalName) + ".json"
+}
+
+// decodeFilenameIndex parses an index object; an empty body is an empty index
+func decodeFilenameIndex(body []byte) ([]FilenameIndexEntry, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var entries []FilenameIndexEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse filename index: %w", err)
+	}
+	return entries, nil
+}
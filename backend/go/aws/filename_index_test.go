@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// indexedKeys returns the keys of entries, in order
+func indexedKeys(entries []FilenameIndexEntry) []string {
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+func TestFilenameIndexRoundTrip(t *testing.T) {
+	type record struct {
+		name, key, version string
+	}
+	tests := []struct {
+		name    string
+		records []record
+		want    map[string][]string // lookups by original name
+	}{
+		{
+			name:    "single upload",
+			records: []record{{"clip.mp4", "videos/3f2a.mp4", ""}},
+			want:    map[string][]string{"clip.mp4": {"videos/3f2a.mp4"}},
+		},
+		{
+			name: "colliding names keep every key, oldest first",
+			records: []record{
+				{"clip.mp4", "videos/front/clip.mp4", ""},
+				{"clip.mp4", "videos/back/clip.mp4", ""},
+				{"clip.mp4", "videos/front/clip-1.mp4", ""},
+			},
+			want: map[string][]string{"clip.mp4": {"videos/front/clip.mp4", "videos/back/clip.mp4", "videos/front/clip-1.mp4"}},
+		},
+		{
+			name: "recording again is a no-op",
+			records: []record{
+				{"clip.mp4", "videos/clip.mp4", "v1"},
+				{"clip.mp4", "videos/clip.mp4", "v1"},
+			},
+			want: map[string][]string{"clip.mp4": {"videos/clip.mp4"}},
+		},
+		{
+			name: "overwrites are new versions",
+			records: []record{
+				{"clip.mp4", "videos/clip.mp4", "v1"},
+				{"clip.mp4", "videos/clip.mp4", "v2"},
+			},
+			want: map[string][]string{"clip.mp4": {"videos/clip.mp4", "videos/clip.mp4"}},
+		},
+		{
+			name: "names that escape alike stay apart",
+			records: []record{
+				{"a/b.mp4", "videos/1.mp4", ""},
+				{"a%2Fb.mp4", "videos/2.mp4", ""},
+				{"a b.mp4", "videos/3.mp4", ""},
+				{"a+b.mp4", "videos/4.mp4", ""},
+			},
+			want: map[string][]string{
+				"a/b.mp4":   {"videos/1.mp4"},
+				"a%2Fb.mp4": {"videos/2.mp4"},
+				"a b.mp4":   {"videos/3.mp4"},
+				"a+b.mp4":   {"videos/4.mp4"},
+			},
+		},
+		{
+			name:    "unicode",
+			records: []record{{"entrée caméra 🎥.mp4", "videos/5.mp4", ""}},
+			want:    map[string][]string{"entrée caméra 🎥.mp4": {"videos/5.mp4"}, "entree camera.mp4": nil},
+		},
+		{
+			name: "never indexed",
+			want: map[string][]string{"missing.mp4": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakes3.New(t)
+			index := newTestUploader(t, server, DefaultS3UploaderOptions()).NewFilenameIndex("index/")
+			ctx := context.Background()
+
+			for _, r := range tt.records {
+				if err := index.Record(ctx, r.name, FilenameIndexEntry{Key: r.key, VersionID: r.version}); err != nil {
+					t.Fatalf("Record(%s, %s): %v", r.name, r.key, err)
+				}
+			}
+			for name, want := range tt.want {
+				entries, err := index.Lookup(ctx, name)
+				if err != nil {
+					t.Fatalf("Lookup(%s): %v", name, err)
+				}
+				if got := indexedKeys(entries); !slices.Equal(got, want) {
+					t.Errorf("Lookup(%s) = %v, want %v", name, got, want)
+				}
+				for _, entry := range entries {
+					if entry.UploadedAt == "" {
+						t.Errorf("Lookup(%s) entry %s has no upload time", name, entry.Key)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFilenameIndexConcurrentCollisions(t *testing.T) {
+	server := fakes3.New(t)
+	// Separate indexes stand in for separate processes, which only the
+	// conditional writes keep from losing each other's entries
+	const writers = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		index := newTestUploader(t, server, DefaultS3UploaderOptions()).NewFilenameIndex("index/")
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs <- index.Record(context.Background(), "clip.mp4", FilenameIndexEntry{Key: fmt.Sprintf("videos/camera-%d/clip.mp4", w)})
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	entries, err := newTestUploader(t, server, DefaultS3UploaderOptions()).NewFilenameIndex("index/").Lookup(context.Background(), "clip.mp4")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	got := indexedKeys(entries)
+	slices.Sort(got)
+	var want []string
+	for w := 0; w < writers; w++ {
+		want = append(want, fmt.Sprintf("videos/camera-%d/clip.mp4", w))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lookup() = %v, want %v", got, want)
+	}
+}
+
+func TestFilenameIndexCorruptObject(t *testing.T) {
+	server := fakes3.New(t)
+	index := newTestUploader(t, server, DefaultS3UploaderOptions()).NewFilenameIndex("index/")
+	server.Put(testBucket, "index/clip.mp4.json", []byte("not json"))
+	utils.SetDefaultMaxElapsed(time.Millisecond)
+	t.Cleanup(func() { utils.SetDefaultMaxElapsed(0) })
+
+	if _, err := index.Lookup(context.Background(), "clip.mp4"); err == nil {
+		t.Error("Lookup of a corrupt index object succeeded")
+	}
+	if err := index.Record(context.Background(), "clip.mp4", FilenameIndexEntry{Key: "videos/clip.mp4"}); err == nil {
+		t.Error("Record over a corrupt index object succeeded")
+	}
+}
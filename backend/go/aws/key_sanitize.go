@@ -0,0 +1,48 @@
+package aws
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Characters S3 recommends avoiding in keys, and that URLs either reserve or
+// handle inconsistently ('+' is read as a space by some clients)
+const unsafeKeyChars = "\\{}^%`[]\"<>~#|+?&=;:@$,"
+
+// SanitizeFilename normalises a filename for use in an S3 key, so its key and
+// CloudFront URL are predictable: control characters and invalid UTF-8 are
+// dropped, runs of whitespace and characters that are awkward in keys or URLs
+// become a single "_", and letters in any script are kept.
+func SanitizeFilename(name string) string {
+	var b strings.Builder
+	lastReplaced := false
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError || (unicode.IsControl(r) && !unicode.IsSpace(r)):
+			continue
+		case unicode.IsSpace(r) || r == '/' || strings.ContainsRune(unsafeKeyChars, r) || !unicode.IsPrint(r):
+			if !lastReplaced {
+				b.WriteRune('_')
+			}
+			lastReplaced = true
+			continue
+		}
+		b.WriteRune(r)
+		lastReplaced = false
+	}
+
+	sanitized := strings.Trim(b.String(), "_")
+	if sanitized == "" || sanitized == "." || sanitized == ".." {
+		return "video"
+	}
+	return sanitized
+}
+
+// cloudFrontObjectURL builds the CloudFront URL of an S3 key with its path
+// percent-encoded, so the URL that is signed is byte-for-byte the one requested
+func cloudFrontObjectURL(cloudFrontDomain, s3Key string) string {
+	u := url.URL{Scheme: "https", Host: cloudFrontDomain, Path: "/" + s3Key}
+	return u.String()
+}
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// User metadata the watcher sets on videos, read to fill in key templates
+const (
+	MetadataCaptureTime = "capture-time"
+	MetadataCameraID    = "camera-id"
+)
+
+// DefaultKeyTemplate keys each video by its filename alone
+const DefaultKeyTemplate KeyTemplate = "{filename}"
+
+// Camera name used in keys of videos whose camera isn't known, e.g. ones
+// retried from the failed upload directory
+const unknownCamera = "unknown"
+
+var keyPlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// KeyTemplate lays out video keys beneath KeyPrefix, e.g.
+// "{camera}/{year}/{month}/{day}/{filename}". Placeholders:
+//
+//   - {filename}: the video's sanitized filename
+//   - {camera}: the watched directory the video was written to
+//   - {year}, {month}, {day}: the capture date, in UTC
+//   - {timestamp}: the capture time, in UTC, as 20060102T150405Z
+type KeyTemplate string
+
+// ParseKeyTemplate checks a key template, rejecting unknown placeholders and
+// templates without {filename}, whose keys could collide
+func ParseKeyTemplate(template string) (KeyTemplate, error) {
+	template = strings.TrimLeft(strings.TrimSpace(template), "/")
+	for _, placeholder := range keyPlaceholderPattern.FindAllString(template, -1) {
+		switch placeholder {
+		case "{filename}", "{camera}", "{year}", "{month}", "{day}", "{timestamp}":
+		default:
+			return "", fmt.Errorf("unknown key template placeholder %s (supported: {filename}, {camera}, {year}, {month}, {day}, {timestamp})", placeholder)
+		}
+	}
+	if strings.ContainsAny(keyPlaceholderPattern.ReplaceAllString(template, ""), "{}") {
+		return "", fmt.Errorf("unbalanced braces in key template %q", template)
+	}
+	if !strings.Contains(template, "{filename}") {
+		return "", fmt.Errorf("key template %q must contain {filename}", template)
+	}
+	return KeyTemplate(template), nil
+}
+
+// render builds the key, below the prefix, of a video with the given user
+// metadata. A video without a capture time is dated by its modification time.
+func (t KeyTemplate) render(filePath string, metadata map[string]string) string {
+	if t == "" {
+		t = DefaultKeyTemplate
+	}
+
+	camera := unknownCamera
+	if id := metadata[MetadataCameraID]; id != "" {
+		camera = SanitizeFilename(id)
+	}
+	captured, err := time.Parse(time.RFC3339, metadata[MetadataCaptureTime])
+	if err != nil {
+		captured = time.Now()
+		if info, err := os.Stat(filePath); err == nil {
+			captured = info.ModTime()
+		}
+	}
+	captured = captured.UTC()
+
+	return strings.NewReplacer(
+		"{filename}", SanitizeFilename(filepath.Base(filePath)),
+		"{camera}", camera,
+		"{year}", captured.Format("2006"),
+		"{month}", captured.Format("01"),
+		"{day}", captured.Format("02"),
+		"{timestamp}", captured.Format("20060102T150405Z"),
+	).Replace(string(t))
+}
@@ -0,0 +1,154 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+const (
+	// Attempts at a conditional write before giving up on an update
+	maxConditionalWriteAttempts = 5
+)
+
+// errWriteConflict means another writer changed an object since we read it
+var errWriteConflict = errors.New("object modified concurrently")
+
+// ManifestEntry records one uploaded object
+type ManifestEntry struct {
+	Key        string `json:"key"`
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+	VersionID  string `json:"version_id,omitempty"`
+	UploadedAt string `json:"uploaded_at"`
+}
+
+// ManifestWriter appends uploaded keys and their SHA-256 to a daily JSON Lines
+// manifest in S3 (<prefix>YYYY-MM-DD.jsonl). Each append is a read-modify-write
+// made safe against other writers with conditional puts (If-Match / If-None-Match).
+type ManifestWriter struct {
+	client s3API
+	bucket string
+	prefix string
+
+	// Serialises appends from this process so they don't conflict with each other
+	mu sync.Mutex
+}
+
+// NewManifestWriter creates a manifest writer
+func NewManifestWriter(client s3API, bucket, prefix string) *ManifestWriter {
+	return &ManifestWriter{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+// Append adds an entry to today's manifest
+func (m *ManifestWriter) Append(ctx context.Context, entry ManifestEntry) error {
+	now := time.Now().UTC()
+	if entry.UploadedAt == "" {
+		entry.UploadedAt = now.Format(time.RFC3339)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	key := m.prefix + now.Format("2006-01-02") + ".jsonl"
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	retryConfig := utils.RetryConfig{
+		MaxRetries:    maxConditionalWriteAttempts - 1,
+		InitialDelay:  100 * time.Millisecond,
+		MaxDelay:      2 * time.Second,
+		OperationName: fmt.Sprintf("manifest append %s", key),
+		Jitter:        true,
+		IsRetryable: func(err error) bool {
+			return errors.Is(err, errWriteConflict) || IsRetryableError(err)
+		},
+	}
+
+	err = utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		existing, etag, err := readObject(ctx, m.client, m.bucket, key)
+		if err != nil {
+			return err
+		}
+		return writeObjectIfUnchanged(ctx, m.client, m.bucket, key, append(existing, line...), etag, "application/x-ndjson")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append %s to manifest %s: %w", entry.Key, key, err)
+	}
+
+	slog.Info("Added upload to manifest", "s3_key", entry.Key, "manifest", key)
+	return nil
+}
+
+// readObject returns an object's contents and ETag, or nothing if it doesn't exist yet
+func readObject(ctx context.Context, client s3API, bucket, key string) ([]byte, string, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return body, aws.ToString(output.ETag), nil
+}
+
+// writeObjectIfUnchanged replaces an object only if it still has the given ETag,
+// or only if it doesn't exist when etag is empty. A lost race returns errWriteConflict.
+func writeObjectIfUnchanged(ctx context.Context, client s3API, bucket, key string, body []byte, etag, contentType string) error {
+	condition := smithyhttp.SetHeaderValue("If-None-Match", "*")
+	if etag != "" {
+		condition = smithyhttp.SetHeaderValue("If-Match", etag)
+	}
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	}, s3.WithAPIOptions(condition))
+	if isPreconditionFailed(err) {
+		return errWriteConflict
+	}
+	return err
+}
+
+// isPreconditionFailed reports whether S3 rejected a conditional write
+func isPreconditionFailed(err error) bool {
+	var responseErr *smithyhttp.ResponseError
+	if !errors.As(err, &responseErr) {
+		return false
+	}
+	status := responseErr.HTTPStatusCode()
+	// 409 is returned when a concurrent conditional write is in progress
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
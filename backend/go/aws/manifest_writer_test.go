@@ -0,0 +1,163 @@
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+)
+
+var manifestKeyPattern = regexp.MustCompile(`^manifests/\d{4}-\d{2}-\d{2}\.jsonl$`)
+
+// readManifest returns the entries of the single manifest in the fake bucket
+func readManifest(t *testing.T, server *fakes3.Server) []ManifestEntry {
+	t.Helper()
+	var keys []string
+	for _, key := range server.Keys(testBucket) {
+		if strings.HasPrefix(key, "manifests/") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 1 || !manifestKeyPattern.MatchString(keys[0]) {
+		t.Fatalf("manifest objects = %v, want one named manifests/YYYY-MM-DD.jsonl", keys)
+	}
+
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(bytes.NewReader(server.Object(testBucket, keys[0]).Body))
+	for scanner.Scan() {
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("manifest line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestManifestAppend(t *testing.T) {
+	server := fakes3.New(t)
+	manifest := newTestUploader(t, server, DefaultS3UploaderOptions()).NewManifestWriter("manifests/")
+
+	appended := []ManifestEntry{
+		{Key: "videos/a.mp4", SHA256: "aaa", Size: 1, VersionID: "v1"},
+		{Key: "videos/b.mp4", SHA256: "bbb", Size: 2},
+		{Key: "videos/c.mp4", SHA256: "ccc", Size: 3, UploadedAt: "2026-01-02T03:04:05Z"},
+	}
+	for _, entry := range appended {
+		if err := manifest.Append(context.Background(), entry); err != nil {
+			t.Fatalf("Append(%s): %v", entry.Key, err)
+		}
+	}
+
+	entries := readManifest(t, server)
+	if len(entries) != len(appended) {
+		t.Fatalf("manifest holds %d entries, want %d", len(entries), len(appended))
+	}
+	for i, entry := range entries {
+		want := appended[i]
+		if want.UploadedAt == "" {
+			if _, err := time.Parse(time.RFC3339, entry.UploadedAt); err != nil {
+				t.Errorf("entry %d uploaded_at %q is not a timestamp", i, entry.UploadedAt)
+			}
+			want.UploadedAt = entry.UploadedAt
+		}
+		if entry != want {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want)
+		}
+	}
+
+	// The first write creates the manifest, later ones replace the version they read
+	var conditions []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && strings.HasPrefix(req.Key, "manifests/") {
+			switch {
+			case req.Header.Get("If-None-Match") == "*":
+				conditions = append(conditions, "If-None-Match")
+			case req.Header.Get("If-Match") != "":
+				conditions = append(conditions, "If-Match")
+			default:
+				conditions = append(conditions, "none")
+			}
+		}
+	}
+	if want := []string{"If-None-Match", "If-Match", "If-Match"}; fmt.Sprint(conditions) != fmt.Sprint(want) {
+		t.Errorf("manifest write conditions = %v, want %v", conditions, want)
+	}
+}
+
+func TestManifestAppendRetriesConflicts(t *testing.T) {
+	server := fakes3.New(t)
+	manifest := newTestUploader(t, server, DefaultS3UploaderOptions()).NewManifestWriter("manifests/")
+	if err := manifest.Append(context.Background(), ManifestEntry{Key: "videos/a.mp4"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	// Another writer gets in between our read and write, once
+	conflicted := false
+	server.Hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/"+testBucket+"/manifests/") && !conflicted {
+			conflicted = true
+			fakes3.Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return true
+		}
+		return false
+	}
+	if err := manifest.Append(context.Background(), ManifestEntry{Key: "videos/b.mp4"}); err != nil {
+		t.Fatalf("Append after a conflict: %v", err)
+	}
+
+	entries := readManifest(t, server)
+	if len(entries) != 2 || entries[0].Key != "videos/a.mp4" || entries[1].Key != "videos/b.mp4" {
+		t.Errorf("manifest entries = %+v, want a then b once each", entries)
+	}
+}
+
+func TestManifestConcurrentWriters(t *testing.T) {
+	const writers, perWriter = 3, 4
+	server := fakes3.New(t)
+	uploader := newTestUploader(t, server, DefaultS3UploaderOptions())
+
+	// Separate writers, as in separate processes, only coordinate through conditional puts
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	var want []string
+	for w := 0; w < writers; w++ {
+		manifest := uploader.NewManifestWriter("manifests/")
+		for i := 0; i < perWriter; i++ {
+			key := fmt.Sprintf("videos/%d-%d.mp4", w, i)
+			want = append(want, key)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- manifest.Append(context.Background(), ManifestEntry{Key: key})
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Append: %v", err)
+		}
+	}
+
+	var got []string
+	for _, entry := range readManifest(t, server) {
+		got = append(got, entry.Key)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("manifest keys = %v, want each of %v once", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// NotificationBatcher collects notifications and flushes them together once
+// a count or size threshold is reached or the batch window closes, whichever
+// comes first. A batch belongs to every notification in it, so however it is
+// flushed, a failure is logged and counted once per notification rather than
+// returned to whichever caller happened to fill it, and each caller that asked
+// is told how its own notification's delivery went.
+type NotificationBatcher struct {
+	flush    func(context.Context, []VideoNotification) error
+	window   time.Duration
+	maxCount int
+	maxBytes int
+
+	mu      sync.Mutex
+	pending queuedBatch
+	bytes   int
+	timer   *time.Timer
+	// generation increments on every flush so a stale window timer can't
+	// flush the batch that replaced its own
+	generation int
+}
+
+// queuedBatch holds a batch's notifications, each with the func told of its
+// delivery, which may be nil
+type queuedBatch struct {
+	notifications []VideoNotification
+	delivered     []func(error)
+}
+
+// NewNotificationBatcher creates a batcher that hands each completed batch to flush.
+// A maxCount or maxBytes of zero disables that trigger.
+func NewNotificationBatcher(flush func(context.Context, []VideoNotification) error, window time.Duration, maxCount, maxBytes int) *NotificationBatcher {
+	return &NotificationBatcher{
+		flush:    flush,
+		window:   window,
+		maxCount: maxCount,
+		maxBytes: maxBytes,
+	}
+}
+
+// Add queues a notification, flushing synchronously if it completes a batch.
+// It only fails if the notification can't be queued; batch failures are reported
+// per notification. delivered, if set, is called with the batch's result once it
+// has been flushed, which may be before Add returns.
+func (b *NotificationBatcher) Add(ctx context.Context, notification VideoNotification, delivered func(error)) error {
+	encoded, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	size := len(encoded)
+
+	var ready []queuedBatch
+
+	b.mu.Lock()
+	// Flush what's pending first if this notification would push it past the size limit
+	if b.maxBytes > 0 && len(b.pending.notifications) > 0 && b.bytes+size > b.maxBytes {
+		ready = append(ready, b.takeLocked())
+	}
+
+	b.pending.notifications = append(b.pending.notifications, notification)
+	b.pending.delivered = append(b.pending.delivered, delivered)
+	b.bytes += size
+
+	if len(b.pending.notifications) == 1 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.window, func() {
+			b.flushWindow(generation)
+		})
+	}
+
+	if (b.maxCount > 0 && len(b.pending.notifications) >= b.maxCount) || (b.maxBytes > 0 && b.bytes >= b.maxBytes) {
+		ready = append(ready, b.takeLocked())
+	}
+	b.mu.Unlock()
+
+	// The batch isn't this caller's alone, so its cancellation mustn't abort it
+	for _, batch := range ready {
+		b.report(batch, b.flush(context.WithoutCancel(ctx), batch.notifications))
+	}
+	return nil
+}
+
+// Flush publishes whatever is pending, e.g. on shutdown
+func (b *NotificationBatcher) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+
+	if len(batch.notifications) == 0 {
+		return nil
+	}
+	err := b.flush(ctx, batch.notifications)
+	b.report(batch, err)
+	return err
+}
+
+// flushWindow flushes the batch started in the given generation when its window closes
+func (b *NotificationBatcher) flushWindow(generation int) {
+	b.mu.Lock()
+	if b.generation != generation || len(b.pending.notifications) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	batch := b.takeLocked()
+	b.mu.Unlock()
+
+	b.report(batch, b.flush(context.Background(), batch.notifications))
+}
+
+// report logs and counts a failed batch's error against each notification in it,
+// then passes the result to each notification's delivered func
+func (b *NotificationBatcher) report(batch queuedBatch, err error) {
+	if err != nil {
+		slog.Error("Failed to publish notification batch", "count", len(batch.notifications), "error", err)
+		for _, notification := range batch.notifications {
+			metrics.Default.Inc(metrics.PublishFailed)
+			slog.Error("Notification lost with its batch", "s3_key", notification.S3Key, "event_type", notification.EventType)
+		}
+	}
+	for _, delivered := range batch.delivered {
+		if delivered != nil {
+			delivered(err)
+		}
+	}
+}
+
+// takeLocked removes and returns the pending batch; b.mu must be held
+func (b *NotificationBatcher) takeLocked() queuedBatch {
+	batch := b.pending
+	b.pending = queuedBatch{}
+	b.bytes = 0
+	b.generation++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
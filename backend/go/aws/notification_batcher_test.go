@@ -0,0 +1,224 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// batchRecorder is a flush func that records the batches it is given
+type batchRecorder struct {
+	err error
+
+	mu      sync.Mutex
+	batches [][]VideoNotification
+}
+
+func newBatchRecorder(err error) *batchRecorder {
+	return &batchRecorder{err: err}
+}
+
+func (r *batchRecorder) flush(ctx context.Context, batch []VideoNotification) error {
+	r.mu.Lock()
+	r.batches = append(r.batches, batch)
+	r.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sizes []int
+	for _, batch := range r.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func testNotification(i int) VideoNotification {
+	return VideoNotification{S3Key: fmt.Sprintf("videos/clip-%d.mp4", i), EventType: EventTypeHumanDetected}
+}
+
+func TestNotificationBatcherReportsFailuresPerNotification(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger string
+	}{
+		{"count flush", "count"},
+		{"window flush", "window"},
+		{"shutdown flush", "shutdown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newBatchRecorder(errors.New("sns unavailable"))
+			window, maxCount := time.Hour, 0
+			switch tt.trigger {
+			case "count":
+				maxCount = 3
+			case "window":
+				window = 20 * time.Millisecond
+			}
+			batcher := NewNotificationBatcher(recorder.flush, window, maxCount, 0)
+			failed := metrics.Default.Counter(metrics.PublishFailed).Value()
+
+			for i := 0; i < 3; i++ {
+				if err := batcher.Add(context.Background(), testNotification(i), nil); err != nil {
+					t.Errorf("Add(%d) = %v, want nil: batch failures aren't one caller's", i, err)
+				}
+			}
+			switch tt.trigger {
+			case "window":
+				// The failure is reported just after the flush returns
+				deadline := time.Now().Add(5 * time.Second)
+				for metrics.Default.Counter(metrics.PublishFailed).Value()-failed < 3 && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+			case "shutdown":
+				if err := batcher.Flush(context.Background()); err == nil {
+					t.Error("Flush succeeded, want the batch error")
+				}
+			}
+
+			if got := metrics.Default.Counter(metrics.PublishFailed).Value() - failed; got != 3 {
+				t.Errorf("publish failures counted = %d, want 3 (one per notification)", got)
+			}
+			if got := recorder.sizes(); len(got) != 1 || got[0] != 3 {
+				t.Errorf("batches = %v, want one of 3", got)
+			}
+		})
+	}
+}
+
+func TestNotificationBatcherIgnoresTriggeringCallerCancellation(t *testing.T) {
+	recorder := newBatchRecorder(nil)
+	batcher := NewNotificationBatcher(recorder.flush, time.Hour, 2, 0)
+	failed := metrics.Default.Counter(metrics.PublishFailed).Value()
+
+	batcher.Add(context.Background(), testNotification(0), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := batcher.Add(ctx, testNotification(1), nil); err != nil {
+		t.Fatalf("Add = %v", err)
+	}
+	if got := metrics.Default.Counter(metrics.PublishFailed).Value() - failed; got != 0 {
+		t.Errorf("publish failures = %d, want 0: the batch was flushed with a cancelled context", got)
+	}
+}
+
+func TestNotificationBatcherTriggers(t *testing.T) {
+	// Each test notification marshals to the same size
+	encoded, _ := json.Marshal(testNotification(0))
+	size := len(encoded)
+
+	tests := []struct {
+		name     string
+		window   time.Duration
+		maxCount int
+		maxBytes int
+		adds     int
+		wait     time.Duration
+		want     []int // batch sizes flushed, in order
+	}{
+		{"count", time.Hour, 3, 0, 7, 0, []int{3, 3}},
+		{"size reached exactly", time.Hour, 0, 2 * size, 5, 0, []int{2, 2}},
+		{"size exceeded flushes what fits first", time.Hour, 0, 2*size + size/2, 5, 0, []int{2, 2}},
+		{"window", 30 * time.Millisecond, 0, 0, 4, 200 * time.Millisecond, []int{4}},
+		{"count before window", 30 * time.Millisecond, 2, 0, 3, 200 * time.Millisecond, []int{2, 1}},
+		{"nothing triggered", time.Hour, 10, 0, 4, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newBatchRecorder(nil)
+			batcher := NewNotificationBatcher(recorder.flush, tt.window, tt.maxCount, tt.maxBytes)
+			for i := 0; i < tt.adds; i++ {
+				if err := batcher.Add(context.Background(), testNotification(i), nil); err != nil {
+					t.Fatalf("Add(%d) = %v", i, err)
+				}
+			}
+			time.Sleep(tt.wait)
+
+			if got := recorder.sizes(); !slices.Equal(got, tt.want) {
+				t.Errorf("batches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationBatcherFlushOnShutdown(t *testing.T) {
+	recorder := newBatchRecorder(nil)
+	batcher := NewNotificationBatcher(recorder.flush, time.Hour, 10, 0)
+	for i := 0; i < 3; i++ {
+		batcher.Add(context.Background(), testNotification(i), nil)
+	}
+	if err := batcher.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush = %v", err)
+	}
+	// Flushing again has nothing to send
+	if err := batcher.Flush(context.Background()); err != nil {
+		t.Fatalf("second Flush = %v", err)
+	}
+	if got := recorder.sizes(); !slices.Equal(got, []int{3}) {
+		t.Errorf("batches = %v, want [3]", got)
+	}
+	// A later notification starts a new batch instead of being flushed with the old one
+	batcher.Add(context.Background(), testNotification(3), nil)
+	if got := recorder.sizes(); !slices.Equal(got, []int{3}) {
+		t.Errorf("batches after a new add = %v, want [3]", got)
+	}
+}
+
+func TestNotificationBatcherTellsEachCallerOfDelivery(t *testing.T) {
+	errDown := errors.New("sns unavailable")
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"delivered", nil},
+		{"failed", errDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newBatchRecorder(tt.err)
+			batcher := NewNotificationBatcher(recorder.flush, time.Hour, 3, 0)
+
+			var mu sync.Mutex
+			results := map[int]error{}
+			for i := 0; i < 3; i++ {
+				if err := batcher.Add(context.Background(), testNotification(i), func(err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					results[i] = err
+				}); err != nil {
+					t.Fatalf("Add(%d) = %v", i, err)
+				}
+				// Nothing is delivered until the batch is flushed
+				mu.Lock()
+				if n := len(results); i < 2 && n != 0 {
+					t.Errorf("%d deliveries reported after %d adds, want none before the flush", n, i+1)
+				}
+				mu.Unlock()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(results) != 3 {
+				t.Fatalf("deliveries reported for %d notifications, want 3", len(results))
+			}
+			for i, err := range results {
+				if err != tt.err {
+					t.Errorf("notification %d delivered with %v, want %v", i, err, tt.err)
+				}
+			}
+		})
+	}
+}
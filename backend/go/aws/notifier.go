@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// Notification backends, selected with NOTIFIER
+const (
+	NotifierSNS     = "sns"
+	NotifierFile    = "file"
+	NotifierWebhook = "webhook"
+)
+
+// Notifier delivers video notifications. SNSPublisher publishes them to its topic;
+// FileNotifier appends them to a local file for deployments without SNS, and
+// WebhookNotifier POSTs them to an HTTP endpoint.
+type Notifier interface {
+	PublishNotification(ctx context.Context, notification VideoNotification) error
+}
+
+// QueueingNotifier is a Notifier that may hold notifications back to deliver
+// later, e.g. SNSPublisher with batching. QueueNotification reports whether it
+// queued the notification, in which case delivered is called with the result of
+// its delivery.
+type QueueingNotifier interface {
+	Notifier
+	QueueNotification(ctx context.Context, notification VideoNotification, delivered func(error)) (bool, error)
+}
+
+// FileNotifierOptions configures a file notifier
+type FileNotifierOptions struct {
+	// MaxBytes rotates the file once it would grow past this size; 0 never rotates
+	MaxBytes int64
+
+	// MaxBackups is how many rotated files (<path>.1 newest, <path>.2, ...) are kept
+	MaxBackups int
+}
+
+// DefaultFileNotifierOptions returns the default file notifier options
+func DefaultFileNotifierOptions() FileNotifierOptions {
+	return FileNotifierOptions{
+		MaxBytes:   10 * 1024 * 1024,
+		MaxBackups: 5,
+	}
+}
+
+// FileNotifier appends each notification as a JSON line to a local file, so
+// air-gapped or on-prem deployments still get the notification payloads
+type FileNotifier struct {
+	path string
+	opts FileNotifierOptions
+
+	// mu serialises writes and rotation; size is the current file's length
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileNotifier opens (or creates) the notification file at path for appending
+func NewFileNotifier(path string, opts FileNotifierOptions) (*FileNotifier, error) {
+	n := &FileNotifier{path: path, opts: opts}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create notification file directory: %w", err)
+	}
+	if err := n.open(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// PublishNotification appends the notification to the file, rotating it first
+// if the line would take it past MaxBytes
+func (n *FileNotifier) PublishNotification(ctx context.Context, notification VideoNotification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	line = append(line, '\n')
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.opts.MaxBytes > 0 && n.size > 0 && n.size+int64(len(line)) > n.opts.MaxBytes {
+		if err := n.rotate(); err != nil {
+			metrics.Default.Inc(metrics.PublishFailed)
+			return err
+		}
+	}
+
+	written, err := n.file.Write(line)
+	n.size += int64(written)
+	if err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return fmt.Errorf("failed to write notification to %s: %w", n.path, err)
+	}
+
+	slog.Info("Wrote notification to file", "path", n.path, "s3_key", notification.S3Key, "event_type", notification.EventType)
+	return nil
+}
+
+// Close closes the notification file
+func (n *FileNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.file.Close()
+}
+
+// open opens the file for appending and records its size. Callers hold n.mu,
+// except during construction.
+func (n *FileNotifier) open() error {
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat notification file: %w", err)
+	}
+	n.file, n.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts each backup up one (dropping the oldest beyond MaxBackups), moves
+// the current file to <path>.1 and starts a new one. If the file can't be moved
+// aside, appending to it continues. Callers hold n.mu.
+func (n *FileNotifier) rotate() error {
+	if err := n.file.Close(); err != nil {
+		slog.Warn("Failed to close notification file before rotating", "path", n.path, "error", err)
+	}
+
+	var err error
+	if n.opts.MaxBackups > 0 {
+		// The oldest backup, if there is one, is overwritten by the rename before it
+		for i := n.opts.MaxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(n.backupPath(i), n.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to shift notification file backup", "path", n.backupPath(i), "error", err)
+			}
+		}
+		err = os.Rename(n.path, n.backupPath(1))
+	} else {
+		err = os.Remove(n.path)
+	}
+	if err != nil {
+		slog.Warn("Failed to rotate notification file, appending to it instead", "path", n.path, "error", err)
+	} else {
+		slog.Info("Rotated notification file", "path", n.path, "backups", n.opts.MaxBackups)
+	}
+	return n.open()
+}
+
+// backupPath returns the path of the i'th most recent rotated file
+func (n *FileNotifier) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", n.path, i)
+}
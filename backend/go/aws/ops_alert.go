@@ -0,0 +1,172 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+const (
+	// EventTypeOpsAlert is the event type of operational alerts, so subscription
+	// filter policies can tell them apart from detections
+	EventTypeOpsAlert = "ops_alert"
+
+	// How often the failure counters are sampled
+	opsAlertCheckInterval = 15 * time.Second
+)
+
+// opsAlertCounters are the failure counters ops alerts are raised on
+var opsAlertCounters = []string{metrics.UploadsFailed, metrics.PublishFailed}
+
+// OpsAlertOptions configures ops alerting
+type OpsAlertOptions struct {
+	// Threshold is how many upload and publish failures within Window raise an alert
+	Threshold int
+
+	// Window is the sliding window failures are counted over
+	Window time.Duration
+
+	// Cooldown is the least time between two alerts
+	Cooldown time.Duration
+}
+
+// DefaultOpsAlertOptions returns the default ops alert options
+func DefaultOpsAlertOptions() OpsAlertOptions {
+	return OpsAlertOptions{
+		Threshold: 10,
+		Window:    5 * time.Minute,
+		Cooldown:  30 * time.Minute,
+	}
+}
+
+// OpsAlert is the message published when failures cross the threshold
+type OpsAlert struct {
+	EventType string `json:"event_type"`
+	Timestamp string `json:"timestamp"`
+	// Failures counts each kind of failure within the window, by counter name
+	Failures  map[string]int64 `json:"failures"`
+	Window    string           `json:"window"`
+	Threshold int              `json:"threshold"`
+}
+
+// failureSample is the failure counters' values at one point in time
+type failureSample struct {
+	at     time.Time
+	counts map[string]int64
+}
+
+// OpsAlerter raises an alert on a separate ops topic when upload and publish
+// failures within a window reach a threshold, so systemic problems (SSM
+// unreachable, bucket missing) are noticed without reading logs. Alerts are
+// at least Cooldown apart; failures during the cooldown don't queue another.
+type OpsAlerter struct {
+	publisher *SNSPublisher
+	opts      OpsAlertOptions
+
+	// Counter samples covering the window, oldest first; only used by Run
+	samples   []failureSample
+	lastAlert time.Time
+}
+
+// NewOpsAlerter creates an alerter publishing through publisher, which should
+// be created for the ops topic. Failures from before it is created aren't counted.
+func NewOpsAlerter(publisher *SNSPublisher, opts OpsAlertOptions) *OpsAlerter {
+	defaults := DefaultOpsAlertOptions()
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaults.Threshold
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaults.Window
+	}
+
+	return &OpsAlerter{
+		publisher: publisher,
+		opts:      opts,
+		samples:   []failureSample{sampleFailures(time.Now())},
+	}
+}
+
+// Run checks the failure counters until ctx is cancelled. Call it in a goroutine.
+func (a *OpsAlerter) Run(ctx context.Context) {
+	interval := min(opsAlertCheckInterval, a.opts.Window)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.check(ctx, time.Now())
+		}
+	}
+}
+
+// check counts the failures within the window and alerts if they reach the
+// threshold and the cooldown has passed
+func (a *OpsAlerter) check(ctx context.Context, now time.Time) {
+	current := sampleFailures(now)
+	a.samples = append(a.samples, current)
+	for now.Sub(a.samples[0].at) > a.opts.Window {
+		a.samples = a.samples[1:]
+	}
+	baseline := a.samples[0]
+
+	failures := make(map[string]int64, len(opsAlertCounters))
+	var total int64
+	for _, counter := range opsAlertCounters {
+		delta := current.counts[counter] - baseline.counts[counter]
+		if delta < 0 {
+			// The counters were reset (POST /metrics/reset) within the window
+			delta = current.counts[counter]
+		}
+		failures[counter] = delta
+		total += delta
+	}
+	if total < int64(a.opts.Threshold) {
+		return
+	}
+	if !a.lastAlert.IsZero() && now.Sub(a.lastAlert) < a.opts.Cooldown {
+		slog.Debug("Failures over the ops alert threshold, alert suppressed by cooldown", "failures", total)
+		return
+	}
+
+	alert := OpsAlert{
+		EventType: EventTypeOpsAlert,
+		Timestamp: now.UTC().Format(time.RFC3339),
+		Failures:  failures,
+		Window:    a.opts.Window.String(),
+		Threshold: a.opts.Threshold,
+	}
+	if err := a.publisher.publishOpsAlert(ctx, alert, total); err != nil {
+		slog.Error("Failed to publish ops alert", "failures", total, "error", err)
+		return
+	}
+	a.lastAlert = now
+	metrics.Default.Inc(metrics.OpsAlertsSent)
+	slog.Warn("Published ops alert", "failures", total, "window", a.opts.Window.String(), "topic_arn", a.publisher.topicARN)
+}
+
+// sampleFailures reads the failure counters
+func sampleFailures(at time.Time) failureSample {
+	counts := make(map[string]int64, len(opsAlertCounters))
+	for _, counter := range opsAlertCounters {
+		counts[counter] = metrics.Default.Counter(counter).Value()
+	}
+	return failureSample{at: at, counts: counts}
+}
+
+// publishOpsAlert publishes an ops alert through the normal publish path
+func (p *SNSPublisher) publishOpsAlert(ctx context.Context, alert OpsAlert, total int64) error {
+	body := fmt.Sprintf("%d upload and publish failures in the last %s", total, alert.Window)
+	push := pushContent{Body: body, Data: map[string]string{"event_type": EventTypeOpsAlert}}
+	fifo := fifoIDs{GroupID: EventTypeOpsAlert, DeduplicationID: deduplicationID(EventTypeOpsAlert, alert.Timestamp)}
+	if err := p.publishMessage(ctx, "EyeSeeYou Ops Alert", EventTypeOpsAlert, alert, nil, push, fifo); err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// Default lifetime of a presigned upload URL
+const defaultPresignExpiry = 15 * time.Minute
+
+// PresignedUpload is everything a client without AWS credentials needs to
+// upload one object: a plain HTTP request to make before Expires
+type PresignedUpload struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	// Header must be sent as is: it was included in the signature
+	Header      http.Header `json:"header,omitempty"`
+	ContentType string      `json:"content_type"`
+	Expires     time.Time   `json:"expires"`
+}
+
+// PresignPut presigns a PUT of key, so a lightweight uploader can send the clip
+// with PutPresigned while the credentials stay here. The uploader's storage
+// class and encryption settings are signed, so the PUT must send the returned
+// headers. A zero expiry uses PresignExpiry.
+func (u *S3Uploader) PresignPut(ctx context.Context, key, contentType string, expiry time.Duration) (PresignedUpload, error) {
+	if u.presigner == nil {
+		return PresignedUpload{}, errors.New("presigning is not available on this uploader")
+	}
+	if expiry <= 0 {
+		expiry = u.opts.PresignExpiry
+	}
+	if expiry <= 0 {
+		expiry = defaultPresignExpiry
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:       aws.String(u.bucket),
+		Key:          aws.String(key),
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClass(u.opts.StorageClass),
+	}
+	u.applyEncryption(input)
+
+	expires := time.Now().Add(expiry)
+	request, err := u.presigner.PresignPutObject(ctx, input, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to presign upload of %s: %w", key, err)
+	}
+
+	slog.Info("Presigned S3 upload", "s3_key", key, "expires", expires.UTC().Format(time.RFC3339))
+	return PresignedUpload{
+		URL:         request.URL,
+		Method:      request.Method,
+		Header:      request.SignedHeader,
+		ContentType: contentType,
+		Expires:     expires,
+	}, nil
+}
+
+// PutPresigned uploads a file to a presigned URL with plain HTTP, retrying
+// network errors and 5xx responses. It needs no AWS credentials or SDK clients;
+// a nil client uses http.DefaultClient.
+func PutPresigned(ctx context.Context, client *http.Client, upload PresignedUpload, filePath string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if time.Now().After(upload.Expires) {
+		return fmt.Errorf("presigned URL for %s expired at %s", filePath, upload.Expires.UTC().Format(time.RFC3339))
+	}
+
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("presigned upload %s", filePath))
+	retryConfig.IsRetryable = isRetryableHTTPError
+
+	start := time.Now()
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		return putPresigned(ctx, client, upload, filePath)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to presigned URL: %w", filePath, err)
+	}
+
+	slog.Info("Uploaded video to presigned URL", "path", filePath, "duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// putPresigned makes one PUT attempt
+func putPresigned(ctx context.Context, client *http.Client, upload PresignedUpload, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	method := upload.Method
+	if method == "" {
+		method = http.MethodPut
+	}
+	req, err := http.NewRequestWithContext(ctx, method, upload.URL, file)
+	if err != nil {
+		return fmt.Errorf("failed to build presigned upload request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	if upload.ContentType != "" {
+		req.Header.Set("Content-Type", upload.ContentType)
+	}
+	for name, values := range upload.Header {
+		// Host comes from the URL
+		if strings.EqualFold(name, "Host") {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &httpStatusError{target: "presigned S3 URL", status: resp.StatusCode, body: strings.TrimSpace(string(detail))}
+	}
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
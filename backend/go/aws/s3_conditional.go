@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Under DuplicateKeySkip, uploads are conditional (If-None-Match: *), so S3
+// itself refuses to overwrite a key that appeared after the existence check,
+// e.g. from another uploader or a run that crashed mid-upload. A single-part
+// upload carries the condition on its PutObject and a multipart upload on its
+// CompleteMultipartUpload, the request that creates the object. Buckets (mostly
+// S3-compatible stores) that don't support conditional writes rely on the
+// existence check alone.
+
+// ifNoneMatch makes a request conditional on the key not existing
+var ifNoneMatch = s3.WithAPIOptions(smithyhttp.SetHeaderValue("If-None-Match", "*"))
+
+// conditionalClient sends the requests that create an object with If-None-Match: *.
+// S3 only evaluates the condition on these; the other multipart requests go unchanged.
+type conditionalClient struct {
+	manager.UploadAPIClient
+}
+
+// PutObject creates the object unless the key exists
+func (c conditionalClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return c.UploadAPIClient.PutObject(ctx, params, append(optFns, ifNoneMatch)...)
+}
+
+// CompleteMultipartUpload assembles the object from its parts unless the key exists
+func (c conditionalClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return c.UploadAPIClient.CompleteMultipartUpload(ctx, params, append(optFns, ifNoneMatch)...)
+}
+
+// conditionalPut reports whether uploads are sent as conditional writes
+func (u *S3Uploader) conditionalPut() bool {
+	return u.opts.DuplicateKeyPolicy == DuplicateKeySkip && !u.conditionalUnsupported.Load()
+}
+
+// isConditionalUnsupported reports whether a store rejected a write for its
+// If-None-Match header
+func isConditionalUnsupported(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		return true
+	}
+	var responseErr *smithyhttp.ResponseError
+	return errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusNotImplemented
+}
@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+)
+
+const testBucket = "test-bucket"
+
+// newTestUploader creates an uploader against a fake S3 endpoint
+func newTestUploader(t *testing.T, server *fakes3.Server, opts S3UploaderOptions) *S3Uploader {
+	t.Helper()
+	server.SetEnv(t)
+	if opts.FailedUploadDir == "" {
+		opts.FailedUploadDir = t.TempDir()
+	}
+	uploader, err := NewS3Uploader(context.Background(), "us-east-1", testBucket, opts)
+	if err != nil {
+		t.Fatalf("NewS3Uploader: %v", err)
+	}
+	return uploader
+}
+
+// writeTestFile writes size bytes to a file named name in a temporary directory
+func writeTestFile(t *testing.T, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, bytes.Repeat([]byte("v"), size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUploadConditionalHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		size   int
+		// conditional lists the operations (method and query) expected to carry If-None-Match
+		conditional map[string]bool
+	}{
+		{"single part skip", DuplicateKeySkip, 1024, map[string]bool{"PUT": true}},
+		{"multipart skip", DuplicateKeySkip, int(manager.MinUploadPartSize) + 1024, map[string]bool{"POST uploadId": true}},
+		{"single part overwrite", DuplicateKeyOverwrite, 1024, nil},
+		{"multipart overwrite", DuplicateKeyOverwrite, int(manager.MinUploadPartSize) + 1024, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakes3.New(t)
+			opts := DefaultS3UploaderOptions()
+			opts.DuplicateKeyPolicy = tt.policy
+			opts.PartSize = manager.MinUploadPartSize
+			uploader := newTestUploader(t, server, opts)
+
+			if _, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", tt.size), nil); err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+
+			for _, req := range server.Requests() {
+				if req.Key == "" {
+					continue
+				}
+				op := req.Method
+				if query, _ := url.ParseQuery(req.Query); req.Method == http.MethodPost && query.Has("uploadId") {
+					op = "POST uploadId"
+				}
+				got := req.Header.Get("If-None-Match") == "*"
+				if got != tt.conditional[op] {
+					t.Errorf("%s %s?%s: If-None-Match set = %v, want %v", req.Method, req.Key, req.Query, got, tt.conditional[op])
+				}
+			}
+		})
+	}
+}
+
+func TestUploadConditionalKeepsKeyCreatedMeanwhile(t *testing.T) {
+	for _, size := range []int{1024, int(manager.MinUploadPartSize) + 1024} {
+		server := fakes3.New(t)
+		opts := DefaultS3UploaderOptions()
+		opts.DuplicateKeyPolicy = DuplicateKeySkip
+		opts.PartSize = manager.MinUploadPartSize
+		uploader := newTestUploader(t, server, opts)
+
+		// The existence check misses the object, as if it was written just after
+		existing := []byte("uploaded by someone else")
+		server.Put(testBucket, "videos/clip.mp4", existing)
+		checked := false
+		server.Hook = func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Method == http.MethodHead && !checked {
+				checked = true
+				w.WriteHeader(http.StatusNotFound)
+				return true
+			}
+			return false
+		}
+
+		result, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", size), nil)
+		if err != nil {
+			t.Fatalf("size %d: Upload: %v", size, err)
+		}
+		if !result.Skipped {
+			t.Errorf("size %d: result not marked skipped: %+v", size, result)
+		}
+		if got := server.Object(testBucket, "videos/clip.mp4").Body; !bytes.Equal(got, existing) {
+			t.Errorf("size %d: existing object was overwritten (%d bytes)", size, len(got))
+		}
+	}
+}
@@ -0,0 +1,168 @@
+//go:build integration
+
+// Integration tests against a real S3 API: LocalStack or MinIO, named by
+// AWS_ENDPOINT_URL. Run with
+//
+//	AWS_ENDPOINT_URL=http://localhost:4566 go test -tags integration ./aws/
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// integrationEnv is a bucket created for one test on the endpoint in AWS_ENDPOINT_URL
+type integrationEnv struct {
+	endpoint string
+	region   string
+	bucket   string
+	client   *s3.Client
+}
+
+func newIntegrationEnv(t *testing.T) *integrationEnv {
+	t.Helper()
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		t.Skip("AWS_ENDPOINT_URL is not set; start LocalStack or MinIO and point it there")
+	}
+	// LocalStack accepts any credentials; MinIO needs its own from the environment
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "test")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		t.Fatalf("load AWS config: %v", err)
+	}
+	env := &integrationEnv{
+		endpoint: endpoint,
+		region:   region,
+		bucket:   fmt.Sprintf("eyeseeyou-integration-%d", time.Now().UnixNano()),
+		client:   s3.NewFromConfig(cfg, s3PathStyle(cfg)),
+	}
+	if _, err := env.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(env.bucket)}); err != nil {
+		t.Fatalf("create bucket %s: %v", env.bucket, err)
+	}
+	t.Cleanup(env.deleteBucket)
+	return env
+}
+
+// deleteBucket empties and deletes the test bucket
+func (e *integrationEnv) deleteBucket() {
+	ctx := context.Background()
+	output, err := e.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(e.bucket)})
+	if err == nil {
+		for _, object := range output.Contents {
+			e.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(e.bucket), Key: object.Key})
+		}
+	}
+	e.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(e.bucket)})
+}
+
+func (e *integrationEnv) newUploader(t *testing.T, opts S3UploaderOptions) *S3Uploader {
+	t.Helper()
+	opts.FailedUploadDir = t.TempDir()
+	uploader, err := NewS3Uploader(context.Background(), e.region, e.bucket, opts)
+	if err != nil {
+		t.Fatalf("NewS3Uploader: %v", err)
+	}
+	return uploader
+}
+
+func TestIntegrationUpload(t *testing.T) {
+	env := newIntegrationEnv(t)
+	opts := DefaultS3UploaderOptions()
+	opts.KeyTemplate = "{camera}/{year}/{month}/{day}/{filename}"
+	uploader := env.newUploader(t, opts)
+
+	path := writeTestFile(t, "front door 1.mp4", 64*1024)
+	metadata := map[string]string{
+		MetadataCameraID:    "front-door",
+		MetadataCaptureTime: "2026-03-14T09:26:53Z",
+	}
+	result, err := uploader.Upload(context.Background(), path, metadata)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	wantKey := "videos/front-door/2026/03/14/" + SanitizeFilename("front door 1.mp4")
+	if result.Key != wantKey {
+		t.Errorf("key = %s, want %s", result.Key, wantKey)
+	}
+
+	head, err := env.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(env.bucket),
+		Key:    aws.String(wantKey),
+	})
+	if err != nil {
+		t.Fatalf("HeadObject %s: %v", wantKey, err)
+	}
+	if got := aws.ToInt64(head.ContentLength); got != 64*1024 {
+		t.Errorf("object is %d bytes, want %d", got, 64*1024)
+	}
+	if got := aws.ToString(head.ContentType); got != "video/mp4" {
+		t.Errorf("content type = %q, want video/mp4", got)
+	}
+	if got := head.Metadata[MetadataCameraID]; got != "front-door" {
+		t.Errorf("camera metadata = %q, want front-door", got)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("uploaded file should be left for the caller: %v", err)
+	}
+}
+
+func TestIntegrationUploadVerificationFailure(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	// Relay to the endpoint, reporting a different checksum for the uploaded
+	// object, as if S3 had stored other bytes. The Host header is passed on
+	// unchanged so signatures still verify.
+	target, err := url.Parse(env.endpoint)
+	if err != nil {
+		t.Fatalf("parse AWS_ENDPOINT_URL: %v", err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if resp.Request.Method == http.MethodHead && resp.StatusCode == http.StatusOK && strings.HasSuffix(resp.Request.URL.Path, ".mp4") {
+			resp.Header.Set("X-Amz-Checksum-Sha256", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+		}
+		return nil
+	}
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+
+	uploader := env.newUploader(t, DefaultS3UploaderOptions())
+	path := writeTestFile(t, "clip.mp4", 1024)
+
+	_, err = uploader.Upload(context.Background(), path, nil)
+	if !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("Upload error = %v, want a checksum mismatch", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file was not moved out of the video directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(uploader.opts.FailedUploadDir, "clip.mp4")); err != nil {
+		t.Errorf("file was not moved to the failed upload directory: %v", err)
+	}
+}
@@ -2,40 +2,169 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
 	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
 )
 
 const (
-	// S3 operation timeout
-	s3UploadTimeout = 60 * time.Second
+	// Default time allowed for an upload, including its retries
+	defaultS3UploadTimeout = 60 * time.Second
 
-	// Failed upload directory
-	failedUploadDir = "/tmp/videos-failed-upload"
+	// DefaultFailedUploadDir holds files whose upload or verification failed
+	DefaultFailedUploadDir = "/tmp/videos-failed-upload"
 
-	// Max size for failed upload directory (100 MB)
-	maxFailedUploadDirSize = 100 * 1024 * 1024
+	// Default max size for the failed upload directory (100 MB)
+	defaultFailedUploadMaxBytes = 100 * 1024 * 1024
 )
 
+// Duplicate key policies, applied when the destination key already exists
+const (
+	// DuplicateKeyOverwrite uploads anyway (a new version on versioned buckets)
+	DuplicateKeyOverwrite = "overwrite"
+	// DuplicateKeySkip keeps the existing object and skips the upload, using a
+	// conditional put where possible so a key created meanwhile isn't overwritten
+	DuplicateKeySkip = "skip"
+)
+
+// S3UploaderOptions holds optional S3 uploader settings
+type S3UploaderOptions struct {
+	// AutodetectRegion looks up the bucket's actual region and uses it
+	// instead of the configured AWS region
+	AutodetectRegion bool
+
+	// DuplicateKeyPolicy decides what happens when the key already exists
+	DuplicateKeyPolicy string
+
+	// StorageClass videos are written with, e.g. STANDARD_IA for rarely watched clips
+	StorageClass string
+
+	// ContentTypes overrides the content type videos are uploaded with, by
+	// lowercase extension (".mkv"); other videos use the built-in map
+	ContentTypes map[string]string
+
+	// SSEKMSKeyID, if set, encrypts uploaded objects with this KMS key (ID, ARN or
+	// alias) using SSE-KMS; when empty the bucket's default encryption applies
+	SSEKMSKeyID string
+
+	// KeyPrefix is prepended to each video's sanitized filename to form its key,
+	// e.g. "prod/videos/" when environments share a bucket
+	KeyPrefix string
+
+	// KeyTemplate lays out keys beneath KeyPrefix, e.g. by camera and date
+	KeyTemplate KeyTemplate
+
+	// PartSize and Concurrency tune multipart uploads: files of at least PartSize
+	// bytes are sent in parts of that size, Concurrency parts at a time
+	PartSize    int64
+	Concurrency int
+
+	// UploadTimeout bounds each upload, including its retries. When MinThroughput
+	// (bytes per second) is set, each file gets an extra size/MinThroughput on top,
+	// so large clips on slow links aren't cut off.
+	UploadTimeout time.Duration
+	MinThroughput int64
+
+	// CircuitThreshold consecutive failed upload attempts open a circuit breaker:
+	// uploads then fail fast, quarantining their files in the failed upload
+	// directory, until CircuitCooldown has passed and a probe succeeds. 0 disables it.
+	CircuitThreshold int
+	CircuitCooldown  time.Duration
+
+	// FailedUploadDir holds files whose upload or verification failed until they are
+	// retried. Past FailedUploadMaxBytes its oldest files are evicted to make room.
+	FailedUploadDir      string
+	FailedUploadMaxBytes int64
+
+	// Progress, if set, is told how far each video upload has got. When unset,
+	// multipart (large) uploads log at 25, 50, 75 and 100%.
+	Progress ProgressFunc
+
+	// DedupIndexPath is a local file of recently uploaded content hashes. A clip
+	// identical to one uploaded within DedupWindow isn't uploaded again; the
+	// earlier upload's key is returned instead. The file keeps at most
+	// DedupMaxEntries hashes. Empty disables deduplication.
+	DedupIndexPath  string
+	DedupWindow     time.Duration
+	DedupMaxEntries int
+
+	// PresignExpiry is how long presigned upload URLs stay valid when
+	// PresignPut isn't given an expiry
+	PresignExpiry time.Duration
+}
+
+// DefaultS3UploaderOptions returns the default S3 uploader options
+func DefaultS3UploaderOptions() S3UploaderOptions {
+	return S3UploaderOptions{
+		DuplicateKeyPolicy:   DuplicateKeyOverwrite,
+		StorageClass:         string(types.StorageClassStandard),
+		KeyPrefix:            "videos/",
+		KeyTemplate:          DefaultKeyTemplate,
+		PartSize:             manager.DefaultUploadPartSize,
+		Concurrency:          manager.DefaultUploadConcurrency,
+		UploadTimeout:        defaultS3UploadTimeout,
+		CircuitThreshold:     5,
+		CircuitCooldown:      time.Minute,
+		FailedUploadDir:      DefaultFailedUploadDir,
+		FailedUploadMaxBytes: defaultFailedUploadMaxBytes,
+		DedupWindow:          24 * time.Hour,
+		DedupMaxEntries:      1000,
+		PresignExpiry:        defaultPresignExpiry,
+	}
+}
+
+// UploadResult describes an object written (or found) in S3
+type UploadResult struct {
+	Key string
+	// VersionID is set when the bucket has versioning enabled
+	VersionID string
+	// Skipped is true when an existing object was reused instead of uploading
+	Skipped bool
+	// Size and hex SHA-256 of the uploaded bytes; unset when Skipped
+	Size   int64
+	SHA256 string
+}
+
 // S3Uploader handles uploading videos to S3
 type S3Uploader struct {
-	client   *s3.Client
-	uploader *manager.Uploader
-	bucket   string
+	client              s3API
+	uploader            s3UploadAPI
+	conditionalUploader s3UploadAPI  // uploads with If-None-Match: * (DuplicateKeySkip)
+	presigner           s3PresignAPI // nil unless created by NewS3Uploader
+	partSize            int64        // uploads of at least this size go multipart
+	bucket              string
+	opts                S3UploaderOptions
+
+	// Shared by all uploads; nil when disabled
+	breaker *utils.CircuitBreaker
+	dedup   *DedupIndex
+
+	// Set once the bucket has rejected a conditional write as unsupported
+	conditionalUnsupported atomic.Bool
+
+	// Failed-upload files currently being retried
+	retryMu  sync.Mutex
+	retrying map[string]bool
 }
 
 // NewS3Uploader creates a new S3 uploader
-func NewS3Uploader(ctx context.Context, awsRegion, bucket string) (*S3Uploader, error) {
+func NewS3Uploader(ctx context.Context, awsRegion, bucket string, opts S3UploaderOptions) (*S3Uploader, error) {
 	// Load AWS SDK config (uses IAM role credentials from ~/.aws/credentials)
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(awsRegion),
@@ -44,32 +173,369 @@ func NewS3Uploader(ctx context.Context, awsRegion, bucket string) (*S3Uploader,
 		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
-	uploader := manager.NewUploader(client)
+	if cfg.BaseEndpoint != nil {
+		slog.Info("Using custom S3 endpoint", "endpoint", *cfg.BaseEndpoint)
+	}
+	client := s3.NewFromConfig(cfg, s3PathStyle(cfg))
+
+	if opts.AutodetectRegion {
+		bucketRegion, err := detectBucketRegion(ctx, client, bucket)
+		if err != nil {
+			return nil, err
+		}
+		if bucketRegion != awsRegion {
+			slog.Warn("Bucket is not in the configured region; using the bucket's region for S3",
+				"bucket", bucket, "bucket_region", bucketRegion, "configured_region", awsRegion)
+			cfg.Region = bucketRegion
+			client = s3.NewFromConfig(cfg, s3PathStyle(cfg))
+		} else {
+			slog.Info("Detected bucket region", "bucket", bucket, "region", bucketRegion)
+		}
+	}
+
+	configure := func(u *manager.Uploader) {
+		if opts.PartSize > 0 {
+			u.PartSize = opts.PartSize
+		}
+		if opts.Concurrency > 0 {
+			u.Concurrency = opts.Concurrency
+		}
+	}
+	uploader := manager.NewUploader(client, configure)
+
+	s3Uploader := newS3Uploader(client, uploader, uploader.PartSize, bucket, opts)
+	s3Uploader.conditionalUploader = manager.NewUploader(conditionalClient{client}, configure)
+	s3Uploader.presigner = s3.NewPresignClient(client)
+	if opts.DedupIndexPath != "" {
+		if s3Uploader.dedup, err = LoadDedupIndex(opts.DedupIndexPath, opts.DedupWindow, opts.DedupMaxEntries); err != nil {
+			return nil, err
+		}
+	}
+	return s3Uploader, nil
+}
+
+// s3PathStyle addresses buckets path-style when a custom endpoint is set
+// (AWS_ENDPOINT_URL): LocalStack and MinIO don't resolve bucket subdomains
+func s3PathStyle(cfg aws.Config) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.UsePathStyle = cfg.BaseEndpoint != nil
+	}
+}
+
+// newS3Uploader creates an S3 uploader on the given clients
+func newS3Uploader(client s3API, uploader s3UploadAPI, partSize int64, bucket string, opts S3UploaderOptions) *S3Uploader {
+	if opts.UploadTimeout <= 0 {
+		opts.UploadTimeout = defaultS3UploadTimeout
+	}
+	if opts.FailedUploadDir == "" {
+		opts.FailedUploadDir = DefaultFailedUploadDir
+	}
+	if opts.FailedUploadMaxBytes <= 0 {
+		opts.FailedUploadMaxBytes = defaultFailedUploadMaxBytes
+	}
+
+	var breaker *utils.CircuitBreaker
+	if opts.CircuitThreshold > 0 {
+		breaker = utils.NewCircuitBreaker("S3 upload", opts.CircuitThreshold, opts.CircuitCooldown)
+	}
 
 	return &S3Uploader{
 		client:   client,
 		uploader: uploader,
-		bucket:   bucket,
-	}, nil
+		// Fakes don't evaluate conditions
+		conditionalUploader: uploader,
+		partSize:            partSize,
+		bucket:              bucket,
+		opts:                opts,
+		breaker:             breaker,
+		retrying:            make(map[string]bool),
+	}
 }
 
-// Upload uploads a video file to S3 with retry logic and verification
-// Returns the S3 key on success, or error if upload/verification fails
-func (u *S3Uploader) Upload(ctx context.Context, filePath string) (string, error) {
-	filename := filepath.Base(filePath)
-	key := "videos/" + filename
+// detectBucketRegion looks up the region a bucket lives in
+func detectBucketRegion(ctx context.Context, client manager.HeadBucketAPIClient, bucket string) (string, error) {
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("S3 bucket region lookup %s", bucket))
+	retryConfig.IsRetryable = IsRetryableError
 
-	log.Printf("Uploading %s to s3://%s/%s", filePath, u.bucket, key)
+	var region string
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		var err error
+		region, err = manager.GetBucketRegion(ctx, client, bucket)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to detect region of bucket %s: %w", bucket, err)
+	}
+	return region, nil
+}
 
-	// Create context with timeout for S3 operations
-	uploadCtx, cancel := context.WithTimeout(ctx, s3UploadTimeout)
+// Upload uploads a video file to S3 with retry logic and verification.
+// metadata is stored as S3 user metadata (x-amz-meta-*) and may be nil.
+// Returns the uploaded object on success, or error if upload/verification fails
+func (u *S3Uploader) Upload(ctx context.Context, filePath string, metadata map[string]string) (UploadResult, error) {
+	return u.UploadConverted(ctx, filePath, filePath, metadata)
+}
+
+// UploadConverted uploads filePath, a conversion of the video at sourcePath, e.g.
+// a transcode. A video that fails verification, or meets an open circuit breaker,
+// is moved to the failed upload directory by its sourcePath, so the recorded clip
+// is what gets retried.
+func (u *S3Uploader) UploadConverted(ctx context.Context, sourcePath, filePath string, metadata map[string]string) (UploadResult, error) {
+	filename := filepath.Base(filePath)
+	key := u.opts.KeyPrefix + u.opts.KeyTemplate.render(filePath, metadata)
+	if SanitizeFilename(filename) != filename {
+		slog.Info("Sanitized filename for S3 key", "filename", filename, "s3_key", key)
+	}
+
+	// Create context with timeout for S3 operations, scaled to the file's size
+	uploadCtx, cancel := context.WithTimeout(ctx, u.UploadTimeout(filePath))
 	defer cancel()
 
+	start := time.Now()
+
+	if u.opts.DuplicateKeyPolicy == DuplicateKeySkip {
+		if existing, found := u.findExisting(uploadCtx, key); found {
+			slog.Info("Object already exists, skipping upload", "filename", filename, "s3_key", key)
+			return existing, nil
+		}
+	}
+
+	// Hash locally so S3 can reject corrupted bytes and verification can compare digests
+	digest, err := fileDigest(filePath)
+	if err != nil {
+		metrics.Default.Inc(metrics.UploadsFailed)
+		return UploadResult{}, fmt.Errorf("failed to hash %s: %w", filePath, err)
+	}
+
+	if existing, found := u.dedup.Lookup(digest.hex); found {
+		slog.Info("Identical clip already uploaded, reusing its key", "filename", filename,
+			"s3_key", existing.Key, "uploaded_at", existing.UploadedAt)
+		metrics.Default.Inc(metrics.UploadsDeduplicated)
+		return UploadResult{Key: existing.Key, VersionID: existing.VersionID, Skipped: true}, nil
+	}
+
+	contentType := u.contentType(filePath)
+	slog.Info("Uploading video", "filename", filename, "s3_key", key, "bucket", u.bucket,
+		"size_bytes", digest.size, "content_type", contentType)
+
 	// Retry configuration for S3 upload
 	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("S3 upload %s", filename))
+	retryConfig.IsRetryable = IsRetryableError
+	retryConfig.Breaker = u.breaker
 
 	// Upload with retry
+	result := UploadResult{Key: key, Size: digest.size, SHA256: digest.hex}
+	var alreadyExists bool
+	err = utils.RetryWithBackoff(uploadCtx, retryConfig, func() error {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer file.Close()
+
+		var body io.Reader = file
+		if progress := u.progressFor(filename, key, digest.size); progress != nil {
+			body = newProgressReader(file, digest.size, progress)
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:       aws.String(u.bucket),
+			Key:          aws.String(key),
+			Body:         body,
+			ContentType:  aws.String(contentType),
+			Metadata:     metadata,
+			StorageClass: types.StorageClass(u.opts.StorageClass),
+		}
+		u.applyEncryption(input)
+		if digest.size < u.partSize {
+			// Single PutObject: S3 checks the whole object against our digest
+			input.ChecksumSHA256 = aws.String(digest.sha256)
+		} else {
+			// Multipart: S3 checks each part, and the object gets a composite checksum
+			input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+		}
+
+		conditional := u.conditionalPut()
+		uploader := u.uploader
+		if conditional {
+			uploader = u.conditionalUploader
+		}
+		output, err := uploader.Upload(uploadCtx, input)
+		if err != nil && conditional {
+			if isPreconditionFailed(err) {
+				// Not a failure: the key was created since the existence check
+				alreadyExists = true
+				return nil
+			}
+			if isConditionalUnsupported(err) {
+				// The next attempt goes without the condition
+				u.conditionalUnsupported.Store(true)
+				slog.Warn("Bucket doesn't support conditional writes, relying on the existence check", "bucket", u.bucket, "error", err)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		result.VersionID = aws.ToString(output.VersionID)
+		return nil
+	})
+
+	if errors.Is(err, utils.ErrCircuitOpen) {
+		// S3 looks down: quarantine the video for the failed-upload retries
+		// instead of leaving it to be picked up again
+		if moveErr := u.MoveToFailedDir(sourcePath); moveErr != nil {
+			slog.Error("Failed to move file to failed directory", "filename", filename, "error", moveErr)
+		}
+		metrics.Default.Inc(metrics.UploadsFailed)
+		return UploadResult{}, fmt.Errorf("S3 upload skipped: %w", err)
+	}
+	if err != nil {
+		metrics.Default.Inc(metrics.UploadsFailed)
+		return UploadResult{}, fmt.Errorf("failed to upload to S3 after retries: %w", err)
+	}
+	if alreadyExists {
+		slog.Info("Object already exists, skipping upload", "filename", filename, "s3_key", key, "conditional", true)
+		if existing, found := u.findExisting(uploadCtx, key); found {
+			return existing, nil
+		}
+		return UploadResult{Key: key, Skipped: true}, nil
+	}
+
+	slog.Info("Uploaded video", "filename", filename, "s3_key", key, "version_id", result.VersionID,
+		"duration_ms", time.Since(start).Milliseconds())
+
+	// Verify upload with HeadObject
+	if err := u.verifyUpload(uploadCtx, key, digest); err != nil {
+		slog.Error("Upload verification failed", "filename", filename, "s3_key", key, "error", err)
+		// Move file to failed upload directory
+		if moveErr := u.MoveToFailedDir(sourcePath); moveErr != nil {
+			slog.Error("Failed to move file to failed directory", "filename", filename, "error", moveErr)
+		}
+		metrics.Default.Inc(metrics.UploadsFailed)
+		return UploadResult{}, fmt.Errorf("upload verification failed: %w", err)
+	}
+
+	slog.Info("Upload verified", "s3_key", key, "duration_ms", time.Since(start).Milliseconds())
+	if err := u.dedup.Record(digest.hex, result); err != nil {
+		slog.Warn("Failed to record upload in dedup index", "s3_key", key, "error", err)
+	}
+	metrics.Default.Inc(metrics.UploadsSucceeded)
+	metrics.UploadDuration.Observe(time.Since(start).Seconds())
+	return result, nil
+}
+
+// applyEncryption sets SSE-KMS on an upload when a KMS key is configured
+func (u *S3Uploader) applyEncryption(input *s3.PutObjectInput) {
+	if u.opts.SSEKMSKeyID == "" {
+		return
+	}
+	input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+	input.SSEKMSKeyId = aws.String(u.opts.SSEKMSKeyID)
+}
+
+// progressFor returns the progress reporter for an upload attempt, or nil if
+// its progress isn't reported
+func (u *S3Uploader) progressFor(filename, key string, size int64) ProgressFunc {
+	if u.opts.Progress != nil {
+		return u.opts.Progress
+	}
+	if size >= u.partSize {
+		return logProgress(filename, key)
+	}
+	return nil
+}
+
+// UploadTimeout returns the deadline for uploading a file: the base timeout plus
+// the time the file takes at the minimum expected throughput
+func (u *S3Uploader) UploadTimeout(filePath string) time.Duration {
+	timeout := u.opts.UploadTimeout
+	if u.opts.MinThroughput <= 0 {
+		return timeout
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		// The upload itself will report the problem
+		return timeout
+	}
+	return timeout + time.Duration(float64(stat.Size())/float64(u.opts.MinThroughput)*float64(time.Second))
+}
+
+// CheckHealth verifies the bucket exists and is reachable with the current credentials
+func (u *S3Uploader) CheckHealth(ctx context.Context) error {
+	if _, err := u.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(u.bucket)}); err != nil {
+		return fmt.Errorf("bucket %s is not accessible: %w", u.bucket, err)
+	}
+	return nil
+}
+
+// CheckWrite verifies the credentials can write to the bucket, which HeadBucket
+// doesn't, by putting a tiny object under the key prefix and deleting it again.
+// Failing to delete it is only logged: the role may not be granted s3:DeleteObject.
+func (u *S3Uploader) CheckWrite(ctx context.Context) error {
+	key := fmt.Sprintf("%s.eyeseeyou-write-check-%d", u.opts.KeyPrefix, time.Now().UnixNano())
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(key),
+		Body:        strings.NewReader("eyeseeyou write check"),
+		ContentType: aws.String("text/plain"),
+	}
+	u.applyEncryption(input)
+
+	if _, err := u.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("cannot write to bucket %s (is s3:PutObject allowed?): %w", u.bucket, err)
+	}
+	if _, err := u.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(u.bucket), Key: aws.String(key)}); err != nil {
+		slog.Warn("Failed to delete write check object", "bucket", u.bucket, "s3_key", key, "error", err)
+	}
+	return nil
+}
+
+// DeleteObject deletes an object. When versionID is set that version is removed
+// permanently; otherwise a versioned bucket keeps the object behind a delete marker.
+func (u *S3Uploader) DeleteObject(ctx context.Context, key, versionID string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("S3 delete %s", key))
+	retryConfig.IsRetryable = IsRetryableError
+
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		_, err := u.client.DeleteObject(ctx, input)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// NewManifestWriter creates a manifest writer for the uploader's bucket
+func (u *S3Uploader) NewManifestWriter(prefix string) *ManifestWriter {
+	return NewManifestWriter(u.client, u.bucket, prefix)
+}
+
+// NewFilenameIndex creates a filename index in the uploader's bucket
+func (u *S3Uploader) NewFilenameIndex(prefix string) *FilenameIndex {
+	return NewFilenameIndex(u.client, u.bucket, prefix)
+}
+
+// UploadFile uploads a supporting file (e.g. a preview) to an explicit key with retry logic.
+// Unlike Upload it doesn't verify the object or quarantine the file on failure.
+func (u *S3Uploader) UploadFile(ctx context.Context, filePath, key, contentType string) error {
+	uploadCtx, cancel := context.WithTimeout(ctx, u.UploadTimeout(filePath))
+	defer cancel()
+
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("S3 upload %s", key))
+	retryConfig.IsRetryable = IsRetryableError
+	retryConfig.Breaker = u.breaker
+
 	err := utils.RetryWithBackoff(uploadCtx, retryConfig, func() error {
 		file, err := os.Open(filePath)
 		if err != nil {
@@ -77,125 +543,191 @@ func (u *S3Uploader) Upload(ctx context.Context, filePath string) (string, error
 		}
 		defer file.Close()
 
-		_, err = u.uploader.Upload(uploadCtx, &s3.PutObjectInput{
+		input := &s3.PutObjectInput{
 			Bucket:      aws.String(u.bucket),
 			Key:         aws.String(key),
 			Body:        file,
-			ContentType: aws.String("video/mp4"),
-		})
-
+			ContentType: aws.String(contentType),
+		}
+		u.applyEncryption(input)
+		_, err = u.uploader.Upload(uploadCtx, input)
 		return err
 	})
-
 	if err != nil {
-		return "", fmt.Errorf("failed to upload to S3 after retries: %w", err)
+		return fmt.Errorf("failed to upload %s to S3 after retries: %w", key, err)
 	}
 
-	log.Printf("Successfully uploaded %s to S3", key)
+	slog.Info("Uploaded file", "s3_key", key)
+	return nil
+}
 
-	// Verify upload with HeadObject
-	if err := u.verifyUpload(uploadCtx, key); err != nil {
-		log.Printf("ERROR: Upload verification failed for %s: %v", key, err)
-		// Move file to failed upload directory
-		if moveErr := u.moveToFailedDir(filePath); moveErr != nil {
-			log.Printf("ERROR: Failed to move file to failed directory: %v", moveErr)
+// findExisting looks up the current version of a key, reporting whether it exists.
+// Lookup errors other than not-found are logged and treated as absent so the upload proceeds.
+func (u *S3Uploader) findExisting(ctx context.Context, key string) (UploadResult, bool) {
+	output, err := u.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if !errors.As(err, &notFound) {
+			slog.Warn("Failed to check for existing object, uploading anyway", "s3_key", key, "error", err)
 		}
-		return "", fmt.Errorf("upload verification failed: %w", err)
+		return UploadResult{}, false
 	}
 
-	log.Printf("Upload verification successful for %s", key)
-	return key, nil
+	return UploadResult{
+		Key:       key,
+		VersionID: aws.ToString(output.VersionId),
+		Skipped:   true,
+	}, true
 }
 
-// verifyUpload checks if the uploaded file exists in S3 using HeadObject
-func (u *S3Uploader) verifyUpload(ctx context.Context, key string) error {
+// verifyUpload checks the uploaded object with HeadObject: its size must match the
+// local file and, for single-part uploads, its SHA-256 checksum must match the local digest
+func (u *S3Uploader) verifyUpload(ctx context.Context, key string, digest localDigest) error {
 	retryConfig := utils.RetryConfig{
 		MaxRetries:    2, // Quick verification, only 2 retries
 		InitialDelay:  500 * time.Millisecond,
 		MaxDelay:      2 * time.Second,
 		OperationName: fmt.Sprintf("S3 verify %s", key),
+		Jitter:        true,
+		IsRetryable: func(err error) bool {
+			// A mismatch won't fix itself
+			return !errors.Is(err, errChecksumMismatch) && IsRetryableError(err)
+		},
 	}
 
 	return utils.RetryWithBackoff(ctx, retryConfig, func() error {
-		_, err := u.client.HeadObject(ctx, &s3.HeadObjectInput{
-			Bucket: aws.String(u.bucket),
-			Key:    aws.String(key),
+		output, err := u.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:       aws.String(u.bucket),
+			Key:          aws.String(key),
+			ChecksumMode: types.ChecksumModeEnabled,
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		return digest.verify(aws.ToInt64(output.ContentLength), aws.ToString(output.ChecksumSHA256))
 	})
 }
 
-// moveToFailedDir moves a file to the failed upload directory
-// If directory exceeds size limit, it deletes all files before moving
-func (u *S3Uploader) moveToFailedDir(filePath string) error {
+// MoveToFailedDir moves a file to the failed upload directory
+// If the directory would exceed its size limit, the oldest files are deleted first
+func (u *S3Uploader) MoveToFailedDir(filePath string) error {
+	// A retried file is already there; leave it under its current name
+	failedDir := u.opts.FailedUploadDir
+	if filepath.Dir(filePath) == filepath.Clean(failedDir) {
+		return nil
+	}
+
 	// Ensure failed upload directory exists
-	if err := os.MkdirAll(failedUploadDir, 0755); err != nil {
+	if err := os.MkdirAll(failedDir, 0755); err != nil {
 		return fmt.Errorf("failed to create failed upload directory: %w", err)
 	}
 
-	// Check directory size
-	dirSize, err := getDirSize(failedUploadDir)
-	if err != nil {
-		log.Printf("WARNING: Failed to get directory size, proceeding anyway: %v", err)
-	} else if dirSize >= maxFailedUploadDirSize {
-		log.Printf("Failed upload directory exceeds %d bytes, clearing it", maxFailedUploadDirSize)
-		if err := clearDirectory(failedUploadDir); err != nil {
-			return fmt.Errorf("failed to clear directory: %w", err)
-		}
+	// Make room for the file by evicting the oldest failures, keeping the recent
+	// ones, which are the likeliest to be retried successfully
+	var incoming int64
+	if info, err := os.Stat(filePath); err == nil {
+		incoming = info.Size()
+	}
+	if err := u.evictFailedUploads(incoming); err != nil {
+		slog.Warn("Failed to make room in failed upload directory, proceeding anyway", "dir", failedDir, "error", err)
 	}
 
-	// Move file to failed directory
-	filename := filepath.Base(filePath)
-	destPath := filepath.Join(failedUploadDir, filename)
+	// Move file to failed directory, without overwriting an earlier clip of the same name
+	destPath := uniqueFailedPath(failedDir, filepath.Base(filePath), time.Now())
 
-	log.Printf("Moving failed upload %s to %s", filePath, destPath)
+	slog.Info("Moving failed upload", "path", filePath, "dest", destPath)
 
 	if err := os.Rename(filePath, destPath); err != nil {
 		return fmt.Errorf("failed to move file: %w", err)
 	}
 
-	log.Printf("File moved to failed upload directory: %s", destPath)
+	slog.Info("File moved to failed upload directory", "dest", destPath)
 	return nil
 }
 
-// getDirSize calculates the total size of all files in a directory
-func getDirSize(dirPath string) (int64, error) {
-	var totalSize int64
+// uniqueFailedPath returns a path in the failed upload directory dir for filename.
+// If the name is taken, a timestamp is added before the extension
+// (clip.mp4 -> clip.20240612T103000.mp4), then a counter if that is taken too.
+func uniqueFailedPath(dir, filename string, now time.Time) string {
+	destPath := filepath.Join(dir, filename)
+	if !pathExists(destPath) {
+		return destPath
+	}
 
-	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if !d.IsDir() {
-			info, err := d.Info()
-			if err != nil {
-				return err
-			}
-			totalSize += info.Size()
-		}
-		return nil
-	})
+	ext := filepath.Ext(filename)
+	stamped := strings.TrimSuffix(filename, ext) + "." + now.UTC().Format("20060102T150405")
+	destPath = filepath.Join(dir, stamped+ext)
+	for i := 1; pathExists(destPath); i++ {
+		destPath = filepath.Join(dir, fmt.Sprintf("%s.%d%s", stamped, i, ext))
+	}
+	return destPath
+}
 
-	return totalSize, err
+// pathExists reports whether anything exists at path
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
 }
 
-// clearDirectory removes all files from a directory
-func clearDirectory(dirPath string) error {
-	entries, err := os.ReadDir(dirPath)
+// evictFailedUploads deletes the oldest files (by modification time) from the failed
+// upload directory until there is room for incoming more bytes under the size cap.
+// Files currently being retried are left alone.
+func (u *S3Uploader) evictFailedUploads(incoming int64) error {
+	dir := u.opts.FailedUploadDir
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return err
 	}
 
+	type failedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []failedFile
+	var total int64
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			filePath := filepath.Join(dirPath, entry.Name())
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("WARNING: Failed to delete %s: %v", filePath, err)
-			} else {
-				log.Printf("Deleted: %s", filePath)
-			}
+		if !entry.Type().IsRegular() {
+			continue
 		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+		total += info.Size()
+		files = append(files, failedFile{path: filepath.Join(dir, entry.Name()), size: info.Size(), modTime: info.ModTime()})
+	}
+	if total+incoming <= u.opts.FailedUploadMaxBytes {
+		return nil
 	}
 
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	u.retryMu.Lock()
+	defer u.retryMu.Unlock()
+	for _, file := range files {
+		if total+incoming <= u.opts.FailedUploadMaxBytes {
+			break
+		}
+		if u.retrying[file.path] {
+			continue
+		}
+		if err := os.Remove(file.path); err != nil {
+			slog.Warn("Failed to evict failed upload", "path", file.path, "error", err)
+			continue
+		}
+		total -= file.size
+		slog.Warn("Evicted oldest failed upload to make room", "path", file.path, "size_bytes", file.size,
+			"modified", file.modTime.UTC().Format(time.RFC3339))
+	}
+
+	if total+incoming > u.opts.FailedUploadMaxBytes {
+		slog.Warn("Failed upload directory is still over its size limit after eviction", "dir", dir,
+			"size_bytes", total, "incoming_bytes", incoming, "limit_bytes", u.opts.FailedUploadMaxBytes)
+	}
 	return nil
 }
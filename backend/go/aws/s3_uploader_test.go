@@ -0,0 +1,399 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// signingRegion returns the region a request was signed for, from its SigV4 credential scope
+func signingRegion(req fakes3.Request) string {
+	_, scope, _ := strings.Cut(req.Header.Get("Authorization"), "Credential=")
+	parts := strings.Split(scope, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+func TestAutodetectRegion(t *testing.T) {
+	tests := []struct {
+		name         string
+		bucketRegion string
+		autodetect   bool
+		wantRegion   string
+	}{
+		{"bucket elsewhere", "ap-southeast-2", true, "ap-southeast-2"},
+		{"bucket in configured region", "us-east-1", true, "us-east-1"},
+		{"detection off", "ap-southeast-2", false, "us-east-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakes3.New(t)
+			server.Region = tt.bucketRegion
+			opts := DefaultS3UploaderOptions()
+			opts.AutodetectRegion = tt.autodetect
+			uploader := newTestUploader(t, server, opts)
+
+			if _, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", 1024), nil); err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			for _, req := range server.Requests() {
+				if req.Method != http.MethodPut {
+					continue
+				}
+				if got := signingRegion(req); got != tt.wantRegion {
+					t.Errorf("upload signed for %q, want %q", got, tt.wantRegion)
+				}
+			}
+		})
+	}
+}
+
+func TestAutodetectRegionLookupFails(t *testing.T) {
+	// Give up after the first lookup
+	utils.SetDefaultMaxElapsed(time.Millisecond)
+	t.Cleanup(func() { utils.SetDefaultMaxElapsed(0) })
+
+	server := fakes3.New(t)
+	server.SetEnv(t)
+	// No region header, as when the bucket doesn't exist
+	server.Hook = func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusNotFound)
+		return true
+	}
+	opts := DefaultS3UploaderOptions()
+	opts.AutodetectRegion = true
+	opts.FailedUploadDir = t.TempDir()
+
+	_, err := NewS3Uploader(context.Background(), "us-east-1", testBucket, opts)
+	if err == nil || !strings.Contains(err.Error(), "failed to detect region of bucket "+testBucket) {
+		t.Errorf("NewS3Uploader() = %v, want a region detection error", err)
+	}
+}
+
+func TestUploadCapturesVersionID(t *testing.T) {
+	tests := []struct {
+		name        string
+		versioning  bool
+		existing    bool
+		policy      string
+		size        int
+		wantVersion string
+		wantSkipped bool
+	}{
+		{"single part", true, false, DuplicateKeyOverwrite, 1024, "v1", false},
+		{"multipart", true, false, DuplicateKeyOverwrite, int(manager.MinUploadPartSize) + 1024, "v1", false},
+		{"unversioned bucket", false, false, DuplicateKeyOverwrite, 1024, "", false},
+		{"overwrite existing", true, true, DuplicateKeyOverwrite, 1024, "v2", false},
+		{"skip existing", true, true, DuplicateKeySkip, 1024, "v1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakes3.New(t)
+			server.Versioning = tt.versioning
+			if tt.existing {
+				server.Put(testBucket, "videos/clip.mp4", []byte("earlier clip"))
+			}
+			opts := DefaultS3UploaderOptions()
+			opts.DuplicateKeyPolicy = tt.policy
+			opts.PartSize = manager.MinUploadPartSize
+			uploader := newTestUploader(t, server, opts)
+
+			result, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", tt.size), nil)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if result.VersionID != tt.wantVersion || result.Skipped != tt.wantSkipped {
+				t.Errorf("result version %q, skipped %v; want %q, %v", result.VersionID, result.Skipped, tt.wantVersion, tt.wantSkipped)
+			}
+			if stored := server.Object(testBucket, "videos/clip.mp4"); stored.VersionID != tt.wantVersion {
+				t.Errorf("stored version %q, result %q", stored.VersionID, result.VersionID)
+			}
+
+			// The version reaches the notification, so consumers fetch that exact object
+			client := &fakeSNS{}
+			if err := newTestPublisher(client, &fakeSigner{}, DefaultSNSPublisherOptions()).Publish(context.Background(), result, "cdn.example.com", DefaultDetectionEvent()); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if got := decodeNotification(t, client.messages()[0]).VersionID; got != tt.wantVersion {
+				t.Errorf("notification version_id = %q, want %q", got, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestKeyPrefixReachesSignedURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		wantKey string
+	}{
+		{"default", "videos/", "videos/clip.mp4"},
+		{"environment", "prod/videos/", "prod/videos/clip.mp4"},
+		{"nested environment", "staging/eu/videos/", "staging/eu/videos/clip.mp4"},
+		{"empty", "", "clip.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, _ := newTestSigner(t)
+			server := fakes3.New(t)
+			opts := DefaultS3UploaderOptions()
+			opts.KeyPrefix = tt.prefix
+			uploader := newTestUploader(t, server, opts)
+
+			result, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", 1024), nil)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if result.Key != tt.wantKey {
+				t.Fatalf("key = %q, want %q", result.Key, tt.wantKey)
+			}
+			if server.Object(testBucket, tt.wantKey) == nil {
+				t.Fatalf("nothing stored at %s; have %v", tt.wantKey, server.Keys(testBucket))
+			}
+
+			client := &fakeSNS{}
+			publisher := newTestPublisher(client, signer, DefaultSNSPublisherOptions())
+			if err := publisher.Publish(context.Background(), result, "cdn.example.com", DetectionEvent{}); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			notification := decodeNotification(t, client.messages()[0])
+			signedURL, err := url.Parse(notification.CloudFrontURL)
+			if err != nil {
+				t.Fatalf("parse signed URL %q: %v", notification.CloudFrontURL, err)
+			}
+			if signedURL.Path != "/"+tt.wantKey {
+				t.Errorf("signed URL path = %s, want /%s", signedURL.Path, tt.wantKey)
+			}
+			if !signedURL.Query().Has("Signature") {
+				t.Errorf("URL is not signed: %s", notification.CloudFrontURL)
+			}
+		})
+	}
+}
+
+// fakeS3Client stores uploads in memory. The first uploadFailures uploads fail
+// with uploadErr. HeadObject fails with headErr if set, and otherwise reports
+// the stored object, altered by tamper if set.
+type fakeS3Client struct {
+	uploadFailures int
+	uploadErr      error
+	headErr        error
+	tamper         func(output *s3.HeadObjectOutput)
+
+	mu      sync.Mutex
+	uploads int
+	heads   int
+	objects map[string]*s3.HeadObjectOutput
+}
+
+func (f *fakeS3Client) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploads++
+	if f.uploads <= f.uploadFailures {
+		return nil, f.uploadErr
+	}
+
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(body)
+	if f.objects == nil {
+		f.objects = make(map[string]*s3.HeadObjectOutput)
+	}
+	f.objects[aws.ToString(input.Key)] = &s3.HeadObjectOutput{
+		ContentLength:  aws.Int64(int64(len(body))),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(digest[:])),
+		VersionId:      aws.String(fmt.Sprintf("v%d", f.uploads)),
+	}
+	return &manager.UploadOutput{Key: input.Key, VersionID: aws.String(fmt.Sprintf("v%d", f.uploads))}, nil
+}
+
+func (f *fakeS3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heads++
+	if f.headErr != nil {
+		return nil, f.headErr
+	}
+	object, ok := f.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	output := *object
+	if f.tamper != nil {
+		f.tamper(&output)
+	}
+	return &output, nil
+}
+
+func (f *fakeS3Client) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (f *fakeS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, &types.NoSuchKey{}
+}
+
+func (f *fakeS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestUploadWithFakeClient(t *testing.T) {
+	denied := apiError("AccessDenied", http.StatusForbidden)
+	tests := []struct {
+		name       string
+		client     *fakeS3Client
+		wantErr    error
+		wantMoved  bool
+		wantHeads  bool
+		wantFailed int64
+	}{
+		{name: "success", client: &fakeS3Client{}, wantHeads: true},
+		{name: "checksum mismatch", client: &fakeS3Client{tamper: func(output *s3.HeadObjectOutput) {
+			output.ChecksumSHA256 = aws.String("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+		}}, wantErr: errChecksumMismatch, wantMoved: true, wantHeads: true, wantFailed: 1},
+		{name: "size mismatch", client: &fakeS3Client{tamper: func(output *s3.HeadObjectOutput) {
+			output.ContentLength = aws.Int64(aws.ToInt64(output.ContentLength) - 1)
+		}}, wantErr: errChecksumMismatch, wantMoved: true, wantHeads: true, wantFailed: 1},
+		{name: "verification denied", client: &fakeS3Client{headErr: denied},
+			wantErr: denied, wantMoved: true, wantHeads: true, wantFailed: 1},
+		{name: "upload denied", client: &fakeS3Client{uploadFailures: 1, uploadErr: denied},
+			wantErr: denied, wantFailed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultS3UploaderOptions()
+			opts.FailedUploadDir = t.TempDir()
+			uploader := newS3Uploader(tt.client, tt.client, manager.DefaultUploadPartSize, testBucket, opts)
+			path := writeTestFile(t, "clip.mp4", 1024)
+			failedBefore := metrics.Default.Counter(metrics.UploadsFailed).Value()
+
+			result, err := uploader.Upload(context.Background(), path, nil)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Upload: %v", err)
+				}
+				if result.Key != "videos/clip.mp4" || result.VersionID != "v1" || result.Size != 1024 {
+					t.Errorf("Upload() = %+v", result)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upload() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := metrics.Default.Counter(metrics.UploadsFailed).Value() - failedBefore; got != tt.wantFailed {
+				t.Errorf("%s changed by %d, want %d", metrics.UploadsFailed, got, tt.wantFailed)
+			}
+			if got := tt.client.heads > 0; got != tt.wantHeads {
+				t.Errorf("verified = %v, want %v", got, tt.wantHeads)
+			}
+
+			_, statErr := os.Stat(path)
+			if stayed := statErr == nil; stayed == tt.wantMoved {
+				t.Errorf("file left in place = %v, want %v", stayed, !tt.wantMoved)
+			}
+			_, statErr = os.Stat(filepath.Join(opts.FailedUploadDir, "clip.mp4"))
+			if moved := statErr == nil; moved != tt.wantMoved {
+				t.Errorf("file moved to the failed upload directory = %v, want %v", moved, tt.wantMoved)
+			}
+		})
+	}
+}
+
+func TestUploadKMSEncryption(t *testing.T) {
+	const keyARN = "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	uploads := []struct {
+		name   string
+		upload func(t *testing.T, uploader *S3Uploader) error
+	}{
+		{"single part", func(t *testing.T, uploader *S3Uploader) error {
+			_, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", 1024), nil)
+			return err
+		}},
+		{"multipart", func(t *testing.T, uploader *S3Uploader) error {
+			_, err := uploader.Upload(context.Background(), writeTestFile(t, "clip.mp4", int(manager.MinUploadPartSize)+1024), nil)
+			return err
+		}},
+		{"supporting file", func(t *testing.T, uploader *S3Uploader) error {
+			return uploader.UploadFile(context.Background(), writeTestFile(t, "clip.jpg", 1024), "thumbnails/clip.jpg", "image/jpeg")
+		}},
+		{"write check", func(t *testing.T, uploader *S3Uploader) error {
+			return uploader.CheckWrite(context.Background())
+		}},
+		{"presigned", func(t *testing.T, uploader *S3Uploader) error {
+			upload, err := uploader.PresignPut(context.Background(), "videos/clip.mp4", "video/mp4", 0)
+			if err != nil {
+				return err
+			}
+			return PutPresigned(context.Background(), nil, upload, writeTestFile(t, "clip.mp4", 1024))
+		}},
+	}
+	for _, kmsKey := range []string{"", "1234abcd-12ab-34cd-56ef-1234567890ab", keyARN} {
+		for _, tt := range uploads {
+			name := tt.name + " with bucket default"
+			if kmsKey != "" {
+				name = tt.name + " with " + kmsKey
+			}
+			t.Run(name, func(t *testing.T) {
+				server := fakes3.New(t)
+				opts := DefaultS3UploaderOptions()
+				opts.PartSize = manager.MinUploadPartSize
+				opts.SSEKMSKeyID = kmsKey
+				uploader := newTestUploader(t, server, opts)
+
+				if err := tt.upload(t, uploader); err != nil {
+					t.Fatalf("upload: %v", err)
+				}
+
+				created := 0
+				for _, req := range server.Requests() {
+					query, _ := url.ParseQuery(req.Query)
+					// Encryption is chosen when an object is created: by PutObject or
+					// CreateMultipartUpload, not the parts that follow
+					if !(req.Method == http.MethodPut && !query.Has("partNumber")) && !(req.Method == http.MethodPost && query.Has("uploads")) {
+						continue
+					}
+					created++
+					wantSSE, wantKey := "", ""
+					if kmsKey != "" {
+						wantSSE, wantKey = "aws:kms", kmsKey
+					}
+					if got := req.Header.Get("X-Amz-Server-Side-Encryption"); got != wantSSE {
+						t.Errorf("%s %s: server-side encryption = %q, want %q", req.Method, req.Key, got, wantSSE)
+					}
+					if got := req.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != wantKey {
+						t.Errorf("%s %s: KMS key = %q, want %q", req.Method, req.Key, got, wantKey)
+					}
+				}
+				if created == 0 {
+					t.Error("no object was created")
+				}
+			})
+		}
+	}
+}
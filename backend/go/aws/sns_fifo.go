@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// Message group for notifications that don't name a camera
+	defaultMessageGroupID = "default"
+
+	// SNS limit on message group ID length
+	maxMessageGroupIDLength = 128
+)
+
+// fifoIDs are the ordering and deduplication IDs FIFO topics require.
+// They are ignored when publishing to a standard topic.
+type fifoIDs struct {
+	// GroupID orders messages: those sharing it are delivered in order
+	GroupID string
+	// DeduplicationID suppresses repeats of a message for SNS's 5-minute window
+	DeduplicationID string
+}
+
+// isFIFOTopic reports whether a topic ARN names a FIFO topic
+func isFIFOTopic(topicARN string) bool {
+	return strings.HasSuffix(topicARN, ".fifo")
+}
+
+// notificationFIFO groups a notification by camera and deduplicates it by
+// S3 key and version, so re-publishing the same upload is suppressed
+func notificationFIFO(notification VideoNotification) fifoIDs {
+	return fifoIDs{
+		GroupID:         messageGroupID(notification.CameraID),
+		DeduplicationID: deduplicationID(notification.S3Key, notification.VersionID),
+	}
+}
+
+// batchFIFO groups a batch by its camera when every notification shares one,
+// and deduplicates it by the set of keys it contains
+func batchFIFO(notifications []VideoNotification) fifoIDs {
+	camera := notifications[0].CameraID
+	parts := make([]string, 0, 2*len(notifications))
+	for _, notification := range notifications {
+		if notification.CameraID != camera {
+			camera = ""
+		}
+		parts = append(parts, notification.S3Key, notification.VersionID)
+	}
+	return fifoIDs{
+		GroupID:         messageGroupID(camera),
+		DeduplicationID: deduplicationID(parts...),
+	}
+}
+
+// messageGroupID turns a camera ID into a valid message group ID: characters
+// SNS doesn't allow become "_", and long IDs are truncated
+func messageGroupID(camera string) string {
+	if camera == "" {
+		return defaultMessageGroupID
+	}
+	id := strings.Map(func(r rune) rune {
+		if r > ' ' && r <= '~' {
+			return r
+		}
+		return '_'
+	}, camera)
+	if len(id) > maxMessageGroupIDLength {
+		id = id[:maxMessageGroupIDLength]
+	}
+	return id
+}
+
+// deduplicationID hashes its parts into a fixed-length deduplication ID
+func deduplicationID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
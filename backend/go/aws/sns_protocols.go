@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SNS subscription protocols that can be sent their own message. Protocols not
+// listed receive the "default" message, the full notification JSON.
+const (
+	ProtocolSMS       = "sms"
+	ProtocolEmail     = "email"
+	ProtocolEmailJSON = "email-json"
+	ProtocolHTTP      = "http"
+	ProtocolHTTPS     = "https"
+	ProtocolSQS       = "sqs"
+	ProtocolLambda    = "lambda"
+)
+
+// ParseMessageProtocols normalises a list of subscription protocol names
+func ParseMessageProtocols(names []string) ([]string, error) {
+	var protocols []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		protocol := strings.ToLower(strings.TrimSpace(name))
+		switch protocol {
+		case ProtocolSMS, ProtocolEmail, ProtocolEmailJSON, ProtocolHTTP, ProtocolHTTPS, ProtocolSQS, ProtocolLambda:
+		default:
+			return nil, fmt.Errorf("unknown SNS protocol %q (supported: sms, email, email-json, http, https, sqs, lambda)", name)
+		}
+		if !seen[protocol] {
+			seen[protocol] = true
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols, nil
+}
+
+// protocolMessage builds the message for one subscription protocol: a short
+// line of text for SMS, a readable summary with the clip link above the full
+// JSON for email, and the full JSON message for everything else
+func protocolMessage(protocol, message string, push pushContent) string {
+	switch protocol {
+	case ProtocolSMS:
+		// Signed URLs run to hundreds of characters, too long for a text message
+		return fmt.Sprintf("%s: %s", push.Title, push.Body)
+	case ProtocolEmail:
+		var text strings.Builder
+		fmt.Fprintf(&text, "%s\n\n%s\n\n", push.Title, push.Body)
+		if url := push.Data["cloudfront_url"]; url != "" {
+			fmt.Fprintf(&text, "Watch the clip: %s\n\n", url)
+		}
+		text.WriteString(message)
+		return text.String()
+	default:
+		return message
+	}
+}
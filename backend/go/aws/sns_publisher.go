@@ -4,25 +4,126 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+	"github.com/lachiem1/eyeSeeYou/backend/go/notify"
 	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
 )
 
 const (
 	// SNS operation timeout
 	snsPublishTimeout = 15 * time.Second
+
+	// Default number of times URL signing is retried before giving up
+	defaultSigningRetries = 2
+
+	// EventTypeHumanDetected is the event type for person detections
+	EventTypeHumanDetected = "human_detected"
+
+	// Event type attribute for batches mixing several event types
+	eventTypeMixed = "mixed"
+)
+
+// Signing fallback policies, applied when URL signing fails persistently
+const (
+	// SigningFallbackFail fails the publish when the URL can't be signed
+	SigningFallbackFail = "fail"
+	// SigningFallbackUnsigned publishes the unsigned URL instead
+	SigningFallbackUnsigned = "unsigned"
+)
+
+// Asset kinds, whose URLs are signed with independent expiries
+const (
+	AssetVideo     = "video"
+	AssetThumbnail = "thumbnail"
 )
 
+// URLSigner signs CloudFront URLs
+type URLSigner interface {
+	SignURLWithExpiry(rawURL string, d time.Duration) (string, error)
+}
+
+// SNSPublisherOptions holds optional SNS publisher settings
+type SNSPublisherOptions struct {
+	// SigningRetries is how many times a failed URL signing is retried
+	SigningRetries int
+
+	// SigningFallback is the policy applied when signing still fails after retries
+	SigningFallback string
+
+	// VideoURLTTL and ThumbnailURLTTL are how long signed video and thumbnail
+	// (thumbnail image, preview sprite and VTT) URLs stay valid
+	VideoURLTTL     time.Duration
+	ThumbnailURLTTL time.Duration
+
+	// BatchWindow enables batching: notifications are collected for up to this
+	// long and published as one message. Zero publishes each one immediately.
+	BatchWindow time.Duration
+
+	// BatchMaxCount flushes a batch early once it holds this many notifications
+	BatchMaxCount int
+
+	// BatchMaxBytes flushes a batch early once its encoded notifications reach this size
+	BatchMaxBytes int
+
+	// SanitizeMode controls how subjects and messages are cleaned before publishing
+	SanitizeMode string
+
+	// Bucket is sent as the s3_bucket message attribute so subscribers can filter on it
+	Bucket string
+
+	// Transform reshapes each notification before it is encoded; empty sends it as is
+	Transform notify.Pipeline
+
+	// PushPlatforms adds mobile push payloads (APNS, APNS_SANDBOX, GCM) to each
+	// message using MessageStructure "json"; empty publishes plain messages
+	PushPlatforms []string
+
+	// Protocols sends subscribers on these protocols (e.g. sms, email) their own
+	// form of each message, also using MessageStructure "json"
+	Protocols []string
+
+	// SelfTestTimeout is how long SelfTest waits for its message to arrive
+	SelfTestTimeout time.Duration
+
+	// BackendVersion, if set, is sent as backend_version in each notification
+	BackendVersion string
+}
+
+// DefaultSNSPublisherOptions returns the default SNS publisher options
+func DefaultSNSPublisherOptions() SNSPublisherOptions {
+	return SNSPublisherOptions{
+		SigningRetries:  defaultSigningRetries,
+		SigningFallback: SigningFallbackFail,
+		VideoURLTTL:     DefaultURLExpiration,
+		ThumbnailURLTTL: DefaultURLExpiration,
+		BatchMaxCount:   10,
+		BatchMaxBytes:   200 * 1024, // stay well under the 256 KB SNS message limit
+		SanitizeMode:    SanitizeLenient,
+		SelfTestTimeout: defaultSelfTestTimeout,
+	}
+}
+
 // SNSPublisher handles publishing notifications to SNS
 type SNSPublisher struct {
-	client          *sns.Client
-	topicARN        string
-	cloudFrontSigner *CloudFrontSigner
+	client           snsAPI
+	sqsClient        sqsAPI // only used by SelfTest
+	topicARN         string
+	region           string
+	cloudFrontSigner URLSigner
+	opts             SNSPublisherOptions
+	batcher          *NotificationBatcher
 }
 
 // VideoNotification represents a video detection notification
@@ -31,10 +132,64 @@ type VideoNotification struct {
 	Timestamp     string `json:"timestamp"`
 	EventType     string `json:"event_type"`
 	CloudFrontURL string `json:"cloudfront_url"`
+	Signed        bool   `json:"signed"`
+	VersionID     string `json:"version_id,omitempty"`
+	// CameraID names the source camera, when known; FIFO topics order messages per camera
+	CameraID string `json:"camera_id,omitempty"`
+	// Confidence is the detector's score for the event, when it reported one
+	Confidence *float64 `json:"confidence,omitempty"`
+	// Labels and Detections are what the detector reported seeing, when it did
+	Labels     []string    `json:"labels,omitempty"`
+	Detections []Detection `json:"detections,omitempty"`
+	// ThumbnailURL is a JPEG of the video's first keyframe, when thumbnailing is enabled
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	// Scrub preview: a WebVTT track whose cues point at regions of the sprite sheet
+	PreviewVTTURL    string `json:"preview_vtt_url,omitempty"`
+	PreviewSpriteURL string `json:"preview_sprite_url,omitempty"`
+	// Video metadata; duration and resolution are omitted when they couldn't be read
+	SizeBytes       int64   `json:"size_bytes"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	// BackendVersion identifies the build that produced the notification, when enabled
+	BackendVersion string `json:"backend_version,omitempty"`
+}
+
+// DetectionEvent describes what the detector saw in a clip
+type DetectionEvent struct {
+	// Type is the event type, e.g. "human_detected" or "package_detected"
+	Type string
+	// Confidence is the optional detection score (0-1)
+	Confidence *float64
+	// Labels are the kinds of object seen, e.g. "person"
+	Labels []string
+	// Detections are the individual objects found, with their bounding boxes
+	Detections []Detection
+}
+
+// Detection is one object the detector found in a clip
+type Detection struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+	// Box is the bounding box as [x1, y1, x2, y2] in pixels, when reported
+	Box []float64 `json:"box,omitempty"`
+}
+
+// DefaultDetectionEvent returns the event used when the detector didn't say what it saw
+func DefaultDetectionEvent() DetectionEvent {
+	return DetectionEvent{Type: EventTypeHumanDetected}
+}
+
+// BatchNotification groups several video notifications into one message
+type BatchNotification struct {
+	Timestamp string `json:"timestamp"`
+	Count     int    `json:"count"`
+	// Notifications are VideoNotifications after the publisher's transforms
+	Notifications []interface{} `json:"notifications"`
 }
 
 // NewSNSPublisher creates a new SNS publisher
-func NewSNSPublisher(ctx context.Context, awsRegion, topicARN string, signer *CloudFrontSigner) (*SNSPublisher, error) {
+func NewSNSPublisher(ctx context.Context, awsRegion, topicARN string, signer URLSigner, opts SNSPublisherOptions) (*SNSPublisher, error) {
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(awsRegion),
 	)
@@ -42,55 +197,223 @@ func NewSNSPublisher(ctx context.Context, awsRegion, topicARN string, signer *Cl
 		return nil, fmt.Errorf("unable to load AWS SDK config: %w", err)
 	}
 
-	return &SNSPublisher{
-		client:          sns.NewFromConfig(cfg),
-		topicARN:        topicARN,
+	return newSNSPublisher(sns.NewFromConfig(cfg), sqs.NewFromConfig(cfg), awsRegion, topicARN, signer, opts), nil
+}
+
+// newSNSPublisher creates an SNS publisher on the given clients
+func newSNSPublisher(client snsAPI, sqsClient sqsAPI, awsRegion, topicARN string, signer URLSigner, opts SNSPublisherOptions) *SNSPublisher {
+	publisher := &SNSPublisher{
+		client:           client,
+		sqsClient:        sqsClient,
+		topicARN:         topicARN,
+		region:           awsRegion,
 		cloudFrontSigner: signer,
+		opts:             opts,
+	}
+	if opts.BatchWindow > 0 {
+		publisher.batcher = NewNotificationBatcher(publisher.publishBatch, opts.BatchWindow, opts.BatchMaxCount, opts.BatchMaxBytes)
+	}
+
+	return publisher
+}
+
+// CheckHealth verifies the topic exists and is reachable with the current credentials
+func (p *SNSPublisher) CheckHealth(ctx context.Context) error {
+	if _, err := p.client.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(p.topicARN)}); err != nil {
+		return fmt.Errorf("topic %s is not accessible: %w", p.topicARN, err)
+	}
+	return nil
+}
+
+// Publish publishes a video notification to SNS with retry logic.
+// When batching is enabled the notification is queued and published with its batch.
+func (p *SNSPublisher) Publish(ctx context.Context, upload UploadResult, cloudFrontDomain string, event DetectionEvent) error {
+	notification, err := p.BuildNotification(ctx, upload, cloudFrontDomain, event)
+	if err != nil {
+		return err
+	}
+	return p.PublishNotification(ctx, notification)
+}
+
+// PublishNotification publishes an already-built notification, queueing it
+// when batching is enabled. A queued notification returns nil; if its batch
+// later fails, the batcher reports the failure for each notification in it.
+func (p *SNSPublisher) PublishNotification(ctx context.Context, notification VideoNotification) error {
+	if p.batcher != nil {
+		return p.batcher.Add(ctx, notification, nil)
+	}
+	if err := p.publishNotification(ctx, notification); err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return err
+	}
+	return nil
+}
+
+// QueueNotification queues a notification in the current batch, calling delivered
+// with the batch's result once it is flushed. Without batching nothing is queued
+// and it returns false.
+func (p *SNSPublisher) QueueNotification(ctx context.Context, notification VideoNotification, delivered func(error)) (bool, error) {
+	if p.batcher == nil {
+		return false, nil
+	}
+	if err := p.batcher.Add(ctx, notification, delivered); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// publishNotification transforms and publishes a single notification
+func (p *SNSPublisher) publishNotification(ctx context.Context, notification VideoNotification) error {
+	payload, err := p.opts.Transform.Apply(notification)
+	if err != nil {
+		return fmt.Errorf("failed to transform notification: %w", err)
+	}
+	return p.publishMessage(ctx, eventSubject(notification.EventType), notification.EventType, payload,
+		notificationAttributes(notification), notificationPush(notification), notificationFIFO(notification))
+}
+
+// Flush publishes any notifications still waiting in the current batch
+func (p *SNSPublisher) Flush(ctx context.Context) error {
+	if p.batcher == nil {
+		return nil
+	}
+	return p.batcher.Flush(ctx)
+}
+
+// BuildNotification signs the CloudFront URL for an upload and builds its notification
+func (p *SNSPublisher) BuildNotification(ctx context.Context, upload UploadResult, cloudFrontDomain string, event DetectionEvent) (VideoNotification, error) {
+	if event.Type == "" {
+		event.Type = EventTypeHumanDetected
+	}
+
+	signedURL, signed, err := p.SignedURL(ctx, cloudFrontDomain, upload.Key, AssetVideo)
+	if err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return VideoNotification{}, err
+	}
+
+	return VideoNotification{
+		S3Key:          upload.Key,
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		EventType:      event.Type,
+		CloudFrontURL:  signedURL, // Use signed URL
+		Signed:         signed,
+		VersionID:      upload.VersionID,
+		Confidence:     event.Confidence,
+		Labels:         event.Labels,
+		Detections:     event.Detections,
+		BackendVersion: p.opts.BackendVersion,
 	}, nil
 }
 
-// Publish publishes a video notification to SNS with retry logic
-func (p *SNSPublisher) Publish(ctx context.Context, s3Key, cloudFrontDomain string) error {
+// SignedURL builds and signs the CloudFront URL for an S3 key. If signing fails
+// and the fallback policy allows it, the unsigned URL is returned with signed=false.
+func (p *SNSPublisher) SignedURL(ctx context.Context, cloudFrontDomain, s3Key, asset string) (string, bool, error) {
 	// Construct CloudFront URL
-	cloudFrontURL := fmt.Sprintf("https://%s/%s", cloudFrontDomain, s3Key)
+	cloudFrontURL := cloudFrontObjectURL(cloudFrontDomain, s3Key)
 
 	// Sign the CloudFront URL
-	signedURL, err := p.cloudFrontSigner.SignURL(cloudFrontURL)
+	signedURL, err := p.signURL(ctx, cloudFrontURL, p.urlTTL(asset))
 	if err != nil {
-		return fmt.Errorf("failed to sign CloudFront URL: %w", err)
+		if p.opts.SigningFallback != SigningFallbackUnsigned {
+			return "", false, fmt.Errorf("failed to sign CloudFront URL: %w", err)
+		}
+		slog.Warn("Failed to sign CloudFront URL, falling back to unsigned URL", "s3_key", s3Key, "error", err)
+		return cloudFrontURL, false, nil
+	}
+
+	slog.Debug("Signed CloudFront URL", "s3_key", s3Key)
+	return signedURL, true, nil
+}
+
+// publishBatch publishes a batch of notifications as a single SNS message
+func (p *SNSPublisher) publishBatch(ctx context.Context, notifications []VideoNotification) error {
+	if len(notifications) == 1 {
+		return p.publishNotification(ctx, notifications[0])
+	}
+
+	// Batches are filterable by event type only when every notification shares it
+	eventType := notifications[0].EventType
+	for _, notification := range notifications[1:] {
+		if notification.EventType != eventType {
+			eventType = eventTypeMixed
+			break
+		}
 	}
 
-	log.Printf("Signed CloudFront URL (expires in 30 days)")
+	transformed := make([]interface{}, len(notifications))
+	for i, notification := range notifications {
+		var err error
+		if transformed[i], err = p.opts.Transform.Apply(notification); err != nil {
+			return fmt.Errorf("failed to transform notification: %w", err)
+		}
+	}
 
-	notification := VideoNotification{
-		S3Key:         s3Key,
+	batch := BatchNotification{
 		Timestamp:     time.Now().UTC().Format(time.RFC3339),
-		EventType:     "human_detected",
-		CloudFrontURL: signedURL, // Use signed URL
+		Count:         len(notifications),
+		Notifications: transformed,
 	}
+	return p.publishMessage(ctx, fmt.Sprintf("%d Detections", len(notifications)), eventType, batch, nil, batchPush(notifications), batchFIFO(notifications))
+}
 
-	messageBytes, err := json.Marshal(notification)
+// publishMessage marshals a payload to JSON and publishes it to SNS with retries.
+// Callers count failures, as a batched message carries several notifications.
+func (p *SNSPublisher) publishMessage(ctx context.Context, subject, eventType string, payload interface{}, extra map[string]types.MessageAttributeValue, push pushContent, fifo fifoIDs) error {
+	messageBytes, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	message := string(messageBytes)
-	log.Printf("Publishing notification to SNS: %s", message)
+	// Strip or reject what SNS won't accept, per SNS_SANITIZE
+	message, err := SanitizeMessage(string(messageBytes), p.opts.SanitizeMode)
+	if err != nil {
+		return fmt.Errorf("invalid SNS message: %w", err)
+	}
+	subject = SanitizeSubject(subject, p.opts.SanitizeMode)
+	slog.Debug("Publishing notification to SNS", "subject", subject, "message", message)
+
+	// With push platforms or per-protocol messages configured, send a structured
+	// message built from push, sanitized again as a whole
+	var messageStructure *string
+	if len(p.opts.PushPlatforms) > 0 || len(p.opts.Protocols) > 0 {
+		push.Title = subject
+		if message, err = buildStructuredMessage(message, push, p.opts.PushPlatforms, p.opts.Protocols); err != nil {
+			return err
+		}
+		if message, err = SanitizeMessage(message, p.opts.SanitizeMode); err != nil {
+			return fmt.Errorf("invalid SNS structured message: %w", err)
+		}
+		messageStructure = aws.String("json")
+	}
 
 	// Create context with timeout for SNS operations
+	start := time.Now()
 	publishCtx, cancel := context.WithTimeout(ctx, snsPublishTimeout)
 	defer cancel()
 
 	// Retry configuration for SNS publish
 	retryConfig := utils.DefaultRetryConfig("SNS publish")
+	retryConfig.IsRetryable = IsRetryableError
 
 	// Publish with retry
 	err = utils.RetryWithBackoff(publishCtx, retryConfig, func() error {
-		_, err := p.client.Publish(publishCtx, &sns.PublishInput{
-			TopicArn: aws.String(p.topicARN),
-			Message:  aws.String(message),
-			Subject:  aws.String("Human Detected"),
-		})
+		input := &sns.PublishInput{
+			TopicArn:          aws.String(p.topicARN),
+			Message:           aws.String(message),
+			MessageStructure:  messageStructure,
+			MessageAttributes: p.messageAttributes(eventType, extra),
+		}
+		// SNS rejects empty subjects, so omit one that sanitized away
+		if subject != "" {
+			input.Subject = aws.String(subject)
+		}
+		// FIFO topics require the group and deduplication IDs from fifo
+		if isFIFOTopic(p.topicARN) {
+			input.MessageGroupId = aws.String(fifo.GroupID)
+			input.MessageDeduplicationId = aws.String(fifo.DeduplicationID)
+		}
+		_, err := p.client.Publish(publishCtx, input)
 		return err
 	})
 
@@ -98,6 +421,95 @@ func (p *SNSPublisher) Publish(ctx context.Context, s3Key, cloudFrontDomain stri
 		return fmt.Errorf("failed to publish to SNS after retries: %w", err)
 	}
 
-	log.Printf("Successfully published notification to SNS")
+	slog.Info("Published notification to SNS", "subject", subject, "event_type", eventType, "duration_ms", time.Since(start).Milliseconds())
 	return nil
 }
+
+// eventSubject derives a notification subject from an event type,
+// e.g. "human_detected" becomes "Human Detected"
+func eventSubject(eventType string) string {
+	words := strings.Fields(strings.ReplaceAll(eventType, "_", " "))
+	for i, word := range words {
+		// Decode the first rune so multibyte letters are capitalised whole
+		first, size := utf8.DecodeRuneInString(word)
+		if first != utf8.RuneError {
+			words[i] = string(unicode.ToUpper(first)) + word[size:]
+		}
+	}
+	if len(words) == 0 {
+		return "Detection"
+	}
+	return strings.Join(words, " ")
+}
+
+// messageAttributes builds the SNS message attributes subscribers can filter on
+func (p *SNSPublisher) messageAttributes(eventType string, extra map[string]types.MessageAttributeValue) map[string]types.MessageAttributeValue {
+	attributes := map[string]types.MessageAttributeValue{
+		"event_type": stringAttribute(eventType),
+		"region":     stringAttribute(p.region),
+	}
+	if p.opts.Bucket != "" {
+		attributes["s3_bucket"] = stringAttribute(p.opts.Bucket)
+	}
+	for name, value := range extra {
+		attributes[name] = value
+	}
+	return attributes
+}
+
+// notificationAttributes exposes a notification's confidence and labels as
+// message attributes, so subscriptions can filter on e.g. confidence >= 0.8
+func notificationAttributes(notification VideoNotification) map[string]types.MessageAttributeValue {
+	attributes := map[string]types.MessageAttributeValue{}
+	if notification.Confidence != nil {
+		attributes["confidence"] = types.MessageAttributeValue{
+			DataType:    aws.String("Number"),
+			StringValue: aws.String(strconv.FormatFloat(*notification.Confidence, 'f', -1, 64)),
+		}
+	}
+	if len(notification.Labels) > 0 {
+		// Encoding a []string can't fail
+		labels, _ := json.Marshal(notification.Labels)
+		attributes["labels"] = types.MessageAttributeValue{
+			DataType:    aws.String("String.Array"),
+			StringValue: aws.String(string(labels)),
+		}
+	}
+	return attributes
+}
+
+// stringAttribute builds a String-typed SNS message attribute
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+// urlTTL returns how long signed URLs for an asset kind stay valid
+func (p *SNSPublisher) urlTTL(asset string) time.Duration {
+	if asset == AssetThumbnail {
+		return p.opts.ThumbnailURLTTL
+	}
+	return p.opts.VideoURLTTL
+}
+
+// signURL signs a CloudFront URL, retrying briefly so a momentary signer
+// failure doesn't drop the notification
+func (p *SNSPublisher) signURL(ctx context.Context, rawURL string, ttl time.Duration) (string, error) {
+	retryConfig := utils.RetryConfig{
+		MaxRetries:    p.opts.SigningRetries,
+		InitialDelay:  100 * time.Millisecond,
+		MaxDelay:      1 * time.Second,
+		OperationName: "CloudFront URL signing",
+		Jitter:        true,
+	}
+
+	var signedURL string
+	err := utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		var err error
+		signedURL, err = p.cloudFrontSigner.SignURLWithExpiry(rawURL, ttl)
+		return err
+	})
+	return signedURL, err
+}
@@ -0,0 +1,368 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+	"github.com/lachiem1/eyeSeeYou/backend/go/notify"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+const testTopicARN = "arn:aws:sns:us-east-1:123456789012:eyeseeyou"
+
+var errSigning = errors.New("signer unavailable")
+
+// fakeSigner "signs" a URL by appending its TTL, failing the first failures calls
+type fakeSigner struct {
+	failures int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *fakeSigner) SignURLWithExpiry(rawURL string, d time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return "", errSigning
+	}
+	return rawURL + "?ttl=" + d.String(), nil
+}
+
+// fakeSNS records published messages, failing the first failures calls with err.
+// Messages are delivered to the fake SQS queues subscribed to the topic.
+type fakeSNS struct {
+	failures int
+	err      error
+	sqs      *fakeSQS
+
+	mu            sync.Mutex
+	calls         int
+	published     []*sns.PublishInput
+	subscriptions map[string]string // subscription ARN to queue ARN
+}
+
+func (f *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	f.published = append(f.published, params)
+	for _, queueARN := range f.subscriptions {
+		f.sqs.deliver(queueARN, aws.ToString(params.Message))
+	}
+	return &sns.PublishOutput{MessageId: aws.String(fmt.Sprint(f.calls))}, nil
+}
+
+func (f *fakeSNS) GetTopicAttributes(ctx context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	return &sns.GetTopicAttributesOutput{}, nil
+}
+
+func (f *fakeSNS) Subscribe(ctx context.Context, params *sns.SubscribeInput, optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subscriptions == nil {
+		f.subscriptions = make(map[string]string)
+	}
+	arn := fmt.Sprintf("%s:subscription-%d", aws.ToString(params.TopicArn), len(f.subscriptions)+1)
+	f.subscriptions[arn] = aws.ToString(params.Endpoint)
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String(arn)}, nil
+}
+
+func (f *fakeSNS) Unsubscribe(ctx context.Context, params *sns.UnsubscribeInput, optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscriptions, aws.ToString(params.SubscriptionArn))
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+// messages returns the published message bodies
+func (f *fakeSNS) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var messages []string
+	for _, input := range f.published {
+		messages = append(messages, aws.ToString(input.Message))
+	}
+	return messages
+}
+
+// newTestPublisher creates a publisher on a fake SNS client
+func newTestPublisher(client *fakeSNS, signer URLSigner, opts SNSPublisherOptions) *SNSPublisher {
+	return newSNSPublisher(client, nil, "us-east-1", testTopicARN, signer, opts)
+}
+
+// decodeNotification decodes a published single-notification message
+func decodeNotification(t *testing.T, message string) VideoNotification {
+	t.Helper()
+	var notification VideoNotification
+	if err := json.Unmarshal([]byte(message), &notification); err != nil {
+		t.Fatalf("decode notification %q: %v", message, err)
+	}
+	return notification
+}
+
+func TestEventSubject(t *testing.T) {
+	tests := []struct {
+		eventType string
+		want      string
+	}{
+		{"human_detected", "Human Detected"},
+		{"motion", "Motion"},
+		{"", "Detection"},
+		{"__", "Detection"},
+		{"élan_détecté", "Élan Détecté"},
+		{"ёж_замечен", "Ёж Замечен"},
+		{"猫_detected", "猫 Detected"},
+		{"\xffbad_name", "\xffbad Name"},
+	}
+	for _, tt := range tests {
+		if got := eventSubject(tt.eventType); got != tt.want {
+			t.Errorf("eventSubject(%q) = %q, want %q", tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestPublishRetriesSigning(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"signs first time", 0, 2, 1, false},
+		{"fails once then succeeds", 1, 2, 2, false},
+		{"succeeds on last retry", 2, 2, 3, false},
+		{"fails every retry", 3, 2, 3, true},
+		{"retries disabled", 1, 0, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &fakeSigner{failures: tt.failures}
+			client := &fakeSNS{}
+			opts := DefaultSNSPublisherOptions()
+			opts.SigningRetries = tt.retries
+			publisher := newTestPublisher(client, signer, opts)
+
+			err := publisher.Publish(context.Background(), UploadResult{Key: "videos/clip.mp4"}, "cdn.example.com", DefaultDetectionEvent())
+			if signer.calls != tt.wantCalls {
+				t.Errorf("signer called %d times, want %d", signer.calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, errSigning) {
+					t.Errorf("Publish() = %v, want the signing error", err)
+				}
+				if messages := client.messages(); len(messages) != 0 {
+					t.Errorf("published %d messages after signing failed", len(messages))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			messages := client.messages()
+			if len(messages) != 1 {
+				t.Fatalf("published %d messages, want 1", len(messages))
+			}
+			notification := decodeNotification(t, messages[0])
+			if !notification.Signed || !strings.HasPrefix(notification.CloudFrontURL, "https://cdn.example.com/videos/clip.mp4?ttl=") {
+				t.Errorf("notification URL = %q (signed %v), want a signed URL", notification.CloudFrontURL, notification.Signed)
+			}
+		})
+	}
+}
+
+func TestPublishSigningFallback(t *testing.T) {
+	tests := []struct {
+		policy     string
+		wantErr    bool
+		wantURL    string
+		wantSigned bool
+	}{
+		{SigningFallbackFail, true, "", false},
+		{SigningFallbackUnsigned, false, "https://cdn.example.com/videos/front%20door.mp4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			client := &fakeSNS{}
+			opts := DefaultSNSPublisherOptions()
+			opts.SigningRetries = 0
+			opts.SigningFallback = tt.policy
+			publisher := newTestPublisher(client, &fakeSigner{failures: 1}, opts)
+			failed := metrics.Default.Counter(metrics.PublishFailed).Value()
+
+			err := publisher.Publish(context.Background(), UploadResult{Key: "videos/front door.mp4"}, "cdn.example.com", DefaultDetectionEvent())
+			messages := client.messages()
+			if tt.wantErr {
+				if err == nil || len(messages) != 0 {
+					t.Fatalf("Publish() = %v with %d messages, want an error and nothing published", err, len(messages))
+				}
+				if got := metrics.Default.Counter(metrics.PublishFailed).Value() - failed; got != 1 {
+					t.Errorf("publish failures = %d, want 1", got)
+				}
+				return
+			}
+			if err != nil || len(messages) != 1 {
+				t.Fatalf("Publish() = %v with %d messages, want one message", err, len(messages))
+			}
+			notification := decodeNotification(t, messages[0])
+			if notification.CloudFrontURL != tt.wantURL || notification.Signed != tt.wantSigned {
+				t.Errorf("notification URL = %q, signed %v; want %q, signed %v",
+					notification.CloudFrontURL, notification.Signed, tt.wantURL, tt.wantSigned)
+			}
+			if !strings.Contains(messages[0], `"signed":false`) {
+				t.Errorf("message does not mark the URL unsigned: %s", messages[0])
+			}
+		})
+	}
+}
+
+func TestPublishAppliesTransform(t *testing.T) {
+	pipeline, err := notify.ParsePipeline("rename:cloudfront_url=url|project:s3_key,url|wrap:video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		batch []VideoNotification
+		want  string
+	}{
+		{"single", []VideoNotification{testNotification(1)},
+			`{"video":{"s3_key":"videos/clip-1.mp4","url":""}}`},
+		{"batch", []VideoNotification{testNotification(1), testNotification(2)},
+			`[{"video":{"s3_key":"videos/clip-1.mp4","url":""}},{"video":{"s3_key":"videos/clip-2.mp4","url":""}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeSNS{}
+			opts := DefaultSNSPublisherOptions()
+			opts.Transform = pipeline
+			publisher := newTestPublisher(client, &fakeSigner{}, opts)
+
+			if err := publisher.publishBatch(context.Background(), tt.batch); err != nil {
+				t.Fatalf("publishBatch: %v", err)
+			}
+			message := client.messages()[0]
+			if len(tt.batch) > 1 {
+				var batch struct {
+					Notifications json.RawMessage `json:"notifications"`
+				}
+				if err := json.Unmarshal([]byte(message), &batch); err != nil {
+					t.Fatalf("decode batch %s: %v", message, err)
+				}
+				message = string(batch.Notifications)
+			}
+			if message != tt.want {
+				t.Errorf("published %s\nwant %s", message, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedURLPerAssetTTL(t *testing.T) {
+	tests := []struct {
+		name         string
+		videoTTL     time.Duration
+		thumbnailTTL time.Duration
+	}{
+		{"longer thumbnails", time.Hour, 7 * 24 * time.Hour},
+		{"shorter thumbnails", 24 * time.Hour, 15 * time.Minute},
+		{"equal", time.Hour, time.Hour},
+	}
+	signer, _ := newTestSigner(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultSNSPublisherOptions()
+			opts.VideoURLTTL = tt.videoTTL
+			opts.ThumbnailURLTTL = tt.thumbnailTTL
+			publisher := newTestPublisher(&fakeSNS{}, signer, opts)
+
+			expires := func(key, asset string) time.Duration {
+				t.Helper()
+				before := time.Now()
+				signedURL, signed, err := publisher.SignedURL(context.Background(), "cdn.example.com", key, asset)
+				if err != nil || !signed {
+					t.Fatalf("SignedURL(%s) = %v, signed %v", asset, err, signed)
+				}
+				_, params := splitSignedURL(t, signedURL, "Expires")
+				unix, err := strconv.ParseInt(params.Get("Expires"), 10, 64)
+				if err != nil {
+					t.Fatalf("Expires %q: %v", params.Get("Expires"), err)
+				}
+				return time.Unix(unix, 0).Sub(before.Truncate(time.Second))
+			}
+
+			video := expires("videos/clip.mp4", AssetVideo)
+			thumbnail := expires("thumbnails/clip.jpg", AssetThumbnail)
+			// Expires has one-second resolution
+			if diff := video - tt.videoTTL; diff < 0 || diff > time.Second {
+				t.Errorf("video URL expires in %s, want %s", video, tt.videoTTL)
+			}
+			if diff := thumbnail - tt.thumbnailTTL; diff < 0 || diff > time.Second {
+				t.Errorf("thumbnail URL expires in %s, want %s", thumbnail, tt.thumbnailTTL)
+			}
+		})
+	}
+}
+
+func TestPublishRetryExhaustion(t *testing.T) {
+	// Bound retrying so exhausting it stays quick; the first backoff always fits
+	utils.SetDefaultMaxElapsed(1500 * time.Millisecond)
+	t.Cleanup(func() { utils.SetDefaultMaxElapsed(0) })
+
+	throttled := apiError("Throttling", http.StatusBadRequest)
+	denied := apiError("AuthorizationError", http.StatusForbidden)
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		minCalls int
+		maxCalls int
+		wantErr  error
+	}{
+		{"recovers after a retry", 1, throttled, 2, 2, nil},
+		{"retries exhausted", 100, throttled, 2, 5, throttled},
+		{"not retryable", 100, denied, 1, 1, denied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeSNS{failures: tt.failures, err: tt.err}
+			publisher := newTestPublisher(client, &fakeSigner{}, DefaultSNSPublisherOptions())
+
+			err := publisher.Publish(context.Background(), UploadResult{Key: "videos/clip.mp4"}, "cdn.example.com", DefaultDetectionEvent())
+			if client.calls < tt.minCalls || client.calls > tt.maxCalls {
+				t.Errorf("Publish called %d times, want %d to %d", client.calls, tt.minCalls, tt.maxCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+				if messages := client.messages(); len(messages) != 1 {
+					t.Errorf("published %d messages, want 1", len(messages))
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Publish() = %v, want %v", err, tt.wantErr)
+			}
+			if messages := client.messages(); len(messages) != 0 {
+				t.Errorf("published %d messages after every attempt failed", len(messages))
+			}
+		})
+	}
+}
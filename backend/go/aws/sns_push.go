@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SNS platform keys for mobile push endpoints. FCM is delivered under the GCM key.
+const (
+	PushPlatformAPNS        = "APNS"
+	PushPlatformAPNSSandbox = "APNS_SANDBOX"
+	PushPlatformGCM         = "GCM"
+	pushPlatformFCM         = "FCM"
+)
+
+// ParsePushPlatforms normalises a list of push platform names, mapping FCM to GCM
+func ParsePushPlatforms(names []string) ([]string, error) {
+	var platforms []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		platform := strings.ToUpper(strings.TrimSpace(name))
+		if platform == pushPlatformFCM {
+			platform = PushPlatformGCM
+		}
+		switch platform {
+		case PushPlatformAPNS, PushPlatformAPNSSandbox, PushPlatformGCM:
+		default:
+			return nil, fmt.Errorf("unknown push platform %q (supported: APNS, APNS_SANDBOX, GCM, FCM)", name)
+		}
+		if !seen[platform] {
+			seen[platform] = true
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms, nil
+}
+
+// pushContent is the short, push-friendly form of a notification
+type pushContent struct {
+	// Title is the message subject, set when publishing
+	Title string
+	Body  string
+	// Data is delivered to the app alongside the alert
+	Data map[string]string
+}
+
+// notificationPush derives push content from a single notification
+func notificationPush(notification VideoNotification) pushContent {
+	return pushContent{
+		Body: fmt.Sprintf("New clip recorded at %s", notification.Timestamp),
+		Data: map[string]string{
+			"s3_key":         notification.S3Key,
+			"event_type":     notification.EventType,
+			"cloudfront_url": notification.CloudFrontURL,
+		},
+	}
+}
+
+// batchPush derives push content from a batch of notifications
+func batchPush(notifications []VideoNotification) pushContent {
+	return pushContent{
+		Body: fmt.Sprintf("%d new clips recorded", len(notifications)),
+		Data: map[string]string{
+			"count": fmt.Sprint(len(notifications)),
+		},
+	}
+}
+
+// buildStructuredMessage builds an SNS message for MessageStructure "json": the
+// original message under "default" for other subscribers, a platform payload per
+// push platform and a message per subscription protocol
+func buildStructuredMessage(message string, push pushContent, platforms, protocols []string) (string, error) {
+	structured := map[string]string{"default": message}
+	for _, protocol := range protocols {
+		structured[protocol] = protocolMessage(protocol, message, push)
+	}
+
+	for _, platform := range platforms {
+		var payload interface{}
+		switch platform {
+		case PushPlatformAPNS, PushPlatformAPNSSandbox:
+			apns := map[string]interface{}{
+				"aps": map[string]interface{}{
+					"alert": map[string]string{"title": push.Title, "body": push.Body},
+					"sound": "default",
+				},
+			}
+			// APNs custom data sits beside the aps dictionary
+			for key, value := range push.Data {
+				apns[key] = value
+			}
+			payload = apns
+		case PushPlatformGCM:
+			payload = map[string]interface{}{
+				"notification": map[string]string{"title": push.Title, "body": push.Body},
+				"data":         push.Data,
+			}
+		default:
+			return "", fmt.Errorf("unknown push platform %q", platform)
+		}
+
+		// Each platform payload is itself a JSON-encoded string
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode %s payload: %w", platform, err)
+		}
+		structured[platform] = string(encoded)
+	}
+
+	encoded, err := json.Marshal(structured)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode structured message: %w", err)
+	}
+	return string(encoded), nil
+}
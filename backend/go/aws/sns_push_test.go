@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestParsePushPlatforms(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{[]string{"apns", " GCM "}, []string{PushPlatformAPNS, PushPlatformGCM}, false},
+		{[]string{"fcm"}, []string{PushPlatformGCM}, false},
+		{[]string{"GCM", "FCM", "APNS_SANDBOX"}, []string{PushPlatformGCM, PushPlatformAPNSSandbox}, false},
+		{[]string{"ADM"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePushPlatforms(tt.names)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePushPlatforms(%q) = %v, %v; want %v, error %v", tt.names, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// decodeStructured decodes a MessageStructure "json" message and the platform
+// payloads it carries as JSON strings
+func decodeStructured(t *testing.T, message string) (map[string]string, map[string]map[string]interface{}) {
+	t.Helper()
+	var structured map[string]string
+	if err := json.Unmarshal([]byte(message), &structured); err != nil {
+		t.Fatalf("decode structured message %s: %v", message, err)
+	}
+	payloads := make(map[string]map[string]interface{})
+	for _, platform := range []string{PushPlatformAPNS, PushPlatformAPNSSandbox, PushPlatformGCM} {
+		if encoded, ok := structured[platform]; ok {
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(encoded), &payload); err != nil {
+				t.Fatalf("decode %s payload %s: %v", platform, encoded, err)
+			}
+			payloads[platform] = payload
+		}
+	}
+	return structured, payloads
+}
+
+func TestPublishPushPayloads(t *testing.T) {
+	single := VideoNotification{
+		S3Key:         "videos/clip.mp4",
+		Timestamp:     "2026-03-14T09:26:53Z",
+		EventType:     EventTypeHumanDetected,
+		CloudFrontURL: "https://cdn.example.com/videos/clip.mp4?sig",
+	}
+	singleData := map[string]interface{}{
+		"s3_key":         "videos/clip.mp4",
+		"event_type":     EventTypeHumanDetected,
+		"cloudfront_url": "https://cdn.example.com/videos/clip.mp4?sig",
+	}
+	singleAPNS := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]interface{}{"title": "Human Detected", "body": "New clip recorded at 2026-03-14T09:26:53Z"},
+			"sound": "default",
+		},
+		"s3_key":         "videos/clip.mp4",
+		"event_type":     EventTypeHumanDetected,
+		"cloudfront_url": "https://cdn.example.com/videos/clip.mp4?sig",
+	}
+	singleGCM := map[string]interface{}{
+		"notification": map[string]interface{}{"title": "Human Detected", "body": "New clip recorded at 2026-03-14T09:26:53Z"},
+		"data":         singleData,
+	}
+
+	tests := []struct {
+		name      string
+		platforms []string
+		batch     []VideoNotification
+		want      map[string]map[string]interface{}
+	}{
+		{"APNS", []string{PushPlatformAPNS}, []VideoNotification{single},
+			map[string]map[string]interface{}{PushPlatformAPNS: singleAPNS}},
+		{"APNS sandbox and GCM", []string{PushPlatformAPNSSandbox, PushPlatformGCM}, []VideoNotification{single},
+			map[string]map[string]interface{}{PushPlatformAPNSSandbox: singleAPNS, PushPlatformGCM: singleGCM}},
+		{"batch", []string{PushPlatformGCM}, []VideoNotification{single, single},
+			map[string]map[string]interface{}{PushPlatformGCM: {
+				"notification": map[string]interface{}{"title": "2 Detections", "body": "2 new clips recorded"},
+				"data":         map[string]interface{}{"count": "2"},
+			}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeSNS{}
+			opts := DefaultSNSPublisherOptions()
+			opts.PushPlatforms = tt.platforms
+			publisher := newTestPublisher(client, &fakeSigner{}, opts)
+
+			if err := publisher.publishBatch(context.Background(), tt.batch); err != nil {
+				t.Fatalf("publishBatch: %v", err)
+			}
+			input := client.published[0]
+			if got := aws.ToString(input.MessageStructure); got != "json" {
+				t.Errorf("MessageStructure = %q, want json", got)
+			}
+
+			structured, payloads := decodeStructured(t, aws.ToString(input.Message))
+			if !reflect.DeepEqual(payloads, tt.want) {
+				t.Errorf("platform payloads = %v\nwant %v", payloads, tt.want)
+			}
+			// Other subscribers still get the whole notification
+			if len(tt.batch) == 1 {
+				if got := decodeNotification(t, structured["default"]); !reflect.DeepEqual(got, single) {
+					t.Errorf("default message = %+v, want %+v", got, single)
+				}
+			} else if _, ok := structured["default"]; !ok {
+				t.Error("no default message")
+			}
+		})
+	}
+}
+
+func TestPublishWithoutPushPlatforms(t *testing.T) {
+	client := &fakeSNS{}
+	publisher := newTestPublisher(client, &fakeSigner{}, DefaultSNSPublisherOptions())
+	if err := publisher.publishBatch(context.Background(), []VideoNotification{testNotification(1)}); err != nil {
+		t.Fatalf("publishBatch: %v", err)
+	}
+	if input := client.published[0]; input.MessageStructure != nil {
+		t.Errorf("MessageStructure = %q, want none for plain messages", aws.ToString(input.MessageStructure))
+	}
+	decodeNotification(t, client.messages()[0])
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Sanitization modes for SNS subjects and messages
+const (
+	// SanitizeOff sends subjects and messages as-is
+	SanitizeOff = "off"
+	// SanitizeLenient strips control characters and enforces length limits
+	SanitizeLenient = "lenient"
+	// SanitizeStrict additionally restricts subjects to printable ASCII and
+	// replaces invalid UTF-8 in messages
+	SanitizeStrict = "strict"
+)
+
+const (
+	// SNS subject limit (characters)
+	maxSubjectLength = 100
+
+	// SNS message limit (bytes)
+	maxMessageBytes = 256 * 1024
+)
+
+// SanitizeSubject makes a subject acceptable to SNS, which rejects control
+// characters and line breaks and limits subjects to 100 characters
+func SanitizeSubject(subject, mode string) string {
+	if mode == SanitizeOff {
+		return subject
+	}
+
+	var b strings.Builder
+	for _, r := range subject {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteRune(' ')
+		case unicode.IsControl(r):
+			// Drop other control characters
+		case mode == SanitizeStrict && (r < 0x20 || r > 0x7e):
+			// Strict mode only allows printable ASCII
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	sanitized := strings.TrimSpace(strings.Join(strings.Fields(b.String()), " "))
+	if runes := []rune(sanitized); len(runes) > maxSubjectLength {
+		sanitized = strings.TrimSpace(string(runes[:maxSubjectLength]))
+	}
+	return sanitized
+}
+
+// SanitizeMessage checks a message against SNS limits. Messages are JSON, so
+// rather than truncating (and corrupting) an oversized message it is rejected.
+func SanitizeMessage(message, mode string) (string, error) {
+	if mode == SanitizeOff {
+		return message, nil
+	}
+
+	if mode == SanitizeStrict {
+		message = strings.ToValidUTF8(message, "�")
+	}
+	message = strings.Map(func(r rune) rune {
+		// JSON encoding escapes control characters inside strings, so any left
+		// over are stray bytes between tokens
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return -1
+		}
+		return r
+	}, message)
+
+	if len(message) > maxMessageBytes {
+		return "", fmt.Errorf("message is %d bytes, exceeding the SNS limit of %d", len(message), maxMessageBytes)
+	}
+	return message, nil
+}
@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSanitizeSubject(t *testing.T) {
+	long := strings.Repeat("a", 150)
+	longUnicode := strings.Repeat("é", 150)
+	tests := []struct {
+		name    string
+		subject string
+		mode    string
+		want    string
+	}{
+		{"clean", "Human Detected", SanitizeLenient, "Human Detected"},
+		{"line breaks and tabs", "Front\r\nDoor\tCamera", SanitizeLenient, "Front Door Camera"},
+		{"control characters", "Front\x00 Door\x07\x1b Camera\x7f", SanitizeLenient, "Front Door Camera"},
+		{"C1 control characters", "Front\u0085Door\u009b", SanitizeLenient, "FrontDoor"},
+		{"surrounding whitespace", "  \n Human Detected \t ", SanitizeLenient, "Human Detected"},
+		{"only control characters", "\x00\x01\x02", SanitizeLenient, ""},
+		{"over length", long, SanitizeLenient, long[:maxSubjectLength]},
+		{"over length counted in characters", longUnicode, SanitizeLenient, strings.Repeat("é", maxSubjectLength)},
+		{"trailing space after truncation", strings.Repeat("a", 99) + " b", SanitizeLenient, strings.Repeat("a", 99)},
+		{"unicode kept when lenient", "Caméra Entrée", SanitizeLenient, "Caméra Entrée"},
+		{"unicode dropped when strict", "Caméra Entrée 🚪", SanitizeStrict, "Camra Entre"},
+		{"strict also strips control characters", "Front\x07\nDoor", SanitizeStrict, "Front Door"},
+		{"off", "Front\x07\nDoor", SanitizeOff, "Front\x07\nDoor"},
+		{"off keeps over-length subjects", long, SanitizeOff, long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeSubject(tt.subject, tt.mode); got != tt.want {
+				t.Errorf("SanitizeSubject(%q, %s) = %q, want %q", tt.subject, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	oversized := `{"pad":"` + strings.Repeat("x", maxMessageBytes) + `"}`
+	tests := []struct {
+		name    string
+		message string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"clean", `{"s3_key":"videos/clip.mp4"}`, SanitizeLenient, `{"s3_key":"videos/clip.mp4"}`, false},
+		{"stray control characters", "{\x00\"a\":\x07\"b\"}\x1b", SanitizeLenient, `{"a":"b"}`, false},
+		{"whitespace kept", "{\n\t\"a\": \"b\"\r\n}", SanitizeLenient, "{\n\t\"a\": \"b\"\r\n}", false},
+		{"invalid UTF-8 replaced when strict", "{\"a\":\"\xff\"}", SanitizeStrict, `{"a":"�"}`, false},
+		{"over length", oversized, SanitizeLenient, "", true},
+		{"over length when strict", oversized, SanitizeStrict, "", true},
+		{"off", "{\x00}", SanitizeOff, "{\x00}", false},
+		{"off skips the length check", oversized, SanitizeOff, oversized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeMessage(tt.message, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SanitizeMessage() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SanitizeMessage() = %.80q, want %.80q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishSanitizesSubject(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		want      string
+	}{
+		{"control characters", "door\x07_bell\x1b", "Door Bell"},
+		{"over length", strings.Repeat("motion_", 30), strings.TrimSpace(strings.Repeat("Motion ", 15))[:maxSubjectLength]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeSNS{}
+			publisher := newTestPublisher(client, &fakeSigner{}, DefaultSNSPublisherOptions())
+			err := publisher.Publish(context.Background(), UploadResult{Key: "videos/clip.mp4"}, "cdn.example.com", DetectionEvent{Type: tt.eventType})
+			if err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			subject := aws.ToString(client.published[0].Subject)
+			if subject != tt.want {
+				t.Errorf("subject = %q, want %q", subject, tt.want)
+			}
+			if utf8.RuneCountInString(subject) > maxSubjectLength {
+				t.Errorf("subject is %d characters, over the SNS limit", utf8.RuneCountInString(subject))
+			}
+		})
+	}
+}
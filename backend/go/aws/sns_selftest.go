@@ -0,0 +1,246 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/lachiem1/eyeSeeYou/backend/go/health"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+const (
+	// EventTypeSelfTest is the event type of self-test messages, so subscription
+	// filter policies can exclude them
+	EventTypeSelfTest = "self_test"
+
+	// Default time allowed for a self-test message to reach the temporary queue
+	defaultSelfTestTimeout = 30 * time.Second
+
+	// Longest SQS long-poll wait per receive call
+	selfTestPollWait = 20 * time.Second
+
+	// Time allowed for deleting the temporary subscription and queue
+	selfTestCleanupTimeout = 15 * time.Second
+)
+
+// ErrSelfTestTimeout is returned when the self-test message never reached the queue
+var ErrSelfTestTimeout = errors.New("self-test message was not delivered before the timeout")
+
+// SelfTestResult describes a completed self-test round trip
+type SelfTestResult struct {
+	MessageID string `json:"message_id"`
+	// DurationMs is the time from publishing to the message arriving
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// selfTestMessage is the payload published during a self-test
+type selfTestMessage struct {
+	EventType string `json:"event_type"`
+	Timestamp string `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+}
+
+// SelfTest verifies the SNS path end to end: it subscribes a temporary SQS queue
+// to the topic, publishes a self-test message through the normal publish path and
+// waits for it to arrive. The queue and subscription are removed afterwards.
+// Other subscribers receive the message too, with an event_type of "self_test".
+func (p *SNSPublisher) SelfTest(ctx context.Context) (SelfTestResult, error) {
+	timeout := p.opts.SelfTestTimeout
+	if timeout <= 0 {
+		timeout = defaultSelfTestTimeout
+	}
+
+	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+	queueURL, queueARN, err := p.createSelfTestQueue(ctx, "eyeseeyou-selftest-"+nonce, isFIFOTopic(p.topicARN))
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+	defer p.deleteSelfTestQueue(ctx, queueURL)
+
+	subscription, err := p.client.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn:              aws.String(p.topicARN),
+		Protocol:              aws.String("sqs"),
+		Endpoint:              aws.String(queueARN),
+		Attributes:            map[string]string{"RawMessageDelivery": "true"},
+		ReturnSubscriptionArn: true,
+	})
+	if err != nil {
+		return SelfTestResult{}, fmt.Errorf("failed to subscribe self-test queue: %w", err)
+	}
+	defer p.unsubscribeSelfTest(ctx, aws.ToString(subscription.SubscriptionArn))
+
+	start := time.Now()
+	message := selfTestMessage{
+		EventType: EventTypeSelfTest,
+		Timestamp: start.UTC().Format(time.RFC3339),
+		Nonce:     nonce,
+	}
+	push := pushContent{Body: "Notification self-test", Data: map[string]string{"event_type": EventTypeSelfTest}}
+	fifo := fifoIDs{GroupID: EventTypeSelfTest, DeduplicationID: deduplicationID(nonce)}
+	if err := p.publishMessage(ctx, "Self Test", EventTypeSelfTest, message, nil, push, fifo); err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return SelfTestResult{}, err
+	}
+
+	messageID, err := p.awaitSelfTestMessage(ctx, queueURL, nonce, timeout)
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+
+	result := SelfTestResult{MessageID: messageID, DurationMs: time.Since(start).Milliseconds()}
+	slog.Info("SNS self-test succeeded", "topic_arn", p.topicARN, "duration_ms", result.DurationMs)
+	return result, nil
+}
+
+// createSelfTestQueue creates a queue the topic is allowed to send to and
+// returns its URL and ARN. FIFO topics can only deliver to FIFO queues.
+func (p *SNSPublisher) createSelfTestQueue(ctx context.Context, name string, fifo bool) (string, string, error) {
+	input := &sqs.CreateQueueInput{QueueName: aws.String(name)}
+	if fifo {
+		input.QueueName = aws.String(name + ".fifo")
+		input.Attributes = map[string]string{string(sqstypes.QueueAttributeNameFifoQueue): "true"}
+	}
+	created, err := p.sqsClient.CreateQueue(ctx, input)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create self-test queue: %w", err)
+	}
+	queueURL := aws.ToString(created.QueueUrl)
+
+	attributes, err := p.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueURL),
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		p.deleteSelfTestQueue(ctx, queueURL)
+		return "", "", fmt.Errorf("failed to read self-test queue ARN: %w", err)
+	}
+	queueARN := attributes.Attributes[string(sqstypes.QueueAttributeNameQueueArn)]
+
+	_, err = p.sqsClient.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl: aws.String(queueURL),
+		Attributes: map[string]string{
+			string(sqstypes.QueueAttributeNamePolicy): selfTestQueuePolicy(queueARN, p.topicARN),
+		},
+	})
+	if err != nil {
+		p.deleteSelfTestQueue(ctx, queueURL)
+		return "", "", fmt.Errorf("failed to set self-test queue policy: %w", err)
+	}
+
+	return queueURL, queueARN, nil
+}
+
+// awaitSelfTestMessage long-polls the queue until the message carrying nonce
+// arrives, returning its SQS message ID
+func (p *SNSPublisher) awaitSelfTestMessage(ctx context.Context, queueURL, nonce string, timeout time.Duration) (string, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		wait := selfTestPollWait
+		if deadline, ok := waitCtx.Deadline(); ok && time.Until(deadline) < wait {
+			wait = time.Until(deadline)
+		}
+		if wait < time.Second {
+			return "", ErrSelfTestTimeout
+		}
+
+		received, err := p.sqsClient.ReceiveMessage(waitCtx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     int32(wait / time.Second),
+		})
+		if err != nil {
+			if waitCtx.Err() != nil && ctx.Err() == nil {
+				return "", ErrSelfTestTimeout
+			}
+			return "", fmt.Errorf("failed to receive from self-test queue: %w", err)
+		}
+
+		for _, msg := range received.Messages {
+			var body selfTestMessage
+			if json.Unmarshal([]byte(aws.ToString(msg.Body)), &body) == nil && body.Nonce == nonce {
+				return aws.ToString(msg.MessageId), nil
+			}
+		}
+	}
+}
+
+// unsubscribeSelfTest removes the temporary subscription, even if ctx was cancelled
+func (p *SNSPublisher) unsubscribeSelfTest(ctx context.Context, subscriptionARN string) {
+	if subscriptionARN == "" {
+		return
+	}
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), selfTestCleanupTimeout)
+	defer cancel()
+
+	if _, err := p.client.Unsubscribe(cleanupCtx, &sns.UnsubscribeInput{SubscriptionArn: aws.String(subscriptionARN)}); err != nil {
+		slog.Warn("Failed to remove self-test subscription", "subscription_arn", subscriptionARN, "error", err)
+	}
+}
+
+// deleteSelfTestQueue removes the temporary queue, even if ctx was cancelled
+func (p *SNSPublisher) deleteSelfTestQueue(ctx context.Context, queueURL string) {
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), selfTestCleanupTimeout)
+	defer cancel()
+
+	if _, err := p.sqsClient.DeleteQueue(cleanupCtx, &sqs.DeleteQueueInput{QueueUrl: aws.String(queueURL)}); err != nil {
+		slog.Warn("Failed to delete self-test queue", "queue_url", queueURL, "error", err)
+	}
+}
+
+// selfTestQueuePolicy allows only the topic to send to the queue
+func selfTestQueuePolicy(queueARN, topicARN string) string {
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueARN,
+			"Condition": map[string]interface{}{
+				"ArnEquals": map[string]string{"aws:SourceArn": topicARN},
+			},
+		}},
+	}
+	encoded, _ := json.Marshal(policy)
+	return string(encoded)
+}
+
+// SelfTestHandler runs a self-test on POST and reports the outcome.
+// Only one self-test runs at a time; concurrent requests get 409.
+func SelfTestHandler(publisher *SNSPublisher) http.Handler {
+	var running sync.Mutex
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !running.TryLock() {
+			http.Error(w, "self-test already running", http.StatusConflict)
+			return
+		}
+		defer running.Unlock()
+
+		result, err := publisher.SelfTest(r.Context())
+		if err != nil {
+			slog.Error("SNS self-test failed", "topic_arn", publisher.topicARN, "error", err)
+			health.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		health.WriteJSON(w, http.StatusOK, result)
+	})
+}
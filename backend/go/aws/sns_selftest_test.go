@@ -0,0 +1,252 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeSQS holds queues in memory. Messages delivered to a queue are preceded by
+// the bodies in noise; if drop is set they are discarded instead.
+type fakeSQS struct {
+	drop  bool
+	noise []string
+
+	mu      sync.Mutex
+	queues  map[string]*fakeQueue // by URL
+	created []*fakeQueue
+}
+
+type fakeQueue struct {
+	name       string
+	arn        string
+	attributes map[string]string
+	messages   []sqstypes.Message
+}
+
+func newFakeSQS() *fakeSQS {
+	return &fakeSQS{queues: make(map[string]*fakeQueue)}
+}
+
+// deliver adds a message to the queue with the given ARN
+func (f *fakeSQS) deliver(queueARN, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.drop {
+		return
+	}
+	for _, queue := range f.queues {
+		if queue.arn != queueARN {
+			continue
+		}
+		for _, b := range append(f.noise, body) {
+			id := fmt.Sprintf("msg-%d", len(queue.messages)+1)
+			queue.messages = append(queue.messages, sqstypes.Message{MessageId: aws.String(id), Body: aws.String(b)})
+		}
+	}
+}
+
+func (f *fakeSQS) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.QueueName)
+	queue := &fakeQueue{
+		name:       name,
+		arn:        "arn:aws:sqs:us-east-1:123456789012:" + name,
+		attributes: make(map[string]string),
+	}
+	for k, v := range params.Attributes {
+		queue.attributes[k] = v
+	}
+	url := "https://sqs.us-east-1.amazonaws.com/123456789012/" + name
+	f.queues[url] = queue
+	f.created = append(f.created, queue)
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(url)}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	queue, err := f.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{string(sqstypes.QueueAttributeNameQueueArn): queue.arn}}, nil
+}
+
+func (f *fakeSQS) SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	queue, err := f.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range params.Attributes {
+		queue.attributes[k] = v
+	}
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	queue, err := f.queue(params.QueueUrl)
+	var messages []sqstypes.Message
+	if err == nil {
+		messages, queue.messages = queue.messages, nil
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(messages) == 0 {
+		// A short stand-in for the long poll
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (f *fakeSQS) DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.queue(params.QueueUrl); err != nil {
+		return nil, err
+	}
+	delete(f.queues, aws.ToString(params.QueueUrl))
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+// queue looks up a queue by URL. f.mu must be held.
+func (f *fakeSQS) queue(url *string) (*fakeQueue, error) {
+	queue, ok := f.queues[aws.ToString(url)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "AWS.SimpleQueueService.NonExistentQueue", Fault: smithy.FaultClient}
+	}
+	return queue, nil
+}
+
+// newSelfTestPublisher creates a publisher whose topic delivers to queues
+func newSelfTestPublisher(topicARN string, client *fakeSNS, queues *fakeSQS) *SNSPublisher {
+	client.sqs = queues
+	opts := DefaultSNSPublisherOptions()
+	// Long enough for one poll; SelfTest gives up once under a second remains
+	opts.SelfTestTimeout = 1500 * time.Millisecond
+	return newSNSPublisher(client, queues, "us-east-1", topicARN, &fakeSigner{}, opts)
+}
+
+func TestSelfTest(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AuthorizationError", Fault: smithy.FaultClient}
+	stale := []string{`{"event_type":"self_test","nonce":"1"}`, "not json"}
+
+	tests := []struct {
+		name     string
+		topicARN string
+		client   *fakeSNS
+		drop     bool
+		noise    []string
+		fifo     bool
+		wantErr  error
+	}{
+		{name: "round trip", topicARN: testTopicARN, client: &fakeSNS{}},
+		{name: "FIFO topic", topicARN: testTopicARN + ".fifo", client: &fakeSNS{}, fifo: true},
+		{name: "other messages ignored", topicARN: testTopicARN, client: &fakeSNS{}, noise: stale},
+		{name: "never delivered", topicARN: testTopicARN, client: &fakeSNS{}, drop: true, wantErr: ErrSelfTestTimeout},
+		{name: "publish denied", topicARN: testTopicARN, client: &fakeSNS{failures: 1, err: denied}, wantErr: denied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queues := newFakeSQS()
+			queues.drop = tt.drop
+			queues.noise = tt.noise
+			publisher := newSelfTestPublisher(tt.topicARN, tt.client, queues)
+
+			result, err := publisher.SelfTest(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SelfTest() error = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("SelfTest: %v", err)
+				}
+				// The self-test message follows any noise in the queue
+				if want := fmt.Sprintf("msg-%d", len(tt.noise)+1); result.MessageID != want {
+					t.Errorf("MessageID = %q, want %q", result.MessageID, want)
+				}
+			}
+
+			if len(queues.created) != 1 {
+				t.Fatalf("created %d queues, want 1", len(queues.created))
+			}
+			queue := queues.created[0]
+			if got := strings.HasSuffix(queue.name, ".fifo"); got != tt.fifo {
+				t.Errorf("queue name %q, want FIFO %v", queue.name, tt.fifo)
+			}
+			if got := queue.attributes[string(sqstypes.QueueAttributeNameFifoQueue)] == "true"; got != tt.fifo {
+				t.Errorf("queue attributes %v, want FIFO %v", queue.attributes, tt.fifo)
+			}
+			if policy := queue.attributes[string(sqstypes.QueueAttributeNamePolicy)]; !strings.Contains(policy, tt.topicARN) {
+				t.Errorf("queue policy does not name the topic: %s", policy)
+			}
+
+			published := tt.client.published
+			if tt.wantErr != denied {
+				if len(published) != 1 {
+					t.Fatalf("published %d messages, want 1", len(published))
+				}
+				if got := aws.ToString(published[0].MessageGroupId) != ""; got != tt.fifo {
+					t.Errorf("MessageGroupId = %q, want FIFO %v", aws.ToString(published[0].MessageGroupId), tt.fifo)
+				}
+			}
+
+			// Nothing is left behind, whatever the outcome
+			if len(queues.queues) != 0 {
+				t.Errorf("%d self-test queues left behind", len(queues.queues))
+			}
+			if len(tt.client.subscriptions) != 0 {
+				t.Errorf("%d self-test subscriptions left behind", len(tt.client.subscriptions))
+			}
+		})
+	}
+}
+
+func TestSelfTestHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		drop   bool
+		want   int
+	}{
+		{"success", http.MethodPost, false, http.StatusOK},
+		{"failure", http.MethodPost, true, http.StatusServiceUnavailable},
+		{"wrong method", http.MethodGet, false, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queues := newFakeSQS()
+			queues.drop = tt.drop
+			handler := SelfTestHandler(newSelfTestPublisher(testTopicARN, &fakeSNS{}, queues))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/selftest", nil))
+			if rec.Code != tt.want {
+				t.Errorf("%s /selftest = %d, want %d (%s)", tt.method, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ProgressFunc is called as an upload reads its file, with the bytes read so far
+// and the file's size. Parts of a multipart upload are read concurrently, so it
+// may be called from several goroutines, though never at once.
+type ProgressFunc func(sent, total int64)
+
+// progressReader wraps a file being uploaded and reports how far into it the
+// upload has read. It keeps the file's Seek and ReadAt, so the upload manager
+// reads it exactly as it would the bare file. Re-reads (e.g. after a seek back
+// to sign or checksum the body) don't count twice: progress is the furthest
+// offset reached.
+type progressReader struct {
+	file     *os.File
+	total    int64
+	progress ProgressFunc
+
+	// pos is the offset sequential Reads have reached
+	pos int64
+
+	mu   sync.Mutex
+	sent int64
+}
+
+// newProgressReader wraps file, whose size is total, reporting to progress
+func newProgressReader(file *os.File, total int64, progress ProgressFunc) *progressReader {
+	return &progressReader{file: file, total: total, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.file.Read(p)
+	r.pos += int64(n)
+	r.advance(r.pos)
+	return n, err
+}
+
+func (r *progressReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.file.ReadAt(p, off)
+	r.advance(off + int64(n))
+	return n, err
+}
+
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.file.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+// advance reports progress if end is further into the file than before
+func (r *progressReader) advance(end int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if end <= r.sent {
+		return
+	}
+	r.sent = end
+	r.progress(end, r.total)
+}
+
+// logProgress returns a ProgressFunc that logs when an upload passes 25, 50, 75
+// and 100% of its file
+func logProgress(filename, key string) ProgressFunc {
+	logged := 0
+	return func(sent, total int64) {
+		if total <= 0 {
+			return
+		}
+		quarter := int(sent * 4 / total)
+		if quarter <= logged {
+			return
+		}
+		logged = quarter
+		slog.Info("Upload progress", "filename", filename, "s3_key", key, "percent", quarter*25,
+			"sent_bytes", sent, "size_bytes", total)
+	}
+}
@@ -0,0 +1,125 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// WebhookNotifierOptions configures a webhook notifier
+type WebhookNotifierOptions struct {
+	// Timeout bounds each POST attempt
+	Timeout time.Duration
+
+	// Token, if set, is sent as "Authorization: Bearer <token>"
+	Token string
+}
+
+// DefaultWebhookNotifierOptions returns the default webhook notifier options
+func DefaultWebhookNotifierOptions() WebhookNotifierOptions {
+	return WebhookNotifierOptions{
+		Timeout: 10 * time.Second,
+	}
+}
+
+// httpStatusError is a non-2xx response from a plain HTTP endpoint, such as
+// the webhook or a presigned S3 URL
+type httpStatusError struct {
+	target string
+	status int
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s returned %d %s: %s", e.target, e.status, http.StatusText(e.status), e.body)
+}
+
+// WebhookNotifier POSTs each notification's JSON to an HTTP endpoint, e.g. a
+// home-automation hub, retrying network errors, 5xx and 429 responses
+type WebhookNotifier struct {
+	url    string
+	opts   WebhookNotifierOptions
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that POSTs to url
+func NewWebhookNotifier(url string, opts WebhookNotifierOptions) *WebhookNotifier {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookNotifierOptions().Timeout
+	}
+	return &WebhookNotifier{
+		url:    url,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// PublishNotification POSTs the notification, retrying transient failures.
+// Returns an error if it was not accepted with a 2xx response.
+func (w *WebhookNotifier) PublishNotification(ctx context.Context, notification VideoNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	retryConfig := utils.DefaultRetryConfig(fmt.Sprintf("webhook %s", notification.S3Key))
+	retryConfig.IsRetryable = isRetryableHTTPError
+
+	err = utils.RetryWithBackoff(ctx, retryConfig, func() error {
+		return w.post(ctx, body)
+	})
+	if err != nil {
+		metrics.Default.Inc(metrics.PublishFailed)
+		return fmt.Errorf("failed to deliver webhook notification: %w", err)
+	}
+
+	slog.Info("Delivered notification to webhook", "s3_key", notification.S3Key, "event_type", notification.EventType)
+	return nil
+}
+
+// post makes one POST attempt
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.opts.Token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &httpStatusError{target: "webhook", status: resp.StatusCode, body: strings.TrimSpace(string(detail))}
+	}
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// isRetryableHTTPError reports whether a failed request is worth retrying:
+// network errors and timeouts, 5xx responses, 408 and 429
+func isRetryableHTTPError(err error) bool {
+	var statusErr *httpStatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.status >= 500 || statusErr.status == http.StatusRequestTimeout || statusErr.status == http.StatusTooManyRequests
+}
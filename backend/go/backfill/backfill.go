@@ -0,0 +1,181 @@
+// Package backfill pushes a directory of existing videos through the upload and
+// notification pipeline on demand, e.g. clips that piled up while offline.
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/watcher"
+	"golang.org/x/sync/errgroup"
+)
+
+// Default number of videos processed at once
+const defaultConcurrency = 4
+
+// Uploader uploads videos; satisfied by *aws.S3Uploader
+type Uploader interface {
+	Upload(ctx context.Context, filePath string, metadata map[string]string) (awspackage.UploadResult, error)
+	MoveToFailedDir(filePath string) error
+}
+
+// Publisher builds and publishes video notifications; satisfied by *aws.SNSPublisher
+type Publisher interface {
+	Publish(ctx context.Context, upload awspackage.UploadResult, cloudFrontDomain string, event awspackage.DetectionEvent) error
+	BuildNotification(ctx context.Context, upload awspackage.UploadResult, cloudFrontDomain string, event awspackage.DetectionEvent) (awspackage.VideoNotification, error)
+}
+
+// Options configures a backfill run
+type Options struct {
+	// Concurrency is how many videos are processed at once
+	Concurrency int
+
+	// Notify publishes a notification for each uploaded video
+	Notify bool
+
+	// Notifier, if set, delivers the notifications the publisher builds
+	// instead of the publisher itself, as NOTIFIER=file does for the watcher
+	Notifier awspackage.Notifier
+
+	// MoveFailed moves videos that fail to upload or notify to the failed
+	// upload directory, as the live path does, so they are retried later
+	MoveFailed bool
+
+	// Extensions selects the files to backfill, e.g. ".mp4"
+	Extensions []string
+
+	CloudFrontDomain string
+}
+
+// Failure records a video that could not be backfilled
+type Failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Summary is the outcome of a backfill run
+type Summary struct {
+	Total      int       `json:"total"`
+	Succeeded  int       `json:"succeeded"`
+	Failed     int       `json:"failed"`
+	DurationMs int64     `json:"duration_ms"`
+	Failures   []Failure `json:"failures,omitempty"`
+}
+
+// Run uploads every video directly inside dir and, if enabled, publishes its
+// notification. Videos are left in place once backfilled. It only returns an
+// error if dir can't be read; per-video failures are in the summary.
+func Run(ctx context.Context, dir string, uploader Uploader, publisher Publisher, opts Options) (Summary, error) {
+	start := time.Now()
+	files, err := videoFiles(dir, opts.Extensions)
+	if err != nil {
+		return Summary{}, err
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = defaultConcurrency
+	}
+	slog.Info("Backfilling videos", "dir", dir, "videos", len(files), "concurrency", opts.Concurrency, "notify", opts.Notify)
+
+	summary := Summary{Total: len(files)}
+	var mu sync.Mutex
+
+	var group errgroup.Group
+	group.SetLimit(opts.Concurrency)
+	for _, path := range files {
+		group.Go(func() error {
+			err := backfillVideo(ctx, dir, path, uploader, publisher, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				summary.Failed++
+				summary.Failures = append(summary.Failures, Failure{Path: path, Error: err.Error()})
+				slog.Error("Failed to backfill video", "path", path, "error", err)
+				// Upload moves some failures to the failed directory itself
+				if _, statErr := os.Stat(path); opts.MoveFailed && statErr == nil {
+					if moveErr := uploader.MoveToFailedDir(path); moveErr != nil {
+						slog.Error("Failed to move video to failed upload directory", "path", path, "error", moveErr)
+					}
+				}
+				return nil
+			}
+			summary.Succeeded++
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	sort.Slice(summary.Failures, func(i, j int) bool { return summary.Failures[i].Path < summary.Failures[j].Path })
+	summary.DurationMs = time.Since(start).Milliseconds()
+	slog.Info("Backfill complete", "dir", dir, "succeeded", summary.Succeeded, "failed", summary.Failed, "duration_ms", summary.DurationMs)
+	return summary, nil
+}
+
+// backfillVideo uploads one video and publishes its notification
+func backfillVideo(ctx context.Context, dir, path string, uploader Uploader, publisher Publisher, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The backfilled directory stands in for the watched one, naming the camera
+	upload, err := uploader.Upload(ctx, path, watcher.VideoMetadata(path, []string{dir}))
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	slog.Info("Backfilled video", "path", path, "s3_key", upload.Key, "skipped", upload.Skipped)
+
+	if !opts.Notify {
+		return nil
+	}
+	if err := publish(ctx, upload, publisher, opts); err != nil {
+		return fmt.Errorf("uploaded as %s but notification failed: %w", upload.Key, err)
+	}
+	return nil
+}
+
+// publish sends an upload's notification through the notifier, if one is set,
+// or the publisher
+func publish(ctx context.Context, upload awspackage.UploadResult, publisher Publisher, opts Options) error {
+	event := awspackage.DefaultDetectionEvent()
+	if opts.Notifier == nil {
+		return publisher.Publish(ctx, upload, opts.CloudFrontDomain, event)
+	}
+
+	notification, err := publisher.BuildNotification(ctx, upload, opts.CloudFrontDomain, event)
+	if err != nil {
+		return err
+	}
+	return opts.Notifier.PublishNotification(ctx, notification)
+}
+
+// videoFiles lists the regular files directly inside dir with one of the
+// extensions, sorted by name
+func videoFiles(dir string, extensions []string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		for _, videoExt := range extensions {
+			if ext == videoExt {
+				files = append(files, filepath.Join(dir, entry.Name()))
+				break
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,81 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/lachiem1/eyeSeeYou/backend/go/buildinfo.Commit=$(git rev-parse HEAD)"
+//
+// When unset they are filled from the VCS information Go embeds in builds made
+// inside a git checkout.
+var (
+	// Version is the release version
+	Version = "dev"
+	// Commit is the git SHA the binary was built from
+	Commit = ""
+	// BuildTime is when the binary was built (or its commit was made), RFC 3339
+	BuildTime = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	// Modified is true when the binary was built from a checkout with uncommitted changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information, preferring values set with -ldflags
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// String formats the build information as "<version> (<short commit>, <build time>)",
+// the version identifying a binary in logs and notifications
+func (i Info) String() string {
+	if i.Commit == "" {
+		return i.Version
+	}
+	commit := i.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if i.Modified {
+		commit += "-dirty"
+	}
+	if i.BuildTime == "" {
+		return fmt.Sprintf("%s (%s)", i.Version, commit)
+	}
+	return fmt.Sprintf("%s (%s, %s)", i.Version, commit, i.BuildTime)
+}
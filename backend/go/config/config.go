@@ -3,17 +3,205 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the backend
 type Config struct {
-	AWSRegion        string
-	S3Bucket         string
-	SNSTopicARN      string
-	VideoDir         string
-	CloudFrontDomain string
+	AWSRegion             string
+	AWSEndpointURL        string
+	S3Bucket              string
+	S3AutodetectRegion    bool
+	S3DuplicateKeyPolicy  string
+	S3StorageClass        string
+	S3ContentTypes        map[string]string
+	S3SSEKMSKeyID         string
+	S3KeyPrefix           string
+	S3KeyTemplate         string
+	S3PartSizeMB          int
+	S3UploadConcurrency   int
+	S3UploadTimeout       time.Duration
+	S3MinUploadKBps       int
+	S3CircuitThreshold    int
+	S3CircuitCooldown     time.Duration
+	S3Manifest            bool
+	S3ManifestPrefix      string
+	S3FilenameIndex       bool
+	S3FilenameIndexPrefix string
+	DedupIndexFile        string
+	DedupWindow           time.Duration
+	DedupMaxEntries       int
+	SNSTopicARN           string
+	Notifier              string
+	NotifierFile          string
+	NotifierFileMaxMB     int
+	NotifierFileBackups   int
+	WebhookURL            string
+	WebhookTimeout        time.Duration
+	WebhookToken          string
+	VideoDir              string
+	VideoDirs             []string
+	VideoExtensions       []string
+	Recursive             bool
+	MaxVideoSizeMB        int
+	VideoSignatureCheck   bool
+	RejectedDir           string
+	KeepLocal             bool
+	LocalRetention        time.Duration
+	ProcessedDir          string
+	FailedUploadDir       string
+	FailedUploadMaxMB     int
+	MinFreeDiskMB         int
+	DiskCheckInterval     time.Duration
+	CloudFrontDomain      string
+	HealthPort            int
+	RecentEventsSize      int
+	MetricsResetEnabled   bool
+	MetricsResetToken     string
+	CloudWatchMetrics     bool
+	CloudWatchNamespace   string
+	CloudWatchFlush       time.Duration
+	SigningRetries        int
+	SigningFallback       string
+	SNSSanitizeMode       string
+	SNSTransforms         string
+	SNSPushPlatforms      []string
+	SNSProtocols          []string
+	NotifyBackendVersion  bool
+	SNSRoutes             []SNSRoute
+	SNSSelfTestEnabled    bool
+	SNSSelfTestTimeout    time.Duration
+	SelfTestTopicARN      string
+	OpsAlertTopicARN      string
+	OpsAlertThreshold     int
+	OpsAlertWindow        time.Duration
+	OpsAlertCooldown      time.Duration
+	URLExpiration         time.Duration
+	ThumbnailURLTTL       time.Duration
+	KeyRefreshInterval    time.Duration
+	KeyPairID             string
+	KeyPairIDParameter    string
+	PrivateKeyFile        string
+	PrivateKeyPEM         string
+
+	// Notification batching: a batch is published when any threshold is hit
+	BatchWindow   time.Duration
+	BatchMaxCount int
+	BatchMaxBytes int
+
+	// Optional ffmpeg transcoding to H.264/AAC before upload, or a
+	// remux-only conversion of non-MP4 containers with compatible codecs
+	Transcode     bool
+	Remux         bool
+	TranscodeArgs []string
+	TranscodeDir  string
+	FFmpegPath    string
+
+	// Optional scrub preview (sprite sheet + WebVTT) uploaded alongside each video
+	Preview         bool
+	PreviewInterval time.Duration
+	FFprobePath     string
+
+	// Optional first-keyframe JPEG uploaded under ThumbnailPrefix, mirroring the
+	// video's key beneath S3_KEY_PREFIX, and its width in pixels
+	Thumbnail       bool
+	ThumbnailPrefix string
+	ThumbnailWidth  int
+
+	// How often files in the failed upload directory are retried; 0 disables
+	FailedUploadRetryInterval time.Duration
+
+	// How long shutdown waits for in-flight uploads before abandoning them, and
+	// what to clear once they have drained
+	ShutdownTimeout time.Duration
+	ShutdownCleanup string
+
+	// Wall-clock jump detection; a zero interval disables it
+	ClockDriftInterval  time.Duration
+	ClockDriftThreshold time.Duration
+
+	// Backoff strategy for AWS retries (exponential or decorrelated), and the
+	// most time one operation may spend retrying; 0 leaves it bounded by attempts
+	RetryStrategy   string
+	RetryMaxElapsed time.Duration
+
+	// AWS error codes to retry in addition to the built-in ones, and codes never
+	// to retry; a code in both lists is not retried
+	RetryableErrorCodes    []string
+	NonRetryableErrorCodes []string
+
+	// Logging: level is debug, info, warn or error; format is text or json
+	LogLevel  string
+	LogFormat string
+
+	// Repeated events for the same path within this window are ignored; 0 disables
+	EventDebounceWindow time.Duration
+
+	// A new video is processed once no writes to it have been seen for this long; 0 processes it at once
+	SettleDuration time.Duration
+
+	// How often watched directories are checked for having vanished or been
+	// remounted (0 disables), and how many failed re-watches are tolerated
+	WatchRevalidateInterval time.Duration
+	WatchRecoveryAttempts   int
+
+	// How new videos are noticed: fsnotify events, or polling for filesystems
+	// that deliver none (some network mounts and SD cards), every WatchPollInterval
+	WatchMode         string
+	WatchPollInterval time.Duration
+
+	// Concurrent AWS dependency checks: their shared deadline, what a failed
+	// startup check does (off, warn or fail), and whether /readyz runs them
+	DependencyCheckTimeout  time.Duration
+	PreflightCheck          string
+	ReadyzCheckDependencies bool
+	// Refuse to start unless a test object can be written to the bucket and the
+	// SNS topic can be read, so missing IAM permissions show up at deploy time
+	StartupSelfTest bool
+
+	// Per-file processing deadline, with an early warning at a percentage of it
+	ProcessTimeout            time.Duration
+	ProcessTimeoutWarnPercent int
+
+	// Workers processing videos, and whether each source directory's videos
+	// are processed one at a time in order
+	ProcessConcurrency    int
+	ProcessOrderPerSource bool
+
+	// Videos waiting for a worker: how many may wait, and whether a video
+	// arriving at a full queue is dropped at once or after waiting
+	// ProcessQueueOfferTimeout for room (drop or block)
+	ProcessQueueSize         int
+	ProcessQueuePolicy       string
+	ProcessQueueOfferTimeout time.Duration
+
+	// What a panic while processing a file does: recover or crash
+	ProcessPanicPolicy string
+
+	// Move videos whose sidecar is empty or malformed to RejectedDir instead of using the default event
+	SidecarStrict bool
+
+	// DynamoDB table each upload is recorded in (empty disables it), and the
+	// bounds on its writes
+	DynamoDBTable               string
+	DynamoDBMaxConcurrentWrites int
+	DynamoDBWritesPerSecond     int
+
+	// How a video's outcome follows from its notification sinks (SNS, manifest,
+	// filename index, DynamoDB): all, any, or required (every sink in RequiredSinks)
+	SinkSuccessPolicy string
+	RequiredSinks     []string
+}
+
+// SNSRoute sends notifications for matching videos to their own topic
+type SNSRoute struct {
+	// Match is a watched directory (an absolute path) or a filename prefix
+	Match    string
+	TopicARN string
 }
 
 // LoadConfig loads configuration from environment variables
@@ -22,22 +210,282 @@ func LoadConfig() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		AWSRegion:        getEnv("AWS_REGION", "ap-southeast-2"),
-		S3Bucket:         getEnv("S3_BUCKET", ""),
-		SNSTopicARN:      getEnv("SNS_TOPIC_ARN", ""),
-		VideoDir:         getEnv("VIDEO_DIR", "/tmp/videos"),
-		CloudFrontDomain: getEnv("CLOUDFRONT_DOMAIN", ""),
+		AWSRegion:             getEnv("AWS_REGION", "ap-southeast-2"),
+		AWSEndpointURL:        getEnv("AWS_ENDPOINT_URL", ""),
+		S3Bucket:              getEnv("S3_BUCKET", ""),
+		SNSTopicARN:           getEnv("SNS_TOPIC_ARN", ""),
+		Notifier:              strings.ToLower(getEnv("NOTIFIER", "sns")),
+		NotifierFile:          getEnv("NOTIFIER_FILE", "/tmp/eyeseeyou-notifications.jsonl"),
+		WebhookURL:            getEnv("WEBHOOK_URL", ""),
+		WebhookToken:          getEnv("WEBHOOK_TOKEN", ""),
+		VideoDir:              getEnv("VIDEO_DIR", "/tmp/videos"),
+		CloudFrontDomain:      getEnv("CLOUDFRONT_DOMAIN", ""),
+		SigningFallback:       strings.ToLower(getEnv("SIGNING_FALLBACK", "fail")),
+		KeyPairID:             getEnv("CLOUDFRONT_KEY_PAIR_ID", ""),
+		KeyPairIDParameter:    getEnv("CLOUDFRONT_KEY_PAIR_ID_PARAM", ""),
+		PrivateKeyFile:        getEnv("CLOUDFRONT_PRIVATE_KEY_FILE", ""),
+		PrivateKeyPEM:         getEnv("CLOUDFRONT_PRIVATE_KEY", ""),
+		S3DuplicateKeyPolicy:  strings.ToLower(getEnv("S3_DUPLICATE_KEY_POLICY", "overwrite")),
+		S3StorageClass:        strings.ToUpper(getEnv("S3_STORAGE_CLASS", "STANDARD")),
+		S3SSEKMSKeyID:         getEnv("S3_SSE_KMS_KEY_ID", ""),
+		S3ManifestPrefix:      getEnv("S3_MANIFEST_PREFIX", "manifests/"),
+		S3FilenameIndexPrefix: getEnv("S3_FILENAME_INDEX_PREFIX", "index/filenames/"),
+		SNSSanitizeMode:       strings.ToLower(getEnv("SNS_SANITIZE", "lenient")),
+		SNSTransforms:         getEnv("SNS_TRANSFORMS", ""),
+		SelfTestTopicARN:      getEnv("SELFTEST_TOPIC_ARN", ""),
+		OpsAlertTopicARN:      getEnv("OPS_ALERT_TOPIC_ARN", ""),
+		TranscodeArgs:         strings.Fields(getEnv("TRANSCODE_ARGS", "")),
+		TranscodeDir:          getEnv("TRANSCODE_DIR", "/tmp/videos-transcode"),
+		RejectedDir:           getEnv("REJECTED_DIR", "/tmp/videos-rejected"),
+		ProcessedDir:          getEnv("PROCESSED_DIR", "/tmp/videos-processed"),
+		FailedUploadDir:       getEnv("FAILED_UPLOAD_DIR", "/tmp/videos-failed-upload"),
+		DedupIndexFile:        getEnv("DEDUP_INDEX_FILE", ""),
+		DynamoDBTable:         getEnv("DYNAMODB_TABLE", ""),
+		FFmpegPath:            getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:           getEnv("FFPROBE_PATH", "ffprobe"),
+		MetricsResetToken:     getEnv("METRICS_RESET_TOKEN", ""),
+		CloudWatchNamespace:   getEnv("CLOUDWATCH_NAMESPACE", "EyeSeeYou"),
+		ShutdownCleanup:       strings.ToLower(getEnv("SHUTDOWN_CLEANUP", "keep")),
+		LogLevel:              strings.ToLower(getEnv("LOG_LEVEL", "info")),
+		RetryStrategy:         strings.ToLower(getEnv("RETRY_STRATEGY", "exponential")),
+		LogFormat:             strings.ToLower(getEnv("LOG_FORMAT", "text")),
+		ProcessPanicPolicy:    strings.ToLower(getEnv("PROCESS_PANIC_POLICY", "recover")),
+		WatchMode:             strings.ToLower(getEnv("WATCH_MODE", "fsnotify")),
+		ProcessQueuePolicy:    strings.ToLower(getEnv("PROCESS_QUEUE_POLICY", "drop")),
+		PreflightCheck:        strings.ToLower(getEnv("PREFLIGHT_CHECK", "warn")),
+		SinkSuccessPolicy:     strings.ToLower(getEnv("SINK_SUCCESS_POLICY", "required")),
+	}
+
+	// VIDEO_DIRS takes precedence over the single VIDEO_DIR
+	cfg.VideoDirs = getEnvList("VIDEO_DIRS", []string{cfg.VideoDir})
+	cfg.S3KeyPrefix = normalizeKeyPrefix(getEnv("S3_KEY_PREFIX", "videos/"))
+	cfg.S3KeyTemplate = getEnv("S3_KEY_TEMPLATE", "{filename}")
+	cfg.ThumbnailPrefix = normalizeKeyPrefix(getEnv("THUMBNAIL_PREFIX", "thumbnails/"))
+	cfg.RetryableErrorCodes = getEnvList("RETRYABLE_ERROR_CODES", nil)
+	cfg.NonRetryableErrorCodes = getEnvList("NON_RETRYABLE_ERROR_CODES", nil)
+	cfg.SNSPushPlatforms = getEnvList("SNS_PUSH_PLATFORMS", nil)
+	cfg.SNSProtocols = getEnvList("SNS_PROTOCOL_MESSAGES", nil)
+	cfg.VideoExtensions = normalizeExtensions(getEnvList("VIDEO_EXTENSIONS", []string{".mp4"}))
+	cfg.RequiredSinks = getEnvList("REQUIRED_SINKS", []string{"sns"})
+	for i, sink := range cfg.RequiredSinks {
+		cfg.RequiredSinks[i] = strings.ToLower(sink)
 	}
 
-	// Validate required fields
-	if cfg.S3Bucket == "" {
-		return nil, fmt.Errorf("S3_BUCKET environment variable is required")
+	var err error
+	if cfg.SNSRoutes, err = parseSNSRoutes(getEnvList("SNS_TOPIC_ROUTES", nil)); err != nil {
+		return nil, err
+	}
+	if cfg.S3ContentTypes, err = parseContentTypes(getEnvList("S3_CONTENT_TYPES", nil)); err != nil {
+		return nil, err
+	}
+	if cfg.S3AutodetectRegion, err = getEnvBool("S3_AUTODETECT_REGION", false); err != nil {
+		return nil, err
+	}
+	if cfg.S3PartSizeMB, err = getEnvInt("S3_PART_SIZE_MB", 5); err != nil {
+		return nil, err
+	}
+	if cfg.S3UploadConcurrency, err = getEnvInt("S3_UPLOAD_CONCURRENCY", 5); err != nil {
+		return nil, err
+	}
+	if cfg.S3UploadTimeout, err = getEnvDuration("S3_UPLOAD_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.S3MinUploadKBps, err = getEnvInt("S3_MIN_UPLOAD_KBPS", 256); err != nil {
+		return nil, err
+	}
+	if cfg.S3CircuitThreshold, err = getEnvInt("S3_CIRCUIT_BREAKER_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.S3CircuitCooldown, err = getEnvDuration("S3_CIRCUIT_BREAKER_COOLDOWN", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.S3Manifest, err = getEnvBool("S3_MANIFEST", false); err != nil {
+		return nil, err
+	}
+	if cfg.NotifierFileMaxMB, err = getEnvInt("NOTIFIER_FILE_MAX_MB", 10); err != nil {
+		return nil, err
+	}
+	if cfg.NotifierFileBackups, err = getEnvInt("NOTIFIER_FILE_BACKUPS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookTimeout, err = getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DedupWindow, err = getEnvDuration("DEDUP_WINDOW", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.DedupMaxEntries, err = getEnvInt("DEDUP_MAX_ENTRIES", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.NotifyBackendVersion, err = getEnvBool("NOTIFY_BACKEND_VERSION", false); err != nil {
+		return nil, err
+	}
+	if cfg.SNSSelfTestEnabled, err = getEnvBool("SNS_SELF_TEST_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.SNSSelfTestTimeout, err = getEnvDuration("SNS_SELF_TEST_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.S3FilenameIndex, err = getEnvBool("S3_FILENAME_INDEX", false); err != nil {
+		return nil, err
+	}
+	if cfg.DynamoDBMaxConcurrentWrites, err = getEnvInt("DYNAMODB_MAX_CONCURRENT_WRITES", 4); err != nil {
+		return nil, err
+	}
+	if cfg.DynamoDBWritesPerSecond, err = getEnvInt("DYNAMODB_WRITES_PER_SECOND", 5); err != nil {
+		return nil, err
+	}
+	if cfg.HealthPort, err = getEnvInt("HEALTH_PORT", 8080); err != nil {
+		return nil, err
+	}
+	if cfg.RecentEventsSize, err = getEnvInt("RECENT_EVENTS_SIZE", 50); err != nil {
+		return nil, err
+	}
+	if cfg.DependencyCheckTimeout, err = getEnvDuration("DEPENDENCY_CHECK_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ReadyzCheckDependencies, err = getEnvBool("READYZ_CHECK_DEPENDENCIES", false); err != nil {
+		return nil, err
+	}
+	if cfg.StartupSelfTest, err = getEnvBool("STARTUP_SELFTEST", false); err != nil {
+		return nil, err
+	}
+	if cfg.MetricsResetEnabled, err = getEnvBool("METRICS_RESET_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.OpsAlertThreshold, err = getEnvInt("OPS_ALERT_THRESHOLD", 10); err != nil {
+		return nil, err
+	}
+	if cfg.OpsAlertWindow, err = getEnvDuration("OPS_ALERT_WINDOW", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.OpsAlertCooldown, err = getEnvDuration("OPS_ALERT_COOLDOWN", 30*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.CloudWatchMetrics, err = getEnvBool("CLOUDWATCH_METRICS", false); err != nil {
+		return nil, err
+	}
+	if cfg.CloudWatchFlush, err = getEnvDuration("CLOUDWATCH_FLUSH_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.SigningRetries, err = getEnvInt("SIGNING_RETRIES", 2); err != nil {
+		return nil, err
+	}
+	if cfg.URLExpiration, err = getEnvDuration("URL_EXPIRATION", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+	// Thumbnails follow the video expiry unless given their own
+	if cfg.ThumbnailURLTTL, err = getEnvDuration("THUMBNAIL_URL_TTL", cfg.URLExpiration); err != nil {
+		return nil, err
+	}
+	if cfg.KeyRefreshInterval, err = getEnvDuration("CLOUDFRONT_KEY_REFRESH_INTERVAL", 1*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.BatchWindow, err = getEnvDuration("BATCH_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.BatchMaxCount, err = getEnvInt("BATCH_MAX_COUNT", 10); err != nil {
+		return nil, err
+	}
+	if cfg.BatchMaxBytes, err = getEnvInt("BATCH_MAX_BYTES", 200*1024); err != nil {
+		return nil, err
+	}
+	if cfg.Transcode, err = getEnvBool("TRANSCODE", false); err != nil {
+		return nil, err
+	}
+	if cfg.Remux, err = getEnvBool("REMUX", false); err != nil {
+		return nil, err
+	}
+	if cfg.Preview, err = getEnvBool("PREVIEW", false); err != nil {
+		return nil, err
+	}
+	if cfg.PreviewInterval, err = getEnvDuration("PREVIEW_INTERVAL", 1*time.Second); err != nil {
+		return nil, err
 	}
-	if cfg.SNSTopicARN == "" {
-		return nil, fmt.Errorf("SNS_TOPIC_ARN environment variable is required")
+	if cfg.Thumbnail, err = getEnvBool("THUMBNAIL", false); err != nil {
+		return nil, err
 	}
-	if cfg.CloudFrontDomain == "" {
-		return nil, fmt.Errorf("CLOUDFRONT_DOMAIN environment variable is required")
+	if cfg.ThumbnailWidth, err = getEnvInt("THUMBNAIL_WIDTH", 320); err != nil {
+		return nil, err
+	}
+	if cfg.FailedUploadRetryInterval, err = getEnvDuration("FAILED_UPLOAD_RETRY_INTERVAL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.ClockDriftInterval, err = getEnvDuration("CLOCK_DRIFT_CHECK_INTERVAL", 1*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.ClockDriftThreshold, err = getEnvDuration("CLOCK_DRIFT_THRESHOLD", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.RetryMaxElapsed, err = getEnvDuration("RETRY_MAX_ELAPSED", 0); err != nil {
+		return nil, err
+	}
+	if cfg.EventDebounceWindow, err = getEnvDuration("EVENT_DEBOUNCE_WINDOW", 2*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.SettleDuration, err = getEnvDuration("SETTLE_DURATION", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WatchRevalidateInterval, err = getEnvDuration("WATCH_REVALIDATE_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WatchRecoveryAttempts, err = getEnvInt("WATCH_RECOVERY_ATTEMPTS", 3); err != nil {
+		return nil, err
+	}
+	if cfg.WatchPollInterval, err = getEnvDuration("WATCH_POLL_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.SidecarStrict, err = getEnvBool("SIDECAR_STRICT", false); err != nil {
+		return nil, err
+	}
+	if cfg.Recursive, err = getEnvBool("RECURSIVE", false); err != nil {
+		return nil, err
+	}
+	if cfg.MaxVideoSizeMB, err = getEnvInt("MAX_VIDEO_SIZE_MB", 0); err != nil {
+		return nil, err
+	}
+	if cfg.VideoSignatureCheck, err = getEnvBool("VIDEO_SIGNATURE_CHECK", true); err != nil {
+		return nil, err
+	}
+	if cfg.KeepLocal, err = getEnvBool("KEEP_LOCAL", false); err != nil {
+		return nil, err
+	}
+	if cfg.LocalRetention, err = getEnvDuration("LOCAL_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.FailedUploadMaxMB, err = getEnvInt("FAILED_UPLOAD_MAX_MB", 100); err != nil {
+		return nil, err
+	}
+	if cfg.MinFreeDiskMB, err = getEnvInt("MIN_FREE_DISK_MB", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DiskCheckInterval, err = getEnvDuration("DISK_CHECK_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ProcessTimeout, err = getEnvDuration("PROCESS_TIMEOUT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ProcessTimeoutWarnPercent, err = getEnvInt("PROCESS_TIMEOUT_WARN_PERCENT", 80); err != nil {
+		return nil, err
+	}
+	if cfg.ProcessConcurrency, err = getEnvInt("PROCESS_CONCURRENCY", 4); err != nil {
+		return nil, err
+	}
+	if cfg.ProcessOrderPerSource, err = getEnvBool("PROCESS_ORDER_PER_SOURCE", false); err != nil {
+		return nil, err
+	}
+	if cfg.ProcessQueueSize, err = getEnvInt("PROCESS_QUEUE_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.ProcessQueueOfferTimeout, err = getEnvDuration("PROCESS_QUEUE_OFFER_TIMEOUT", time.Second); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
@@ -51,3 +499,113 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt gets an integer environment variable with a fallback default value
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// getEnvBool gets a boolean environment variable with a fallback default value
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// getEnvDuration gets a duration environment variable (e.g. "90s", "5m") with a fallback default value
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// getEnvList gets a comma-separated environment variable as a list, dropping empty entries
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSNSRoutes parses "match=topic-arn" entries
+func parseSNSRoutes(entries []string) ([]SNSRoute, error) {
+	routes := make([]SNSRoute, 0, len(entries))
+	for _, entry := range entries {
+		match, topicARN, ok := strings.Cut(entry, "=")
+		match, topicARN = strings.TrimSpace(match), strings.TrimSpace(topicARN)
+		if !ok || match == "" || topicARN == "" {
+			return nil, fmt.Errorf("SNS_TOPIC_ROUTES entry %q must be <directory or filename prefix>=<topic ARN>", entry)
+		}
+		routes = append(routes, SNSRoute{Match: match, TopicARN: topicARN})
+	}
+	return routes, nil
+}
+
+// parseContentTypes parses "extension=content-type" entries
+func parseContentTypes(entries []string) (map[string]string, error) {
+	contentTypes := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		ext, contentType, ok := strings.Cut(entry, "=")
+		ext, contentType = strings.TrimSpace(ext), strings.TrimSpace(contentType)
+		if !ok || ext == "" || contentType == "" {
+			return nil, fmt.Errorf("S3_CONTENT_TYPES entry %q must be <extension>=<content type>", entry)
+		}
+		contentTypes[normalizeExtensions([]string{ext})[0]] = contentType
+	}
+	return contentTypes, nil
+}
+
+// normalizeExtensions lowercases extensions and ensures each has a leading dot
+func normalizeExtensions(exts []string) []string {
+	normalized := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized
+}
+
+// normalizeKeyPrefix strips leading slashes and ensures a trailing one,
+// so "prod/videos" and "/prod/videos/" both become "prod/videos/"
+func normalizeKeyPrefix(prefix string) string {
+	prefix = strings.TrimLeft(strings.TrimSpace(prefix), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestNormalizeKeyPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"videos/", "videos/"},
+		{"prod/videos", "prod/videos/"},
+		{"/staging/videos/", "staging/videos/"},
+		{" prod/videos ", "prod/videos/"},
+		{"", ""},
+		{"/", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeKeyPrefix(tt.prefix); got != tt.want {
+			t.Errorf("normalizeKeyPrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestParseContentTypes(t *testing.T) {
+	got, err := parseContentTypes([]string{"mkv=video/webm", " .H264 = video/h264 "})
+	if err != nil {
+		t.Fatalf("parseContentTypes: %v", err)
+	}
+	want := map[string]string{".mkv": "video/webm", ".h264": "video/h264"}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseContentTypes = %v, want %v", got, want)
+	}
+
+	for _, entry := range []string{"mkv", "=video/webm", "mkv="} {
+		if _, err := parseContentTypes([]string{entry}); err == nil {
+			t.Errorf("parseContentTypes accepted %q", entry)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// arn:<partition>:sns:<region>:<account>:<topic>, where FIFO topics end in .fifo
+	snsTopicARNPattern = regexp.MustCompile(`^arn:aws(-[a-z]+)*:sns:[a-z]{2}(-[a-z]+)+-\d+:\d{12}:[A-Za-z0-9_-]{1,256}(\.fifo)?$`)
+
+	// Region names such as ap-southeast-2, us-gov-west-1 or eu-central-1
+	awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+	// S3 bucket naming rules: 3-63 lowercase letters, digits, dots and hyphens,
+	// starting and ending with a letter or digit
+	s3BucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+	// A DNS label: letters, digits and hyphens, not starting or ending with a hyphen
+	hostLabelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+)
+
+// validate checks the loaded configuration, returning every problem found
+// rather than stopping at the first so typos can be fixed in one go
+func (cfg *Config) validate() error {
+	var errs []error
+
+	// Required AWS resources, checked for shape so typos fail at startup
+	// rather than on the first clip
+	if cfg.S3Bucket == "" {
+		errs = append(errs, fmt.Errorf("S3_BUCKET environment variable is required"))
+	} else if !isValidBucketName(cfg.S3Bucket) {
+		errs = append(errs, fmt.Errorf("S3_BUCKET %q is not a valid bucket name", cfg.S3Bucket))
+	}
+	switch cfg.Notifier {
+	case "sns":
+	case "file":
+		if cfg.NotifierFile == "" {
+			errs = append(errs, fmt.Errorf("NOTIFIER_FILE is required when NOTIFIER=file"))
+		}
+		if cfg.NotifierFileMaxMB < 0 || cfg.NotifierFileBackups < 0 {
+			errs = append(errs, fmt.Errorf("NOTIFIER_FILE_MAX_MB and NOTIFIER_FILE_BACKUPS must not be negative"))
+		}
+	case "webhook":
+		if u, err := url.Parse(cfg.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("WEBHOOK_URL must be an http or https URL when NOTIFIER=webhook"))
+		}
+		if cfg.WebhookTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("WEBHOOK_TIMEOUT must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("NOTIFIER must be one of: sns, file, webhook"))
+	}
+	if cfg.Notifier != "sns" && len(cfg.SNSRoutes) > 0 {
+		errs = append(errs, fmt.Errorf("SNS_TOPIC_ROUTES requires NOTIFIER=sns"))
+	}
+	// Notifications only need a topic when they go to SNS
+	if cfg.SNSTopicARN == "" {
+		if cfg.Notifier == "sns" {
+			errs = append(errs, fmt.Errorf("SNS_TOPIC_ARN environment variable is required"))
+		}
+	} else if !snsTopicARNPattern.MatchString(cfg.SNSTopicARN) {
+		errs = append(errs, fmt.Errorf("SNS_TOPIC_ARN %q is not an SNS topic ARN (arn:aws:sns:<region>:<account-id>:<topic>)", cfg.SNSTopicARN))
+	}
+	for _, route := range cfg.SNSRoutes {
+		if !snsTopicARNPattern.MatchString(route.TopicARN) {
+			errs = append(errs, fmt.Errorf("SNS_TOPIC_ROUTES topic %q for %q is not an SNS topic ARN", route.TopicARN, route.Match))
+		}
+	}
+	if cfg.SelfTestTopicARN != "" && !snsTopicARNPattern.MatchString(cfg.SelfTestTopicARN) {
+		errs = append(errs, fmt.Errorf("SELFTEST_TOPIC_ARN %q is not an SNS topic ARN", cfg.SelfTestTopicARN))
+	}
+	if cfg.OpsAlertTopicARN != "" {
+		if !snsTopicARNPattern.MatchString(cfg.OpsAlertTopicARN) {
+			errs = append(errs, fmt.Errorf("OPS_ALERT_TOPIC_ARN %q is not an SNS topic ARN", cfg.OpsAlertTopicARN))
+		}
+		if cfg.OpsAlertThreshold <= 0 {
+			errs = append(errs, fmt.Errorf("OPS_ALERT_THRESHOLD must be positive"))
+		}
+		if cfg.OpsAlertWindow <= 0 {
+			errs = append(errs, fmt.Errorf("OPS_ALERT_WINDOW must be positive"))
+		}
+		if cfg.OpsAlertCooldown < 0 {
+			errs = append(errs, fmt.Errorf("OPS_ALERT_COOLDOWN must not be negative"))
+		}
+	}
+	if cfg.CloudFrontDomain == "" {
+		errs = append(errs, fmt.Errorf("CLOUDFRONT_DOMAIN environment variable is required"))
+	} else if !isValidHost(cfg.CloudFrontDomain) {
+		errs = append(errs, fmt.Errorf("CLOUDFRONT_DOMAIN %q must be a bare host name, without scheme, port or path", cfg.CloudFrontDomain))
+	}
+	if !awsRegionPattern.MatchString(cfg.AWSRegion) {
+		errs = append(errs, fmt.Errorf("AWS_REGION %q is not a valid region name", cfg.AWSRegion))
+	}
+	// Read by the AWS SDK itself; checked here so a typo fails at startup
+	if cfg.AWSEndpointURL != "" {
+		if u, err := url.Parse(cfg.AWSEndpointURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("AWS_ENDPOINT_URL must be an http or https URL"))
+		}
+	}
+
+	// Other settings
+	if len(cfg.VideoDirs) == 0 {
+		errs = append(errs, fmt.Errorf("at least one video directory must be configured"))
+	}
+	if len(cfg.VideoExtensions) == 0 {
+		errs = append(errs, fmt.Errorf("VIDEO_EXTENSIONS must contain at least one extension"))
+	}
+	if cfg.RecentEventsSize < 1 {
+		errs = append(errs, fmt.Errorf("RECENT_EVENTS_SIZE must be at least 1"))
+	}
+	if cfg.MetricsResetEnabled && cfg.MetricsResetToken == "" {
+		errs = append(errs, fmt.Errorf("METRICS_RESET_TOKEN is required when METRICS_RESET_ENABLED is set"))
+	}
+	if cfg.DependencyCheckTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("DEPENDENCY_CHECK_TIMEOUT must be positive"))
+	}
+	if cfg.PreflightCheck != "off" && cfg.PreflightCheck != "warn" && cfg.PreflightCheck != "fail" {
+		errs = append(errs, fmt.Errorf("PREFLIGHT_CHECK must be one of: off, warn, fail"))
+	}
+	if cfg.SigningRetries < 0 {
+		errs = append(errs, fmt.Errorf("SIGNING_RETRIES must not be negative"))
+	}
+	if cfg.SigningFallback != "fail" && cfg.SigningFallback != "unsigned" {
+		errs = append(errs, fmt.Errorf("SIGNING_FALLBACK must be one of: fail, unsigned"))
+	}
+	if cfg.URLExpiration <= 0 {
+		errs = append(errs, fmt.Errorf("URL_EXPIRATION must be positive"))
+	}
+	if cfg.ThumbnailURLTTL <= 0 {
+		errs = append(errs, fmt.Errorf("THUMBNAIL_URL_TTL must be positive"))
+	}
+	if cfg.S3DuplicateKeyPolicy != "overwrite" && cfg.S3DuplicateKeyPolicy != "skip" {
+		errs = append(errs, fmt.Errorf("S3_DUPLICATE_KEY_POLICY must be one of: overwrite, skip"))
+	}
+	for ext, contentType := range cfg.S3ContentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil || !strings.Contains(contentType, "/") {
+			errs = append(errs, fmt.Errorf("S3_CONTENT_TYPES type %q for %s is not a valid content type", contentType, ext))
+		}
+	}
+	if !isValidStorageClass(cfg.S3StorageClass) {
+		errs = append(errs, fmt.Errorf("S3_STORAGE_CLASS must be one of: %s", strings.Join(validStorageClasses, ", ")))
+	}
+	// S3 rejects parts under 5 MiB (other than the last) and allows at most 5 GiB
+	if cfg.S3PartSizeMB < 5 || cfg.S3PartSizeMB > 5*1024 {
+		errs = append(errs, fmt.Errorf("S3_PART_SIZE_MB must be between 5 and 5120"))
+	}
+	if cfg.S3UploadConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("S3_UPLOAD_CONCURRENCY must be at least 1"))
+	}
+	if cfg.S3UploadTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("S3_UPLOAD_TIMEOUT must be positive"))
+	}
+	if cfg.S3MinUploadKBps < 0 {
+		errs = append(errs, fmt.Errorf("S3_MIN_UPLOAD_KBPS must not be negative"))
+	}
+	if cfg.S3CircuitThreshold < 0 {
+		errs = append(errs, fmt.Errorf("S3_CIRCUIT_BREAKER_THRESHOLD must not be negative"))
+	}
+	if cfg.S3CircuitThreshold > 0 && cfg.S3CircuitCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("S3_CIRCUIT_BREAKER_COOLDOWN must be positive"))
+	}
+	if cfg.DynamoDBMaxConcurrentWrites < 0 || cfg.DynamoDBWritesPerSecond < 0 {
+		errs = append(errs, fmt.Errorf("DYNAMODB_MAX_CONCURRENT_WRITES and DYNAMODB_WRITES_PER_SECOND must not be negative"))
+	}
+	if cfg.DedupIndexFile != "" {
+		if cfg.DedupWindow <= 0 {
+			errs = append(errs, fmt.Errorf("DEDUP_WINDOW must be positive"))
+		}
+		if cfg.DedupMaxEntries < 1 {
+			errs = append(errs, fmt.Errorf("DEDUP_MAX_ENTRIES must be at least 1"))
+		}
+	}
+	if cfg.SNSSanitizeMode != "off" && cfg.SNSSanitizeMode != "lenient" && cfg.SNSSanitizeMode != "strict" {
+		errs = append(errs, fmt.Errorf("SNS_SANITIZE must be one of: off, lenient, strict"))
+	}
+	if cfg.SNSSelfTestTimeout < 2*time.Second {
+		errs = append(errs, fmt.Errorf("SNS_SELF_TEST_TIMEOUT must be at least 2s"))
+	}
+	if cfg.KeyPairID == "" && cfg.KeyPairIDParameter == "" {
+		errs = append(errs, fmt.Errorf("CLOUDFRONT_KEY_PAIR_ID or CLOUDFRONT_KEY_PAIR_ID_PARAM is required"))
+	}
+	if cfg.PrivateKeyFile != "" && cfg.PrivateKeyPEM != "" {
+		errs = append(errs, fmt.Errorf("set only one of CLOUDFRONT_PRIVATE_KEY_FILE and CLOUDFRONT_PRIVATE_KEY"))
+	}
+	if cfg.KeyRefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("CLOUDFRONT_KEY_REFRESH_INTERVAL must not be negative"))
+	}
+	if cfg.BatchWindow < 0 || cfg.BatchMaxCount < 0 || cfg.BatchMaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("BATCH_WINDOW, BATCH_MAX_COUNT and BATCH_MAX_BYTES must not be negative"))
+	}
+	if cfg.PreviewInterval <= 0 {
+		errs = append(errs, fmt.Errorf("PREVIEW_INTERVAL must be positive"))
+	}
+	if cfg.ThumbnailWidth <= 0 {
+		errs = append(errs, fmt.Errorf("THUMBNAIL_WIDTH must be positive"))
+	}
+	if cfg.Thumbnail && cfg.ThumbnailPrefix == cfg.S3KeyPrefix {
+		errs = append(errs, fmt.Errorf("THUMBNAIL_PREFIX must differ from S3_KEY_PREFIX"))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if cfg.ShutdownCleanup != "keep" && cfg.ShutdownCleanup != "clear-intermediates" && cfg.ShutdownCleanup != "clear-all" {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_CLEANUP must be one of: keep, clear-intermediates, clear-all"))
+	}
+	if cfg.FailedUploadRetryInterval < 0 {
+		errs = append(errs, fmt.Errorf("FAILED_UPLOAD_RETRY_INTERVAL must not be negative"))
+	}
+	if cfg.ClockDriftInterval < 0 || cfg.ClockDriftThreshold <= 0 {
+		errs = append(errs, fmt.Errorf("CLOCK_DRIFT_CHECK_INTERVAL must not be negative and CLOCK_DRIFT_THRESHOLD must be positive"))
+	}
+	if cfg.RetryStrategy != "exponential" && cfg.RetryStrategy != "decorrelated" {
+		errs = append(errs, fmt.Errorf("RETRY_STRATEGY must be one of: exponential, decorrelated"))
+	}
+	if cfg.RetryMaxElapsed < 0 {
+		errs = append(errs, fmt.Errorf("RETRY_MAX_ELAPSED must not be negative"))
+	}
+	if cfg.EventDebounceWindow < 0 {
+		errs = append(errs, fmt.Errorf("EVENT_DEBOUNCE_WINDOW must not be negative"))
+	}
+	if cfg.SettleDuration < 0 {
+		errs = append(errs, fmt.Errorf("SETTLE_DURATION must not be negative"))
+	}
+	// Files written to a watched directory would be picked up as new videos
+	for _, dir := range cfg.VideoDirs {
+		if cfg.Recursive && (cfg.Transcode || cfg.Remux) && isWithinDir(cfg.TranscodeDir, dir) {
+			errs = append(errs, fmt.Errorf("TRANSCODE_DIR must not be inside VIDEO_DIRS entry %s when RECURSIVE is enabled", dir))
+		}
+		if filepath.Clean(cfg.RejectedDir) == filepath.Clean(dir) || (cfg.Recursive && isWithinDir(cfg.RejectedDir, dir)) {
+			errs = append(errs, fmt.Errorf("REJECTED_DIR must not be watched, but it is inside VIDEO_DIRS entry %s", dir))
+		}
+		if filepath.Clean(cfg.FailedUploadDir) == filepath.Clean(dir) || (cfg.Recursive && isWithinDir(cfg.FailedUploadDir, dir)) {
+			errs = append(errs, fmt.Errorf("FAILED_UPLOAD_DIR must not be watched, but it is inside VIDEO_DIRS entry %s", dir))
+		}
+		if (cfg.KeepLocal || cfg.LocalRetention > 0) && (filepath.Clean(cfg.ProcessedDir) == filepath.Clean(dir) || (cfg.Recursive && isWithinDir(cfg.ProcessedDir, dir))) {
+			errs = append(errs, fmt.Errorf("PROCESSED_DIR must not be watched, but it is inside VIDEO_DIRS entry %s", dir))
+		}
+	}
+	if cfg.LocalRetention < 0 {
+		errs = append(errs, fmt.Errorf("LOCAL_RETENTION must not be negative"))
+	}
+	if (cfg.KeepLocal || cfg.LocalRetention > 0) && cfg.ProcessedDir == "" {
+		errs = append(errs, fmt.Errorf("PROCESSED_DIR is required when KEEP_LOCAL or LOCAL_RETENTION is set"))
+	}
+	if cfg.FailedUploadDir == "" {
+		errs = append(errs, fmt.Errorf("FAILED_UPLOAD_DIR is required"))
+	}
+	if cfg.FailedUploadMaxMB < 1 {
+		errs = append(errs, fmt.Errorf("FAILED_UPLOAD_MAX_MB must be at least 1"))
+	}
+	if cfg.MinFreeDiskMB < 0 {
+		errs = append(errs, fmt.Errorf("MIN_FREE_DISK_MB must not be negative"))
+	}
+	if cfg.MinFreeDiskMB > 0 && cfg.DiskCheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("DISK_CHECK_INTERVAL must be positive"))
+	}
+	if cfg.CloudWatchMetrics {
+		if cfg.CloudWatchNamespace == "" || strings.HasPrefix(cfg.CloudWatchNamespace, "AWS/") {
+			errs = append(errs, fmt.Errorf("CLOUDWATCH_NAMESPACE must be set and must not start with AWS/"))
+		}
+		if cfg.CloudWatchFlush < time.Second {
+			errs = append(errs, fmt.Errorf("CLOUDWATCH_FLUSH_INTERVAL must be at least 1s"))
+		}
+	}
+	if cfg.MaxVideoSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("MAX_VIDEO_SIZE_MB must not be negative"))
+	}
+	if cfg.RejectedDir == "" {
+		errs = append(errs, fmt.Errorf("REJECTED_DIR is required"))
+	}
+	if cfg.WatchRevalidateInterval < 0 {
+		errs = append(errs, fmt.Errorf("WATCH_REVALIDATE_INTERVAL must not be negative"))
+	}
+	if cfg.WatchRecoveryAttempts < 1 {
+		errs = append(errs, fmt.Errorf("WATCH_RECOVERY_ATTEMPTS must be at least 1"))
+	}
+	if cfg.WatchMode != "fsnotify" && cfg.WatchMode != "poll" {
+		errs = append(errs, fmt.Errorf("WATCH_MODE must be one of: fsnotify, poll"))
+	}
+	if cfg.WatchMode == "poll" && cfg.WatchPollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("WATCH_POLL_INTERVAL must be positive"))
+	}
+	if cfg.ProcessTimeout < 0 {
+		errs = append(errs, fmt.Errorf("PROCESS_TIMEOUT must not be negative"))
+	}
+	if cfg.ProcessConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("PROCESS_CONCURRENCY must be at least 1"))
+	}
+	if cfg.ProcessQueueSize < 1 {
+		errs = append(errs, fmt.Errorf("PROCESS_QUEUE_SIZE must be at least 1"))
+	}
+	if cfg.ProcessQueuePolicy != "drop" && cfg.ProcessQueuePolicy != "block" {
+		errs = append(errs, fmt.Errorf("PROCESS_QUEUE_POLICY must be one of: drop, block"))
+	}
+	if cfg.ProcessQueuePolicy == "block" && cfg.ProcessQueueOfferTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("PROCESS_QUEUE_OFFER_TIMEOUT must be positive"))
+	}
+	if cfg.ProcessTimeoutWarnPercent < 1 || cfg.ProcessTimeoutWarnPercent > 100 {
+		errs = append(errs, fmt.Errorf("PROCESS_TIMEOUT_WARN_PERCENT must be between 1 and 100"))
+	}
+	if cfg.ProcessPanicPolicy != "recover" && cfg.ProcessPanicPolicy != "crash" {
+		errs = append(errs, fmt.Errorf("PROCESS_PANIC_POLICY must be one of: recover, crash"))
+	}
+	if cfg.SinkSuccessPolicy != "all" && cfg.SinkSuccessPolicy != "any" && cfg.SinkSuccessPolicy != "required" {
+		errs = append(errs, fmt.Errorf("SINK_SUCCESS_POLICY must be one of: all, any, required"))
+	}
+	for _, sink := range cfg.RequiredSinks {
+		switch sink {
+		case "sns":
+		case "manifest":
+			if !cfg.S3Manifest {
+				errs = append(errs, fmt.Errorf("REQUIRED_SINKS includes manifest but S3_MANIFEST is disabled"))
+			}
+		case "filename_index":
+			if !cfg.S3FilenameIndex {
+				errs = append(errs, fmt.Errorf("REQUIRED_SINKS includes filename_index but S3_FILENAME_INDEX is disabled"))
+			}
+		case "dynamodb":
+			if cfg.DynamoDBTable == "" {
+				errs = append(errs, fmt.Errorf("REQUIRED_SINKS includes dynamodb but DYNAMODB_TABLE is not set"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("REQUIRED_SINKS entry %q must be one of: sns, manifest, filename_index, dynamodb", sink))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isWithinDir reports whether path is dir or lies beneath it
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isValidBucketName reports whether name follows the S3 bucket naming rules
+func isValidBucketName(name string) bool {
+	if !s3BucketPattern.MatchString(name) || strings.Contains(name, "..") {
+		return false
+	}
+	// Buckets can't be named like IP addresses
+	return net.ParseIP(name) == nil
+}
+
+// isValidHost reports whether host is a bare DNS host name
+func isValidHost(host string) bool {
+	if len(host) > 253 || !strings.Contains(host, ".") {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if !hostLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// S3 storage classes that suit clips uploaded for immediate viewing
+var validStorageClasses = []string{"STANDARD", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER_IR"}
+
+// isValidStorageClass reports whether class is one of validStorageClasses
+func isValidStorageClass(class string) bool {
+	for _, valid := range validStorageClasses {
+		if class == valid {
+			return true
+		}
+	}
+	return false
+}
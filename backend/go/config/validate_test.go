@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSigningFallback(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr bool
+	}{
+		{"fail", false},
+		{"unsigned", false},
+		{"", true},
+		{"drop", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := &Config{SigningFallback: tt.policy}
+			err := cfg.validate()
+			got := err != nil && strings.Contains(err.Error(), "SIGNING_FALLBACK")
+			if got != tt.wantErr {
+				t.Errorf("SIGNING_FALLBACK=%q rejected = %v, want %v (%v)", tt.policy, got, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateMetricsResetToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		token   string
+		wantErr bool
+	}{
+		{"disabled", false, "", false},
+		{"enabled with token", true, "s3cret", false},
+		{"enabled without token", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{MetricsResetEnabled: tt.enabled, MetricsResetToken: tt.token}
+			err := cfg.validate()
+			got := err != nil && strings.Contains(err.Error(), "METRICS_RESET_TOKEN")
+			if got != tt.wantErr {
+				t.Errorf("rejected = %v, want %v (%v)", got, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateThumbnailURLTTL(t *testing.T) {
+	tests := []struct {
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{time.Hour, false},
+		{7 * 24 * time.Hour, false},
+		{0, true},
+		{-time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ttl.String(), func(t *testing.T) {
+			cfg := &Config{URLExpiration: time.Hour, ThumbnailURLTTL: tt.ttl}
+			err := cfg.validate()
+			got := err != nil && strings.Contains(err.Error(), "THUMBNAIL_URL_TTL")
+			if got != tt.wantErr {
+				t.Errorf("THUMBNAIL_URL_TTL=%s rejected = %v, want %v (%v)", tt.ttl, got, tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Dependency check statuses
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Check probes a single dependency, returning an error if it is unhealthy
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one dependency check
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// CheckReport is the outcome of running every registered check
+type CheckReport struct {
+	Healthy bool                   `json:"healthy"`
+	Checks  map[string]CheckResult `json:"checks"`
+}
+
+// Unhealthy returns the names of the failed checks, sorted
+func (r CheckReport) Unhealthy() []string {
+	var names []string
+	for name, result := range r.Checks {
+		if result.Status != StatusHealthy {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checker runs named dependency checks concurrently under a shared deadline
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]Check
+}
+
+// NewChecker creates a checker whose runs are bounded by timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers a check under a dependency name, replacing any earlier one
+func (c *Checker) Add(name string, check Check) {
+	if _, exists := c.checks[name]; !exists {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Run runs every check concurrently and reports each dependency's status.
+// A failing check doesn't cancel the others; all share one deadline.
+func (c *Checker) Run(ctx context.Context) CheckReport {
+	runCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	report := CheckReport{Healthy: true, Checks: make(map[string]CheckResult, len(c.names))}
+
+	var g errgroup.Group
+	for _, name := range c.names {
+		name, check := name, c.checks[name]
+		g.Go(func() error {
+			start := time.Now()
+			err := check(runCtx)
+
+			result := CheckResult{Status: StatusHealthy, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = StatusUnhealthy
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Healthy = false
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return report
+}
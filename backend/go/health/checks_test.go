@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errUnreachable = errors.New("endpoint unreachable")
+
+// healthy and failing are fake dependency checks
+func healthy(ctx context.Context) error { return nil }
+func failing(ctx context.Context) error { return errUnreachable }
+
+// hanging blocks until the run's deadline
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCheckerRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		checks        map[string]Check
+		wantHealthy   bool
+		wantUnhealthy []string
+	}{
+		{"no checks", nil, true, nil},
+		{"all healthy", map[string]Check{"s3": healthy, "sns": healthy, "ssm": healthy, "cloudfront": healthy}, true, nil},
+		{"one failing", map[string]Check{"s3": healthy, "sns": failing, "ssm": healthy, "cloudfront": healthy}, false, []string{"sns"}},
+		{"several failing", map[string]Check{"s3": failing, "sns": healthy, "ssm": failing, "cloudfront": healthy}, false, []string{"s3", "ssm"}},
+		{"past the deadline", map[string]Check{"s3": healthy, "cloudfront": hanging}, false, []string{"cloudfront"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(100 * time.Millisecond)
+			for name, check := range tt.checks {
+				checker.Add(name, check)
+			}
+
+			report := checker.Run(context.Background())
+			if report.Healthy != tt.wantHealthy {
+				t.Errorf("Healthy = %v, want %v", report.Healthy, tt.wantHealthy)
+			}
+			if got := report.Unhealthy(); !slices.Equal(got, tt.wantUnhealthy) {
+				t.Errorf("Unhealthy() = %v, want %v", got, tt.wantUnhealthy)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("report has %d checks, want %d", len(report.Checks), len(tt.checks))
+			}
+			for name, result := range report.Checks {
+				failed := slices.Contains(tt.wantUnhealthy, name)
+				if failed != (result.Status == StatusUnhealthy) || failed != (result.Error != "") {
+					t.Errorf("%s = %+v, want unhealthy %v", name, result, failed)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerRunsConcurrently(t *testing.T) {
+	const n = 4
+	var started sync.WaitGroup
+	started.Add(n)
+	// Each check waits for all of them to start, so run one at a time they'd
+	// hang until the deadline
+	check := func(ctx context.Context) error {
+		started.Done()
+		done := make(chan struct{})
+		go func() { started.Wait(); close(done) }()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	checker := NewChecker(2 * time.Second)
+	for _, name := range []string{"s3", "sns", "ssm", "cloudfront"} {
+		checker.Add(name, check)
+	}
+	if report := checker.Run(context.Background()); !report.Healthy {
+		t.Errorf("checks did not run concurrently: %+v", report.Checks)
+	}
+}
+
+func TestCheckerAddReplaces(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("s3", failing)
+	checker.Add("s3", healthy)
+
+	report := checker.Run(context.Background())
+	if !report.Healthy || len(report.Checks) != 1 {
+		t.Errorf("Run() = %+v, want only the replacement check", report)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	mixed := NewChecker(time.Second)
+	mixed.Add("s3", healthy)
+	mixed.Add("sns", failing)
+	allHealthy := NewChecker(time.Second)
+	allHealthy.Add("s3", healthy)
+
+	tests := []struct {
+		name       string
+		ready      bool
+		checker    *Checker
+		wantStatus int
+		wantChecks map[string]string
+	}{
+		{"not ready", false, allHealthy, http.StatusServiceUnavailable, nil},
+		{"no checker", true, nil, http.StatusOK, nil},
+		{"healthy dependencies", true, allHealthy, http.StatusOK, map[string]string{"s3": StatusHealthy}},
+		{"failing dependency", true, mixed, http.StatusServiceUnavailable, map[string]string{"s3": StatusHealthy, "sns": StatusUnhealthy}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ReadinessHandler(func() bool { return tt.ready }, tt.checker)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var body readinessResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response %q: %v", rec.Body, err)
+			}
+			statuses := make(map[string]string)
+			for name, result := range body.Checks {
+				statuses[name] = result.Status
+			}
+			if len(statuses) != len(tt.wantChecks) {
+				t.Fatalf("checks = %v, want %v", statuses, tt.wantChecks)
+			}
+			for name, want := range tt.wantChecks {
+				if statuses[name] != want {
+					t.Errorf("%s = %q, want %q", name, statuses[name], want)
+				}
+			}
+		})
+	}
+}
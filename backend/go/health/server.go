@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// Time allowed for in-flight requests when shutting down
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server serves the backend's operational HTTP endpoints
+type Server struct {
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewServer creates a new HTTP server listening on the given port
+func NewServer(port int) *Server {
+	mux := http.NewServeMux()
+
+	return &Server{
+		mux: mux,
+		server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// Handle registers a handler for the given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Run serves HTTP requests until the context is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	serverErrors := make(chan error, 1)
+	go func() {
+		slog.Info("HTTP server listening", "addr", s.server.Addr)
+		serverErrors <- s.server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErrors:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("HTTP server shutdown failed: %w", err)
+	}
+	slog.Info("HTTP server stopped")
+	return nil
+}
+
+// WriteJSON writes v as a JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode JSON response", "error", err)
+	}
+}
+
+// LivenessHandler always reports healthy; it answers as long as the process is up
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// readinessResponse is the body served by ReadinessHandler
+type readinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// ReadinessHandler reports ready only while ready returns true and, when checker
+// is non-nil, every dependency check passes. Each dependency's status is included.
+func ReadinessHandler(ready func() bool, checker *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready() {
+			WriteJSON(w, http.StatusServiceUnavailable, readinessResponse{Status: "not ready"})
+			return
+		}
+		if checker == nil {
+			WriteJSON(w, http.StatusOK, readinessResponse{Status: "ready"})
+			return
+		}
+
+		report := checker.Run(r.Context())
+		if !report.Healthy {
+			WriteJSON(w, http.StatusServiceUnavailable, readinessResponse{Status: "not ready", Checks: report.Checks})
+			return
+		}
+		WriteJSON(w, http.StatusOK, readinessResponse{Status: "ready", Checks: report.Checks})
+	})
+}
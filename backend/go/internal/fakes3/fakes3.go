@@ -0,0 +1,310 @@
+// Package fakes3 is an in-memory S3 endpoint for tests. It speaks enough of the
+// S3 REST API for the uploader: single-part and multipart uploads, HeadObject,
+// GetObject, DeleteObject and HeadBucket, with versioning, If-None-Match and If-Match.
+package fakes3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Object is a stored object
+type Object struct {
+	Body      []byte
+	Header    http.Header // as sent with the upload
+	VersionID string
+	// SHA256 is the base64 checksum S3 reports: the whole object's for a single
+	// PutObject, a composite "<hash>-<parts>" for a multipart upload
+	SHA256 string
+}
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	Bucket string
+	Key    string
+	Query  string
+	Header http.Header
+}
+
+// Server is a fake S3 endpoint. Buckets exist implicitly.
+type Server struct {
+	*httptest.Server
+
+	// Region is reported for every bucket
+	Region string
+	// Versioning gives each write a version ID
+	Versioning bool
+	// Hook, if set, sees each request first and answers it itself by returning true
+	Hook func(w http.ResponseWriter, r *http.Request) bool
+
+	mu       sync.Mutex
+	objects  map[string]*Object
+	uploads  map[string]map[int][]byte
+	requests []Request
+	versions int
+}
+
+// New starts a fake S3 endpoint that is closed when the test ends
+func New(t testing.TB) *Server {
+	s := &Server{
+		Region:  "us-east-1",
+		objects: make(map[string]*Object),
+		uploads: make(map[string]map[int][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SetEnv points the AWS SDK at the server for the rest of the test, with
+// static credentials and no shared config files or instance metadata
+func (s *Server) SetEnv(t testing.TB) {
+	t.Setenv("AWS_ENDPOINT_URL", s.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+// Object returns a stored object, or nil
+func (s *Server) Object(bucket, key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[bucket+"/"+key]
+}
+
+// Put stores an object directly, as if uploaded earlier
+func (s *Server) Put(bucket, key string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(bucket, key, body, http.Header{}, checksum(body))
+}
+
+// Keys returns the keys stored in a bucket, sorted
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for name := range s.objects {
+		if key, ok := strings.CutPrefix(name, bucket+"/"); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Error writes an S3 XML error response
+func Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Bucket: bucket, Key: key, Query: r.URL.RawQuery, Header: r.Header.Clone()})
+	s.mu.Unlock()
+
+	if s.Hook != nil && s.Hook(w, r) {
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	name := bucket + "/" + key
+	switch {
+	case key == "":
+		w.Header().Set("X-Amz-Bucket-Region", s.Region)
+		if query.Has("location") {
+			fmt.Fprintf(w, "<LocationConstraint>%s</LocationConstraint>", s.Region)
+		}
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+		s.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>",
+			bucket, key, uploadID)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", etag(body))
+		w.Header().Set("X-Amz-Checksum-Sha256", checksum(body))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && s.objects[name] != nil {
+			Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		var numbers []int
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var object, sums []byte
+		for _, number := range numbers {
+			object = append(object, parts[number]...)
+			sum := sha256.Sum256(parts[number])
+			sums = append(sums, sum[:]...)
+		}
+		delete(s.uploads, query.Get("uploadId"))
+		composite := fmt.Sprintf("%s-%d", checksum(sums), len(numbers))
+		stored := s.store(bucket, key, object, r.Header, composite)
+		s.writeVersion(w, stored)
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>",
+			bucket, key, etag(object))
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" && s.objects[name] != nil {
+			Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && (s.objects[name] == nil || etag(s.objects[name].Body) != match) {
+			Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		sum := checksum(body)
+		if sent := r.Header.Get("X-Amz-Checksum-Sha256"); sent != "" && sent != sum {
+			Error(w, http.StatusBadRequest, "BadDigest")
+			return
+		}
+		stored := s.store(bucket, key, body, r.Header, sum)
+		s.writeVersion(w, stored)
+		w.Header().Set("ETag", etag(body))
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		object := s.objects[name]
+		if object == nil {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for header, values := range object.Header {
+			if strings.HasPrefix(strings.ToLower(header), "x-amz-meta-") || header == "Content-Type" {
+				w.Header()[header] = values
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.Body)))
+		w.Header().Set("ETag", etag(object.Body))
+		w.Header().Set("X-Amz-Checksum-Sha256", object.SHA256)
+		s.writeVersion(w, object)
+		if r.Method == http.MethodGet {
+			w.Write(object.Body)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// store saves an object; callers must hold s.mu
+func (s *Server) store(bucket, key string, body []byte, header http.Header, sha string) *Object {
+	object := &Object{Body: body, Header: header.Clone(), SHA256: sha}
+	if s.Versioning {
+		s.versions++
+		object.VersionID = fmt.Sprintf("v%d", s.versions)
+	}
+	s.objects[bucket+"/"+key] = object
+	return object
+}
+
+func (s *Server) writeVersion(w http.ResponseWriter, object *Object) {
+	if object.VersionID != "" {
+		w.Header().Set("X-Amz-Version-Id", object.VersionID)
+	}
+}
+
+// readBody reads a request body, decoding aws-chunked bodies, which newer SDKs
+// send with trailing checksums
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") &&
+		!strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var body bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err := io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("%q", base64.RawURLEncoding.EncodeToString(sum[:16]))
+}
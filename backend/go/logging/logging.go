@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup installs the default slog logger with the given level (debug, info, warn,
+// error) and format (text, json). Output from the standard log package is routed
+// through it too, at info level.
+func Setup(level, format string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// ParseLevel parses a log level name
+func ParseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", level)
+	}
+	return lvl, nil
+}
+
+// Retry logs a failed attempt of a retried operation
+func Retry(operation string, attempt, maxAttempts int, err error, delay time.Duration) {
+	slog.Warn("Operation failed, retrying",
+		"operation", operation,
+		"attempt", attempt,
+		"max_attempts", maxAttempts,
+		"retry_in_ms", delay.Milliseconds(),
+		"error", err)
+}
+
+// RetrySucceeded logs an operation that succeeded after retrying
+func RetrySucceeded(operation string, retries int) {
+	slog.Info("Operation succeeded after retries", "operation", operation, "retries", retries)
+}
+
+// Fatal logs at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -2,64 +2,393 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/backfill"
+	"github.com/lachiem1/eyeSeeYou/backend/go/buildinfo"
 	"github.com/lachiem1/eyeSeeYou/backend/go/config"
+	"github.com/lachiem1/eyeSeeYou/backend/go/health"
+	"github.com/lachiem1/eyeSeeYou/backend/go/logging"
+	"github.com/lachiem1/eyeSeeYou/backend/go/media"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+	"github.com/lachiem1/eyeSeeYou/backend/go/notify"
+	"github.com/lachiem1/eyeSeeYou/backend/go/selftest"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
 	"github.com/lachiem1/eyeSeeYou/backend/go/watcher"
 )
 
 func main() {
-	log.Println("Starting EyeSeeYou Backend...")
+	selfTest := flag.Bool("selftest", false, "run the pipeline once against a generated test video, report each step and exit")
+	backfillDir := flag.String("backfill", "", "upload the existing videos in this directory, report a summary and exit")
+	backfillConcurrency := flag.Int("backfill-concurrency", 4, "number of videos --backfill processes at once")
+	noNotify := flag.Bool("no-notify", false, "with --backfill, upload without publishing notifications")
+	backfillMoveFailed := flag.Bool("backfill-move-failed", false, "with --backfill, move videos that fail to the failed upload directory")
+	showVersion := flag.Bool("version", false, "print the version, git commit and build time and exit")
+	flag.Parse()
+
+	build := buildinfo.Get()
+	if *showVersion {
+		fmt.Printf("eyeseeyou-backend %s %s\n", build, build.GoVersion)
+		return
+	}
+
+	slog.Info("Starting EyeSeeYou Backend...", "version", build.String())
 
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logging.Fatal("Failed to load config", "error", err)
+	}
+
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		logging.Fatal("Invalid logging configuration", "error", err)
+	}
+
+	if err := utils.SetDefaultStrategy(cfg.RetryStrategy); err != nil {
+		logging.Fatal("Invalid retry strategy", "error", err)
 	}
+	utils.SetDefaultMaxElapsed(cfg.RetryMaxElapsed)
+	awspackage.SetErrorCodeOverrides(cfg.RetryableErrorCodes, cfg.NonRetryableErrorCodes)
 
-	log.Printf("Configuration loaded:")
-	log.Printf("  AWS Region: %s", cfg.AWSRegion)
-	log.Printf("  S3 Bucket: %s", cfg.S3Bucket)
-	log.Printf("  SNS Topic ARN: %s", cfg.SNSTopicARN)
-	log.Printf("  Video Directory: %s", cfg.VideoDir)
-	log.Printf("  CloudFront Domain: %s", cfg.CloudFrontDomain)
+	slog.Info("Configuration loaded",
+		"aws_region", cfg.AWSRegion,
+		"aws_endpoint_url", cfg.AWSEndpointURL,
+		"s3_bucket", cfg.S3Bucket,
+		"s3_storage_class", cfg.S3StorageClass,
+		"sns_topic_arn", cfg.SNSTopicARN,
+		"video_dirs", strings.Join(cfg.VideoDirs, ","),
+		"video_extensions", strings.Join(cfg.VideoExtensions, ","),
+		"watch_mode", cfg.WatchMode,
+		"cloudfront_domain", cfg.CloudFrontDomain,
+		"url_expiration", cfg.URLExpiration.String(),
+		"thumbnail_url_ttl", cfg.ThumbnailURLTTL.String())
 
 	// Create context that can be cancelled
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Initialize CloudFront signer (fetches private key from SSM)
-	cloudFrontSigner, err := awspackage.NewCloudFrontSigner(ctx, cfg.AWSRegion)
+	// Warn if the wall clock jumps, since notification and S3 timestamps rely on it
+	if cfg.ClockDriftInterval > 0 {
+		utils.MonitorClockDrift(ctx, utils.SystemClock(), cfg.ClockDriftInterval, cfg.ClockDriftThreshold)
+	}
+
+	// Initialize CloudFront signer (fetches private key from SSM unless a local one is configured)
+	signerOptions := awspackage.DefaultCloudFrontSignerOptions()
+	signerOptions.Expiration = cfg.URLExpiration
+	signerOptions.KeyPairID = cfg.KeyPairID
+	signerOptions.KeyPairIDParameter = cfg.KeyPairIDParameter
+	signerOptions.PrivateKeyFile = cfg.PrivateKeyFile
+	signerOptions.PrivateKeyPEM = cfg.PrivateKeyPEM
+	cloudFrontSigner, err := awspackage.NewCloudFrontSigner(ctx, cfg.AWSRegion, signerOptions)
 	if err != nil {
-		log.Fatalf("Failed to create CloudFront signer: %v", err)
+		logging.Fatal("Failed to create CloudFront signer", "error", err)
+	}
+	slog.Info("CloudFront signer initialized")
+
+	// Periodically re-fetch the key so rotations in SSM are picked up
+	if cfg.KeyRefreshInterval > 0 {
+		cloudFrontSigner.StartKeyRefresh(ctx, cfg.KeyRefreshInterval)
 	}
-	log.Println("CloudFront signer initialized")
 
 	// Initialize S3 uploader
-	s3Uploader, err := awspackage.NewS3Uploader(ctx, cfg.AWSRegion, cfg.S3Bucket)
+	s3Options := awspackage.DefaultS3UploaderOptions()
+	s3Options.AutodetectRegion = cfg.S3AutodetectRegion
+	s3Options.DuplicateKeyPolicy = cfg.S3DuplicateKeyPolicy
+	s3Options.StorageClass = cfg.S3StorageClass
+	s3Options.ContentTypes = cfg.S3ContentTypes
+	s3Options.SSEKMSKeyID = cfg.S3SSEKMSKeyID
+	s3Options.KeyPrefix = cfg.S3KeyPrefix
+	if s3Options.KeyTemplate, err = awspackage.ParseKeyTemplate(cfg.S3KeyTemplate); err != nil {
+		logging.Fatal("Invalid S3_KEY_TEMPLATE", "error", err)
+	}
+	s3Options.PartSize = int64(cfg.S3PartSizeMB) * 1024 * 1024
+	s3Options.Concurrency = cfg.S3UploadConcurrency
+	s3Options.UploadTimeout = cfg.S3UploadTimeout
+	s3Options.MinThroughput = int64(cfg.S3MinUploadKBps) * 1024
+	s3Options.CircuitThreshold = cfg.S3CircuitThreshold
+	s3Options.CircuitCooldown = cfg.S3CircuitCooldown
+	s3Options.FailedUploadDir = cfg.FailedUploadDir
+	s3Options.FailedUploadMaxBytes = int64(cfg.FailedUploadMaxMB) * 1024 * 1024
+	s3Options.DedupIndexPath = cfg.DedupIndexFile
+	s3Options.DedupWindow = cfg.DedupWindow
+	s3Options.DedupMaxEntries = cfg.DedupMaxEntries
+	s3Uploader, err := awspackage.NewS3Uploader(ctx, cfg.AWSRegion, cfg.S3Bucket, s3Options)
 	if err != nil {
-		log.Fatalf("Failed to create S3 uploader: %v", err)
+		logging.Fatal("Failed to create S3 uploader", "error", err)
 	}
-	log.Println("S3 uploader initialized")
+	slog.Info("S3 uploader initialized")
 
 	// Initialize SNS publisher with CloudFront signer
-	snsPublisher, err := awspackage.NewSNSPublisher(ctx, cfg.AWSRegion, cfg.SNSTopicARN, cloudFrontSigner)
+	snsOptions := awspackage.DefaultSNSPublisherOptions()
+	snsOptions.SigningRetries = cfg.SigningRetries
+	snsOptions.SigningFallback = cfg.SigningFallback
+	snsOptions.VideoURLTTL = cfg.URLExpiration
+	snsOptions.ThumbnailURLTTL = cfg.ThumbnailURLTTL
+	snsOptions.BatchWindow = cfg.BatchWindow
+	snsOptions.BatchMaxCount = cfg.BatchMaxCount
+	snsOptions.BatchMaxBytes = cfg.BatchMaxBytes
+	snsOptions.SanitizeMode = cfg.SNSSanitizeMode
+	snsOptions.Bucket = cfg.S3Bucket
+	snsOptions.SelfTestTimeout = cfg.SNSSelfTestTimeout
+	if cfg.NotifyBackendVersion {
+		snsOptions.BackendVersion = build.String()
+	}
+	if snsOptions.Transform, err = notify.ParsePipeline(cfg.SNSTransforms); err != nil {
+		logging.Fatal("Invalid SNS_TRANSFORMS", "error", err)
+	}
+	if snsOptions.PushPlatforms, err = awspackage.ParsePushPlatforms(cfg.SNSPushPlatforms); err != nil {
+		logging.Fatal("Invalid SNS_PUSH_PLATFORMS", "error", err)
+	}
+	if snsOptions.Protocols, err = awspackage.ParseMessageProtocols(cfg.SNSProtocols); err != nil {
+		logging.Fatal("Invalid SNS_PROTOCOL_MESSAGES", "error", err)
+	}
+	snsPublisher, err := awspackage.NewSNSPublisher(ctx, cfg.AWSRegion, cfg.SNSTopicARN, cloudFrontSigner, snsOptions)
 	if err != nil {
-		log.Fatalf("Failed to create SNS publisher: %v", err)
+		logging.Fatal("Failed to create SNS publisher", "error", err)
+	}
+	slog.Info("SNS publisher initialized")
+
+	// Without SNS, the publisher still builds and signs notifications but another
+	// notifier delivers them
+	var notifier awspackage.Notifier
+	var fileNotifier *awspackage.FileNotifier
+	switch cfg.Notifier {
+	case awspackage.NotifierFile:
+		fileNotifierOptions := awspackage.DefaultFileNotifierOptions()
+		fileNotifierOptions.MaxBytes = int64(cfg.NotifierFileMaxMB) * 1024 * 1024
+		fileNotifierOptions.MaxBackups = cfg.NotifierFileBackups
+		if fileNotifier, err = awspackage.NewFileNotifier(cfg.NotifierFile, fileNotifierOptions); err != nil {
+			logging.Fatal("Failed to create file notifier", "path", cfg.NotifierFile, "error", err)
+		}
+		defer fileNotifier.Close()
+		notifier = fileNotifier
+		slog.Info("Writing notifications to file", "path", cfg.NotifierFile)
+	case awspackage.NotifierWebhook:
+		webhookOptions := awspackage.DefaultWebhookNotifierOptions()
+		webhookOptions.Timeout = cfg.WebhookTimeout
+		webhookOptions.Token = cfg.WebhookToken
+		notifier = awspackage.NewWebhookNotifier(cfg.WebhookURL, webhookOptions)
+		// The URL isn't logged: webhook IDs in it are often the only secret
+		slog.Info("Delivering notifications to webhook", "timeout", cfg.WebhookTimeout.String(), "bearer_token", cfg.WebhookToken != "")
+	}
+
+	if *selfTest {
+		os.Exit(runSelfTest(ctx, cfg, s3Uploader, cloudFrontSigner, snsPublisher, snsOptions))
+	}
+	if *backfillDir != "" {
+		backfillOptions := backfill.Options{
+			Concurrency:      *backfillConcurrency,
+			Notify:           !*noNotify,
+			MoveFailed:       *backfillMoveFailed,
+			Extensions:       cfg.VideoExtensions,
+			CloudFrontDomain: cfg.CloudFrontDomain,
+		}
+		backfillOptions.Notifier = notifier
+		exitCode := runBackfill(ctx, cfg, *backfillDir, s3Uploader, snsPublisher, backfillOptions)
+		if fileNotifier != nil {
+			fileNotifier.Close()
+		}
+		os.Exit(exitCode)
+	}
+
+	// Per-source topics: one publisher per distinct routed topic
+	publishers := map[string]*awspackage.SNSPublisher{cfg.SNSTopicARN: snsPublisher}
+	var watcherOptions watcher.Options
+	watcherOptions.Notifier = notifier
+	for _, route := range cfg.SNSRoutes {
+		publisher, ok := publishers[route.TopicARN]
+		if !ok {
+			if publisher, err = awspackage.NewSNSPublisher(ctx, cfg.AWSRegion, route.TopicARN, cloudFrontSigner, snsOptions); err != nil {
+				logging.Fatal("Failed to create SNS publisher", "topic_arn", route.TopicARN, "error", err)
+			}
+			publishers[route.TopicARN] = publisher
+		}
+		watcherOptions.Routes = append(watcherOptions.Routes, watcher.Route{Match: route.Match, Publisher: publisher})
+		slog.Info("Routing notifications", "match", route.Match, "topic_arn", route.TopicARN)
 	}
-	log.Println("SNS publisher initialized")
 
 	// Initialize file watcher
-	fileWatcher, err := watcher.NewFileWatcher(cfg, s3Uploader, snsPublisher)
+	if cfg.S3Manifest {
+		watcherOptions.Manifest = s3Uploader.NewManifestWriter(cfg.S3ManifestPrefix)
+		slog.Info("Writing upload manifests", "bucket", cfg.S3Bucket, "prefix", cfg.S3ManifestPrefix)
+	}
+	if cfg.S3FilenameIndex {
+		watcherOptions.FilenameIndex = s3Uploader.NewFilenameIndex(cfg.S3FilenameIndexPrefix)
+		slog.Info("Indexing original filenames", "bucket", cfg.S3Bucket, "prefix", cfg.S3FilenameIndexPrefix)
+	}
+	if cfg.DynamoDBTable != "" {
+		recorderOptions := awspackage.DefaultDynamoDBRecorderOptions()
+		recorderOptions.MaxConcurrentWrites = cfg.DynamoDBMaxConcurrentWrites
+		recorderOptions.WritesPerSecond = cfg.DynamoDBWritesPerSecond
+		if watcherOptions.Recorder, err = awspackage.NewDynamoDBRecorder(ctx, cfg.AWSRegion, cfg.DynamoDBTable, recorderOptions); err != nil {
+			logging.Fatal("Failed to create DynamoDB recorder", "error", err)
+		}
+		slog.Info("Recording uploads in DynamoDB", "table", cfg.DynamoDBTable,
+			"max_concurrent_writes", recorderOptions.MaxConcurrentWrites, "writes_per_second", recorderOptions.WritesPerSecond)
+	}
+	if cfg.Transcode {
+		transcodeArgs := cfg.TranscodeArgs
+		if len(transcodeArgs) == 0 {
+			transcodeArgs = media.DefaultTranscodeArgs
+		}
+		transcoder, err := media.NewFFmpegTranscoder(cfg.FFmpegPath, transcodeArgs)
+		if err != nil {
+			slog.Warn("Transcoding disabled", "error", err)
+		} else {
+			watcherOptions.Transcoder = transcoder
+			slog.Info("Transcoding enabled")
+		}
+	}
+
+	if cfg.Remux && !cfg.Transcode {
+		remuxer, err := media.NewFFmpegRemuxer(cfg.FFmpegPath, cfg.FFprobePath)
+		if err != nil {
+			slog.Warn("Remuxing disabled", "error", err)
+		} else {
+			watcherOptions.Remuxer = remuxer
+			slog.Info("Remuxing of non-MP4 containers enabled")
+		}
+	}
+
+	if cfg.Preview {
+		previewOptions := media.DefaultPreviewOptions()
+		previewOptions.Interval = cfg.PreviewInterval
+		previewGenerator, err := media.NewFFmpegPreviewGenerator(cfg.FFmpegPath, cfg.FFprobePath, previewOptions)
+		if err != nil {
+			slog.Warn("Scrub previews disabled", "error", err)
+		} else {
+			watcherOptions.PreviewGenerator = previewGenerator
+			slog.Info("Scrub previews enabled")
+		}
+	}
+
+	if cfg.Thumbnail {
+		// Motion JPEG MP4s are thumbnailed in process; other codecs need ffmpeg
+		thumbnailer, err := media.NewMP4Thumbnailer(cfg.ThumbnailWidth)
+		if err != nil {
+			logging.Fatal("Invalid thumbnail configuration", "error", err)
+		}
+		thumbnailers := media.ThumbnailerChain{thumbnailer}
+		if ffmpegThumbnailer, err := media.NewFFmpegThumbnailer(cfg.FFmpegPath, cfg.ThumbnailWidth); err != nil {
+			slog.Warn("ffmpeg not available, thumbnails only for Motion JPEG MP4s", "error", err)
+		} else {
+			thumbnailers = append(thumbnailers, ffmpegThumbnailer)
+		}
+		watcherOptions.Thumbnailer = thumbnailers
+		slog.Info("Thumbnails enabled", "prefix", cfg.ThumbnailPrefix, "width", cfg.ThumbnailWidth, "ffmpeg", len(thumbnailers) > 1)
+	}
+
+	fileWatcher, err := watcher.NewFileWatcher(cfg, s3Uploader, snsPublisher, watcherOptions)
 	if err != nil {
-		log.Fatalf("Failed to create file watcher: %v", err)
+		logging.Fatal("Failed to create file watcher", "error", err)
+	}
+	slog.Info("File watcher initialized")
+
+	// AWS dependency checks, run concurrently for the preflight and optionally /readyz
+	dependencyChecker := health.NewChecker(cfg.DependencyCheckTimeout)
+	dependencyChecker.Add("s3", s3Uploader.CheckHealth)
+	if cfg.Notifier == awspackage.NotifierSNS {
+		dependencyChecker.Add("sns", snsPublisher.CheckHealth)
+	}
+	dependencyChecker.Add("ssm", cloudFrontSigner.CheckSSM)
+	dependencyChecker.Add("cloudfront", cloudFrontSigner.CheckSigning)
+	if cfg.PreflightCheck != "off" {
+		report := dependencyChecker.Run(ctx)
+		for name, result := range report.Checks {
+			slog.Info("Dependency check", "dependency", name, "status", result.Status, "duration_ms", result.DurationMs, "error", result.Error)
+		}
+		if !report.Healthy {
+			unhealthy := strings.Join(report.Unhealthy(), ",")
+			if cfg.PreflightCheck == "fail" {
+				logging.Fatal("Preflight dependency checks failed", "unhealthy", unhealthy)
+			}
+			slog.Warn("Preflight dependency checks failed, continuing", "unhealthy", unhealthy)
+		}
+	}
+	if cfg.StartupSelfTest {
+		runStartupSelfTest(ctx, cfg, s3Uploader, snsPublisher)
+	}
+
+	// Start HTTP server for operational endpoints
+	healthServer := health.NewServer(cfg.HealthPort)
+	healthServer.Handle("/healthz", health.LivenessHandler())
+	// AWS clients are initialized above, so readiness waits on the watcher and,
+	// if enabled, re-checks the AWS dependencies
+	var readinessChecker *health.Checker
+	if cfg.ReadyzCheckDependencies {
+		readinessChecker = dependencyChecker
+	}
+	healthServer.Handle("/readyz", health.ReadinessHandler(fileWatcher.Ready, readinessChecker))
+	healthServer.Handle("/recent", fileWatcher.RecentHandler())
+	healthServer.Handle("/metrics", metrics.Handler())
+	if cfg.MetricsResetEnabled {
+		healthServer.Handle("/metrics/reset", metrics.ResetHandler(metrics.Default, cfg.MetricsResetToken))
+		slog.Info("Metrics reset endpoint enabled")
+	}
+	if cfg.SNSSelfTestEnabled {
+		healthServer.Handle("/selftest/sns", awspackage.SelfTestHandler(snsPublisher))
+		slog.Info("SNS self-test endpoint enabled")
+	}
+	go func() {
+		if err := healthServer.Run(ctx); err != nil {
+			slog.Error("HTTP server failed", "error", err)
+		}
+	}()
+
+	// Mirror the key counters into CloudWatch
+	var cloudWatchMetrics *awspackage.CloudWatchMetrics
+	if cfg.CloudWatchMetrics {
+		cloudWatchOptions := awspackage.DefaultCloudWatchMetricsOptions()
+		cloudWatchOptions.Namespace = cfg.CloudWatchNamespace
+		cloudWatchOptions.FlushInterval = cfg.CloudWatchFlush
+		if cloudWatchMetrics, err = awspackage.NewCloudWatchMetrics(ctx, cfg.AWSRegion, cloudWatchOptions); err != nil {
+			logging.Fatal("Failed to create CloudWatch metrics publisher", "error", err)
+		}
+		go cloudWatchMetrics.Run(ctx)
+		slog.Info("Publishing metrics to CloudWatch", "namespace", cfg.CloudWatchNamespace, "interval", cfg.CloudWatchFlush.String())
+	}
+
+	// Alert the ops topic when failures pile up
+	if cfg.OpsAlertTopicARN != "" {
+		alertSNSOptions := awspackage.DefaultSNSPublisherOptions()
+		alertSNSOptions.SanitizeMode = cfg.SNSSanitizeMode
+		alertSNSOptions.Bucket = cfg.S3Bucket
+		alertPublisher, err := awspackage.NewSNSPublisher(ctx, cfg.AWSRegion, cfg.OpsAlertTopicARN, cloudFrontSigner, alertSNSOptions)
+		if err != nil {
+			logging.Fatal("Failed to create ops alert publisher", "topic_arn", cfg.OpsAlertTopicARN, "error", err)
+		}
+		alertOptions := awspackage.DefaultOpsAlertOptions()
+		alertOptions.Threshold = cfg.OpsAlertThreshold
+		alertOptions.Window = cfg.OpsAlertWindow
+		alertOptions.Cooldown = cfg.OpsAlertCooldown
+		go awspackage.NewOpsAlerter(alertPublisher, alertOptions).Run(ctx)
+		slog.Info("Ops alerting enabled", "topic_arn", cfg.OpsAlertTopicARN, "threshold", cfg.OpsAlertThreshold,
+			"window", cfg.OpsAlertWindow.String(), "cooldown", cfg.OpsAlertCooldown.String())
+	}
+
+	// Periodically retry uploads that failed, e.g. during an S3 outage
+	if cfg.FailedUploadRetryInterval > 0 {
+		go s3Uploader.RetryFailedUploads(ctx, cfg.FailedUploadRetryInterval, fileWatcher.PublishRetriedUpload)
+	}
+
+	// Delete kept videos once they are past LOCAL_RETENTION
+	if cfg.LocalRetention > 0 {
+		go fileWatcher.SweepProcessed(ctx)
+	}
+
+	// Keep the video volume writable by evicting old files when it runs low
+	if cfg.MinFreeDiskMB > 0 {
+		go fileWatcher.GuardDiskSpace(ctx)
 	}
-	defer fileWatcher.Close()
-	log.Println("File watcher initialized")
 
 	// Start file watcher in a goroutine
 	watcherErrors := make(chan error, 1)
@@ -73,17 +402,130 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	log.Println("EyeSeeYou Backend is running. Press Ctrl+C to stop.")
+	slog.Info("EyeSeeYou Backend is running. Press Ctrl+C to stop.")
 
 	// Wait for shutdown signal or watcher error
 	select {
 	case sig := <-sigChan:
-		log.Printf("Received signal: %v. Shutting down gracefully...", sig)
+		slog.Info("Received signal, shutting down gracefully", "signal", sig.String())
 		cancel()
 	case err := <-watcherErrors:
-		log.Printf("File watcher error: %v. Shutting down...", err)
+		slog.Error("File watcher failed, shutting down", "error", err)
 		cancel()
 	}
 
-	log.Println("Shutdown complete.")
+	// Wait for in-flight uploads before exiting
+	closeErr := fileWatcher.Close()
+	if closeErr != nil {
+		slog.Error("Failed to close file watcher", "error", closeErr)
+	}
+
+	// Publish any notifications still waiting in a batch
+	for topicARN, publisher := range publishers {
+		if err := publisher.Flush(context.Background()); err != nil {
+			slog.Error("Failed to flush batched notifications", "topic_arn", topicARN, "error", err)
+		}
+	}
+
+	// Send the counts since the last CloudWatch flush, including the shutdown drain
+	if cloudWatchMetrics != nil {
+		if err := cloudWatchMetrics.Flush(context.Background()); err != nil {
+			slog.Error("Failed to flush CloudWatch metrics", "error", err)
+		}
+	}
+
+	// Clear local directories per the configured policy now nothing is using them.
+	// Abandoned uploads may still be writing there, so leave them alone.
+	if errors.Is(closeErr, watcher.ErrUploadsAbandoned) {
+		slog.Warn("Skipping shutdown cleanup: uploads were abandoned", "policy", cfg.ShutdownCleanup)
+	} else if err := watcher.ShutdownCleanup(cfg.ShutdownCleanup, []string{cfg.TranscodeDir}, cfg.FailedUploadDir); err != nil {
+		slog.Error("Shutdown cleanup failed", "error", err)
+	}
+
+	slog.Info("Shutdown complete.")
+}
+
+// runSelfTest runs the pipeline once against a generated test video, prints the
+// report as JSON and returns the process exit code
+func runSelfTest(ctx context.Context, cfg *config.Config, uploader *awspackage.S3Uploader, signer *awspackage.CloudFrontSigner, publisher *awspackage.SNSPublisher, snsOptions awspackage.SNSPublisherOptions) int {
+	runner := &selftest.Runner{
+		Uploader:         uploader,
+		Publisher:        publisher,
+		CloudFrontDomain: cfg.CloudFrontDomain,
+		DryRun:           cfg.SelfTestTopicARN == "",
+	}
+	if !runner.DryRun {
+		// Publish immediately rather than waiting on a batch window
+		snsOptions.BatchWindow = 0
+		testPublisher, err := awspackage.NewSNSPublisher(ctx, cfg.AWSRegion, cfg.SelfTestTopicARN, signer, snsOptions)
+		if err != nil {
+			logging.Fatal("Failed to create self-test SNS publisher", "topic_arn", cfg.SelfTestTopicARN, "error", err)
+		}
+		runner.Publisher = testPublisher
+	}
+
+	slog.Info("Running self-test", "dry_run", runner.DryRun, "topic_arn", cfg.SelfTestTopicARN)
+	report := runner.Run(ctx)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error("Failed to write self-test report", "error", err)
+	}
+
+	if !report.Passed {
+		slog.Error("Self-test failed")
+		return 1
+	}
+	slog.Info("Self-test passed")
+	return 0
+}
+
+// runStartupSelfTest checks the credentials can write to the bucket and reach
+// the SNS topic, exiting with the failed checks if not
+func runStartupSelfTest(ctx context.Context, cfg *config.Config, uploader *awspackage.S3Uploader, publisher *awspackage.SNSPublisher) {
+	checker := health.NewChecker(cfg.DependencyCheckTimeout)
+	checker.Add("s3_write", uploader.CheckWrite)
+	if cfg.Notifier == awspackage.NotifierSNS {
+		checker.Add("sns", publisher.CheckHealth)
+	}
+
+	report := checker.Run(ctx)
+	if report.Healthy {
+		slog.Info("Startup self-test passed", "bucket", cfg.S3Bucket, "topic_arn", cfg.SNSTopicARN)
+		return
+	}
+	for _, name := range report.Unhealthy() {
+		slog.Error("Startup self-test check failed", "check", name, "error", report.Checks[name].Error)
+	}
+	logging.Fatal("Startup self-test failed; check the IAM role allows s3:PutObject on the bucket and sns:GetTopicAttributes and sns:Publish on the topic",
+		"failed", strings.Join(report.Unhealthy(), ","))
+}
+
+// runBackfill uploads the existing videos in dir, prints the summary as JSON and
+// returns the process exit code
+func runBackfill(ctx context.Context, cfg *config.Config, dir string, uploader *awspackage.S3Uploader, publisher *awspackage.SNSPublisher, opts backfill.Options) int {
+	summary, err := backfill.Run(ctx, dir, uploader, publisher, opts)
+	if err != nil {
+		slog.Error("Backfill failed", "dir", dir, "error", err)
+		return 1
+	}
+
+	// Send notifications still waiting in a batch window before exiting
+	exitCode := 0
+	if err := publisher.Flush(ctx); err != nil {
+		slog.Error("Failed to flush batched notifications", "error", err)
+		exitCode = 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		slog.Error("Failed to write backfill summary", "error", err)
+	}
+
+	if summary.Failed > 0 {
+		exitCode = 1
+	}
+	return exitCode
 }
@@ -0,0 +1,414 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxAtomHeaderRead caps how much of an mvhd/tkhd atom is read into memory
+const maxAtomHeaderRead = 256
+
+// ErrNoMovieHeader is returned when a file has no moov/mvhd atom
+var ErrNoMovieHeader = errors.New("mp4: no movie header found")
+
+// MP4Info is the metadata read from an MP4's movie and track headers
+type MP4Info struct {
+	DurationSeconds float64
+	// Width and Height come from the first video track; zero if there isn't one
+	Width  int
+	Height int
+}
+
+// ReadMP4Info reads duration and resolution from an MP4's moov atom without ffmpeg.
+// The moov atom may be at either end of the file.
+func ReadMP4Info(path string) (MP4Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return MP4Info{}, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return MP4Info{}, err
+	}
+
+	var info MP4Info
+	found := false
+	err = walkAtoms(file, 0, stat.Size(), func(atomType string, offset, size int64) (bool, error) {
+		switch atomType {
+		case "moov", "trak":
+			// Containers: descend into their children
+			return true, nil
+		case "mvhd":
+			duration, err := readMovieHeader(file, offset, size)
+			if err != nil {
+				return false, err
+			}
+			info.DurationSeconds = duration
+			found = true
+		case "tkhd":
+			width, height, err := readTrackHeader(file, offset, size)
+			if err != nil {
+				return false, err
+			}
+			// Audio tracks have a zero size; keep the first video track
+			if info.Width == 0 && width > 0 && height > 0 {
+				info.Width, info.Height = width, height
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return MP4Info{}, err
+	}
+	if !found {
+		return MP4Info{}, ErrNoMovieHeader
+	}
+	return info, nil
+}
+
+// walkAtoms visits each atom in [start, end), descending into it when visit returns true.
+// offset and size passed to visit describe the atom's payload, excluding its header.
+func walkAtoms(r io.ReaderAt, start, end int64, visit func(atomType string, offset, size int64) (bool, error)) error {
+	header := make([]byte, 16)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(header[:8], pos); err != nil {
+			return fmt.Errorf("mp4: reading atom header at %d: %w", pos, err)
+		}
+
+		atomSize := int64(binary.BigEndian.Uint32(header[:4]))
+		atomType := string(header[4:8])
+		headerSize := int64(8)
+
+		switch atomSize {
+		case 0:
+			// Atom extends to the end of its parent
+			atomSize = end - pos
+		case 1:
+			// 64-bit size follows the type
+			if _, err := r.ReadAt(header[8:16], pos+8); err != nil {
+				return fmt.Errorf("mp4: reading extended size at %d: %w", pos, err)
+			}
+			atomSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if atomSize < headerSize || pos+atomSize > end {
+			return fmt.Errorf("mp4: invalid size %d for atom %q at %d", atomSize, atomType, pos)
+		}
+
+		descend, err := visit(atomType, pos+headerSize, atomSize-headerSize)
+		if err != nil {
+			return err
+		}
+		if descend {
+			if err := walkAtoms(r, pos+headerSize, pos+atomSize, visit); err != nil {
+				return err
+			}
+		}
+
+		pos += atomSize
+	}
+	return nil
+}
+
+// readAtomPayload reads up to maxAtomHeaderRead bytes of an atom's payload
+func readAtomPayload(r io.ReaderAt, offset, size int64) ([]byte, error) {
+	if size > maxAtomHeaderRead {
+		size = maxAtomHeaderRead
+	}
+	buf := make([]byte, size)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readMovieHeader returns the movie duration in seconds from an mvhd atom
+func readMovieHeader(r io.ReaderAt, offset, size int64) (float64, error) {
+	buf, err := readAtomPayload(r, offset, size)
+	if err != nil {
+		return 0, fmt.Errorf("mp4: reading mvhd: %w", err)
+	}
+	if len(buf) < 1 {
+		return 0, fmt.Errorf("mp4: mvhd too short")
+	}
+
+	var timescale uint32
+	var duration uint64
+	switch version := buf[0]; version {
+	case 0:
+		// version+flags(4) creation(4) modification(4) timescale(4) duration(4)
+		if len(buf) < 20 {
+			return 0, fmt.Errorf("mp4: mvhd too short")
+		}
+		timescale = binary.BigEndian.Uint32(buf[12:16])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:20]))
+	case 1:
+		// version+flags(4) creation(8) modification(8) timescale(4) duration(8)
+		if len(buf) < 32 {
+			return 0, fmt.Errorf("mp4: mvhd too short")
+		}
+		timescale = binary.BigEndian.Uint32(buf[20:24])
+		duration = binary.BigEndian.Uint64(buf[24:32])
+	default:
+		return 0, fmt.Errorf("mp4: unsupported mvhd version %d", version)
+	}
+
+	if timescale == 0 {
+		return 0, fmt.Errorf("mp4: mvhd has zero timescale")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// readTrackHeader returns a track's display width and height from a tkhd atom
+func readTrackHeader(r io.ReaderAt, offset, size int64) (int, int, error) {
+	buf, err := readAtomPayload(r, offset, size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("mp4: reading tkhd: %w", err)
+	}
+	if len(buf) < 1 {
+		return 0, 0, fmt.Errorf("mp4: tkhd too short")
+	}
+
+	// Width and height are 16.16 fixed point at the end of the header,
+	// after the version-dependent times and 52 bytes of layout fields
+	var sizeOffset int
+	switch version := buf[0]; version {
+	case 0:
+		sizeOffset = 4 + 20 + 52
+	case 1:
+		sizeOffset = 4 + 32 + 52
+	default:
+		return 0, 0, fmt.Errorf("mp4: unsupported tkhd version %d", version)
+	}
+	if len(buf) < sizeOffset+8 {
+		return 0, 0, fmt.Errorf("mp4: tkhd too short")
+	}
+
+	width := int(binary.BigEndian.Uint32(buf[sizeOffset:sizeOffset+4]) >> 16)
+	height := int(binary.BigEndian.Uint32(buf[sizeOffset+4:sizeOffset+8]) >> 16)
+	return width, height, nil
+}
+
+// maxSampleTableRead caps how much of a sample table atom is read into memory;
+// an hour of 30fps video needs well under 1 MiB per table
+const maxSampleTableRead = 16 << 20
+
+// Keyframe locates a video track's first sync sample in an MP4
+type Keyframe struct {
+	// Codec is the sample entry type from stsd, e.g. "avc1" or "jpeg"
+	Codec  string
+	Offset int64
+	Size   int64
+}
+
+// mp4Track holds what FindFirstKeyframe needs from a trak atom: its handler
+// and codec, and the payload offset and size of each sample table atom
+type mp4Track struct {
+	handler string
+	codec   string
+	tables  map[string][2]int64
+}
+
+// FindFirstKeyframe locates the first sync sample of an MP4's first video track
+// from its sample tables (stss, stsc, stsz and stco/co64), without decoding anything
+func FindFirstKeyframe(path string) (Keyframe, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Keyframe{}, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return Keyframe{}, err
+	}
+
+	var video *mp4Track
+	err = walkAtoms(file, 0, stat.Size(), func(atomType string, offset, size int64) (bool, error) {
+		switch atomType {
+		case "moov":
+			return video == nil, nil
+		case "trak":
+			track, err := readTrack(file, offset, size)
+			if err != nil {
+				return false, err
+			}
+			if video == nil && track.handler == "vide" {
+				video = track
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return Keyframe{}, err
+	}
+	if video == nil {
+		return Keyframe{}, fmt.Errorf("mp4: no video track found")
+	}
+	return video.firstKeyframe(file)
+}
+
+// readTrack collects a trak atom's handler, codec and sample table locations
+func readTrack(r io.ReaderAt, offset, size int64) (*mp4Track, error) {
+	track := &mp4Track{tables: make(map[string][2]int64)}
+	err := walkAtoms(r, offset, offset+size, func(atomType string, offset, size int64) (bool, error) {
+		switch atomType {
+		case "mdia", "minf", "stbl":
+			return true, nil
+		case "hdlr":
+			// version+flags(4) pre_defined(4) handler_type(4)
+			buf, err := readAtomPayload(r, offset, size)
+			if err != nil || len(buf) < 12 {
+				return false, fmt.Errorf("mp4: hdlr too short")
+			}
+			track.handler = string(buf[8:12])
+		case "stsd":
+			// version+flags(4) entry_count(4), then the first entry's size(4) and type(4)
+			buf, err := readAtomPayload(r, offset, size)
+			if err != nil || len(buf) < 16 {
+				return false, fmt.Errorf("mp4: stsd too short")
+			}
+			track.codec = string(buf[12:16])
+		case "stss", "stsc", "stsz", "stco", "co64":
+			track.tables[atomType] = [2]int64{offset, size}
+		}
+		return false, nil
+	})
+	return track, err
+}
+
+// table reads a full-box sample table's entries, after its version, flags and
+// any leading fields, or nil if the track doesn't have it
+func (t *mp4Track) table(r io.ReaderAt, atomType string, skip int) ([]byte, error) {
+	location, ok := t.tables[atomType]
+	if !ok {
+		return nil, nil
+	}
+	offset, size := location[0], location[1]
+	if size > maxSampleTableRead {
+		return nil, fmt.Errorf("mp4: %s is too large (%d bytes)", atomType, size)
+	}
+	buf := make([]byte, size)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil, fmt.Errorf("mp4: reading %s: %w", atomType, err)
+	}
+	if len(buf) < skip {
+		return nil, fmt.Errorf("mp4: %s too short", atomType)
+	}
+	return buf[skip:], nil
+}
+
+// firstKeyframe resolves the track's first sync sample to a file offset and size
+func (t *mp4Track) firstKeyframe(r io.ReaderAt) (Keyframe, error) {
+	// Sample numbers are 1-based; without stss every sample is a sync sample
+	sample := uint32(1)
+	stss, err := t.table(r, "stss", 8)
+	if err != nil {
+		return Keyframe{}, err
+	}
+	if stss != nil {
+		if len(stss) < 4 {
+			return Keyframe{}, fmt.Errorf("mp4: video track has no sync samples")
+		}
+		sample = binary.BigEndian.Uint32(stss[:4])
+	}
+
+	// stsz: sample_size(4) sample_count(4), then a size per sample unless all share sample_size
+	stsz, err := t.table(r, "stsz", 4)
+	if err != nil || stsz == nil || len(stsz) < 8 {
+		return Keyframe{}, fmt.Errorf("mp4: missing or short stsz")
+	}
+	uniformSize, sampleCount := binary.BigEndian.Uint32(stsz[:4]), binary.BigEndian.Uint32(stsz[4:8])
+	if sample == 0 || sample > sampleCount {
+		return Keyframe{}, fmt.Errorf("mp4: sync sample %d out of range (%d samples)", sample, sampleCount)
+	}
+	sampleSize := func(n uint32) (int64, error) {
+		if uniformSize != 0 {
+			return int64(uniformSize), nil
+		}
+		at := 8 + 4*int(n-1)
+		if len(stsz) < at+4 {
+			return 0, fmt.Errorf("mp4: stsz too short for sample %d", n)
+		}
+		return int64(binary.BigEndian.Uint32(stsz[at : at+4])), nil
+	}
+
+	chunk, firstInChunk, err := t.chunkOf(r, sample)
+	if err != nil {
+		return Keyframe{}, err
+	}
+	offset, err := t.chunkOffset(r, chunk)
+	if err != nil {
+		return Keyframe{}, err
+	}
+	// Samples are stored back to back within a chunk
+	for n := firstInChunk; n < sample; n++ {
+		size, err := sampleSize(n)
+		if err != nil {
+			return Keyframe{}, err
+		}
+		offset += size
+	}
+	size, err := sampleSize(sample)
+	if err != nil {
+		return Keyframe{}, err
+	}
+	return Keyframe{Codec: t.codec, Offset: offset, Size: size}, nil
+}
+
+// chunkOf returns the 1-based chunk holding a sample and the chunk's first sample, from stsc
+func (t *mp4Track) chunkOf(r io.ReaderAt, sample uint32) (uint32, uint32, error) {
+	// entry_count(4), then first_chunk(4) samples_per_chunk(4) sample_description_index(4) per run
+	stsc, err := t.table(r, "stsc", 8)
+	if err != nil || stsc == nil {
+		return 0, 0, fmt.Errorf("mp4: missing stsc")
+	}
+	entries := len(stsc) / 12
+	firstSample := uint32(1)
+	for i := 0; i < entries; i++ {
+		firstChunk := binary.BigEndian.Uint32(stsc[i*12:])
+		perChunk := binary.BigEndian.Uint32(stsc[i*12+4:])
+		if perChunk == 0 {
+			return 0, 0, fmt.Errorf("mp4: stsc run with no samples")
+		}
+		// The run lasts until the next entry's first chunk, or to the end of the track
+		if i+1 < entries {
+			chunks := binary.BigEndian.Uint32(stsc[(i+1)*12:]) - firstChunk
+			if sample >= firstSample+chunks*perChunk {
+				firstSample += chunks * perChunk
+				continue
+			}
+		}
+		index := (sample - firstSample) / perChunk
+		return firstChunk + index, firstSample + index*perChunk, nil
+	}
+	return 0, 0, fmt.Errorf("mp4: sample %d not in stsc", sample)
+}
+
+// chunkOffset returns the file offset of a 1-based chunk, from stco or co64
+func (t *mp4Track) chunkOffset(r io.ReaderAt, chunk uint32) (int64, error) {
+	if stco, err := t.table(r, "stco", 8); err != nil {
+		return 0, err
+	} else if stco != nil {
+		at := 4 * int(chunk-1)
+		if len(stco) < at+4 {
+			return 0, fmt.Errorf("mp4: stco too short for chunk %d", chunk)
+		}
+		return int64(binary.BigEndian.Uint32(stco[at : at+4])), nil
+	}
+	co64, err := t.table(r, "co64", 8)
+	if err != nil || co64 == nil {
+		return 0, fmt.Errorf("mp4: missing stco and co64")
+	}
+	at := 8 * int(chunk-1)
+	if len(co64) < at+8 {
+		return 0, fmt.Errorf("mp4: co64 too short for chunk %d", chunk)
+	}
+	return int64(binary.BigEndian.Uint64(co64[at : at+8])), nil
+}
@@ -0,0 +1,160 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preview is a scrub preview: a sprite sheet of thumbnails and a WebVTT
+// track mapping time ranges to regions of the sprite
+type Preview struct {
+	SpritePath string
+	VTTPath    string
+}
+
+// PreviewGenerator builds scrub previews for videos
+type PreviewGenerator interface {
+	// Generate writes the preview files for videoPath into outputDir.
+	// The VTT references the sprite by spriteName, relative to the VTT.
+	Generate(ctx context.Context, videoPath, outputDir, spriteName string) (Preview, error)
+}
+
+// PreviewOptions controls the sprite sheet layout
+type PreviewOptions struct {
+	// Interval between thumbnails
+	Interval time.Duration
+	// Size of each thumbnail in the sprite, in pixels
+	TileWidth  int
+	TileHeight int
+	// Columns of thumbnails per sprite row
+	Columns int
+}
+
+// DefaultPreviewOptions returns the default preview layout
+func DefaultPreviewOptions() PreviewOptions {
+	return PreviewOptions{
+		Interval:   1 * time.Second,
+		TileWidth:  160,
+		TileHeight: 90,
+		Columns:    10,
+	}
+}
+
+// FFmpegPreviewGenerator generates previews with ffprobe and ffmpeg
+type FFmpegPreviewGenerator struct {
+	ffmpegPath  string
+	ffprobePath string
+	opts        PreviewOptions
+}
+
+// NewFFmpegPreviewGenerator creates a preview generator.
+// Returns ErrFFmpegNotFound if either binary can't be resolved.
+func NewFFmpegPreviewGenerator(ffmpegPath, ffprobePath string, opts PreviewOptions) (*FFmpegPreviewGenerator, error) {
+	if opts.Interval <= 0 || opts.TileWidth <= 0 || opts.TileHeight <= 0 || opts.Columns <= 0 {
+		return nil, fmt.Errorf("invalid preview options: %+v", opts)
+	}
+
+	resolvedFFmpeg, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFFmpegNotFound, err)
+	}
+	resolvedFFprobe, err := exec.LookPath(ffprobePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ffprobe: %v", ErrFFmpegNotFound, err)
+	}
+
+	return &FFmpegPreviewGenerator{
+		ffmpegPath:  resolvedFFmpeg,
+		ffprobePath: resolvedFFprobe,
+		opts:        opts,
+	}, nil
+}
+
+// Generate writes a sprite sheet and WebVTT track for videoPath into outputDir
+func (g *FFmpegPreviewGenerator) Generate(ctx context.Context, videoPath, outputDir, spriteName string) (Preview, error) {
+	duration, err := g.probeDuration(ctx, videoPath)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	interval := g.opts.Interval.Seconds()
+	count := int(math.Max(1, math.Ceil(duration/interval)))
+	rows := (count + g.opts.Columns - 1) / g.opts.Columns
+
+	preview := Preview{
+		SpritePath: filepath.Join(outputDir, spriteName),
+		VTTPath:    filepath.Join(outputDir, strings.TrimSuffix(spriteName, filepath.Ext(spriteName))+".vtt"),
+	}
+
+	filter := fmt.Sprintf("fps=1/%s,scale=%d:%d,tile=%dx%d",
+		strconv.FormatFloat(interval, 'f', -1, 64), g.opts.TileWidth, g.opts.TileHeight, g.opts.Columns, rows)
+	output, err := exec.CommandContext(ctx, g.ffmpegPath,
+		"-y", "-loglevel", "error", "-i", videoPath, "-vf", filter, "-frames:v", "1", preview.SpritePath,
+	).CombinedOutput()
+	if err != nil {
+		return Preview{}, fmt.Errorf("ffmpeg sprite generation failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	vtt := BuildSpriteVTT(spriteName, duration, g.opts, count)
+	if err := os.WriteFile(preview.VTTPath, []byte(vtt), 0644); err != nil {
+		return Preview{}, fmt.Errorf("failed to write VTT: %w", err)
+	}
+
+	return preview, nil
+}
+
+// probeDuration returns a video's duration in seconds
+func (g *FFmpegPreviewGenerator) probeDuration(ctx context.Context, videoPath string) (float64, error) {
+	output, err := exec.CommandContext(ctx, g.ffprobePath,
+		"-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", videoPath,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration %q: %w", strings.TrimSpace(string(output)), err)
+	}
+	return duration, nil
+}
+
+// BuildSpriteVTT builds a WebVTT track with one cue per sprite tile
+func BuildSpriteVTT(spriteName string, duration float64, opts PreviewOptions, count int) string {
+	interval := opts.Interval.Seconds()
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := 0; i < count; i++ {
+		start := float64(i) * interval
+		end := math.Min(float64(i+1)*interval, duration)
+		if end <= start {
+			end = start + interval
+		}
+		x := (i % opts.Columns) * opts.TileWidth
+		y := (i / opts.Columns) * opts.TileHeight
+
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			formatVTTTimestamp(start), formatVTTTimestamp(end), spriteName, x, y, opts.TileWidth, opts.TileHeight)
+	}
+	return b.String()
+}
+
+// formatVTTTimestamp formats seconds as a WebVTT timestamp (HH:MM:SS.mmm)
+func formatVTTTimestamp(seconds float64) string {
+	total := time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	hours := total / time.Hour
+	total -= hours * time.Hour
+	minutes := total / time.Minute
+	total -= minutes * time.Minute
+	secs := total / time.Second
+	total -= secs * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, secs, total/time.Millisecond)
+}
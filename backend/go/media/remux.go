@@ -0,0 +1,116 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrIncompatibleCodecs is returned when a video's streams can't be copied into MP4 as is
+var ErrIncompatibleCodecs = errors.New("codecs not compatible with a remux to mp4")
+
+// Codecs that can be copied into an MP4 and still play in browsers
+var (
+	remuxVideoCodecs = map[string]bool{"h264": true}
+	remuxAudioCodecs = map[string]bool{"aac": true, "mp3": true}
+)
+
+// Stream is one stream of a media file as reported by ffprobe
+type Stream struct {
+	// Type is the codec type, e.g. "video" or "audio"
+	Type string
+	// Codec is the codec name, e.g. "h264"
+	Codec string
+}
+
+// RemuxCompatible reports whether streams can be copied into an MP4 without
+// re-encoding: exactly one H.264 video stream, and audio (if any) in AAC or MP3.
+// Other stream types (subtitles, data) are dropped by the remux.
+func RemuxCompatible(streams []Stream) bool {
+	videoStreams := 0
+	for _, stream := range streams {
+		switch stream.Type {
+		case "video":
+			if !remuxVideoCodecs[stream.Codec] {
+				return false
+			}
+			videoStreams++
+		case "audio":
+			if !remuxAudioCodecs[stream.Codec] {
+				return false
+			}
+		}
+	}
+	return videoStreams == 1
+}
+
+// FFmpegRemuxer rewraps videos in an MP4 container without re-encoding.
+// It implements Transcoder, returning ErrIncompatibleCodecs for inputs that would need re-encoding.
+type FFmpegRemuxer struct {
+	ffmpegPath  string
+	ffprobePath string
+}
+
+// NewFFmpegRemuxer creates a remuxer.
+// Returns ErrFFmpegNotFound if either binary can't be resolved.
+func NewFFmpegRemuxer(ffmpegPath, ffprobePath string) (*FFmpegRemuxer, error) {
+	resolvedFFmpeg, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFFmpegNotFound, err)
+	}
+	resolvedFFprobe, err := exec.LookPath(ffprobePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ffprobe: %v", ErrFFmpegNotFound, err)
+	}
+
+	return &FFmpegRemuxer{
+		ffmpegPath:  resolvedFFmpeg,
+		ffprobePath: resolvedFFprobe,
+	}, nil
+}
+
+// Transcode remuxes inputPath into an MP4 at outputPath if its codecs allow it
+func (r *FFmpegRemuxer) Transcode(ctx context.Context, inputPath, outputPath string) error {
+	streams, err := r.probeStreams(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if !RemuxCompatible(streams) {
+		return ErrIncompatibleCodecs
+	}
+
+	output, err := exec.CommandContext(ctx, r.ffmpegPath,
+		"-y", "-loglevel", "error", "-i", inputPath,
+		"-map", "0:v", "-map", "0:a?", "-c", "copy", "-movflags", "+faststart", outputPath,
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg remux failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// probeStreams lists a file's streams with ffprobe
+func (r *FFmpegRemuxer) probeStreams(ctx context.Context, path string) ([]Stream, error) {
+	output, err := exec.CommandContext(ctx, r.ffprobePath,
+		"-v", "error", "-show_entries", "stream=codec_type,codec_name", "-of", "csv=p=0", path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseStreams(string(output)), nil
+}
+
+// parseStreams parses ffprobe's "codec_name,codec_type" CSV lines
+func parseStreams(output string) []Stream {
+	var streams []Stream
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		codec, codecType, ok := strings.Cut(strings.TrimSpace(line), ",")
+		if !ok {
+			continue
+		}
+		streams = append(streams, Stream{Type: codecType, Codec: codec})
+	}
+	return streams
+}
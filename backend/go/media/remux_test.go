@@ -0,0 +1,124 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemuxCompatible(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []Stream
+		want    bool
+	}{
+		{"h264 with aac", []Stream{{"video", "h264"}, {"audio", "aac"}}, true},
+		{"h264 with mp3", []Stream{{"video", "h264"}, {"audio", "mp3"}}, true},
+		{"h264 without audio", []Stream{{"video", "h264"}}, true},
+		{"subtitles dropped", []Stream{{"video", "h264"}, {"subtitle", "subrip"}, {"data", "bin_data"}}, true},
+		{"hevc", []Stream{{"video", "hevc"}, {"audio", "aac"}}, false},
+		{"vp9", []Stream{{"video", "vp9"}}, false},
+		{"opus audio", []Stream{{"video", "h264"}, {"audio", "opus"}}, false},
+		{"two video streams", []Stream{{"video", "h264"}, {"video", "h264"}}, false},
+		{"audio only", []Stream{{"audio", "aac"}}, false},
+		{"no streams", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemuxCompatible(tt.streams); got != tt.want {
+				t.Errorf("RemuxCompatible(%v) = %v, want %v", tt.streams, got, tt.want)
+			}
+		})
+	}
+}
+
+// stubFFmpeg installs ffprobe and ffmpeg scripts in a temporary directory.
+// ffprobe prints probeOutput, or fails if it is empty; ffmpeg records its
+// arguments in the returned log file and copies its input to its output.
+func stubFFmpeg(t *testing.T, probeOutput string) (ffmpeg, ffprobe, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	ffmpeg = filepath.Join(dir, "ffmpeg")
+	ffprobe = filepath.Join(dir, "ffprobe")
+	log = filepath.Join(dir, "ffmpeg.log")
+
+	probeScript := "#!/bin/sh\nexit 1\n"
+	if probeOutput != "" {
+		probeScript = "#!/bin/sh\nprintf '%s' '" + probeOutput + "'\n"
+	}
+	ffmpegScript := "#!/bin/sh\necho \"$@\" > '" + log + "'\nfor arg; do out=$arg; done\ncp \"$5\" \"$out\"\n"
+	for path, script := range map[string]string{ffprobe: probeScript, ffmpeg: ffmpegScript} {
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ffmpeg, ffprobe, log
+}
+
+func TestFFmpegRemuxer(t *testing.T) {
+	tests := []struct {
+		name        string
+		probe       string
+		wantErr     error
+		wantRemuxed bool
+	}{
+		{"compatible", "h264,video\naac,audio\n", nil, true},
+		{"needs re-encoding", "hevc,video\naac,audio\n", ErrIncompatibleCodecs, false},
+		{"probe fails", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpeg, ffprobe, log := stubFFmpeg(t, tt.probe)
+			remuxer, err := NewFFmpegRemuxer(ffmpeg, ffprobe)
+			if err != nil {
+				t.Fatalf("NewFFmpegRemuxer: %v", err)
+			}
+
+			dir := t.TempDir()
+			input := filepath.Join(dir, "clip.mkv")
+			output := filepath.Join(dir, "clip.mp4")
+			if err := os.WriteFile(input, []byte("matroska"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err = remuxer.Transcode(context.Background(), input, output)
+			switch {
+			case tt.wantRemuxed && err != nil:
+				t.Fatalf("Transcode: %v", err)
+			case !tt.wantRemuxed && err == nil:
+				t.Fatal("Transcode succeeded, want an error")
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("Transcode() = %v, want %v", err, tt.wantErr)
+			}
+
+			args, logErr := os.ReadFile(log)
+			if !tt.wantRemuxed {
+				if logErr == nil {
+					t.Errorf("ffmpeg ran for an input that can't be remuxed: %s", args)
+				}
+				return
+			}
+			if logErr != nil {
+				t.Fatalf("ffmpeg did not run: %v", logErr)
+			}
+			// Streams are copied, never re-encoded
+			if !strings.Contains(string(args), "-c copy") {
+				t.Errorf("ffmpeg args %q do not copy streams", args)
+			}
+			if data, err := os.ReadFile(output); err != nil || string(data) != "matroska" {
+				t.Errorf("output = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestNewFFmpegRemuxerMissingBinary(t *testing.T) {
+	ffmpeg, _, _ := stubFFmpeg(t, "")
+	_, err := NewFFmpegRemuxer(ffmpeg, filepath.Join(t.TempDir(), "ffprobe"))
+	if !errors.Is(err, ErrFFmpegNotFound) {
+		t.Errorf("NewFFmpegRemuxer() = %v, want %v", err, ErrFFmpegNotFound)
+	}
+}
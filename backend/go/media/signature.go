@@ -0,0 +1,76 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// Bytes read to identify a container: enough for an MPEG-TS file's second sync byte
+const signatureReadSize = 189
+
+// Container formats DetectContainer recognises
+const (
+	ContainerMP4      = "mp4" // MP4 and QuickTime (.mov), identified by their atoms
+	ContainerMatroska = "matroska"
+	ContainerAVI      = "avi"
+	ContainerMPEGTS   = "mpegts"
+	ContainerMPEGPS   = "mpegps"
+)
+
+// ErrUnknownContainer is returned when a file doesn't start with a known video container signature
+var ErrUnknownContainer = errors.New("not a recognised video container")
+
+// Top-level atoms a QuickTime file may start with instead of ftyp
+var quickTimeLeadingAtoms = []string{"moov", "mdat", "wide", "free", "skip"}
+
+// DetectContainer identifies a video's container from its first bytes, so a
+// mislabelled file or one whose header hasn't been written yet can be told
+// apart from a real video. Returns ErrUnknownContainer for anything else.
+func DetectContainer(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, signatureReadSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return detectContainer(header[:n])
+}
+
+// detectContainer matches a file's first bytes against the known signatures
+func detectContainer(header []byte) (string, error) {
+	switch {
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return ContainerMP4, nil
+	case len(header) >= 8 && isQuickTimeAtom(string(header[4:8])):
+		return ContainerMP4, nil
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// EBML header, used by Matroska and WebM
+		return ContainerMatroska, nil
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return ContainerAVI, nil
+	case len(header) > 188 && header[0] == 0x47 && header[188] == 0x47:
+		// Transport stream packets are 188 bytes, each starting with a sync byte
+		return ContainerMPEGTS, nil
+	case bytes.HasPrefix(header, []byte{0x00, 0x00, 0x01, 0xBA}):
+		// Program stream pack header
+		return ContainerMPEGPS, nil
+	}
+	return "", ErrUnknownContainer
+}
+
+// isQuickTimeAtom reports whether an atom type may lead a QuickTime file
+func isQuickTimeAtom(atomType string) bool {
+	for _, leading := range quickTimeLeadingAtoms {
+		if atomType == leading {
+			return true
+		}
+	}
+	return false
+}
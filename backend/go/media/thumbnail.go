@@ -0,0 +1,175 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// maxKeyframeRead caps the size of a keyframe read into memory
+const maxKeyframeRead = 32 << 20
+
+// ErrUnsupportedCodec is returned when a video's frames can't be decoded in process
+var ErrUnsupportedCodec = errors.New("codec can't be decoded in process")
+
+// Sample entry types of Motion JPEG tracks, whose every frame is a JPEG image
+var motionJPEGCodecs = map[string]bool{"jpeg": true, "mjpa": true, "mjpg": true, "MJPG": true}
+
+// ThumbnailGenerator extracts a still image from a video
+type ThumbnailGenerator interface {
+	// Generate writes a JPEG of the video's first keyframe to outputPath
+	Generate(ctx context.Context, videoPath, outputPath string) error
+}
+
+// ThumbnailerChain tries each thumbnailer in turn until one succeeds
+type ThumbnailerChain []ThumbnailGenerator
+
+// Generate writes a thumbnail with the first thumbnailer that manages it
+func (c ThumbnailerChain) Generate(ctx context.Context, videoPath, outputPath string) error {
+	var errs []error
+	for _, thumbnailer := range c {
+		err := thumbnailer.Generate(ctx, videoPath, outputPath)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MP4Thumbnailer extracts thumbnails in process, without ffmpeg, from MP4s
+// whose video track is Motion JPEG: the first keyframe is found from the
+// sample tables and is itself a JPEG, so it only needs scaling. Go has no
+// H.264/H.265 decoder, so other codecs return ErrUnsupportedCodec.
+type MP4Thumbnailer struct {
+	width int
+}
+
+// NewMP4Thumbnailer creates a thumbnailer scaling images to width pixels wide
+func NewMP4Thumbnailer(width int) (*MP4Thumbnailer, error) {
+	if width <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail width: %d", width)
+	}
+	return &MP4Thumbnailer{width: width}, nil
+}
+
+// Generate writes a JPEG of videoPath's first keyframe to outputPath
+func (t *MP4Thumbnailer) Generate(ctx context.Context, videoPath, outputPath string) error {
+	keyframe, err := FindFirstKeyframe(videoPath)
+	if err != nil {
+		return err
+	}
+	if !motionJPEGCodecs[keyframe.Codec] {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCodec, keyframe.Codec)
+	}
+	if keyframe.Size > maxKeyframeRead {
+		return fmt.Errorf("keyframe is too large (%d bytes)", keyframe.Size)
+	}
+
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	frame, err := jpeg.Decode(io.NewSectionReader(file, keyframe.Offset, keyframe.Size))
+	if err != nil {
+		return fmt.Errorf("failed to decode keyframe: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(output, scaleToWidth(frame, t.width), &jpeg.Options{Quality: 80}); err != nil {
+		output.Close()
+		return fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return output.Close()
+}
+
+// scaleToWidth resizes an image to width pixels wide, keeping the aspect ratio
+// with an even height as ffmpeg's scale=<width>:-2 does. Each output pixel
+// averages the source pixels it covers.
+func scaleToWidth(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	height := (srcH*width/srcW + 1) &^ 1
+	if height < 2 {
+		height = 2
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			at := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[at+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// FFmpegThumbnailer extracts thumbnails with ffmpeg, for the H.264/H.265
+// videos MP4Thumbnailer can't decode, so it needs ffmpeg on the device.
+type FFmpegThumbnailer struct {
+	ffmpegPath string
+	width      int
+}
+
+// NewFFmpegThumbnailer creates a thumbnailer scaling images to width pixels wide.
+// Returns ErrFFmpegNotFound if the binary can't be resolved.
+func NewFFmpegThumbnailer(ffmpegPath string, width int) (*FFmpegThumbnailer, error) {
+	if width <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail width: %d", width)
+	}
+
+	resolved, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFFmpegNotFound, err)
+	}
+	return &FFmpegThumbnailer{ffmpegPath: resolved, width: width}, nil
+}
+
+// Generate writes a JPEG of videoPath's first keyframe to outputPath. Only
+// keyframes are decoded, so this is quick even for long videos.
+func (t *FFmpegThumbnailer) Generate(ctx context.Context, videoPath, outputPath string) error {
+	// Scale to the width, keeping the aspect ratio with an even height
+	filter := fmt.Sprintf("scale=%d:-2", t.width)
+	output, err := exec.CommandContext(ctx, t.ffmpegPath,
+		"-y", "-loglevel", "error", "-skip_frame", "nokey", "-i", videoPath,
+		"-frames:v", "1", "-vf", filter, "-q:v", "4", outputPath,
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg thumbnail extraction failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
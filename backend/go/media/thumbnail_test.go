@@ -0,0 +1,213 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// atom builds an MP4 atom from its type and payload parts
+func atom(atomType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, atomType...), body...)
+}
+
+// fullAtom builds a version 0 full atom whose payload is a list of 32-bit fields
+func fullAtom(atomType string, fields ...uint32) []byte {
+	payload := make([]byte, 4)
+	for _, field := range fields {
+		payload = binary.BigEndian.AppendUint32(payload, field)
+	}
+	return atom(atomType, payload)
+}
+
+// solidJPEG encodes a 64x48 image of a single colour
+func solidJPEG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// mp4Layout describes how a test MP4 stores its two video frames
+type mp4Layout struct {
+	codec         string
+	chunkPerFrame bool // one chunk per sample instead of both in one chunk
+	co64          bool // 64-bit chunk offsets
+	syncSamples   []uint32
+}
+
+// buildMP4 writes an MP4 with an audio track followed by a two-frame video
+// track whose samples are the given frames, placed after some filler in mdat
+func buildMP4(t *testing.T, layout mp4Layout, frames ...[]byte) string {
+	t.Helper()
+	ftyp := atom("ftyp", []byte("isom\x00\x00\x02\x00isom"))
+	filler := []byte("filler!")
+	first := int64(len(ftyp) + 8 + len(filler))
+
+	var chunkOffsets []int64
+	var sizes []uint32
+	offset := first
+	for _, frame := range frames {
+		if layout.chunkPerFrame || len(chunkOffsets) == 0 {
+			chunkOffsets = append(chunkOffsets, offset)
+		}
+		sizes = append(sizes, uint32(len(frame)))
+		offset += int64(len(frame))
+	}
+	mdat := atom("mdat", append([]byte(filler), bytes.Join(frames, nil)...))
+
+	perChunk := uint32(len(frames))
+	if layout.chunkPerFrame {
+		perChunk = 1
+	}
+	stsz := fullAtom("stsz", append([]uint32{0, uint32(len(sizes))}, sizes...)...)
+	stsc := fullAtom("stsc", 1, 1, perChunk, 1)
+	var chunks []byte
+	if layout.co64 {
+		payload := binary.BigEndian.AppendUint32(make([]byte, 4), uint32(len(chunkOffsets)))
+		for _, chunkOffset := range chunkOffsets {
+			payload = binary.BigEndian.AppendUint64(payload, uint64(chunkOffset))
+		}
+		chunks = atom("co64", payload)
+	} else {
+		fields := []uint32{uint32(len(chunkOffsets))}
+		for _, chunkOffset := range chunkOffsets {
+			fields = append(fields, uint32(chunkOffset))
+		}
+		chunks = fullAtom("stco", fields...)
+	}
+	var stss []byte
+	if layout.syncSamples != nil {
+		stss = fullAtom("stss", append([]uint32{uint32(len(layout.syncSamples))}, layout.syncSamples...)...)
+	}
+	stsd := fullAtom("stsd", 1, 16, binary.BigEndian.Uint32([]byte(layout.codec)), 0, 1)
+
+	track := func(handler string, stbl ...[]byte) []byte {
+		hdlr := fullAtom("hdlr", 0, binary.BigEndian.Uint32([]byte(handler)), 0, 0, 0)
+		return atom("trak", atom("mdia", hdlr, atom("minf", atom("stbl", stbl...))))
+	}
+	audio := track("soun", fullAtom("stsd", 1, 16, binary.BigEndian.Uint32([]byte("mp4a")), 0, 1))
+	video := track("vide", stsd, stsc, stsz, chunks, stss)
+	moov := atom("moov", audio, video)
+
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := os.WriteFile(path, bytes.Join([][]byte{ftyp, mdat, moov}, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMP4ThumbnailerUsesFirstKeyframe(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	frames := [][]byte{solidJPEG(t, red), solidJPEG(t, blue)}
+
+	tests := []struct {
+		name   string
+		layout mp4Layout
+		want   color.RGBA
+	}{
+		{"every sample a keyframe", mp4Layout{codec: "jpeg"}, red},
+		{"second sample keyframe", mp4Layout{codec: "jpeg", syncSamples: []uint32{2}}, blue},
+		{"chunk per sample", mp4Layout{codec: "mjpa", chunkPerFrame: true, syncSamples: []uint32{2}}, blue},
+		{"64-bit chunk offsets", mp4Layout{codec: "jpeg", co64: true, syncSamples: []uint32{2}}, blue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnailer, err := NewMP4Thumbnailer(32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(t.TempDir(), "thumb.jpg")
+			if err := thumbnailer.Generate(context.Background(), buildMP4(t, tt.layout, frames...), output); err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			thumbnail, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("thumbnail isn't a JPEG: %v", err)
+			}
+			if got := thumbnail.Bounds().Size(); got != image.Pt(32, 24) {
+				t.Errorf("thumbnail size = %v, want 32x24", got)
+			}
+			r, g, b, _ := thumbnail.At(16, 12).RGBA()
+			if !near(r>>8, tt.want.R) || !near(g>>8, tt.want.G) || !near(b>>8, tt.want.B) {
+				t.Errorf("thumbnail colour = (%d, %d, %d), want %v", r>>8, g>>8, b>>8, tt.want)
+			}
+		})
+	}
+}
+
+// near reports whether a decoded colour channel is within JPEG error of want
+func near(got uint32, want uint8) bool {
+	diff := int(got) - int(want)
+	return diff > -24 && diff < 24
+}
+
+func TestMP4ThumbnailerRejectsOtherCodecs(t *testing.T) {
+	thumbnailer, _ := NewMP4Thumbnailer(32)
+	path := buildMP4(t, mp4Layout{codec: "avc1"}, []byte("not a jpeg"))
+	err := thumbnailer.Generate(context.Background(), path, filepath.Join(t.TempDir(), "thumb.jpg"))
+	if !errors.Is(err, ErrUnsupportedCodec) {
+		t.Errorf("Generate = %v, want ErrUnsupportedCodec", err)
+	}
+}
+
+// fakeThumbnailer returns err, recording that it ran
+type fakeThumbnailer struct {
+	err error
+	ran *int
+}
+
+func (f fakeThumbnailer) Generate(ctx context.Context, videoPath, outputPath string) error {
+	*f.ran++
+	return f.err
+}
+
+func TestThumbnailerChain(t *testing.T) {
+	failing := errors.New("no decoder")
+	tests := []struct {
+		name    string
+		errs    []error
+		wantRan int
+		wantErr bool
+	}{
+		{"first succeeds", []error{nil, nil}, 1, false},
+		{"falls back", []error{failing, nil}, 2, false},
+		{"all fail", []error{failing, failing}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := 0
+			var chain ThumbnailerChain
+			for _, err := range tt.errs {
+				chain = append(chain, fakeThumbnailer{err: err, ran: &ran})
+			}
+			err := chain.Generate(context.Background(), "clip.mp4", "thumb.jpg")
+			if (err != nil) != tt.wantErr || ran != tt.wantRan {
+				t.Errorf("Generate = %v after %d thumbnailers, want error %v after %d", err, ran, tt.wantErr, tt.wantRan)
+			}
+		})
+	}
+}
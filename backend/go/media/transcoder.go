@@ -0,0 +1,59 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrFFmpegNotFound is returned when the ffmpeg binary can't be located
+var ErrFFmpegNotFound = errors.New("ffmpeg not found")
+
+// DefaultTranscodeArgs converts to browser-friendly H.264/AAC with the moov atom up front
+var DefaultTranscodeArgs = []string{
+	"-c:v", "libx264",
+	"-preset", "veryfast",
+	"-crf", "23",
+	"-c:a", "aac",
+	"-movflags", "+faststart",
+}
+
+// Transcoder converts a video into a web-friendly format
+type Transcoder interface {
+	Transcode(ctx context.Context, inputPath, outputPath string) error
+}
+
+// FFmpegTranscoder transcodes videos by running ffmpeg
+type FFmpegTranscoder struct {
+	ffmpegPath string
+	args       []string
+}
+
+// NewFFmpegTranscoder creates a transcoder that runs ffmpeg with the given output args.
+// Returns ErrFFmpegNotFound if ffmpegPath can't be resolved.
+func NewFFmpegTranscoder(ffmpegPath string, args []string) (*FFmpegTranscoder, error) {
+	resolved, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFFmpegNotFound, err)
+	}
+
+	return &FFmpegTranscoder{
+		ffmpegPath: resolved,
+		args:       args,
+	}, nil
+}
+
+// Transcode runs ffmpeg to convert inputPath into outputPath, overwriting outputPath
+func (t *FFmpegTranscoder) Transcode(ctx context.Context, inputPath, outputPath string) error {
+	args := []string{"-y", "-loglevel", "error", "-i", inputPath}
+	args = append(args, t.args...)
+	args = append(args, outputPath)
+
+	output, err := exec.CommandContext(ctx, t.ffmpegPath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
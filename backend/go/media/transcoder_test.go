@@ -0,0 +1,14 @@
+package media
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFFmpegTranscoderMissingBinary(t *testing.T) {
+	_, err := NewFFmpegTranscoder(filepath.Join(t.TempDir(), "ffmpeg"), DefaultTranscodeArgs)
+	if !errors.Is(err, ErrFFmpegNotFound) {
+		t.Errorf("NewFFmpegTranscoder() = %v, want %v", err, ErrFFmpegNotFound)
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// UploadDuration observes how long successful S3 uploads take, including retries and verification
+var UploadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "upload_duration_seconds",
+	Help:    "Time taken to upload and verify a video in S3.",
+	Buckets: prometheus.ExponentialBuckets(0.25, 2, 10), // 0.25s to ~2 minutes
+})
+
+// ProcessQueueDepth is how many videos are waiting for a processing worker
+var ProcessQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "process_queue_depth",
+	Help: "Videos waiting for a processing worker.",
+})
+
+// DiskFreeBytes is the free space on the volume of each watched directory, by directory
+var DiskFreeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "video_disk_free_bytes",
+	Help: "Free disk space on the volume of a watched directory, when MIN_FREE_DISK_MB is set.",
+}, []string{"dir"})
+
+func init() {
+	// Export the known counters from the start rather than on first increment
+	for name := range help {
+		Default.Counter(name)
+	}
+	prometheus.MustRegister(registryCollector{registry: Default}, UploadDuration, ProcessQueueDepth, DiskFreeBytes)
+}
+
+// Handler serves the default Prometheus registry in the text exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// registryCollector exports a Registry's counters to Prometheus. Counters are read
+// at scrape time, so a reset shows up as a counter reset, which Prometheus handles.
+type registryCollector struct {
+	registry *Registry
+}
+
+// Describe sends no descriptors, making this an unchecked collector,
+// since counters can be added to the registry at any time
+func (c registryCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect sends the current value of every counter
+func (c registryCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, value := range c.registry.Snapshot() {
+		desc := prometheus.NewDesc(name, help[name], nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value))
+	}
+}
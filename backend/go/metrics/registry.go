@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Application counter names
+const (
+	VideosDetected      = "videos_detected_total"
+	UploadsSucceeded    = "uploads_succeeded_total"
+	UploadsFailed       = "uploads_failed_total"
+	PublishFailed       = "sns_publish_failed_total"
+	RetryAttempts       = "retry_attempts_total"
+	ProcessPanics       = "process_panics_total"
+	SidecarInvalid      = "sidecar_invalid_total"
+	CircuitOpened       = "circuit_breaker_opened_total"
+	CircuitRejected     = "circuit_breaker_rejected_total"
+	WatchRecoveries     = "watch_recoveries_total"
+	VideosEmpty         = "videos_empty_total"
+	VideosOversized     = "videos_oversized_total"
+	VideosInvalid       = "videos_invalid_total"
+	UploadsDeduplicated = "uploads_deduplicated_total"
+	LocalCleanupFailed  = "local_cleanup_failed_total"
+	OpsAlertsSent       = "ops_alerts_sent_total"
+	VideosDropped       = "videos_dropped_total"
+	DiskSpaceLow        = "disk_space_low_total"
+	ProcessSlow         = "process_slow_total"
+	ProcessTimeouts     = "process_timeouts_total"
+	DiskEvictions       = "disk_space_evictions_total"
+)
+
+// help describes each application counter for /metrics
+var help = map[string]string{
+	VideosDetected:      "Video files detected in the watched directories.",
+	UploadsSucceeded:    "Videos uploaded to S3 and verified.",
+	UploadsFailed:       "Videos whose S3 upload or verification failed.",
+	PublishFailed:       "SNS notifications that could not be built or published.",
+	RetryAttempts:       "Retries of failed AWS operations, e.g. due to throttling.",
+	ProcessPanics:       "Panics recovered while processing a video.",
+	SidecarInvalid:      "Detector sidecar files that were empty or malformed.",
+	CircuitOpened:       "Times a circuit breaker opened after consecutive failures.",
+	CircuitRejected:     "Calls rejected without being attempted because their circuit breaker was open.",
+	WatchRecoveries:     "Watched directories re-watched after being deleted or unmounted.",
+	VideosEmpty:         "Empty video files set aside instead of uploaded.",
+	VideosOversized:     "Video files over MAX_VIDEO_SIZE_MB set aside instead of uploaded.",
+	VideosInvalid:       "Video files without a recognised container signature set aside instead of uploaded.",
+	UploadsDeduplicated: "Videos not uploaded because an identical clip was uploaded within DEDUP_WINDOW.",
+	LocalCleanupFailed:  "Uploaded videos whose local delete (or move to PROCESSED_DIR) failed and is being retried.",
+	OpsAlertsSent:       "Ops alerts published to OPS_ALERT_TOPIC_ARN after failures crossed OPS_ALERT_THRESHOLD.",
+	VideosDropped:       "Videos not processed because the processing queue was full.",
+	ProcessSlow:         "Videos still processing at PROCESS_TIMEOUT_WARN_PERCENT of PROCESS_TIMEOUT.",
+	ProcessTimeouts:     "Videos whose processing was aborted at PROCESS_TIMEOUT.",
+	DiskSpaceLow:        "Disk checks that found less than MIN_FREE_DISK_MB free on a watched volume.",
+	DiskEvictions:       "Failed uploads and processed videos deleted to keep MIN_FREE_DISK_MB free.",
+}
+
+// Default is the registry the application's counters are recorded in
+var Default = NewRegistry()
+
+// Counter is a monotonically increasing count that can be reset to zero
+type Counter struct {
+	value atomic.Int64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n int64) {
+	c.value.Add(n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+// Registry holds named application counters
+type Registry struct {
+	mu       sync.RWMutex
+	counters map[string]*Counter
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*Counter)}
+}
+
+// Counter returns the named counter, creating it on first use
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.RLock()
+	counter, ok := r.counters[name]
+	r.mu.RUnlock()
+	if ok {
+		return counter
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if counter, ok = r.counters[name]; !ok {
+		counter = &Counter{}
+		r.counters[name] = counter
+	}
+	return counter
+}
+
+// Inc adds one to the named counter
+func (r *Registry) Inc(name string) {
+	r.Counter(name).Inc()
+}
+
+// Snapshot returns the current value of every counter
+func (r *Registry) Snapshot() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := make(map[string]int64, len(r.counters))
+	for name, counter := range r.counters {
+		snapshot[name] = counter.Value()
+	}
+	return snapshot
+}
+
+// Reset zeroes every counter and returns the values they held.
+// Increments racing with the reset land either before or after it, never lost.
+func (r *Registry) Reset() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	previous := make(map[string]int64, len(r.counters))
+	for name, counter := range r.counters {
+		previous[name] = counter.value.Swap(0)
+	}
+	return previous
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/health"
+)
+
+// ResetResponse is returned by the reset endpoint
+type ResetResponse struct {
+	// Previous holds the counter values at the moment they were reset
+	Previous map[string]int64 `json:"previous"`
+}
+
+// ResetHandler zeroes the registry's counters on an authenticated POST.
+// Requests must carry "Authorization: Bearer <token>"; an empty token rejects everything.
+func ResetHandler(registry *Registry, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !authorized(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		previous := registry.Reset()
+		slog.Info("Metrics counters reset", "remote_addr", r.RemoteAddr)
+		health.WriteJSON(w, http.StatusOK, ResetResponse{Previous: previous})
+	})
+}
+
+// authorized checks the request's bearer token in constant time
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestResetHandler(t *testing.T) {
+	const token = "s3cret"
+	tests := []struct {
+		name       string
+		method     string
+		token      string // configured
+		auth       string // sent
+		wantStatus int
+	}{
+		{"reset", http.MethodPost, token, "Bearer " + token, http.StatusOK},
+		{"no credentials", http.MethodPost, token, "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, token, "Bearer guess", http.StatusUnauthorized},
+		{"token prefix", http.MethodPost, token, "Bearer s3c", http.StatusUnauthorized},
+		{"other scheme", http.MethodPost, token, "Basic " + token, http.StatusUnauthorized},
+		{"no token configured", http.MethodPost, "", "Bearer ", http.StatusUnauthorized},
+		{"GET", http.MethodGet, token, "Bearer " + token, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Counter(UploadsSucceeded).Add(3)
+			registry.Inc(UploadsFailed)
+
+			req := httptest.NewRequest(tt.method, "/metrics/reset", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			ResetHandler(registry, tt.token).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				// Rejected requests leave the counters alone
+				if got := registry.Counter(UploadsSucceeded).Value(); got != 3 {
+					t.Errorf("%s = %d after a rejected reset, want 3", UploadsSucceeded, got)
+				}
+				return
+			}
+
+			var response ResetResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response %q: %v", rec.Body, err)
+			}
+			if response.Previous[UploadsSucceeded] != 3 || response.Previous[UploadsFailed] != 1 {
+				t.Errorf("previous values = %v", response.Previous)
+			}
+			for name, value := range registry.Snapshot() {
+				if value != 0 {
+					t.Errorf("%s = %d after reset, want 0", name, value)
+				}
+			}
+		})
+	}
+}
+
+func TestResetLosesNoIncrements(t *testing.T) {
+	const writers, perWriter = 8, 5000
+	registry := NewRegistry()
+	counter := registry.Counter(UploadsSucceeded)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				counter.Inc()
+			}
+		}()
+	}
+
+	// Every increment is either returned by some reset or still in the counter
+	var reset int64
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		reset += registry.Reset()[UploadsSucceeded]
+	}
+
+	if total := reset + counter.Value(); total != writers*perWriter {
+		t.Errorf("reset %d + remaining %d = %d increments, want %d", reset, counter.Value(), total, writers*perWriter)
+	}
+}
@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Document is a notification decoded into generic JSON form for transforming
+type Document = map[string]interface{}
+
+// Transform reshapes a notification document
+type Transform func(Document) (Document, error)
+
+// TransformFactory builds a transform from its configured argument
+type TransformFactory func(arg string) (Transform, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]TransformFactory{
+		"rename":  newRename,
+		"project": newProject,
+		"drop":    newDrop,
+		"wrap":    newWrap,
+	}
+)
+
+// RegisterTransform makes a transform available to ParsePipeline under name
+func RegisterTransform(name string, factory TransformFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Pipeline is a chain of transforms applied in order
+type Pipeline []Transform
+
+// ParsePipeline parses a transform chain such as
+// "rename:cloudfront_url=url|project:s3_key,url,timestamp|wrap:video".
+// Steps are separated by "|" and each is name:argument. An empty spec is an empty pipeline.
+func ParsePipeline(spec string) (Pipeline, error) {
+	var pipeline Pipeline
+	for _, step := range strings.Split(spec, "|") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+
+		name, arg, _ := strings.Cut(step, ":")
+		registryMu.RLock()
+		factory, ok := registry[strings.TrimSpace(name)]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q (available: %s)", name, strings.Join(transformNames(), ", "))
+		}
+
+		transform, err := factory(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s transform: %w", name, err)
+		}
+		pipeline = append(pipeline, transform)
+	}
+	return pipeline, nil
+}
+
+// Apply runs the pipeline over v, which must encode to a JSON object.
+// An empty pipeline returns v unchanged.
+func (p Pipeline) Apply(v interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return v, nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notification: %w", err)
+	}
+	var doc Document
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("notification is not a JSON object: %w", err)
+	}
+
+	for _, transform := range p {
+		if doc, err = transform(doc); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// transformNames returns the registered transform names in sorted order
+func transformNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitList splits a comma-separated argument, dropping empty entries
+func splitList(arg string) []string {
+	var items []string
+	for _, item := range strings.Split(arg, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newRename renames fields: "old=new,old2=new2"
+func newRename(arg string) (Transform, error) {
+	renames := make(map[string]string)
+	for _, pair := range splitList(arg) {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("expected old=new, got %q", pair)
+		}
+		renames[from] = to
+	}
+	if len(renames) == 0 {
+		return nil, fmt.Errorf("no fields to rename")
+	}
+
+	return func(doc Document) (Document, error) {
+		renamed := make(Document, len(doc))
+		for key, value := range doc {
+			if to, ok := renames[key]; ok {
+				key = to
+			}
+			renamed[key] = value
+		}
+		return renamed, nil
+	}, nil
+}
+
+// newProject keeps only the listed fields: "a,b,c"
+func newProject(arg string) (Transform, error) {
+	fields := splitList(arg)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to keep")
+	}
+
+	return func(doc Document) (Document, error) {
+		projected := make(Document, len(fields))
+		for _, field := range fields {
+			if value, ok := doc[field]; ok {
+				projected[field] = value
+			}
+		}
+		return projected, nil
+	}, nil
+}
+
+// newDrop removes the listed fields: "a,b,c"
+func newDrop(arg string) (Transform, error) {
+	fields := splitList(arg)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to drop")
+	}
+
+	return func(doc Document) (Document, error) {
+		for _, field := range fields {
+			delete(doc, field)
+		}
+		return doc, nil
+	}, nil
+}
+
+// newWrap nests the whole document under a key: "video" gives {"video": {...}}
+func newWrap(arg string) (Transform, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("no key to wrap under")
+	}
+
+	return func(doc Document) (Document, error) {
+		return Document{arg: doc}, nil
+	}, nil
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testNotification is shaped like the publisher's VideoNotification
+type testNotification struct {
+	S3Key         string `json:"s3_key"`
+	Timestamp     string `json:"timestamp"`
+	CloudFrontURL string `json:"cloudfront_url"`
+	Signed        bool   `json:"signed"`
+}
+
+var notification = testNotification{
+	S3Key:         "videos/clip.mp4",
+	Timestamp:     "2026-03-14T09:26:53Z",
+	CloudFrontURL: "https://cdn.example.com/videos/clip.mp4",
+	Signed:        true,
+}
+
+func TestPipelineComposes(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{"empty", "", `{"s3_key":"videos/clip.mp4","timestamp":"2026-03-14T09:26:53Z","cloudfront_url":"https://cdn.example.com/videos/clip.mp4","signed":true}`},
+		{"rename then project", "rename:cloudfront_url=url|project:url,s3_key",
+			`{"s3_key":"videos/clip.mp4","url":"https://cdn.example.com/videos/clip.mp4"}`},
+		{"project sees only renamed names", "rename:cloudfront_url=url|project:cloudfront_url,s3_key",
+			`{"s3_key":"videos/clip.mp4"}`},
+		{"drop then wrap", "drop:signed,timestamp|wrap:video",
+			`{"video":{"cloudfront_url":"https://cdn.example.com/videos/clip.mp4","s3_key":"videos/clip.mp4"}}`},
+		{"wrap then rename the wrapper", "project:s3_key|wrap:video|rename:video=clip",
+			`{"clip":{"s3_key":"videos/clip.mp4"}}`},
+		{"wrapped twice", "project:s3_key|wrap:inner|wrap:outer",
+			`{"outer":{"inner":{"s3_key":"videos/clip.mp4"}}}`},
+		{"whitespace around steps", " rename : s3_key=key | project : key ",
+			`{"key":"videos/clip.mp4"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := ParsePipeline(tt.spec)
+			if err != nil {
+				t.Fatalf("ParsePipeline(%q): %v", tt.spec, err)
+			}
+			out, err := pipeline.Apply(notification)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			got, err := json.Marshal(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Apply() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePipelineErrors(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{"shout:loud", `unknown transform "shout"`},
+		{"rename:cloudfront_url", "invalid rename transform"},
+		{"rename:=url", "invalid rename transform"},
+		{"rename:", "invalid rename transform"},
+		{"project:", "invalid project transform"},
+		{"project:s3_key|drop: , ", "invalid drop transform"},
+		{"wrap", "invalid wrap transform"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParsePipeline(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParsePipeline(%q) = %v, want an error containing %q", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterTransform(t *testing.T) {
+	RegisterTransform("prefix", func(arg string) (Transform, error) {
+		return func(doc Document) (Document, error) {
+			prefixed := make(Document, len(doc))
+			for key, value := range doc {
+				prefixed[arg+key] = value
+			}
+			return prefixed, nil
+		}, nil
+	})
+
+	pipeline, err := ParsePipeline("project:s3_key|prefix:x_|wrap:data")
+	if err != nil {
+		t.Fatalf("ParsePipeline: %v", err)
+	}
+	out, err := pipeline.Apply(notification)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := fmt.Sprint(out); got != "map[data:map[x_s3_key:videos/clip.mp4]]" {
+		t.Errorf("Apply() = %s", got)
+	}
+}
+
+func TestPipelineRejectsNonObjects(t *testing.T) {
+	pipeline, err := ParsePipeline("wrap:video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pipeline.Apply([]string{"not", "an", "object"}); err == nil {
+		t.Error("Apply accepted a JSON array")
+	}
+}
@@ -0,0 +1,247 @@
+// Package selftest runs the processing pipeline once against a generated test
+// video, to prove a deployment works end to end before real traffic arrives.
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+)
+
+// Step names, in the order they run
+const (
+	StepGenerate = "generate"
+	StepUpload   = "upload"
+	StepSign     = "sign"
+	StepVerify   = "verify"
+	StepPublish  = "publish"
+	StepCleanup  = "cleanup"
+)
+
+// Step statuses
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+const (
+	// Time allowed for fetching the uploaded video back through CloudFront
+	defaultVerifyTimeout = 30 * time.Second
+
+	// Time allowed for deleting the test object and local file
+	cleanupTimeout = 30 * time.Second
+)
+
+// Uploader uploads and deletes videos; satisfied by *aws.S3Uploader
+type Uploader interface {
+	Upload(ctx context.Context, filePath string, metadata map[string]string) (awspackage.UploadResult, error)
+	DeleteObject(ctx context.Context, key, versionID string) error
+}
+
+// Publisher signs and publishes notifications; satisfied by *aws.SNSPublisher
+type Publisher interface {
+	BuildNotification(ctx context.Context, upload awspackage.UploadResult, cloudFrontDomain string, event awspackage.DetectionEvent) (awspackage.VideoNotification, error)
+	PublishNotification(ctx context.Context, notification awspackage.VideoNotification) error
+}
+
+// Runner runs the self-test pipeline
+type Runner struct {
+	Uploader         Uploader
+	Publisher        Publisher
+	CloudFrontDomain string
+
+	// DryRun builds and signs the notification but doesn't publish it
+	DryRun bool
+
+	// HTTPClient fetches the signed URL in the verify step; nil uses a client
+	// with a 30 second timeout
+	HTTPClient *http.Client
+
+	// TempDir holds the generated video; empty uses the system temp directory
+	TempDir string
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the outcome of a self-test run
+type Report struct {
+	Passed bool         `json:"passed"`
+	S3Key  string       `json:"s3_key,omitempty"`
+	Steps  []StepResult `json:"steps"`
+}
+
+// Run generates a test video, uploads it, signs its URL, fetches it back through
+// that URL, publishes its notification (unless DryRun) and cleans up. A failed
+// step skips the steps that depend on it; cleanup always runs, last.
+func (r *Runner) Run(ctx context.Context) (report Report) {
+	// report is named so the deferred cleanup step lands in the returned report
+	report = Report{Passed: true}
+	step := func(name string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		result := StepResult{Name: name, Status: StatusPassed, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			report.Passed = false
+			slog.Error("Self-test step failed", "step", name, "error", err)
+		} else {
+			slog.Info("Self-test step passed", "step", name, "duration_ms", result.DurationMs)
+		}
+		report.Steps = append(report.Steps, result)
+		return err == nil
+	}
+	skip := func(names ...string) {
+		for _, name := range names {
+			report.Steps = append(report.Steps, StepResult{Name: name, Status: StatusSkipped})
+		}
+	}
+
+	var videoPath string
+	var upload awspackage.UploadResult
+	var notification awspackage.VideoNotification
+	uploaded := false
+
+	defer func() {
+		// Clean up even if the run was cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+		step(StepCleanup, func() error {
+			var errs []error
+			if uploaded {
+				errs = append(errs, r.Uploader.DeleteObject(cleanupCtx, upload.Key, upload.VersionID))
+			}
+			if videoPath != "" {
+				if err := os.Remove(videoPath); err != nil && !os.IsNotExist(err) {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		})
+	}()
+
+	if !step(StepGenerate, func() error {
+		var err error
+		videoPath, err = r.generateVideo()
+		return err
+	}) {
+		skip(StepUpload, StepSign, StepVerify, StepPublish)
+		return report
+	}
+
+	if !step(StepUpload, func() error {
+		var err error
+		upload, err = r.Uploader.Upload(ctx, videoPath, map[string]string{"selftest": "true"})
+		if err != nil {
+			return err
+		}
+		uploaded = !upload.Skipped
+		report.S3Key = upload.Key
+		return nil
+	}) {
+		skip(StepSign, StepVerify, StepPublish)
+		return report
+	}
+
+	if !step(StepSign, func() error {
+		var err error
+		event := awspackage.DetectionEvent{Type: awspackage.EventTypeSelfTest}
+		notification, err = r.Publisher.BuildNotification(ctx, upload, r.CloudFrontDomain, event)
+		if err != nil {
+			return err
+		}
+		if !notification.Signed {
+			return fmt.Errorf("URL was not signed; signing fell back to an unsigned URL")
+		}
+		return nil
+	}) {
+		skip(StepVerify, StepPublish)
+		return report
+	}
+
+	step(StepVerify, func() error {
+		return r.verifyDownload(ctx, notification.CloudFrontURL, videoPath)
+	})
+
+	if r.DryRun {
+		skip(StepPublish)
+		return report
+	}
+	step(StepPublish, func() error {
+		return r.Publisher.PublishNotification(ctx, notification)
+	})
+	return report
+}
+
+// generateVideo writes a test video with a unique name, so it gets its own S3 key
+func (r *Runner) generateVideo() (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate a test video name: %w", err)
+	}
+	dir := r.TempDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	name := fmt.Sprintf("eyeseeyou-selftest-%s-%s.mp4", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(nonce))
+	path := filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, testVideo(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write test video: %w", err)
+	}
+	return path, nil
+}
+
+// verifyDownload fetches the video through its signed URL and checks the bytes
+// match the local file
+func (r *Runner) verifyDownload(ctx context.Context, signedURL, videoPath string) error {
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultVerifyTimeout}
+	}
+
+	want, err := os.ReadFile(videoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read test video: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, signedURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid signed URL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signed URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching signed URL returned %s", resp.Status)
+	}
+	got, err := io.ReadAll(io.LimitReader(resp.Body, int64(len(want))+1))
+	if err != nil {
+		return fmt.Errorf("failed to read video from signed URL: %w", err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("video fetched from signed URL doesn't match the upload (%d bytes, want %d)", len(got), len(want))
+	}
+	return nil
+}
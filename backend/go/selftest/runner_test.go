@@ -0,0 +1,250 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/media"
+)
+
+var (
+	errUploadDenied  = errors.New("upload denied")
+	errPublishDenied = errors.New("publish denied")
+	errDeleteDenied  = errors.New("delete denied")
+)
+
+// fakeCDN holds uploaded videos and serves them like CloudFront, rejecting
+// requests without a signature. It also stands in for S3 as the runner's Uploader.
+type fakeCDN struct {
+	uploadErr error
+	deleteErr error
+	skipped   bool   // uploads report the object already existed
+	serve     []byte // if set, served instead of the uploaded bytes
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	deleted []string
+}
+
+func (c *fakeCDN) Upload(ctx context.Context, filePath string, metadata map[string]string) (awspackage.UploadResult, error) {
+	if c.uploadErr != nil {
+		return awspackage.UploadResult{}, c.uploadErr
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return awspackage.UploadResult{}, err
+	}
+	key := "videos/" + filepath.Base(filePath)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.objects == nil {
+		c.objects = make(map[string][]byte)
+	}
+	c.objects[key] = data
+	return awspackage.UploadResult{Key: key, VersionID: "v1", Skipped: c.skipped}, nil
+}
+
+func (c *fakeCDN) DeleteObject(ctx context.Context, key, versionID string) error {
+	if c.deleteErr != nil {
+		return c.deleteErr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.objects, key)
+	c.deleted = append(c.deleted, key+"@"+versionID)
+	return nil
+}
+
+func (c *fakeCDN) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("Signature") == "" {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+	c.mu.Lock()
+	data, ok := c.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	c.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if c.serve != nil {
+		data = c.serve
+	}
+	w.Write(data)
+}
+
+// fakePublisher "signs" URLs by adding a Signature parameter, unless unsigned
+// is set, and records published notifications
+type fakePublisher struct {
+	unsigned   bool
+	publishErr error
+
+	published []awspackage.VideoNotification
+}
+
+func (p *fakePublisher) BuildNotification(ctx context.Context, upload awspackage.UploadResult, cloudFrontDomain string, event awspackage.DetectionEvent) (awspackage.VideoNotification, error) {
+	url := cloudFrontDomain + "/" + upload.Key
+	if !p.unsigned {
+		url += "?Signature=test"
+	}
+	return awspackage.VideoNotification{S3Key: upload.Key, EventType: event.Type, CloudFrontURL: url, Signed: !p.unsigned}, nil
+}
+
+func (p *fakePublisher) PublishNotification(ctx context.Context, notification awspackage.VideoNotification) error {
+	if p.publishErr != nil {
+		return p.publishErr
+	}
+	p.published = append(p.published, notification)
+	return nil
+}
+
+// stepStatuses returns each step's status, keyed by step name
+func stepStatuses(report Report) map[string]string {
+	statuses := make(map[string]string, len(report.Steps))
+	for _, step := range report.Steps {
+		statuses[step.Name] = step.Status
+	}
+	return statuses
+}
+
+func TestRunner(t *testing.T) {
+	allSteps := []string{StepGenerate, StepUpload, StepSign, StepVerify, StepPublish, StepCleanup}
+	tests := []struct {
+		name        string
+		cdn         *fakeCDN
+		publisher   *fakePublisher
+		dryRun      bool
+		want        map[string]string // statuses of steps that didn't pass
+		wantDeleted bool
+	}{
+		{"every step passes", &fakeCDN{}, &fakePublisher{}, false, nil, true},
+		{"dry run", &fakeCDN{}, &fakePublisher{}, true,
+			map[string]string{StepPublish: StatusSkipped}, true},
+		{"upload fails", &fakeCDN{uploadErr: errUploadDenied}, &fakePublisher{}, false,
+			map[string]string{StepUpload: StatusFailed, StepSign: StatusSkipped, StepVerify: StatusSkipped, StepPublish: StatusSkipped}, false},
+		{"object already existed", &fakeCDN{skipped: true}, &fakePublisher{}, false, nil, false},
+		{"URL left unsigned", &fakeCDN{}, &fakePublisher{unsigned: true}, false,
+			map[string]string{StepSign: StatusFailed, StepVerify: StatusSkipped, StepPublish: StatusSkipped}, true},
+		{"download doesn't match", &fakeCDN{serve: []byte("not the test video")}, &fakePublisher{}, false,
+			map[string]string{StepVerify: StatusFailed}, true},
+		{"publish fails", &fakeCDN{}, &fakePublisher{publishErr: errPublishDenied}, false,
+			map[string]string{StepPublish: StatusFailed}, true},
+		{"cleanup fails", &fakeCDN{deleteErr: errDeleteDenied}, &fakePublisher{}, false,
+			map[string]string{StepCleanup: StatusFailed}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.cdn)
+			t.Cleanup(server.Close)
+			runner := &Runner{
+				Uploader:         tt.cdn,
+				Publisher:        tt.publisher,
+				CloudFrontDomain: server.URL,
+				DryRun:           tt.dryRun,
+				TempDir:          t.TempDir(),
+			}
+
+			report := runner.Run(context.Background())
+
+			var names []string
+			for _, step := range report.Steps {
+				names = append(names, step.Name)
+				if (step.Status == StatusFailed) != (step.Error != "") {
+					t.Errorf("step %s is %s with error %q", step.Name, step.Status, step.Error)
+				}
+			}
+			if !slices.Equal(names, allSteps) {
+				t.Errorf("steps = %v, want %v", names, allSteps)
+			}
+			statuses := stepStatuses(report)
+			for _, name := range allSteps {
+				want := StatusPassed
+				if status, ok := tt.want[name]; ok {
+					want = status
+				}
+				if statuses[name] != want {
+					t.Errorf("step %s = %s, want %s", name, statuses[name], want)
+				}
+			}
+			wantPassed := true
+			for _, status := range tt.want {
+				wantPassed = wantPassed && status != StatusFailed
+			}
+			if report.Passed != wantPassed {
+				t.Errorf("Passed = %v, want %v", report.Passed, wantPassed)
+			}
+
+			if deleted := len(tt.cdn.deleted) > 0; deleted != tt.wantDeleted {
+				t.Errorf("test object deleted = %v (%v), want %v", deleted, tt.cdn.deleted, tt.wantDeleted)
+			}
+			if tt.wantDeleted && tt.cdn.deleted[0] != report.S3Key+"@v1" {
+				t.Errorf("deleted %v, want %s at its uploaded version", tt.cdn.deleted, report.S3Key)
+			}
+			wantPublished := statuses[StepPublish] == StatusPassed
+			if published := len(tt.publisher.published) == 1; published != wantPublished {
+				t.Errorf("published = %v, want %v", published, wantPublished)
+			} else if published && tt.publisher.published[0].EventType != awspackage.EventTypeSelfTest {
+				t.Errorf("published event type %q, want %q", tt.publisher.published[0].EventType, awspackage.EventTypeSelfTest)
+			}
+
+			// The generated video is removed whatever happened
+			if entries, _ := os.ReadDir(runner.TempDir); len(entries) != 0 {
+				t.Errorf("left %d files in the temp directory", len(entries))
+			}
+		})
+	}
+}
+
+func TestRunnerCleansUpAfterCancellation(t *testing.T) {
+	cdn := &fakeCDN{}
+	server := httptest.NewServer(cdn)
+	t.Cleanup(server.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	publisher := &cancellingPublisher{cancel: cancel}
+	runner := &Runner{Uploader: cdn, Publisher: publisher, CloudFrontDomain: server.URL, TempDir: t.TempDir()}
+
+	report := runner.Run(ctx)
+	if report.Passed {
+		t.Error("cancelled run passed")
+	}
+	if status := stepStatuses(report)[StepCleanup]; status != StatusPassed {
+		t.Errorf("cleanup = %s, want %s despite the cancellation", status, StatusPassed)
+	}
+	if len(cdn.deleted) != 1 {
+		t.Errorf("test object was not deleted after cancellation")
+	}
+}
+
+// cancellingPublisher cancels the run while signing, as a shutdown signal would
+type cancellingPublisher struct {
+	fakePublisher
+	cancel context.CancelFunc
+}
+
+func (p *cancellingPublisher) BuildNotification(ctx context.Context, upload awspackage.UploadResult, cloudFrontDomain string, event awspackage.DetectionEvent) (awspackage.VideoNotification, error) {
+	p.cancel()
+	return p.fakePublisher.BuildNotification(ctx, upload, cloudFrontDomain, event)
+}
+
+func TestTestVideo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mp4")
+	if err := os.WriteFile(path, testVideo(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := media.ReadMP4Info(path)
+	if err != nil {
+		t.Fatalf("ReadMP4Info: %v", err)
+	}
+	if info.Width != testVideoWidth || info.Height != testVideoHeight || info.DurationSeconds != 1 {
+		t.Errorf("test video info = %+v, want %dx%d and 1 second", info, testVideoWidth, testVideoHeight)
+	}
+}
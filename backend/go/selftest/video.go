@@ -0,0 +1,76 @@
+package selftest
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Dimensions and length advertised by the test video's headers
+const (
+	testVideoWidth     = 16
+	testVideoHeight    = 16
+	testVideoTimescale = 1000
+	testVideoDuration  = 1000 // 1 second in timescale units
+)
+
+// testVideo returns a minimal MP4: an ftyp atom and a moov atom with movie and
+// track headers. It carries no media samples, but it is a well-formed container
+// whose duration and resolution media.ReadMP4Info can read.
+func testVideo() []byte {
+	identity := []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+	var mvhd bytes.Buffer
+	write(&mvhd,
+		uint32(0),                  // version 0, flags
+		uint32(0),                  // creation time
+		uint32(0),                  // modification time
+		uint32(testVideoTimescale), // timescale
+		uint32(testVideoDuration),  // duration
+		uint32(0x00010000),         // rate 1.0
+		uint16(0x0100),             // volume 1.0
+		[10]byte{},                 // reserved
+		identity,                   // matrix
+		[24]byte{},                 // pre-defined
+		uint32(2),                  // next track ID
+	)
+
+	var tkhd bytes.Buffer
+	write(&tkhd,
+		uint32(0x00000003),          // version 0, flags: enabled, in movie
+		uint32(0),                   // creation time
+		uint32(0),                   // modification time
+		uint32(1),                   // track ID
+		uint32(0),                   // reserved
+		uint32(testVideoDuration),   // duration
+		[8]byte{},                   // reserved
+		uint16(0),                   // layer
+		uint16(0),                   // alternate group
+		uint16(0),                   // volume (0 for video)
+		uint16(0),                   // reserved
+		identity,                    // matrix
+		uint32(testVideoWidth<<16),  // width, 16.16 fixed point
+		uint32(testVideoHeight<<16), // height, 16.16 fixed point
+	)
+
+	var ftyp bytes.Buffer
+	write(&ftyp, []byte("isom"), uint32(0x200), []byte("isomiso2mp41"))
+
+	trak := atom("trak", atom("tkhd", tkhd.Bytes()))
+	moov := atom("moov", append(atom("mvhd", mvhd.Bytes()), trak...))
+	return append(atom("ftyp", ftyp.Bytes()), moov...)
+}
+
+// atom wraps a payload in an MP4 atom header
+func atom(atomType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	write(&buf, uint32(8+len(payload)), []byte(atomType), payload)
+	return buf.Bytes()
+}
+
+// write appends big-endian encodings of values to buf
+func write(buf *bytes.Buffer, values ...interface{}) {
+	for _, value := range values {
+		// Writing to a bytes.Buffer can't fail
+		_ = binary.Write(buf, binary.BigEndian, value)
+	}
+}
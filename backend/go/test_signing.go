@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 
 	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
 )
@@ -14,7 +15,10 @@ func main() {
 	ctx := context.Background()
 
 	// Create signer (will fetch from SSM)
-	signer, err := awspackage.NewCloudFrontSigner(ctx, "ap-southeast-2")
+	opts := awspackage.DefaultCloudFrontSignerOptions()
+	opts.KeyPairID = os.Getenv("CLOUDFRONT_KEY_PAIR_ID")
+	opts.KeyPairIDParameter = os.Getenv("CLOUDFRONT_KEY_PAIR_ID_PARAM")
+	signer, err := awspackage.NewCloudFrontSigner(ctx, "ap-southeast-2", opts)
 	if err != nil {
 		log.Fatalf("Failed to create signer: %v", err)
 	}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// ErrCircuitOpen is returned instead of calling an operation whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calls to an operation that keeps failing. After threshold
+// consecutive failures it opens and rejects calls for the cooldown, then lets a
+// single probe through: success closes it again, failure re-opens it.
+// A nil *CircuitBreaker allows every call.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	clock     Clock
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Duration // monotonic time the breaker last opened
+	probing  bool          // a half-open probe is in flight
+}
+
+// NewCircuitBreaker creates a closed circuit breaker for the named operation
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return NewCircuitBreakerWithClock(name, threshold, cooldown, SystemClock())
+}
+
+// NewCircuitBreakerWithClock creates a circuit breaker measuring its cooldown on clock
+func NewCircuitBreakerWithClock(name string, threshold int, cooldown time.Duration, clock Clock) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     clock,
+		state:     circuitClosed,
+	}
+}
+
+// Allow reports whether a call may go ahead, returning ErrCircuitOpen if not.
+// Every allowed call must be followed by Record or Release.
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.clock.Monotonic()-b.openedAt < b.cooldown {
+			metrics.Default.Inc(metrics.CircuitRejected)
+			return ErrCircuitOpen
+		}
+		slog.Info("Circuit breaker cooldown elapsed, probing", "operation", b.name)
+		b.state = circuitHalfOpen
+		b.probing = true
+		return nil
+	case circuitHalfOpen:
+		if b.probing {
+			metrics.Default.Inc(metrics.CircuitRejected)
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record reports the result of an allowed call: nil closes the breaker and
+// resets the failure count, an error counts towards opening it
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		if b.state != circuitClosed {
+			slog.Info("Circuit breaker closed", "operation", b.name)
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state != circuitOpen {
+			metrics.Default.Inc(metrics.CircuitOpened)
+			slog.Warn("Circuit breaker opened, failing fast", "operation", b.name,
+				"consecutive_failures", b.failures, "cooldown", b.cooldown.String(), "error", err)
+		}
+		b.state = circuitOpen
+		b.openedAt = b.clock.Monotonic()
+	}
+}
+
+// Release ends an allowed call without recording a result, e.g. when it was
+// cancelled or failed for a reason unrelated to the operation's health
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// State returns the breaker's state: closed, open or half-open
+func (b *CircuitBreaker) State() string {
+	if b == nil {
+		return circuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// Results an allowed call reports in breakerStep
+const (
+	callSucceeds = "succeeds"
+	callFails    = "fails"
+	callReleased = "released"
+	callInFlight = "in flight"
+)
+
+// breakerStep is one call through a circuit breaker
+type breakerStep struct {
+	wait    time.Duration // clock movement before the call
+	allowed bool          // whether Allow lets the call through
+	result  string        // what an allowed call reports
+	state   string        // the breaker's state afterwards
+}
+
+// failures returns n allowed calls that fail, the last leaving the breaker in state
+func failures(n int, state string) []breakerStep {
+	steps := make([]breakerStep, n)
+	for i := range steps {
+		steps[i] = breakerStep{allowed: true, result: callFails, state: circuitClosed}
+	}
+	steps[n-1].state = state
+	return steps
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = time.Minute
+	tripped := failures(3, circuitOpen)
+
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []breakerStep
+	}{
+		{"stays closed below the threshold", 3, append(failures(2, circuitClosed),
+			breakerStep{allowed: true, result: callSucceeds, state: circuitClosed},
+			// The success reset the count
+			breakerStep{allowed: true, result: callFails, state: circuitClosed},
+			breakerStep{allowed: true, result: callFails, state: circuitClosed},
+		)},
+		{"opens after threshold failures", 3, append(failures(3, circuitOpen),
+			breakerStep{allowed: false, state: circuitOpen},
+		)},
+		{"threshold below one opens on the first failure", 0, failures(1, circuitOpen)},
+		{"fails fast during the cooldown", 3, append(tripped,
+			breakerStep{wait: cooldown / 2, allowed: false, state: circuitOpen},
+			breakerStep{wait: cooldown/2 - time.Second, allowed: false, state: circuitOpen},
+		)},
+		{"lets a single probe through", 3, append(tripped,
+			breakerStep{wait: cooldown, allowed: true, result: callInFlight, state: circuitHalfOpen},
+			breakerStep{allowed: false, state: circuitHalfOpen},
+			breakerStep{wait: cooldown, allowed: false, state: circuitHalfOpen},
+		)},
+		{"probe success closes", 3, append(tripped,
+			breakerStep{wait: cooldown, allowed: true, result: callSucceeds, state: circuitClosed},
+			breakerStep{allowed: true, result: callFails, state: circuitClosed},
+		)},
+		{"probe failure reopens for another cooldown", 3, append(tripped,
+			breakerStep{wait: cooldown, allowed: true, result: callFails, state: circuitOpen},
+			breakerStep{wait: cooldown - time.Second, allowed: false, state: circuitOpen},
+			breakerStep{wait: time.Second, allowed: true, result: callSucceeds, state: circuitClosed},
+		)},
+		{"released probe lets another through", 3, append(tripped,
+			breakerStep{wait: cooldown, allowed: true, result: callReleased, state: circuitHalfOpen},
+			breakerStep{allowed: true, result: callSucceeds, state: circuitClosed},
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			breaker := NewCircuitBreakerWithClock("test", tt.threshold, cooldown, clock)
+			rejected := metrics.Default.Counter(metrics.CircuitRejected).Value()
+			wantRejected := 0
+
+			for i, step := range tt.steps {
+				clock.advance(step.wait)
+				err := breaker.Allow()
+				if allowed := err == nil; allowed != step.allowed {
+					t.Fatalf("step %d: Allow() = %v, want allowed %v", i, err, step.allowed)
+				}
+				if err != nil {
+					if !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: Allow() = %v, want ErrCircuitOpen", i, err)
+					}
+					wantRejected++
+				}
+				switch {
+				case !step.allowed, step.result == callInFlight:
+				case step.result == callSucceeds:
+					breaker.Record(nil)
+				case step.result == callFails:
+					breaker.Record(errors.New("S3 is down"))
+				case step.result == callReleased:
+					breaker.Release()
+				}
+				if got := breaker.State(); got != step.state {
+					t.Fatalf("step %d: state = %s, want %s", i, got, step.state)
+				}
+			}
+
+			if got := int(metrics.Default.Counter(metrics.CircuitRejected).Value() - rejected); got != wantRejected {
+				t.Errorf("%s counted %d rejections, want %d", metrics.CircuitRejected, got, wantRejected)
+			}
+		})
+	}
+}
+
+func TestNilCircuitBreakerAllowsEverything(t *testing.T) {
+	var breaker *CircuitBreaker
+	for i := 0; i < 3; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("Allow() = %v", err)
+		}
+		breaker.Record(errors.New("S3 is down"))
+	}
+	breaker.Release()
+	if got := breaker.State(); got != circuitClosed {
+		t.Errorf("state = %s, want %s", got, circuitClosed)
+	}
+}
+
+func TestRetryStopsAtOpenBreaker(t *testing.T) {
+	errDown := errors.New("S3 is down")
+	errDenied := errors.New("access denied")
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+		wantOpen     bool
+	}{
+		{"retryable failures open it", errDown, 2, true},
+		{"permanent errors don't count", errDenied, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := NewCircuitBreakerWithClock("test", 2, time.Minute, &fakeClock{})
+			config := RetryConfig{
+				MaxRetries:    5,
+				InitialDelay:  time.Millisecond,
+				MaxDelay:      time.Millisecond,
+				OperationName: "test",
+				IsRetryable:   func(err error) bool { return err != errDenied },
+				Breaker:       breaker,
+			}
+
+			attempts := 0
+			err := RetryWithBackoff(context.Background(), config, func() error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) || errors.Is(err, ErrCircuitOpen) != tt.wantOpen {
+				t.Errorf("RetryWithBackoff() = %v, want %v with circuit open %v", err, tt.err, tt.wantOpen)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if got := breaker.State() == circuitOpen; got != tt.wantOpen {
+				t.Errorf("breaker state = %s, want open %v", breaker.State(), tt.wantOpen)
+			}
+		})
+	}
+}
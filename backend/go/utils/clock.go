@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Durations in this codebase (settle delay, timeouts, retry backoff) are measured
+// with time.Sleep, time.After, time.AfterFunc and context deadlines, which all use
+// the monotonic clock and are unaffected by wall-clock jumps. The wall clock is only
+// used for timestamps (notifications, S3 metadata, manifest names), which is where
+// a jump shows up and why it's worth warning about.
+
+// SleepContext waits for d, returning early with ctx's error if ctx is done first
+func SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Clock reads the wall clock and a monotonic clock; replaced in tests
+type Clock interface {
+	// Wall returns the wall-clock time, without a monotonic reading
+	Wall() time.Time
+	// Monotonic returns time elapsed on a monotonic clock since an arbitrary origin
+	Monotonic() time.Duration
+}
+
+// systemClock is the real clock
+type systemClock struct {
+	origin time.Time
+}
+
+// SystemClock returns a Clock backed by the system clocks
+func SystemClock() Clock {
+	return systemClock{origin: time.Now()}
+}
+
+func (c systemClock) Wall() time.Time {
+	return time.Now().Round(0)
+}
+
+func (c systemClock) Monotonic() time.Duration {
+	return time.Since(c.origin)
+}
+
+// DriftDetector detects wall-clock jumps (e.g. NTP corrections, a dead RTC
+// battery) by comparing wall-clock and monotonic elapsed time between checks
+type DriftDetector struct {
+	clock     Clock
+	threshold time.Duration
+	lastWall  time.Time
+	lastMono  time.Duration
+}
+
+// NewDriftDetector creates a detector that reports drift beyond threshold,
+// measured from the time it was created
+func NewDriftDetector(clock Clock, threshold time.Duration) *DriftDetector {
+	return &DriftDetector{
+		clock:     clock,
+		threshold: threshold,
+		lastWall:  clock.Wall(),
+		lastMono:  clock.Monotonic(),
+	}
+}
+
+// Check returns how far the wall clock has jumped relative to the monotonic
+// clock since the previous check, and whether that exceeds the threshold.
+// A positive drift means the wall clock jumped forward.
+func (d *DriftDetector) Check() (time.Duration, bool) {
+	wall := d.clock.Wall()
+	mono := d.clock.Monotonic()
+
+	drift := wall.Sub(d.lastWall) - (mono - d.lastMono)
+	d.lastWall, d.lastMono = wall, mono
+
+	exceeded := drift > d.threshold || drift < -d.threshold
+	return drift, exceeded
+}
+
+// MonitorClockDrift checks for wall-clock jumps every interval until ctx is
+// cancelled, logging a warning whenever one exceeds threshold
+func MonitorClockDrift(ctx context.Context, clock Clock, interval, threshold time.Duration) {
+	detector := NewDriftDetector(clock, threshold)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if drift, exceeded := detector.Check(); exceeded {
+					slog.Warn("Wall clock jumped; check NTP. Timestamps may be wrong",
+						"drift", drift.String(), "interval", interval.String())
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose wall and monotonic readings tests set directly
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (c *fakeClock) Wall() time.Time          { return c.wall }
+func (c *fakeClock) Monotonic() time.Duration { return c.mono }
+
+// advance moves both clocks forward together, as when no jump happens
+func (c *fakeClock) advance(d time.Duration) {
+	c.wall = c.wall.Add(d)
+	c.mono += d
+}
+
+func TestDriftDetector(t *testing.T) {
+	const threshold = 2 * time.Second
+	tests := []struct {
+		name      string
+		elapse    time.Duration // monotonic time passing between checks
+		jump      time.Duration // extra wall-clock movement
+		wantDrift time.Duration
+		wantAlert bool
+	}{
+		{"steady", time.Minute, 0, 0, false},
+		{"small forward skew", time.Minute, time.Second, time.Second, false},
+		{"at threshold", time.Minute, threshold, threshold, false},
+		{"forward jump", time.Minute, time.Hour, time.Hour, true},
+		{"backward jump", time.Minute, -5 * time.Second, -5 * time.Second, true},
+		{"wall clock stands still", time.Minute, -time.Minute, -time.Minute, true},
+		{"jump with no time passing", 0, 3 * time.Second, 3 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{wall: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), mono: time.Hour}
+			detector := NewDriftDetector(clock, threshold)
+
+			clock.advance(tt.elapse)
+			clock.wall = clock.wall.Add(tt.jump)
+			drift, alert := detector.Check()
+			if drift != tt.wantDrift || alert != tt.wantAlert {
+				t.Errorf("Check() = %s, %v; want %s, %v", drift, alert, tt.wantDrift, tt.wantAlert)
+			}
+
+			// Drift is measured from the previous check, so a jump is only reported once
+			clock.advance(tt.elapse)
+			if drift, alert := detector.Check(); drift != 0 || alert {
+				t.Errorf("second Check() = %s, %v; want 0, false", drift, alert)
+			}
+		})
+	}
+}
+
+func TestDriftDetectorMeasuresFromCreation(t *testing.T) {
+	clock := &fakeClock{wall: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	detector := NewDriftDetector(clock, time.Second)
+
+	// Small skews below the threshold don't accumulate between checks
+	for i := 0; i < 5; i++ {
+		clock.advance(time.Minute)
+		clock.wall = clock.wall.Add(900 * time.Millisecond)
+		if drift, alert := detector.Check(); drift != 900*time.Millisecond || alert {
+			t.Errorf("check %d = %s, %v; want 900ms, false", i, drift, alert)
+		}
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	clock := SystemClock()
+	// Wall times carry no monotonic reading, so subtracting them measures the wall clock
+	if wall := clock.Wall(); wall != wall.Round(0) {
+		t.Error("Wall() carries a monotonic clock reading")
+	}
+	before := clock.Monotonic()
+	time.Sleep(time.Millisecond)
+	if after := clock.Monotonic(); after <= before {
+		t.Errorf("Monotonic() went from %s to %s", before, after)
+	}
+}
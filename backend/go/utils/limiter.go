@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter bounds how many operations run at once and paces how often they
+// start, e.g. to keep writes within a table's provisioned capacity
+type Limiter struct {
+	slots    chan struct{} // nil when concurrency is unbounded
+	interval time.Duration // minimum time between starts; zero when unpaced
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next operation
+}
+
+// NewLimiter creates a limiter allowing maxConcurrent operations at once,
+// started at most perSecond times a second. Zero disables either bound.
+func NewLimiter(maxConcurrent, perSecond int) *Limiter {
+	l := &Limiter{}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	if perSecond > 0 {
+		l.interval = time.Second / time.Duration(perSecond)
+	}
+	return l
+}
+
+// Acquire waits for a free slot and then for the operation's turn to start.
+// Release must be called once the operation is done, unless Acquire failed.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if l.interval <= 0 {
+		return nil
+	}
+
+	// Reserve the next start time, so waiting callers start one interval apart
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	if err := SleepContext(ctx, start.Sub(now)); err != nil {
+		l.Release()
+		return err
+	}
+	return nil
+}
+
+// Release frees the slot taken by Acquire
+func (l *Limiter) Release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterBoundsConcurrency(t *testing.T) {
+	limiter := NewLimiter(2, 0)
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Acquire(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			defer limiter.Release()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrency = %d, want 2", got)
+	}
+}
+
+func TestLimiterPacesStarts(t *testing.T) {
+	limiter := NewLimiter(0, 50) // one start every 20ms
+	start := time.Now()
+	var starts []time.Duration
+	for i := 0; i < 5; i++ {
+		if err := limiter.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		starts = append(starts, time.Since(start))
+		limiter.Release()
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i] - starts[i-1]; gap < 15*time.Millisecond {
+			t.Errorf("start %d came %s after the previous one, want about 20ms", i, gap)
+		}
+	}
+}
+
+func TestLimiterAcquireCancelled(t *testing.T) {
+	limiter := NewLimiter(1, 0)
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(ctx); err == nil {
+		t.Fatal("Acquire succeeded with every slot taken")
+	}
+
+	// The failed Acquire must not have taken the slot freed here
+	limiter.Release()
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire after Release = %v", err)
+	}
+}
+
+func TestUnboundedLimiter(t *testing.T) {
+	limiter := NewLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if err := limiter.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
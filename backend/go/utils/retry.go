@@ -2,29 +2,104 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"math"
+	"math/rand"
+	"sync"
 	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/logging"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// Backoff strategies
+const (
+	// StrategyExponential doubles the delay each attempt, with optional full jitter
+	StrategyExponential = "exponential"
+	// StrategyDecorrelated picks each delay at random between the initial delay and
+	// three times the previous delay ("decorrelated jitter"), capped at MaxDelay
+	StrategyDecorrelated = "decorrelated"
+)
+
+var (
+	// jitterRand is shared by all retries; rand.Rand is not safe for concurrent use
+	jitterRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterRandMu sync.Mutex
+
+	// defaultStrategy and defaultMaxElapsed are what DefaultRetryConfig uses
+	defaultStrategy   = StrategyExponential
+	defaultMaxElapsed time.Duration
 )
 
 // RetryConfig holds retry configuration
 type RetryConfig struct {
-	MaxRetries      int
-	InitialDelay    time.Duration
-	MaxDelay        time.Duration
-	OperationName   string
+	MaxRetries    int
+	InitialDelay  time.Duration
+	MaxDelay      time.Duration
+	OperationName string
+
+	// Jitter randomises each delay within [0, delay] so concurrent
+	// retries don't hit the service in lockstep
+	Jitter bool
+
+	// IsRetryable classifies errors; when it returns false the error is
+	// returned immediately. If nil, every error is retried.
+	IsRetryable func(error) bool
+
+	// Strategy selects how delays grow; empty means StrategyExponential.
+	// Jitter only applies to StrategyExponential.
+	Strategy string
+
+	// Rand returns a random int64 in [0, n); nil uses a shared source.
+	// Replaceable so tests can make delays deterministic.
+	Rand func(n int64) int64
+
+	// Breaker, if set, is consulted before every attempt: while it is open the
+	// retry fails fast with ErrCircuitOpen. Retryable failures count towards opening it.
+	Breaker *CircuitBreaker
+
+	// MaxElapsed, if set, bounds the total time spent retrying: no retry is made
+	// whose backoff would end past MaxElapsed from the first attempt, whatever
+	// MaxRetries allows. The last error is returned instead.
+	MaxElapsed time.Duration
+
+	// OnRetry, if set, is called before each backoff in place of the standard
+	// "Operation failed, retrying" log line, with the 1-based number of the attempt
+	// that failed, its error and the delay before the next one. It lets callers send
+	// retries to their own logger, metrics or tests. The retry_attempts_total
+	// counter is incremented either way.
+	OnRetry func(attempt int, err error, nextDelay time.Duration)
+}
+
+// SetDefaultMaxElapsed sets the retry time limit used by DefaultRetryConfig; 0 removes it
+func SetDefaultMaxElapsed(d time.Duration) {
+	defaultMaxElapsed = d
+}
+
+// SetDefaultStrategy sets the backoff strategy used by DefaultRetryConfig
+func SetDefaultStrategy(strategy string) error {
+	switch strategy {
+	case StrategyExponential, StrategyDecorrelated:
+		defaultStrategy = strategy
+		return nil
+	default:
+		return fmt.Errorf("unknown retry strategy %q (expected %s or %s)", strategy, StrategyExponential, StrategyDecorrelated)
+	}
 }
 
 // DefaultRetryConfig returns default retry configuration
 // Max retries: 4 (5 total attempts)
-// Delays: 1s -> 2s -> 4s -> 8s (total ~15 seconds)
+// Delays: up to 1s -> 2s -> 4s -> 8s (total ~15 seconds), with full jitter
 func DefaultRetryConfig(operationName string) RetryConfig {
 	return RetryConfig{
 		MaxRetries:    4,
 		InitialDelay:  1 * time.Second,
 		MaxDelay:      8 * time.Second,
 		OperationName: operationName,
+		Jitter:        true,
+		Strategy:      defaultStrategy,
+		MaxElapsed:    defaultMaxElapsed,
 	}
 }
 
@@ -32,6 +107,8 @@ func DefaultRetryConfig(operationName string) RetryConfig {
 // Returns error if all retries are exhausted
 func RetryWithBackoff(ctx context.Context, config RetryConfig, fn func() error) error {
 	var lastErr error
+	var delay time.Duration
+	start := time.Now()
 
 	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
 		// Check if context is cancelled
@@ -41,41 +118,113 @@ func RetryWithBackoff(ctx context.Context, config RetryConfig, fn func() error)
 		default:
 		}
 
+		if err := config.Breaker.Allow(); err != nil {
+			if lastErr != nil {
+				err = errors.Join(err, lastErr)
+			}
+			return fmt.Errorf("%s rejected: %w", config.OperationName, err)
+		}
+
 		// Execute the function
 		err := fn()
+		config.recordResult(ctx, err)
 		if err == nil {
 			// Success!
 			if attempt > 0 {
-				log.Printf("%s succeeded after %d retries", config.OperationName, attempt)
+				logging.RetrySucceeded(config.OperationName, attempt)
 			}
 			return nil
 		}
 
 		lastErr = err
 
+		// Permanent errors won't succeed on retry
+		if config.IsRetryable != nil && !config.IsRetryable(err) {
+			return fmt.Errorf("%s failed with non-retryable error: %w", config.OperationName, err)
+		}
+
 		// If this was the last attempt, don't sleep
 		if attempt == config.MaxRetries {
 			break
 		}
 
-		// Calculate backoff delay with exponential backoff
-		delay := time.Duration(float64(config.InitialDelay) * math.Pow(2, float64(attempt)))
-		if delay > config.MaxDelay {
-			delay = config.MaxDelay
+		// Calculate backoff delay using the configured strategy
+		delay = config.nextDelay(attempt, delay)
+
+		if config.MaxElapsed > 0 && time.Since(start)+delay > config.MaxElapsed {
+			return fmt.Errorf("%s failed after %d attempts, retry time limit %s reached: %w",
+				config.OperationName, attempt+1, config.MaxElapsed, lastErr)
 		}
 
-		log.Printf("%s failed (attempt %d/%d): %v. Retrying in %v...",
-			config.OperationName, attempt+1, config.MaxRetries+1, err, delay)
+		metrics.Default.Inc(metrics.RetryAttempts)
+		if config.OnRetry != nil {
+			config.OnRetry(attempt+1, err, delay)
+		} else {
+			logging.Retry(config.OperationName, attempt+1, config.MaxRetries+1, err, delay)
+		}
 
 		// Wait before retrying
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("%s cancelled during backoff: %w", config.OperationName, ctx.Err())
-		case <-time.After(delay):
-			// Continue to next retry
+		if err := SleepContext(ctx, delay); err != nil {
+			return fmt.Errorf("%s cancelled during backoff: %w", config.OperationName, err)
 		}
 	}
 
 	return fmt.Errorf("%s failed after %d attempts: %w",
 		config.OperationName, config.MaxRetries+1, lastErr)
 }
+
+// recordResult reports an attempt to the breaker. Only failures that say something
+// about the operation's health count: cancellations and permanent errors don't.
+func (config RetryConfig) recordResult(ctx context.Context, err error) {
+	if err != nil && (ctx.Err() != nil || (config.IsRetryable != nil && !config.IsRetryable(err))) {
+		config.Breaker.Release()
+		return
+	}
+	config.Breaker.Record(err)
+}
+
+// nextDelay returns the backoff before the retry following attempt, given the previous delay
+func (config RetryConfig) nextDelay(attempt int, previous time.Duration) time.Duration {
+	if config.Strategy == StrategyDecorrelated {
+		return DecorrelatedDelay(config.InitialDelay, config.MaxDelay, previous, config.random)
+	}
+
+	// Exponential backoff
+	delay := time.Duration(float64(config.InitialDelay) * math.Pow(2, float64(attempt)))
+	if delay > config.MaxDelay {
+		delay = config.MaxDelay
+	}
+	if config.Jitter {
+		delay = time.Duration(config.random(int64(delay) + 1))
+	}
+	return delay
+}
+
+// random returns a random int64 in [0, n) from the configured source
+func (config RetryConfig) random(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	if config.Rand != nil {
+		return config.Rand(n)
+	}
+
+	jitterRandMu.Lock()
+	defer jitterRandMu.Unlock()
+	return jitterRand.Int63n(n)
+}
+
+// DecorrelatedDelay returns min(maxDelay, random_between(base, previous*3)).
+// A zero previous delay (the first retry) is treated as base.
+func DecorrelatedDelay(base, maxDelay, previous time.Duration, random func(n int64) int64) time.Duration {
+	if previous < base {
+		previous = base
+	}
+
+	upper := previous * 3
+	delay := base + time.Duration(random(int64(upper-base)+1))
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
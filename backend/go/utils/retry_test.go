@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestDecorrelatedDelayBounds(t *testing.T) {
+	const base, maxDelay = 10 * time.Millisecond, time.Second
+	seeded := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name   string
+		random func(n int64) int64
+	}{
+		{"lowest", func(n int64) int64 { return 0 }},
+		{"highest", func(n int64) int64 { return n - 1 }},
+		{"seeded", seeded.Int63n},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var previous time.Duration
+			for i := 0; i < 200; i++ {
+				delay := DecorrelatedDelay(base, maxDelay, previous, tt.random)
+
+				upper := min(max(previous, base)*3, maxDelay)
+				if delay < base || delay > upper {
+					t.Fatalf("iteration %d: delay %s after %s, want within [%s, %s]", i, delay, previous, base, upper)
+				}
+				previous = delay
+			}
+		})
+	}
+}
+
+func TestDecorrelatedDelayExtremes(t *testing.T) {
+	const base, maxDelay = 10 * time.Millisecond, 100 * time.Millisecond
+	highest := func(n int64) int64 { return n - 1 }
+
+	// Always drawing the top of the range triples each delay until the cap holds it
+	var delays []time.Duration
+	var previous time.Duration
+	for i := 0; i < 5; i++ {
+		previous = DecorrelatedDelay(base, maxDelay, previous, highest)
+		delays = append(delays, previous)
+	}
+	want := []time.Duration{30 * time.Millisecond, 90 * time.Millisecond, maxDelay, maxDelay, maxDelay}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("delays = %v, want %v", delays, want)
+		}
+	}
+
+	// Always drawing the bottom stays at the base delay
+	if got := DecorrelatedDelay(base, maxDelay, maxDelay, func(n int64) int64 { return 0 }); got != base {
+		t.Errorf("lowest delay = %s, want %s", got, base)
+	}
+}
+
+func TestRetryWithDecorrelatedBackoff(t *testing.T) {
+	const base, maxDelay = time.Millisecond, 20 * time.Millisecond
+	var delays []time.Duration
+	config := RetryConfig{
+		MaxRetries:    8,
+		InitialDelay:  base,
+		MaxDelay:      maxDelay,
+		OperationName: "test",
+		Strategy:      StrategyDecorrelated,
+		Jitter:        true, // ignored by the decorrelated strategy
+		Rand:          rand.New(rand.NewSource(7)).Int63n,
+		OnRetry: func(attempt int, err error, nextDelay time.Duration) {
+			delays = append(delays, nextDelay)
+		},
+	}
+
+	attempts := 0
+	errFailed := errors.New("still failing")
+	err := RetryWithBackoff(context.Background(), config, func() error {
+		attempts++
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) || attempts != 9 {
+		t.Fatalf("RetryWithBackoff() = %v after %d attempts, want the last error after 9", err, attempts)
+	}
+	if len(delays) != 8 {
+		t.Fatalf("%d retries reported, want 8", len(delays))
+	}
+
+	var previous time.Duration
+	for i, delay := range delays {
+		upper := min(max(previous, base)*3, maxDelay)
+		if delay < base || delay > upper {
+			t.Errorf("retry %d: delay %s after %s, want within [%s, %s]", i+1, delay, previous, base, upper)
+		}
+		previous = delay
+	}
+}
+
+func TestSetDefaultStrategy(t *testing.T) {
+	t.Cleanup(func() { SetDefaultStrategy(StrategyExponential) })
+
+	if err := SetDefaultStrategy(StrategyDecorrelated); err != nil {
+		t.Fatalf("SetDefaultStrategy(%s): %v", StrategyDecorrelated, err)
+	}
+	if got := DefaultRetryConfig("test").Strategy; got != StrategyDecorrelated {
+		t.Errorf("default strategy = %s, want %s", got, StrategyDecorrelated)
+	}
+	if err := SetDefaultStrategy("fibonacci"); err == nil {
+		t.Error("SetDefaultStrategy accepted an unknown strategy")
+	}
+	if got := DefaultRetryConfig("test").Strategy; got != StrategyDecorrelated {
+		t.Errorf("default strategy changed to %s by a rejected strategy", got)
+	}
+}
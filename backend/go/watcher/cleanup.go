@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Shutdown cleanup policies
+const (
+	// CleanupKeep leaves every directory untouched
+	CleanupKeep = "keep"
+	// CleanupIntermediates clears intermediate files (e.g. transcodes) but keeps failed uploads
+	CleanupIntermediates = "clear-intermediates"
+	// CleanupAll also clears the failed-upload directory
+	CleanupAll = "clear-all"
+)
+
+// ShutdownCleanup clears directories according to policy. It must only run once
+// processing has drained, as it deletes files a running upload may still be using.
+// The failed-upload directory is only cleared under CleanupAll.
+func ShutdownCleanup(policy string, intermediateDirs []string, failedDir string) error {
+	var dirs []string
+	switch policy {
+	case CleanupKeep:
+		return nil
+	case CleanupIntermediates:
+		dirs = intermediateDirs
+	case CleanupAll:
+		dirs = append(append(dirs, intermediateDirs...), failedDir)
+	default:
+		return fmt.Errorf("unknown shutdown cleanup policy %q", policy)
+	}
+
+	var errs []error
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		slog.Info("Shutdown cleanup: clearing directory", "dir", dir)
+		if err := clearFiles(dir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to clear %s: %w", dir, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// clearFiles removes the regular files directly inside dir, leaving the
+// directory and any subdirectories in place. A missing directory is not an error.
+func clearFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// listFiles returns the names of the entries in dir, or nil if it doesn't exist
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestShutdownCleanup(t *testing.T) {
+	tests := []struct {
+		policy        string
+		wantTranscode []string
+		wantFailed    []string
+		wantErr       bool
+	}{
+		{CleanupKeep, []string{"clip.mp4", "nested"}, []string{"failed.mp4", "nested"}, false},
+		{CleanupIntermediates, []string{"nested"}, []string{"failed.mp4", "nested"}, false},
+		{CleanupAll, []string{"nested"}, []string{"nested"}, false},
+		{"clear-everything", []string{"clip.mp4", "nested"}, []string{"failed.mp4", "nested"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			root := t.TempDir()
+			transcodeDir := filepath.Join(root, "transcode")
+			failedDir := filepath.Join(root, "failed")
+			for _, path := range []string{
+				filepath.Join(transcodeDir, "clip.mp4"),
+				filepath.Join(transcodeDir, "nested", "kept.mp4"),
+				filepath.Join(failedDir, "failed.mp4"),
+				filepath.Join(failedDir, "nested", "kept.mp4"),
+			} {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			missingDir := filepath.Join(root, "never-created")
+
+			err := ShutdownCleanup(tt.policy, []string{transcodeDir, missingDir, ""}, failedDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ShutdownCleanup() = %v, want error %v", err, tt.wantErr)
+			}
+			if got := listFiles(t, transcodeDir); !slices.Equal(got, tt.wantTranscode) {
+				t.Errorf("transcode directory = %v, want %v", got, tt.wantTranscode)
+			}
+			if got := listFiles(t, failedDir); !slices.Equal(got, tt.wantFailed) {
+				t.Errorf("failed upload directory = %v, want %v", got, tt.wantFailed)
+			}
+			// Subdirectories and their contents are never touched
+			for _, dir := range []string{transcodeDir, failedDir} {
+				if got := listFiles(t, filepath.Join(dir, "nested")); !slices.Equal(got, []string{"kept.mp4"}) {
+					t.Errorf("%s/nested = %v, want it untouched", dir, got)
+				}
+			}
+			if _, err := os.Stat(missingDir); !os.IsNotExist(err) {
+				t.Errorf("cleanup created a missing directory: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer suppresses repeated events for the same path within a window.
+// fsnotify can report one file several times depending on how it is written;
+// entries expire so a file that legitimately reappears later is processed again.
+type Debouncer struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	now    func() time.Time
+}
+
+// NewDebouncer creates a debouncer; a zero window lets every event through
+func NewDebouncer(window time.Duration) *Debouncer {
+	return &Debouncer{
+		window: window,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Allow reports whether an event for path should be handled, recording it if so.
+// It returns false for a path already allowed within the window.
+func (d *Debouncer) Allow(path string) bool {
+	if d.window <= 0 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.expire(now)
+
+	if _, ok := d.seen[path]; ok {
+		return false
+	}
+	d.seen[path] = now
+	return true
+}
+
+// expire drops entries older than the window; callers must hold mu
+func (d *Debouncer) expire(now time.Time) {
+	for path, at := range d.seen {
+		if now.Sub(at) >= d.window {
+			delete(d.seen, path)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// During an upload outage, failed uploads and kept videos pile up on the same
+// small disk the camera records to. Once it fills, the camera can't write new
+// clips and the watcher can't move files, so the device wedges until someone
+// clears it by hand. GuardDiskSpace keeps MIN_FREE_DISK_MB free on the volume of
+// each watched directory by deleting the oldest files from the failed upload and
+// processed directories, sacrificing old clips to keep recording new ones.
+
+// evictableFile is a file that may be deleted to free disk space
+type evictableFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	failed  bool // in the failed upload directory
+}
+
+// GuardDiskSpace checks free disk space now and then every DISK_CHECK_INTERVAL
+// until ctx is cancelled. It does nothing when MIN_FREE_DISK_MB is 0.
+func (fw *FileWatcher) GuardDiskSpace(ctx context.Context) {
+	if fw.cfg.MinFreeDiskMB <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(fw.cfg.DiskCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		fw.checkDiskSpace()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDiskSpace frees space on the volume of any watched directory with less
+// than MIN_FREE_DISK_MB free
+func (fw *FileWatcher) checkDiskSpace() {
+	minFree := uint64(fw.cfg.MinFreeDiskMB) * 1024 * 1024
+	for _, dir := range fw.cfg.VideoDirs {
+		free, err := freeBytes(dir)
+		if err != nil {
+			slog.Warn("Failed to check free disk space", "dir", dir, "error", err)
+			continue
+		}
+		metrics.DiskFreeBytes.WithLabelValues(dir).Set(float64(free))
+		if free >= minFree {
+			continue
+		}
+
+		metrics.Default.Inc(metrics.DiskSpaceLow)
+		slog.Warn("Free disk space is low, evicting old files", "dir", dir, "free_bytes", free, "min_free_bytes", minFree)
+		free = fw.freeDiskSpace(dir, free, minFree)
+		metrics.DiskFreeBytes.WithLabelValues(dir).Set(float64(free))
+		if free < minFree {
+			slog.Warn("Free disk space is still low with nothing left to evict", "dir", dir,
+				"free_bytes", free, "min_free_bytes", minFree)
+		}
+	}
+}
+
+// freeDiskSpace deletes the oldest failed uploads and processed videos on dir's
+// volume until minFree bytes are free or none are left, returning the free space
+func (fw *FileWatcher) freeDiskSpace(dir string, free, minFree uint64) uint64 {
+	for _, file := range fw.evictableFiles(dir) {
+		if free >= minFree {
+			break
+		}
+		if file.failed {
+			// A failed upload being retried is left alone
+			if !fw.s3Uploader.EvictFailedUpload(file.path) {
+				continue
+			}
+		} else if err := os.Remove(file.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to evict processed video", "path", file.path, "error", err)
+			continue
+		}
+		metrics.Default.Inc(metrics.DiskEvictions)
+		slog.Warn("Evicted oldest file to free disk space", "path", file.path, "size_bytes", file.size,
+			"modified", file.modTime.UTC().Format(time.RFC3339))
+
+		// Measure rather than add up sizes, as the camera may be writing too
+		now, err := freeBytes(dir)
+		if err != nil {
+			slog.Warn("Failed to check free disk space", "dir", dir, "error", err)
+			break
+		}
+		free = now
+	}
+	return free
+}
+
+// evictableFiles lists the files in the failed upload directory and, when
+// videos are kept locally, the processed directory that are on the same volume
+// as dir, oldest first. Files elsewhere wouldn't free any space on it.
+func (fw *FileWatcher) evictableFiles(dir string) []evictableFile {
+	var files []evictableFile
+	add := func(evictDir string, failed bool) {
+		if !sameVolume(dir, evictDir) {
+			return
+		}
+		entries, err := os.ReadDir(evictDir)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("Failed to read directory for eviction", "dir", evictDir, "error", err)
+			}
+			return
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				// Removed since the directory was read
+				continue
+			}
+			files = append(files, evictableFile{
+				path:    filepath.Join(evictDir, entry.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+				failed:  failed,
+			})
+		}
+	}
+	add(fw.cfg.FailedUploadDir, true)
+	if fw.keepsLocal() {
+		add(fw.cfg.ProcessedDir, false)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files
+}
+
+// freeBytes returns the space available to unprivileged users on dir's volume
+func freeBytes(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("statfs %s: %w", dir, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// sameVolume reports whether two paths are on the same filesystem
+func sameVolume(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	aStat, aOK := aInfo.Sys().(*syscall.Stat_t)
+	bStat, bOK := bInfo.Sys().(*syscall.Stat_t)
+	return aOK && bOK && aStat.Dev == bStat.Dev
+}
@@ -2,69 +2,257 @@ package watcher
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
 	"github.com/lachiem1/eyeSeeYou/backend/go/config"
+	"github.com/lachiem1/eyeSeeYou/backend/go/health"
+	"github.com/lachiem1/eyeSeeYou/backend/go/media"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
 )
 
-// FileWatcher watches a directory for new video files
+// Panic policies for processing goroutines
+const (
+	// PanicRecover logs the panic, moves the file aside and keeps the service running
+	PanicRecover = "recover"
+	// PanicCrash logs the panic and re-raises it, crashing the process
+	PanicCrash = "crash"
+)
+
+// Options holds optional processing steps for the file watcher
+type Options struct {
+	// Transcoder converts videos to a web-friendly format before upload; nil skips transcoding
+	Transcoder media.Transcoder
+
+	// Remuxer rewraps non-MP4 videos as MP4 without re-encoding when no Transcoder
+	// is set; videos it can't remux (media.ErrIncompatibleCodecs) are uploaded as is
+	Remuxer media.Transcoder
+
+	// PreviewGenerator builds a scrub preview uploaded alongside each video; nil skips previews
+	PreviewGenerator media.PreviewGenerator
+
+	// Thumbnailer extracts a thumbnail uploaded under THUMBNAIL_PREFIX; nil skips thumbnails
+	Thumbnailer media.ThumbnailGenerator
+
+	// Manifest records each uploaded key and its SHA-256 for auditing; nil disables it
+	Manifest *awspackage.ManifestWriter
+
+	// FilenameIndex maps each original filename to its S3 key; nil disables it
+	FilenameIndex *awspackage.FilenameIndex
+
+	// Recorder records each upload in a DynamoDB table; nil disables it
+	Recorder *awspackage.DynamoDBRecorder
+
+	// Routes send notifications for matching videos to their own topic; the first
+	// match wins, and videos matching no route use the watcher's publisher
+	Routes []Route
+
+	// Notifier delivers notifications for videos matching no route, e.g. to a local
+	// file instead of SNS; nil delivers them through the watcher's publisher.
+	// The publisher still builds and signs them.
+	Notifier awspackage.Notifier
+}
+
+// Route selects the publisher for videos from one source
+type Route struct {
+	// Match is a watched directory (an absolute path) or a filename prefix
+	Match     string
+	Publisher *awspackage.SNSPublisher
+}
+
+// FileWatcher watches one or more directories for new video files
 type FileWatcher struct {
-	cfg        *config.Config
-	s3Uploader *awspackage.S3Uploader
+	cfg          *config.Config
+	s3Uploader   *awspackage.S3Uploader
 	snsPublisher *awspackage.SNSPublisher
-	watcher    *fsnotify.Watcher
+	notifier     awspackage.Notifier
+	opts         Options
+	watcher      *fsnotify.Watcher // nil in poll mode
+	recent       *RecentEvents
+	debouncer    *Debouncer
+	subdirs      map[string]bool
+	ready        atomic.Bool
+
+	// In-flight processing, tracked so Close can drain it and so a path
+	// is never processed by two goroutines at once. Queued videos are in
+	// flight but not active.
+	mu               sync.Mutex
+	closing          bool
+	processing       map[string]bool
+	queue            chan string
+	queueSlots       chan struct{}
+	sourceQueues     map[string][]string
+	inFlight         sync.WaitGroup
+	active           atomic.Int64
+	processCtx       context.Context
+	cancelProcessing context.CancelFunc
+
+	// Uploaded videos whose local cleanup failed, or whose queued notification
+	// hasn't been delivered yet, by path, with their S3 keys
+	cleanupMu       sync.Mutex
+	pendingCleanup  map[string]string
+	pendingDelivery map[string]string
+
+	// When each video waiting to settle was last written to
+	writesMu   sync.Mutex
+	lastWrites map[string]time.Time
+}
+
+// Stats summarises recent processing activity
+type Stats struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Recent    []RecentEvent `json:"recent"`
 }
 
 // NewFileWatcher creates a new file watcher
-func NewFileWatcher(cfg *config.Config, s3Uploader *awspackage.S3Uploader, snsPublisher *awspackage.SNSPublisher) (*FileWatcher, error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
+func NewFileWatcher(cfg *config.Config, s3Uploader *awspackage.S3Uploader, snsPublisher *awspackage.SNSPublisher, opts Options) (*FileWatcher, error) {
+	var watcher *fsnotify.Watcher
+	if cfg.WatchMode != WatchModePoll {
+		var err error
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Processing runs on its own context so shutdown can let uploads finish
+	processCtx, cancelProcessing := context.WithCancel(context.Background())
+
+	var notifier awspackage.Notifier = snsPublisher
+	if opts.Notifier != nil {
+		notifier = opts.Notifier
 	}
 
-	return &FileWatcher{
-		cfg:        cfg,
-		s3Uploader: s3Uploader,
-		snsPublisher: snsPublisher,
-		watcher:    watcher,
-	}, nil
+	fw := &FileWatcher{
+		cfg:              cfg,
+		s3Uploader:       s3Uploader,
+		snsPublisher:     snsPublisher,
+		notifier:         notifier,
+		opts:             opts,
+		watcher:          watcher,
+		recent:           NewRecentEvents(cfg.RecentEventsSize),
+		debouncer:        NewDebouncer(cfg.EventDebounceWindow),
+		subdirs:          make(map[string]bool),
+		processing:       make(map[string]bool),
+		queue:            make(chan string, cfg.ProcessQueueSize),
+		queueSlots:       make(chan struct{}, cfg.ProcessQueueSize),
+		sourceQueues:     make(map[string][]string),
+		pendingCleanup:   make(map[string]string),
+		pendingDelivery:  make(map[string]string),
+		lastWrites:       make(map[string]time.Time),
+		processCtx:       processCtx,
+		cancelProcessing: cancelProcessing,
+	}
+	fw.startWorkers()
+	return fw, nil
 }
 
-// Watch starts watching the configured directory for new video files
+// Watch starts watching the configured directories for new video files
 func (fw *FileWatcher) Watch(ctx context.Context) error {
-	// Ensure the video directory exists
-	if err := os.MkdirAll(fw.cfg.VideoDir, 0755); err != nil {
-		return err
+	if fw.cfg.WatchMode == WatchModePoll {
+		return fw.poll(ctx)
 	}
 
-	// Add the directory to the watcher
-	if err := fw.watcher.Add(fw.cfg.VideoDir); err != nil {
-		return err
+	var watched []string
+	dirs := make(map[string]*watchedDir)
+	for _, dir := range fw.cfg.VideoDirs {
+		info, err := fw.rewatchDir(dir)
+		if err != nil {
+			slog.Error("Failed to watch directory", "dir", dir, "error", err)
+			continue
+		}
+		watched = append(watched, dir)
+		dirs[dir] = &watchedDir{info: info}
+		slog.Info("Watching directory", "dir", dir)
+	}
+
+	if len(watched) == 0 {
+		return fmt.Errorf("none of the configured video directories could be watched")
+	}
+
+	// Pick up clips left behind by a previous run. The directories are already
+	// watched, so files arriving during the scan are not missed either.
+	for _, dir := range watched {
+		fw.processExisting(dir)
+	}
+	fw.ready.Store(true)
+	defer fw.ready.Store(false)
+
+	// A nil channel never fires, disabling revalidation
+	var revalidate <-chan time.Time
+	if fw.cfg.WatchRevalidateInterval > 0 {
+		ticker := time.NewTicker(fw.cfg.WatchRevalidateInterval)
+		defer ticker.Stop()
+		revalidate = ticker.C
 	}
+	cleanupTicker := time.NewTicker(pendingCleanupInterval)
+	defer cleanupTicker.Stop()
 
-	log.Printf("Watching directory: %s", fw.cfg.VideoDir)
+	// The previous event, to recognise the Create completing a rename
+	var prev fsnotify.Event
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("File watcher shutting down...")
+			slog.Info("File watcher shutting down")
 			fw.watcher.Close()
 			return nil
 
+		case <-revalidate:
+			if err := fw.revalidateDirs(dirs); err != nil {
+				fw.watcher.Close()
+				return err
+			}
+
+		case <-cleanupTicker.C:
+			fw.retryPendingCleanups()
+
 		case event, ok := <-fw.watcher.Events:
 			if !ok {
 				return nil
 			}
+			// Stop accepting new events once shutdown has begun
+			if ctx.Err() != nil {
+				continue
+			}
+			renamed := renamedIntoPlace(prev, event)
+			prev = event
+			if event.Op&fsnotify.Write == fsnotify.Write {
+				fw.recordWrite(event.Name)
+			}
+			if fw.cfg.Recursive && event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				fw.unwatchSubdirs(event.Name)
+			}
 			if event.Op&fsnotify.Create == fsnotify.Create {
-				if filepath.Ext(event.Name) == ".mp4" {
-					log.Printf("New video detected: %s", event.Name)
-					// Process in goroutine to avoid blocking the watcher
-					go fw.processVideo(ctx, event.Name)
+				if fw.cfg.Recursive && isDir(event.Name) {
+					fw.watchNewDir(event.Name)
+					continue
+				}
+				if fw.isVideoFile(event.Name) {
+					if !fw.debouncer.Allow(event.Name) {
+						slog.Debug("Ignoring duplicate event", "path", event.Name, "op", event.Op.String())
+						continue
+					}
+					slog.Info("New video detected", "filename", filepath.Base(event.Name), "path", event.Name, "renamed", renamed)
+					metrics.Default.Inc(metrics.VideosDetected)
+					if renamed {
+						fw.markSettled(event.Name)
+					} else {
+						fw.trackWrites(event.Name)
+					}
+					fw.startProcessing(event.Name)
 				}
 			}
 
@@ -72,40 +260,640 @@ func (fw *FileWatcher) Watch(ctx context.Context) error {
 			if !ok {
 				return nil
 			}
-			log.Printf("Watcher error: %v", err)
+			slog.Error("Watcher error", "error", err)
 		}
 	}
 }
 
-// processVideo handles uploading a video to S3, publishing to SNS, and cleaning up
-func (fw *FileWatcher) processVideo(ctx context.Context, filePath string) {
-	// Wait a moment to ensure the file is fully written
-	time.Sleep(1 * time.Second)
+// processExisting starts processing video files already present in dir and,
+// when watching recursively, in the directories beneath it
+func (fw *FileWatcher) processExisting(dir string) {
+	var found []string
+	defer func() {
+		if fw.cfg.ProcessOrderPerSource {
+			sortByCaptureTime(found)
+		}
+		for _, path := range found {
+			fw.startProcessing(path)
+		}
+	}()
 
-	log.Printf("Processing video: %s", filePath)
+	visit := func(path string, entry fs.DirEntry) {
+		if !entry.Type().IsRegular() || !fw.isVideoFile(path) || !fw.debouncer.Allow(path) {
+			return
+		}
+		slog.Info("Existing video found", "filename", entry.Name(), "path", path)
+		metrics.Default.Inc(metrics.VideosDetected)
+		found = append(found, path)
+	}
+
+	if fw.cfg.Recursive {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				slog.Error("Failed to scan directory for existing videos", "dir", path, "error", err)
+				if entry != nil && entry.IsDir() && path != dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			visit(path, entry)
+			return nil
+		})
+		if err != nil {
+			slog.Error("Failed to scan directory for existing videos", "dir", dir, "error", err)
+		}
+		return
+	}
 
-	// 1. Upload to S3
-	s3Key, err := fw.s3Uploader.Upload(ctx, filePath)
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Printf("ERROR: Failed to upload %s: %v", filePath, err)
+		slog.Error("Failed to scan directory for existing videos", "dir", dir, "error", err)
 		return
 	}
+	for _, entry := range entries {
+		visit(filepath.Join(dir, entry.Name()), entry)
+	}
+}
+
+// addDir ensures a directory exists and adds it to the watcher
+func (fw *FileWatcher) addDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return fw.watcher.Add(dir)
+}
+
+// isVideoFile reports whether a path has one of the configured video extensions
+func (fw *FileWatcher) isVideoFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, videoExt := range fw.cfg.VideoExtensions {
+		if ext == videoExt {
+			return true
+		}
+	}
+	return false
+}
+
+// startProcessing queues a video for the processing workers. It returns false
+// if the video was dropped because the queue was full.
+func (fw *FileWatcher) startProcessing(filePath string) bool {
+	fw.mu.Lock()
+	if fw.closing {
+		fw.mu.Unlock()
+		slog.Warn("Shutting down, not processing video", "path", filePath)
+		return true
+	}
+	if fw.processing[filePath] {
+		fw.mu.Unlock()
+		slog.Debug("Video is already being processed", "path", filePath)
+		return true
+	}
+	if s3Key, ok := fw.pendingCleanupKey(filePath); ok {
+		fw.mu.Unlock()
+		// Its delete failed after upload; uploading it again would duplicate the clip
+		slog.Info("Video was already uploaded, retrying its cleanup instead", "path", filePath, "s3_key", s3Key)
+		fw.retryCleanup(filePath, s3Key)
+		return true
+	}
+	if s3Key, ok := fw.pendingDeliveryKey(filePath); ok {
+		fw.mu.Unlock()
+		slog.Debug("Video's notification is queued, not processing it again", "path", filePath, "s3_key", s3Key)
+		return true
+	}
+	fw.processing[filePath] = true
+	fw.inFlight.Add(1)
+	fw.mu.Unlock()
+
+	// Wait for room without holding the lock the workers need
+	if !fw.offer() {
+		fw.unclaim(filePath)
+		metrics.Default.Inc(metrics.VideosDropped)
+		slog.Warn("Processing queue is full, dropping video", "path", filePath,
+			"queue_size", fw.cfg.ProcessQueueSize, "policy", fw.cfg.ProcessQueuePolicy)
+		return false
+	}
+
+	fw.mu.Lock()
+	if fw.closing {
+		// Close began while waiting; the queue is closed
+		fw.mu.Unlock()
+		fw.dequeued()
+		fw.unclaim(filePath)
+		slog.Warn("Shutting down, not processing video", "path", filePath)
+		return true
+	}
+	fw.schedule(filePath)
+	fw.mu.Unlock()
+	return true
+}
+
+// unclaim releases a video startProcessing claimed but didn't queue
+func (fw *FileWatcher) unclaim(filePath string) {
+	fw.finishProcessing(filePath)
+	fw.inFlight.Done()
+}
+
+// finishProcessing releases a path so a later event for it can be processed
+func (fw *FileWatcher) finishProcessing(filePath string) {
+	fw.mu.Lock()
+	delete(fw.processing, filePath)
+	fw.mu.Unlock()
+	fw.untrackWrites(filePath)
+}
+
+// recoverPanic contains a panic in a processing goroutine so one bad file can't
+// take down the service: the panic and its stack are logged and counted, and the
+// file is moved to the failed upload directory. Under PanicCrash it is re-raised.
+func (fw *FileWatcher) recoverPanic(filePath string) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	metrics.Default.Inc(metrics.ProcessPanics)
+	slog.Error("Panic while processing video", "path", filePath, "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+
+	if fw.cfg.ProcessPanicPolicy == PanicCrash {
+		panic(recovered)
+	}
+
+	fw.recordEvent(filepath.Base(filePath), OutcomePanicked, fmt.Errorf("panic: %v", recovered))
+	if _, err := os.Stat(filePath); err != nil {
+		// Already removed, or moved to the failed upload directory before the panic
+		return
+	}
+	if err := fw.s3Uploader.MoveToFailedDir(filePath); err != nil {
+		slog.Error("Failed to move video to failed upload directory", "path", filePath, "error", err)
+	}
+}
+
+// processWithTimeout runs processVideo under the per-file processing timeout,
+// warning once the configured percentage of the timeout has elapsed. The timeout
+// is never shorter than the video's own upload timeout, which grows with its
+// size, so a large clip isn't cut off mid-upload.
+func (fw *FileWatcher) processWithTimeout(ctx context.Context, filePath string) ProcessResult {
+	timeout := fw.cfg.ProcessTimeout
+	if timeout <= 0 {
+		return fw.processVideo(ctx, filePath)
+	}
+	if uploadTimeout := fw.s3Uploader.UploadTimeout(filePath); uploadTimeout > timeout {
+		timeout = uploadTimeout
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	warnAfter := timeout * time.Duration(fw.cfg.ProcessTimeoutWarnPercent) / 100
+	warnTimer := time.AfterFunc(warnAfter, func() {
+		metrics.Default.Inc(metrics.ProcessSlow)
+		slog.Warn("Processing is taking a long time",
+			"path", filePath, "elapsed", warnAfter.String(),
+			"timeout_percent", fw.cfg.ProcessTimeoutWarnPercent, "timeout", timeout.String())
+	})
+	defer warnTimer.Stop()
+
+	result := fw.processVideo(processCtx, filePath)
+
+	if errors.Is(processCtx.Err(), context.DeadlineExceeded) {
+		metrics.Default.Inc(metrics.ProcessTimeouts)
+		slog.Error("Processing aborted after exceeding the timeout", "path", filePath, "timeout", timeout.String())
+	}
+	return result
+}
+
+// processVideo handles uploading a video to S3, publishing its notification, and cleaning up
+func (fw *FileWatcher) processVideo(ctx context.Context, filePath string) ProcessResult {
+	start := time.Now()
+	slog.Info("Processing video", "path", filePath)
+
+	if err := fw.checkVideo(filePath); err != nil {
+		if isRejectable(err) {
+			return ProcessResult{Outcome: fw.rejectVideo(filePath, err), Err: err, Duration: time.Since(start)}
+		}
+		slog.Error("Failed to check video", "path", filePath, "error", err)
+		return ProcessResult{Outcome: OutcomeUploadFailed, Err: err, Duration: time.Since(start)}
+	}
+
+	event, err := readSidecar(filePath)
+	if err != nil {
+		metrics.Default.Inc(metrics.SidecarInvalid)
+		if fw.cfg.SidecarStrict {
+			slog.Error("Invalid sidecar, not processing video", "path", filePath, "error", err)
+			fw.moveRejected(filePath)
+			return ProcessResult{Outcome: OutcomeSidecarInvalid, Err: err, Duration: time.Since(start)}
+		}
+		slog.Warn("Invalid sidecar, using the default event type", "path", filePath, "error", err)
+	}
+
+	// 1. Transcode if enabled, then upload to S3
+	uploadPath, cleanup := fw.prepareUpload(ctx, filePath)
+	defer cleanup()
+
+	// Conversion is cut short by cancellation; don't go on to upload against a dead context
+	if err := ctx.Err(); err != nil {
+		slog.Error("Processing cancelled before upload", "path", filePath, "error", err)
+		return ProcessResult{Outcome: OutcomeCancelled, Err: err, Duration: time.Since(start)}
+	}
+
+	upload, err := fw.s3Uploader.UploadConverted(ctx, filePath, uploadPath, VideoMetadata(filePath, fw.cfg.VideoDirs))
+	if err != nil {
+		slog.Error("Failed to upload video", "filename", filepath.Base(filePath), "error", err)
+		return ProcessResult{Outcome: OutcomeUploadFailed, Err: err, Duration: time.Since(start)}
+	}
+
+	sinks := SinkResults{}
+	sinkErrs := fw.recordSinks(ctx, filePath, upload, sinks)
+
+	// 2. Publish notification
+	publisher, notifier := fw.publisherFor(filePath)
+	notification, publishErr := publisher.BuildNotification(ctx, upload, fw.cfg.CloudFrontDomain, event)
+	queued := false
+	if publishErr == nil {
+		notification.CameraID = cameraID(filePath, fw.cfg.VideoDirs)
+		attachMetadata(uploadPath, &notification)
+		fw.attachThumbnail(ctx, publisher, uploadPath, upload.Key, &notification)
+		fw.attachPreview(ctx, publisher, uploadPath, upload.Key, &notification)
+		queued, publishErr = fw.publish(ctx, notifier, notification, filePath, start)
+	}
+	if publishErr != nil {
+		slog.Error("Failed to publish notification", "filename", filepath.Base(filePath), "s3_key", upload.Key, "error", publishErr)
+		// Continue to cleanup even if publishing fails
+	}
+	sinks[SinkSNS] = publishErr == nil
+	sinkErrs = append(sinkErrs, publishErr)
 
-	// 2. Publish SNS notification
-	if err := fw.snsPublisher.Publish(ctx, s3Key, fw.cfg.CloudFrontDomain); err != nil {
-		log.Printf("ERROR: Failed to publish SNS for %s: %v", filePath, err)
-		// Continue to cleanup even if SNS fails
+	result := ProcessResult{
+		S3Key:     upload.Key,
+		Published: publishErr == nil,
+		Outcome:   fw.sinkOutcome(sinks),
+		Sinks:     sinks,
+		Err:       errors.Join(sinkErrs...),
+	}
+	if result.Outcome != OutcomeSucceeded {
+		slog.Warn("Video did not reach its required sinks", "s3_key", upload.Key, "policy", fw.cfg.SinkSuccessPolicy, "failed_sinks", sinks.Failed())
+	}
+
+	// A notification lost to cancellation would never be sent once the local file is
+	// gone, so keep the file for the startup scan of the next run
+	if publishErr != nil && ctx.Err() != nil {
+		slog.Error("Processing cancelled before the notification was published, keeping local file",
+			"path", filePath, "s3_key", upload.Key, "error", ctx.Err())
+		result.Outcome = OutcomeCancelled
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	// 3. Clean up local file, or keep it for the retention window. A queued
+	// notification's delivery does that instead.
+	result.Duration = time.Since(start)
+	if !queued {
+		fw.cleanupLocal(filePath, upload.Key, result.Duration)
+	}
+	return result
+}
+
+// PublishRetriedUpload publishes the notification for a file recovered from the
+// failed upload directory. It is the S3Uploader.RetryFailedUploads handler.
+// The file's original directory is unknown, so only filename prefix routes apply
+// and its DynamoDB record has no camera ID. An error is returned when the sink success policy isn't met.
+func (fw *FileWatcher) PublishRetriedUpload(ctx context.Context, filePath string, upload awspackage.UploadResult) error {
+	sinks := SinkResults{}
+	sinkErrs := fw.recordSinks(ctx, filePath, upload, sinks)
+
+	publisher, notifier := fw.publisherFor(filePath)
+	notification, err := publisher.BuildNotification(ctx, upload, fw.cfg.CloudFrontDomain, awspackage.DefaultDetectionEvent())
+	if err == nil {
+		attachMetadata(filePath, &notification)
+		err = notifier.PublishNotification(ctx, notification)
+	}
+	sinks[SinkSNS] = err == nil
+	sinkErrs = append(sinkErrs, err)
+
+	outcome := fw.sinkOutcome(sinks)
+	sinkErr := errors.Join(sinkErrs...)
+	fw.recordSinkEvent(upload.Key, outcome, sinkErr, sinks)
+	if outcome != OutcomeSucceeded {
+		return sinkErr
+	}
+	return nil
+}
+
+// publisherFor returns the publisher that builds a video's notification and the
+// notifier that delivers it: the publisher of the first route matching its
+// directory (or, when watching recursively, a directory above it) or its
+// filename, or the default publisher and notifier
+func (fw *FileWatcher) publisherFor(filePath string) (*awspackage.SNSPublisher, awspackage.Notifier) {
+	dir, name := filepath.Dir(filePath), filepath.Base(filePath)
+	for _, route := range fw.opts.Routes {
+		if filepath.IsAbs(route.Match) {
+			if withinDir(dir, filepath.Clean(route.Match)) {
+				return route.Publisher, route.Publisher
+			}
+		} else if strings.HasPrefix(name, route.Match) {
+			return route.Publisher, route.Publisher
+		}
+	}
+	return fw.snsPublisher, fw.notifier
+}
+
+// prepareUpload returns the path to upload for a video, transcoding it first when a
+// transcoder is configured, or remuxing non-MP4 videos when a remuxer is. If that
+// fails the original is uploaded instead. The returned cleanup func removes any intermediate file.
+func (fw *FileWatcher) prepareUpload(ctx context.Context, filePath string) (string, func()) {
+	noop := func() {}
+
+	converter, mode := fw.opts.Transcoder, "transcode"
+	if converter == nil && fw.opts.Remuxer != nil && !strings.EqualFold(filepath.Ext(filePath), ".mp4") {
+		converter, mode = fw.opts.Remuxer, "remux"
+	}
+	if converter == nil {
+		return filePath, noop
+	}
+
+	if err := os.MkdirAll(fw.cfg.TranscodeDir, 0755); err != nil {
+		slog.Warn("Failed to create transcode directory, uploading original", "path", filePath, "error", err)
+		return filePath, noop
+	}
+
+	base := filepath.Base(filePath)
+	outputPath := filepath.Join(fw.cfg.TranscodeDir, strings.TrimSuffix(base, filepath.Ext(base))+".mp4")
+	cleanup := func() {
+		if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove transcoded file", "path", outputPath, "error", err)
+		}
+	}
+
+	slog.Info("Converting video", "mode", mode, "path", filePath, "output", outputPath)
+	if err := converter.Transcode(ctx, filePath, outputPath); err != nil {
+		if errors.Is(err, media.ErrIncompatibleCodecs) {
+			slog.Info("Codecs need re-encoding, uploading original", "path", filePath)
+		} else {
+			slog.Warn("Failed to convert video, uploading original", "mode", mode, "path", filePath, "error", err)
+		}
+		cleanup()
+		return filePath, noop
 	}
 
-	// 3. Clean up local file
-	if err := os.Remove(filePath); err != nil {
-		log.Printf("ERROR: Failed to delete local file %s: %v", filePath, err)
+	return outputPath, cleanup
+}
+
+// recordSinks records an upload in each configured sink other than SNS, setting
+// its result in sinks, and returns their errors
+func (fw *FileWatcher) recordSinks(ctx context.Context, filePath string, upload awspackage.UploadResult, sinks SinkResults) []error {
+	var errs []error
+	if fw.opts.Manifest != nil {
+		err := fw.recordManifest(ctx, upload)
+		sinks[SinkManifest] = err == nil
+		errs = append(errs, err)
+	}
+	if fw.opts.FilenameIndex != nil {
+		err := fw.recordFilename(ctx, filePath, upload)
+		sinks[SinkFilenameIndex] = err == nil
+		errs = append(errs, err)
+	}
+	if fw.opts.Recorder != nil {
+		err := fw.recordUpload(ctx, filePath, upload)
+		sinks[SinkDynamoDB] = err == nil
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// recordManifest appends a completed upload to the checksum manifest.
+// Skipped uploads are already in an earlier manifest.
+func (fw *FileWatcher) recordManifest(ctx context.Context, upload awspackage.UploadResult) error {
+	if fw.opts.Manifest == nil || upload.Skipped {
+		return nil
+	}
+
+	entry := awspackage.ManifestEntry{
+		Key:       upload.Key,
+		SHA256:    upload.SHA256,
+		Size:      upload.Size,
+		VersionID: upload.VersionID,
+	}
+	if err := fw.opts.Manifest.Append(ctx, entry); err != nil {
+		slog.Warn("Failed to record upload in manifest", "s3_key", upload.Key, "error", err)
+		return fmt.Errorf("manifest: %w", err)
+	}
+	return nil
+}
+
+// recordFilename indexes the original filename of an upload
+func (fw *FileWatcher) recordFilename(ctx context.Context, filePath string, upload awspackage.UploadResult) error {
+	if fw.opts.FilenameIndex == nil {
+		return nil
+	}
+
+	entry := awspackage.FilenameIndexEntry{
+		Key:       upload.Key,
+		VersionID: upload.VersionID,
+	}
+	if err := fw.opts.FilenameIndex.Record(ctx, filepath.Base(filePath), entry); err != nil {
+		slog.Warn("Failed to record original filename", "filename", filepath.Base(filePath), "s3_key", upload.Key, "error", err)
+		return fmt.Errorf("filename index: %w", err)
+	}
+	return nil
+}
+
+// recordUpload records an upload in the DynamoDB table
+func (fw *FileWatcher) recordUpload(ctx context.Context, filePath string, upload awspackage.UploadResult) error {
+	if fw.opts.Recorder == nil {
+		return nil
+	}
+
+	record := awspackage.DynamoDBRecord{
+		Key:       upload.Key,
+		Filename:  filepath.Base(filePath),
+		SHA256:    upload.SHA256,
+		Size:      upload.Size,
+		VersionID: upload.VersionID,
+	}
+	// A retried upload has left its watched directory, so its camera is unknown
+	for _, dir := range fw.cfg.VideoDirs {
+		if withinDir(filepath.Dir(filePath), filepath.Clean(dir)) {
+			record.CameraID = cameraID(filePath, fw.cfg.VideoDirs)
+			break
+		}
+	}
+	if err := fw.opts.Recorder.Record(ctx, record); err != nil {
+		slog.Warn("Failed to record upload in DynamoDB", "s3_key", upload.Key, "error", err)
+		return fmt.Errorf("dynamodb: %w", err)
+	}
+	return nil
+}
+
+// attachMetadata adds the video's size, duration and resolution to the notification.
+// Duration and resolution are left unset if the file can't be parsed as an MP4.
+func attachMetadata(videoPath string, notification *awspackage.VideoNotification) {
+	if stat, err := os.Stat(videoPath); err == nil {
+		notification.SizeBytes = stat.Size()
 	} else {
-		log.Printf("Successfully processed and deleted: %s", filePath)
+		slog.Warn("Failed to stat video", "path", videoPath, "error", err)
 	}
+
+	info, err := media.ReadMP4Info(videoPath)
+	if err != nil {
+		slog.Warn("Failed to read video metadata", "path", videoPath, "error", err)
+		return
+	}
+	notification.DurationSeconds = info.DurationSeconds
+	notification.Width = info.Width
+	notification.Height = info.Height
 }
 
-// Close closes the file watcher
+// attachPreview generates and uploads a scrub preview for a video next to its
+// S3 key, adding the preview URLs, signed by the video's publisher, to the
+// notification. Failures only log a warning.
+func (fw *FileWatcher) attachPreview(ctx context.Context, publisher *awspackage.SNSPublisher, videoPath, s3Key string, notification *awspackage.VideoNotification) {
+	if fw.opts.PreviewGenerator == nil {
+		return
+	}
+
+	workDir, err := os.MkdirTemp("", "eyeseeyou-preview-")
+	if err != nil {
+		slog.Warn("Failed to create preview directory", "path", videoPath, "error", err)
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	keyBase := strings.TrimSuffix(s3Key, filepath.Ext(s3Key))
+	spriteKey := keyBase + "_sprite.jpg"
+	vttKey := keyBase + ".vtt"
+
+	preview, err := fw.opts.PreviewGenerator.Generate(ctx, videoPath, workDir, filepath.Base(spriteKey))
+	if err != nil {
+		slog.Warn("Failed to generate preview", "path", videoPath, "error", err)
+		return
+	}
+
+	if err := fw.s3Uploader.UploadFile(ctx, preview.SpritePath, spriteKey, "image/jpeg"); err != nil {
+		slog.Warn("Failed to upload preview sprite", "path", videoPath, "s3_key", spriteKey, "error", err)
+		return
+	}
+	if err := fw.s3Uploader.UploadFile(ctx, preview.VTTPath, vttKey, "text/vtt"); err != nil {
+		slog.Warn("Failed to upload preview VTT", "path", videoPath, "s3_key", vttKey, "error", err)
+		return
+	}
+
+	vttURL, _, err := publisher.SignedURL(ctx, fw.cfg.CloudFrontDomain, vttKey, awspackage.AssetThumbnail)
+	if err != nil {
+		slog.Warn("Failed to sign preview VTT URL", "s3_key", vttKey, "error", err)
+		return
+	}
+	spriteURL, _, err := publisher.SignedURL(ctx, fw.cfg.CloudFrontDomain, spriteKey, awspackage.AssetThumbnail)
+	if err != nil {
+		slog.Warn("Failed to sign preview sprite URL", "s3_key", spriteKey, "error", err)
+		return
+	}
+
+	notification.PreviewVTTURL = vttURL
+	notification.PreviewSpriteURL = spriteURL
+}
+
+// recordEvent adds a processing outcome to the recent events buffer
+func (fw *FileWatcher) recordEvent(key, outcome string, err error) {
+	fw.recordSinkEvent(key, outcome, err, nil)
+}
+
+// recordSinkEvent adds a processing outcome with its per-sink results to the
+// recent events buffer
+func (fw *FileWatcher) recordSinkEvent(key, outcome string, err error, sinks SinkResults) {
+	event := RecentEvent{
+		Key:     key,
+		Time:    time.Now().UTC(),
+		Outcome: outcome,
+		Sinks:   sinks,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	fw.recent.Add(event)
+}
+
+// Ready reports whether the watcher is watching its directories
+func (fw *FileWatcher) Ready() bool {
+	return fw.ready.Load()
+}
+
+// Stats returns a summary of the recently processed videos
+func (fw *FileWatcher) Stats() Stats {
+	stats := Stats{Recent: fw.recent.Snapshot()}
+	for _, event := range stats.Recent {
+		if event.Outcome == OutcomeSucceeded {
+			stats.Succeeded++
+		} else {
+			stats.Failed++
+		}
+	}
+	return stats
+}
+
+// RecentHandler serves the recent processing activity as JSON
+func (fw *FileWatcher) RecentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		health.WriteJSON(w, http.StatusOK, fw.Stats())
+	})
+}
+
+// ErrUploadsAbandoned is returned by Close when uploads were still running after
+// being cancelled, so their files may still be in use
+var ErrUploadsAbandoned = errors.New("in-flight uploads did not stop after cancellation")
+
+// cancelGracePeriod bounds how long Close waits for cancelled uploads to return
+var cancelGracePeriod = 10 * time.Second
+
+// Close stops the file watcher and waits for in-flight uploads to finish.
+// Uploads still running after SHUTDOWN_TIMEOUT are cancelled and given a
+// short grace period to return; if any are still running after it, Close
+// returns ErrUploadsAbandoned.
 func (fw *FileWatcher) Close() error {
-	return fw.watcher.Close()
+	fw.mu.Lock()
+	if !fw.closing {
+		// The workers exit once they have processed what is already queued
+		close(fw.queue)
+	}
+	fw.closing = true
+	fw.mu.Unlock()
+
+	var err error
+	if fw.watcher != nil {
+		err = fw.watcher.Close()
+	}
+
+	if running := fw.active.Load(); running > 0 {
+		slog.Info("Waiting for in-flight uploads to finish", "count", running, "timeout", fw.cfg.ShutdownTimeout.String())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		fw.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("All in-flight uploads finished")
+		fw.cancelProcessing()
+		return err
+	case <-time.After(fw.cfg.ShutdownTimeout):
+		slog.Warn("Shutdown timeout expired, cancelling in-flight uploads",
+			"timeout", fw.cfg.ShutdownTimeout.String(), "count", fw.active.Load())
+	}
+
+	// Cancelled uploads still hold their files until they return
+	fw.cancelProcessing()
+	select {
+	case <-done:
+		slog.Info("Cancelled uploads stopped")
+	case <-time.After(cancelGracePeriod):
+		slog.Error("Cancelled uploads did not stop, abandoning them",
+			"grace_period", cancelGracePeriod.String(), "abandoned", fw.active.Load())
+		err = errors.Join(err, ErrUploadsAbandoned)
+	}
+	return err
 }
@@ -0,0 +1,553 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/config"
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+	"github.com/lachiem1/eyeSeeYou/backend/go/media"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+func TestProcessWithTimeoutEscalation(t *testing.T) {
+	const timeout = 400 * time.Millisecond // warning at 50%, 200ms
+	tests := []struct {
+		name      string
+		delay     time.Duration
+		outcome   string
+		warned    int64
+		timedOut  int64
+		minElapse time.Duration
+	}{
+		{"fast", 20 * time.Millisecond, OutcomeSucceeded, 0, 0, 0},
+		{"slow", 300 * time.Millisecond, OutcomeSucceeded, 1, 0, 0},
+		{"stuck", time.Hour, OutcomeCancelled, 1, 1, timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &stubTranscoder{delay: tt.delay}
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				cfg.ProcessTimeout = timeout
+				cfg.ProcessTimeoutWarnPercent = 50
+				s3Options.UploadTimeout = 100 * time.Millisecond
+				opts.Transcoder = transcoder
+			})
+			warned := counterChange(metrics.ProcessSlow)
+			timedOut := counterChange(metrics.ProcessTimeouts)
+
+			start := time.Now()
+			result := env.fw.processWithTimeout(context.Background(), writeVideo(t, env.videoDir, "clip.mp4"))
+			elapsed := time.Since(start)
+
+			if result.Outcome != tt.outcome {
+				t.Errorf("outcome = %s, want %s (err %v)", result.Outcome, tt.outcome, result.Err)
+			}
+			if got := warned(); got != tt.warned {
+				t.Errorf("slow warnings = %d, want %d", got, tt.warned)
+			}
+			if got := timedOut(); got != tt.timedOut {
+				t.Errorf("timeouts = %d, want %d", got, tt.timedOut)
+			}
+			if elapsed < tt.minElapse || elapsed > timeout+time.Second {
+				t.Errorf("took %s, want between %s and the timeout", elapsed, tt.minElapse)
+			}
+		})
+	}
+}
+
+func TestProcessTimeoutAllowsTheUploadTimeout(t *testing.T) {
+	// Processing outlasts PROCESS_TIMEOUT but not the upload timeout, so it isn't cut off
+	transcoder := &stubTranscoder{delay: 300 * time.Millisecond}
+	env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+		cfg.ProcessTimeout = 100 * time.Millisecond
+		s3Options.UploadTimeout = 2 * time.Second
+		opts.Transcoder = transcoder
+	})
+	timedOut := counterChange(metrics.ProcessTimeouts)
+
+	result := env.fw.processWithTimeout(context.Background(), writeVideo(t, env.videoDir, "clip.mp4"))
+	if result.Outcome != OutcomeSucceeded {
+		t.Errorf("outcome = %s, want %s (err %v)", result.Outcome, OutcomeSucceeded, result.Err)
+	}
+	if got := timedOut(); got != 0 {
+		t.Errorf("timeouts = %d, want 0", got)
+	}
+}
+
+// stubPreviewGenerator writes placeholder sprite and VTT files
+type stubPreviewGenerator struct {
+	err error
+}
+
+func (g stubPreviewGenerator) Generate(ctx context.Context, videoPath, outputDir, spriteName string) (media.Preview, error) {
+	if g.err != nil {
+		return media.Preview{}, g.err
+	}
+	preview := media.Preview{
+		SpritePath: filepath.Join(outputDir, spriteName),
+		VTTPath:    filepath.Join(outputDir, "preview.vtt"),
+	}
+	if err := os.WriteFile(preview.SpritePath, []byte("sprite"), 0644); err != nil {
+		return media.Preview{}, err
+	}
+	if err := os.WriteFile(preview.VTTPath, []byte("WEBVTT\n\n00:00.000 --> 00:05.000\n"+spriteName+"#xywh=0,0,160,90\n"), 0644); err != nil {
+		return media.Preview{}, err
+	}
+	return preview, nil
+}
+
+func TestAttachPreview(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator stubPreviewGenerator
+		wantKeys  []string
+	}{
+		{"uploaded", stubPreviewGenerator{}, []string{"videos/front/clip.vtt", "videos/front/clip_sprite.jpg"}},
+		{"generation fails", stubPreviewGenerator{err: errors.New("ffmpeg exploded")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				opts.PreviewGenerator = tt.generator
+			})
+			// Preview URLs are signed by the publisher the video is routed to
+			routed, err := awspackage.NewSNSPublisher(context.Background(), "us-east-1", "arn:aws:sns:us-east-1:123456789012:routed",
+				testSigner{name: "routed"}, awspackage.DefaultSNSPublisherOptions())
+			if err != nil {
+				t.Fatalf("NewSNSPublisher: %v", err)
+			}
+
+			var notification awspackage.VideoNotification
+			env.fw.attachPreview(context.Background(), routed, writeVideo(t, env.videoDir, "clip.mp4"), "videos/front/clip.mp4", &notification)
+
+			if got := env.s3.Keys(testBucket); !slices.Equal(got, tt.wantKeys) {
+				t.Errorf("uploaded keys = %v, want %v", got, tt.wantKeys)
+			}
+			if tt.wantKeys == nil {
+				if notification.PreviewVTTURL != "" || notification.PreviewSpriteURL != "" {
+					t.Errorf("preview URLs set after a failure: %q, %q", notification.PreviewVTTURL, notification.PreviewSpriteURL)
+				}
+				return
+			}
+			for url, key := range map[string]string{notification.PreviewVTTURL: "videos/front/clip.vtt", notification.PreviewSpriteURL: "videos/front/clip_sprite.jpg"} {
+				if want := "https://cdn.example.com/" + key + "?signer=routed&"; !strings.HasPrefix(url, want) {
+					t.Errorf("preview URL = %q, want prefix %q", url, want)
+				}
+			}
+			if sprite := env.s3.Object(testBucket, "videos/front/clip_sprite.jpg"); sprite.Header.Get("Content-Type") != "image/jpeg" {
+				t.Errorf("sprite content type = %q, want image/jpeg", sprite.Header.Get("Content-Type"))
+			}
+			if vtt := env.s3.Object(testBucket, "videos/front/clip.vtt"); !strings.Contains(string(vtt.Body), "clip_sprite.jpg#xywh") {
+				t.Errorf("VTT does not reference the sprite by name: %q", vtt.Body)
+			}
+		})
+	}
+}
+
+// stuckTranscoder blocks until released, optionally ignoring cancellation
+type stuckTranscoder struct {
+	ignoreCancel bool
+	started      chan struct{}
+	release      chan struct{}
+	returned     atomic.Bool
+}
+
+func (s *stuckTranscoder) Transcode(ctx context.Context, inputPath, outputPath string) error {
+	close(s.started)
+	defer s.returned.Store(true)
+	if s.ignoreCancel {
+		<-s.release
+		return errors.New("released")
+	}
+	select {
+	case <-s.release:
+		return errors.New("released")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestCloseWaitsForCancelledUploads(t *testing.T) {
+	defer func(d time.Duration) { cancelGracePeriod = d }(cancelGracePeriod)
+	cancelGracePeriod = 200 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		ignoreCancel bool
+		wantErr      error
+	}{
+		{"stops when cancelled", false, nil},
+		{"ignores cancellation", true, ErrUploadsAbandoned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &stuckTranscoder{ignoreCancel: tt.ignoreCancel, started: make(chan struct{}), release: make(chan struct{})}
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				cfg.ShutdownTimeout = 50 * time.Millisecond
+				opts.Transcoder = transcoder
+			})
+			t.Cleanup(func() { close(transcoder.release) })
+
+			env.fw.startProcessing(writeVideo(t, env.videoDir, "clip.mp4"))
+			select {
+			case <-transcoder.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("video was never processed")
+			}
+
+			err := env.fw.Close()
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Close() = %v, want %v", err, tt.wantErr)
+			}
+			if got := transcoder.returned.Load(); got == tt.ignoreCancel {
+				t.Errorf("processing returned before Close = %v, want %v", got, !tt.ignoreCancel)
+			}
+		})
+	}
+}
+
+func TestPrepareUploadTranscodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		transcoder  *stubTranscoder
+		file        string
+		wantConvert bool
+	}{
+		{"no transcoder", nil, "clip.mp4", false},
+		{"transcoded", &stubTranscoder{output: []byte("h264")}, "clip.mp4", true},
+		{"other container transcoded to mp4", &stubTranscoder{output: []byte("h264")}, "clip.mkv", true},
+		{"transcoder fails", &stubTranscoder{err: errors.New("ffmpeg exploded")}, "clip.mp4", false},
+		{"codecs need re-encoding", &stubTranscoder{err: media.ErrIncompatibleCodecs}, "clip.mp4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				if tt.transcoder != nil {
+					opts.Transcoder = tt.transcoder
+				}
+			})
+			video := writeVideo(t, env.videoDir, tt.file)
+
+			uploadPath, cleanup := env.fw.prepareUpload(context.Background(), video)
+			want := video
+			if tt.wantConvert {
+				want = filepath.Join(env.cfg.TranscodeDir, "clip.mp4")
+			}
+			if uploadPath != want {
+				t.Errorf("upload path = %s, want %s", uploadPath, want)
+			}
+			// A failed conversion leaves nothing behind
+			if entries, _ := os.ReadDir(env.cfg.TranscodeDir); !tt.wantConvert && len(entries) != 0 {
+				t.Errorf("transcode directory holds %d files after a failed conversion", len(entries))
+			}
+
+			cleanup()
+			if _, err := os.Stat(video); err != nil {
+				t.Errorf("original removed by cleanup: %v", err)
+			}
+			if entries, _ := os.ReadDir(env.cfg.TranscodeDir); len(entries) != 0 {
+				t.Errorf("transcode directory holds %d files after cleanup", len(entries))
+			}
+		})
+	}
+}
+
+func TestProcessVideoUploadsTranscodedFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcoder *stubTranscoder
+		wantBody   string
+	}{
+		{"transcoded", &stubTranscoder{output: []byte("h264 video")}, "h264 video"},
+		{"transcoder fails", &stubTranscoder{err: errors.New("ffmpeg exploded")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				opts.Transcoder = tt.transcoder
+			})
+			video := writeVideo(t, env.videoDir, "clip.mp4")
+			original, err := os.ReadFile(video)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result := env.fw.processWithTimeout(context.Background(), video); result.Outcome != OutcomeSucceeded {
+				t.Fatalf("outcome = %s (err %v)", result.Outcome, result.Err)
+			}
+			want := []byte(tt.wantBody)
+			if tt.wantBody == "" {
+				want = original
+			}
+			if got := env.s3.Object(testBucket, "videos/clip.mp4").Body; string(got) != string(want) {
+				t.Errorf("uploaded %d bytes (%.20q), want %d", len(got), got, len(want))
+			}
+			if entries, _ := os.ReadDir(env.cfg.TranscodeDir); len(entries) != 0 {
+				t.Errorf("intermediate file left in the transcode directory: %v", entries)
+			}
+		})
+	}
+}
+
+// failVerification makes the fake S3 report a checksum other than the uploaded
+// video's, as if it had stored other bytes
+func failVerification(server *fakes3.Server) {
+	server.Hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodHead || !strings.HasSuffix(r.URL.Path, ".mp4") {
+			return false
+		}
+		w.Header().Set("X-Amz-Checksum-Sha256", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+		w.WriteHeader(http.StatusOK)
+		return true
+	}
+}
+
+func TestProcessVideoVerificationFailureMovesOriginal(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+		opts.Transcoder = &stubTranscoder{output: []byte("h264 video")}
+	})
+	failVerification(env.s3)
+	video := writeVideo(t, env.videoDir, "clip.mp4")
+	original, err := os.ReadFile(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := env.fw.processWithTimeout(context.Background(), video)
+	if result.Outcome != OutcomeUploadFailed {
+		t.Fatalf("outcome = %s (err %v), want %s", result.Outcome, result.Err, OutcomeUploadFailed)
+	}
+	if _, err := os.Stat(video); !os.IsNotExist(err) {
+		t.Errorf("original left in the watched directory to be uploaded again: %v", err)
+	}
+	// The recorded clip is retried, not the transcode
+	if got, err := os.ReadFile(filepath.Join(env.cfg.FailedUploadDir, "clip.mp4")); err != nil || string(got) != string(original) {
+		t.Errorf("failed upload directory holds %.20q (%v), want the original video", got, err)
+	}
+	if entries, _ := os.ReadDir(env.cfg.FailedUploadDir); len(entries) != 1 {
+		t.Errorf("failed upload directory holds %d files, want 1", len(entries))
+	}
+	if entries, _ := os.ReadDir(env.cfg.TranscodeDir); len(entries) != 0 {
+		t.Errorf("intermediate file left in the transcode directory: %v", entries)
+	}
+}
+
+func TestProcessVideoCircuitOpenMovesOriginal(t *testing.T) {
+	// One failed attempt opens the breaker, so the retry meets it
+	t.Setenv("AWS_MAX_ATTEMPTS", "1")
+	env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+		s3Options.CircuitThreshold = 1
+		s3Options.CircuitCooldown = time.Hour
+		opts.Transcoder = &stubTranscoder{output: []byte("h264 video")}
+	})
+	env.s3.Hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		fakes3.Error(w, http.StatusServiceUnavailable, "SlowDown")
+		return true
+	}
+	video := writeVideo(t, env.videoDir, "clip.mp4")
+	original, err := os.ReadFile(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := env.fw.processWithTimeout(context.Background(), video)
+	if result.Outcome != OutcomeUploadFailed || !errors.Is(result.Err, utils.ErrCircuitOpen) {
+		t.Fatalf("outcome = %s (err %v), want %s with the circuit open", result.Outcome, result.Err, OutcomeUploadFailed)
+	}
+	if _, err := os.Stat(video); !os.IsNotExist(err) {
+		t.Errorf("original left in the watched directory to be uploaded again: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(env.cfg.FailedUploadDir, "clip.mp4")); err != nil || string(got) != string(original) {
+		t.Errorf("failed upload directory holds %.20q (%v), want the original video", got, err)
+	}
+	if entries, _ := os.ReadDir(env.cfg.TranscodeDir); len(entries) != 0 {
+		t.Errorf("intermediate file left in the transcode directory: %v", entries)
+	}
+}
+
+func TestProcessVideoKeepsFileUntilQueuedNotificationIsDelivered(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantKept bool
+	}{
+		{"delivered", nil, false},
+		{"batch failed", errors.New("sns unavailable"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &queueingNotifier{}
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				opts.Notifier = notifier
+			})
+			video := writeVideo(t, env.videoDir, "clip.mp4")
+
+			result := env.fw.processWithTimeout(context.Background(), video)
+			if result.Outcome != OutcomeSucceeded || !result.Published {
+				t.Fatalf("outcome = %s (published %v, err %v), want %s", result.Outcome, result.Published, result.Err, OutcomeSucceeded)
+			}
+			if _, err := os.Stat(video); err != nil {
+				t.Fatalf("video removed before its notification was delivered: %v", err)
+			}
+
+			// A rescan meanwhile mustn't upload and notify it again
+			env.fw.startProcessing(video)
+			env.fw.mu.Lock()
+			reprocessing := env.fw.processing[video]
+			env.fw.mu.Unlock()
+			if reprocessing {
+				t.Error("video awaiting delivery was queued for processing again")
+			}
+
+			if n := notifier.deliver(tt.err); n != 1 {
+				t.Fatalf("%d notifications queued, want 1", n)
+			}
+			if _, err := os.Stat(video); (err == nil) != tt.wantKept {
+				t.Errorf("video kept = %v (%v), want %v", err == nil, err, tt.wantKept)
+			}
+			if _, pending := env.fw.pendingDeliveryKey(video); pending {
+				t.Error("video still recorded as awaiting delivery")
+			}
+		})
+	}
+}
+
+func TestPrepareUploadRemuxes(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		remuxer    *stubTranscoder
+		transcoder *stubTranscoder
+		wantRemux  bool
+		wantPath   string // relative to the transcode directory, or the original when empty
+	}{
+		{"mkv remuxed", "clip.mkv", &stubTranscoder{}, nil, true, "clip.mp4"},
+		{"mov remuxed", "clip.MOV", &stubTranscoder{}, nil, true, "clip.mp4"},
+		{"mp4 passed through", "clip.mp4", &stubTranscoder{}, nil, false, ""},
+		{"MP4 passed through", "clip.MP4", &stubTranscoder{}, nil, false, ""},
+		{"incompatible codecs passed through", "clip.mkv", &stubTranscoder{err: media.ErrIncompatibleCodecs}, nil, true, ""},
+		{"remux failure passed through", "clip.mkv", &stubTranscoder{err: errors.New("ffmpeg exploded")}, nil, true, ""},
+		{"transcoder takes precedence", "clip.mkv", &stubTranscoder{}, &stubTranscoder{}, false, "clip.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				opts.Remuxer = tt.remuxer
+				if tt.transcoder != nil {
+					opts.Transcoder = tt.transcoder
+				}
+			})
+			video := writeVideo(t, env.videoDir, tt.file)
+
+			uploadPath, cleanup := env.fw.prepareUpload(context.Background(), video)
+			defer cleanup()
+
+			want := video
+			if tt.wantPath != "" {
+				want = filepath.Join(env.cfg.TranscodeDir, tt.wantPath)
+			}
+			if uploadPath != want {
+				t.Errorf("upload path = %s, want %s", uploadPath, want)
+			}
+			if remuxed := len(tt.remuxer.calls) > 0; remuxed != tt.wantRemux {
+				t.Errorf("remuxer called = %v, want %v", remuxed, tt.wantRemux)
+			}
+		})
+	}
+}
+
+// panickingTranscoder panics on videos whose names start with "bad", and
+// otherwise copies them
+type panickingTranscoder struct {
+	stubTranscoder
+}
+
+func (p *panickingTranscoder) Transcode(ctx context.Context, inputPath, outputPath string) error {
+	if strings.HasPrefix(filepath.Base(inputPath), "bad") {
+		panic("corrupt sidecar")
+	}
+	return p.stubTranscoder.Transcode(ctx, inputPath, outputPath)
+}
+
+func TestProcessingPanicIsContained(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+		cfg.ProcessConcurrency = 1
+		opts.Transcoder = &panickingTranscoder{}
+	})
+	panics := counterChange(metrics.ProcessPanics)
+
+	bad := writeVideo(t, env.videoDir, "bad.mp4")
+	env.fw.startProcessing(bad)
+	// The worker that recovered keeps serving the queue
+	env.fw.startProcessing(writeVideo(t, env.videoDir, "good.mp4"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(env.fw.Stats().Recent) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("videos were not both processed: %+v", env.fw.Stats().Recent)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	outcomes := make(map[string]string)
+	for _, event := range env.fw.Stats().Recent {
+		outcomes[event.Key] = event.Outcome
+	}
+	if got := outcomes["bad.mp4"]; got != OutcomePanicked {
+		t.Errorf("bad.mp4 outcome = %q, want %q", got, OutcomePanicked)
+	}
+	if got := outcomes["videos/good.mp4"]; got != OutcomeSucceeded {
+		t.Errorf("good.mp4 outcome = %q, want %q (events %v)", got, OutcomeSucceeded, outcomes)
+	}
+	if got := panics(); got != 1 {
+		t.Errorf("%s changed by %d, want 1", metrics.ProcessPanics, got)
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Errorf("panicking video was left in the video directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(env.cfg.FailedUploadDir, "bad.mp4")); err != nil {
+		t.Errorf("panicking video was not moved to the failed upload directory: %v", err)
+	}
+
+	// The file is no longer claimed, so it can be processed again once fixed
+	env.fw.mu.Lock()
+	claimed := env.fw.processing[bad]
+	env.fw.mu.Unlock()
+	if claimed {
+		t.Error("panicking video is still marked as processing")
+	}
+}
+
+func TestProcessingPanicCrashPolicy(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+		cfg.ProcessPanicPolicy = PanicCrash
+	})
+	path := writeVideo(t, env.videoDir, "clip.mp4")
+
+	var reraised any
+	func() {
+		defer func() { reraised = recover() }()
+		defer env.fw.recoverPanic(path)
+		panic("boom")
+	}()
+	if reraised != "boom" {
+		t.Errorf("recovered %v from PanicCrash, want the original panic", reraised)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("video was moved although the process is crashing: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/config"
+	"github.com/lachiem1/eyeSeeYou/backend/go/internal/fakes3"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+const testBucket = "test-bucket"
+
+// testSigner "signs" a URL by appending its expiry, so tests can see which
+// signer and TTL produced it
+type testSigner struct {
+	name string
+}
+
+func (s testSigner) SignURLWithExpiry(rawURL string, d time.Duration) (string, error) {
+	return rawURL + "?signer=" + s.name + "&ttl=" + d.String(), nil
+}
+
+// recordingNotifier collects the notifications published through it
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []awspackage.VideoNotification
+}
+
+func (n *recordingNotifier) PublishNotification(ctx context.Context, notification awspackage.VideoNotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *recordingNotifier) published() []awspackage.VideoNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]awspackage.VideoNotification(nil), n.notifications...)
+}
+
+// queueingNotifier queues every notification until deliver reports its delivery
+type queueingNotifier struct {
+	mu        sync.Mutex
+	delivered []func(error)
+}
+
+func (n *queueingNotifier) PublishNotification(ctx context.Context, notification awspackage.VideoNotification) error {
+	return errors.New("published without queueing")
+}
+
+func (n *queueingNotifier) QueueNotification(ctx context.Context, notification awspackage.VideoNotification, delivered func(error)) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.delivered = append(n.delivered, delivered)
+	return true, nil
+}
+
+// deliver reports err as the delivery result of every queued notification
+func (n *queueingNotifier) deliver(err error) int {
+	n.mu.Lock()
+	queued := n.delivered
+	n.delivered = nil
+	n.mu.Unlock()
+	for _, delivered := range queued {
+		delivered(err)
+	}
+	return len(queued)
+}
+
+// testEnv is a file watcher wired to a fake S3 endpoint and a recording notifier
+type testEnv struct {
+	fw       *FileWatcher
+	cfg      *config.Config
+	s3       *fakes3.Server
+	uploader *awspackage.S3Uploader
+	notifier *recordingNotifier
+	videoDir string
+}
+
+// testConfig returns a configuration with every directory in a fresh temporary directory
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	root := t.TempDir()
+	videoDir := filepath.Join(root, "front-door")
+	if err := os.MkdirAll(videoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{
+		S3Bucket:                  testBucket,
+		CloudFrontDomain:          "cdn.example.com",
+		VideoDir:                  videoDir,
+		VideoDirs:                 []string{videoDir},
+		VideoExtensions:           []string{".mp4"},
+		RejectedDir:               filepath.Join(root, "rejected"),
+		ProcessedDir:              filepath.Join(root, "processed"),
+		FailedUploadDir:           filepath.Join(root, "failed"),
+		TranscodeDir:              filepath.Join(root, "transcode"),
+		RecentEventsSize:          10,
+		ShutdownTimeout:           5 * time.Second,
+		WatchMode:                 WatchModePoll,
+		WatchPollInterval:         time.Second,
+		WatchRecoveryAttempts:     1,
+		ProcessConcurrency:        2,
+		ProcessQueueSize:          10,
+		ProcessQueuePolicy:        QueueDrop,
+		ProcessTimeoutWarnPercent: 80,
+		ProcessPanicPolicy:        PanicRecover,
+		SinkSuccessPolicy:         SinkPolicyAll,
+	}
+}
+
+// newTestEnv creates a file watcher against a fake S3 endpoint. configure, if
+// set, adjusts the configuration and options before anything is created.
+func newTestEnv(t *testing.T, configure func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options)) *testEnv {
+	t.Helper()
+	cfg := testConfig(t)
+	s3Options := awspackage.DefaultS3UploaderOptions()
+	s3Options.FailedUploadDir = cfg.FailedUploadDir
+	notifier := &recordingNotifier{}
+	opts := Options{Notifier: notifier}
+	if configure != nil {
+		configure(cfg, &s3Options, &opts)
+	}
+
+	server := fakes3.New(t)
+	server.SetEnv(t)
+	ctx := context.Background()
+	uploader, err := awspackage.NewS3Uploader(ctx, "us-east-1", testBucket, s3Options)
+	if err != nil {
+		t.Fatalf("NewS3Uploader: %v", err)
+	}
+	publisher, err := awspackage.NewSNSPublisher(ctx, "us-east-1", "arn:aws:sns:us-east-1:123456789012:test", testSigner{name: "default"}, awspackage.DefaultSNSPublisherOptions())
+	if err != nil {
+		t.Fatalf("NewSNSPublisher: %v", err)
+	}
+	fw, err := NewFileWatcher(cfg, uploader, publisher, opts)
+	if err != nil {
+		t.Fatalf("NewFileWatcher: %v", err)
+	}
+	t.Cleanup(func() { fw.Close() })
+
+	return &testEnv{fw: fw, cfg: cfg, s3: server, uploader: uploader, notifier: notifier, videoDir: cfg.VideoDirs[0]}
+}
+
+// writeVideo writes a small MP4-signed video into dir and returns its path
+func writeVideo(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	// An ftyp box, so signature checks accept it
+	data := append([]byte{0, 0, 0, 16, 'f', 't', 'y', 'p', 'i', 's', 'o', 'm', 0, 0, 2, 0}, make([]byte, 512)...)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// counterChange returns a func reporting how much a counter has changed since the call
+func counterChange(name string) func() int64 {
+	start := metrics.Default.Counter(name).Value()
+	return func() int64 {
+		return metrics.Default.Counter(name).Value() - start
+	}
+}
+
+// stubTranscoder "converts" a video by copying it after a delay, or writing
+// output when set, or fails with err
+type stubTranscoder struct {
+	delay  time.Duration
+	err    error
+	output []byte
+
+	mu    sync.Mutex
+	calls []string // output paths
+}
+
+func (s *stubTranscoder) Transcode(ctx context.Context, inputPath, outputPath string) error {
+	s.mu.Lock()
+	s.calls = append(s.calls, outputPath)
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if s.output != nil {
+		return os.WriteFile(outputPath, s.output, 0644)
+	}
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+)
+
+// S3 user metadata keys set on uploaded videos
+const (
+	metadataCaptureTime      = awspackage.MetadataCaptureTime
+	metadataCameraID         = awspackage.MetadataCameraID
+	metadataOriginalFilename = "original-filename"
+)
+
+// The detector names clips person_detected_DD-MM-YYYY_HH-MM-SS.mp4
+var captureTimePattern = regexp.MustCompile(`\d{2}-\d{2}-\d{4}_\d{2}-\d{2}-\d{2}`)
+
+const captureTimeLayout = "02-01-2006_15-04-05"
+
+// VideoMetadata builds the S3 user metadata for a video. The camera ID is the
+// name of the watched directory the clip was written to, so each VIDEO_DIRS entry is a camera.
+func VideoMetadata(filePath string, watchedDirs []string) map[string]string {
+	return map[string]string{
+		metadataCaptureTime:      captureTime(filePath).UTC().Format(time.RFC3339),
+		metadataCameraID:         cameraID(filePath, watchedDirs),
+		metadataOriginalFilename: filepath.Base(filePath),
+	}
+}
+
+// cameraID names the camera a clip came from: the watched directory it was
+// written to, even if it is in a subdirectory (e.g. per date) of that directory
+func cameraID(filePath string, watchedDirs []string) string {
+	return filepath.Base(sourceRoot(filePath, watchedDirs))
+}
+
+// sourceRoot returns the watched directory a clip was written to, or beneath;
+// its own directory if it is in none of them
+func sourceRoot(filePath string, watchedDirs []string) string {
+	dir := filepath.Dir(filePath)
+	root := ""
+	for _, watched := range watchedDirs {
+		watched = filepath.Clean(watched)
+		if withinDir(dir, watched) && len(watched) > len(root) {
+			root = watched
+		}
+	}
+	if root == "" {
+		root = dir
+	}
+	return root
+}
+
+// captureTime returns when a clip was recorded, from its filename if it follows
+// the detector's naming scheme, otherwise from its modification time
+func captureTime(filePath string) time.Time {
+	if match := captureTimePattern.FindString(filepath.Base(filePath)); match != "" {
+		if t, err := time.ParseInLocation(captureTimeLayout, match, time.Local); err == nil {
+			return t
+		}
+	}
+
+	if stat, err := os.Stat(filePath); err == nil {
+		return stat.ModTime()
+	}
+	return time.Now()
+}
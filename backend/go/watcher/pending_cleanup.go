@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// How often uploaded videos whose local cleanup failed are retried
+const pendingCleanupInterval = 30 * time.Second
+
+// fsnotify won't report a file again just because deleting it failed, so an
+// uploaded video whose delete (or move to PROCESSED_DIR) failed would linger until
+// the next restart, and a rescan (e.g. after a watch is recovered) would upload and
+// announce it again. Such videos are recorded in fw.pendingCleanup, keyed by path
+// with the S3 key they were uploaded as: their cleanup is retried every
+// pendingCleanupInterval, and they are never processed again meanwhile. The record
+// is in memory only; across restarts DEDUP_INDEX_FILE prevents a second upload.
+
+// cleanupLocal deletes an uploaded video and its sidecar, or moves them to the
+// processed directory when local files are kept. On failure the video is recorded
+// for retries.
+func (fw *FileWatcher) cleanupLocal(filePath, s3Key string, duration time.Duration) {
+	if err := fw.finishLocal(filePath); err != nil {
+		metrics.Default.Inc(metrics.LocalCleanupFailed)
+		slog.Error("Failed to clean up uploaded video, will retry", "path", filePath, "s3_key", s3Key, "error", err)
+		fw.cleanupMu.Lock()
+		fw.pendingCleanup[filePath] = s3Key
+		fw.cleanupMu.Unlock()
+		return
+	}
+
+	if fw.keepsLocal() {
+		slog.Info("Processed and kept video", "path", filePath, "dir", fw.cfg.ProcessedDir, "s3_key", s3Key, "duration_ms", duration.Milliseconds())
+	} else {
+		slog.Info("Processed and deleted video", "path", filePath, "s3_key", s3Key, "duration_ms", duration.Milliseconds())
+	}
+}
+
+// finishLocal deletes or keeps an uploaded video, returning the error that left it
+// in place. A failure to remove the sidecar is only logged.
+func (fw *FileWatcher) finishLocal(filePath string) error {
+	if fw.keepsLocal() {
+		_, err := fw.keepProcessed(filePath)
+		return err
+	}
+
+	if err := removeSidecar(filePath); err != nil {
+		slog.Warn("Failed to delete sidecar", "path", sidecarPath(filePath), "error", err)
+	}
+	return os.Remove(filePath)
+}
+
+// pendingCleanupKey returns the S3 key of a video awaiting cleanup, if filePath is one
+func (fw *FileWatcher) pendingCleanupKey(filePath string) (string, bool) {
+	fw.cleanupMu.Lock()
+	defer fw.cleanupMu.Unlock()
+	key, ok := fw.pendingCleanup[filePath]
+	return key, ok
+}
+
+// retryPendingCleanups retries the cleanup of every video awaiting it
+func (fw *FileWatcher) retryPendingCleanups() {
+	fw.cleanupMu.Lock()
+	pending := make(map[string]string, len(fw.pendingCleanup))
+	for path, key := range fw.pendingCleanup {
+		pending[path] = key
+	}
+	fw.cleanupMu.Unlock()
+
+	for path, key := range pending {
+		fw.retryCleanup(path, key)
+	}
+}
+
+// retryCleanup retries the cleanup of one video, forgetting it once it is gone
+func (fw *FileWatcher) retryCleanup(filePath, s3Key string) {
+	err := fw.finishLocal(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Retried cleanup of uploaded video failed", "path", filePath, "s3_key", s3Key, "error", err)
+		return
+	}
+
+	fw.cleanupMu.Lock()
+	delete(fw.pendingCleanup, filePath)
+	fw.cleanupMu.Unlock()
+	slog.Info("Cleaned up uploaded video on retry", "path", filePath, "s3_key", s3Key)
+}
@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+)
+
+// With batching a queued notification is only sent when its batch is flushed,
+// up to BATCH_WINDOW later, and deleting the video before then would leave
+// nothing to retry from if the batch fails. Such videos are recorded in
+// fw.pendingDelivery, keyed by path with their S3 keys, and left in place until
+// the batch reports back: they are cleaned up once it is delivered, or kept for
+// the startup scan of the next run if it fails. They are never processed again
+// meanwhile.
+
+// publish delivers a video's notification, queueing it if the notifier batches.
+// It reports whether the notification was queued, in which case the video is
+// cleaned up on delivery rather than by the caller.
+func (fw *FileWatcher) publish(ctx context.Context, notifier awspackage.Notifier, notification awspackage.VideoNotification, filePath string, start time.Time) (bool, error) {
+	queuer, ok := notifier.(awspackage.QueueingNotifier)
+	if !ok {
+		return false, notifier.PublishNotification(ctx, notification)
+	}
+
+	// Recorded first, as a batch completed by this notification is delivered before QueueNotification returns
+	fw.cleanupMu.Lock()
+	fw.pendingDelivery[filePath] = notification.S3Key
+	fw.cleanupMu.Unlock()
+
+	queued, err := queuer.QueueNotification(ctx, notification, func(err error) {
+		fw.delivered(filePath, notification.S3Key, start, err)
+	})
+	if queued {
+		return true, nil
+	}
+	fw.forgetDelivery(filePath)
+	if err != nil {
+		return false, err
+	}
+	return false, notifier.PublishNotification(ctx, notification)
+}
+
+// delivered cleans up a video once its queued notification has been sent, or
+// keeps it for the next startup scan if the notification was lost with its batch
+func (fw *FileWatcher) delivered(filePath, s3Key string, start time.Time, err error) {
+	if err != nil {
+		slog.Error("Queued notification was not delivered, keeping local file", "path", filePath, "s3_key", s3Key, "error", err)
+	} else {
+		fw.cleanupLocal(filePath, s3Key, time.Since(start))
+	}
+	fw.forgetDelivery(filePath)
+}
+
+// forgetDelivery removes a video from those awaiting delivery of their notification
+func (fw *FileWatcher) forgetDelivery(filePath string) {
+	fw.cleanupMu.Lock()
+	delete(fw.pendingDelivery, filePath)
+	fw.cleanupMu.Unlock()
+}
+
+// pendingDeliveryKey returns the S3 key of a video awaiting delivery of its
+// notification, if filePath is one
+func (fw *FileWatcher) pendingDeliveryKey(filePath string) (string, bool) {
+	fw.cleanupMu.Lock()
+	defer fw.cleanupMu.Unlock()
+	key, ok := fw.pendingDelivery[filePath]
+	return key, ok
+}
@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// Watch modes
+const (
+	// WatchModeFsnotify notices new videos from filesystem events
+	WatchModeFsnotify = "fsnotify"
+	// WatchModePoll rescans the watched directories on an interval, for
+	// filesystems that deliver no events (some network mounts and SD cards)
+	WatchModePoll = "poll"
+)
+
+// polledFile is what a scan saw of a video
+type polledFile struct {
+	size    int64
+	modTime time.Time
+	// started is set once the video has been queued for processing, so it isn't
+	// again unless it changes
+	started bool
+}
+
+// poll watches the configured directories by rescanning them every
+// WatchPollInterval. A video is processed once its size and modification time
+// are unchanged between two scans; clips already present count as new, so the
+// first scans also pick up those left behind by a previous run.
+func (fw *FileWatcher) poll(ctx context.Context) error {
+	var dirs []string
+	for _, dir := range fw.cfg.VideoDirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			slog.Error("Failed to watch directory", "dir", dir, "error", err)
+			continue
+		}
+		dirs = append(dirs, dir)
+		slog.Info("Polling directory", "dir", dir, "interval", fw.cfg.WatchPollInterval.String())
+	}
+
+	if len(dirs) == 0 {
+		return fmt.Errorf("none of the configured video directories could be watched")
+	}
+
+	seen := make(map[string]*polledFile)
+	fw.ready.Store(fw.pollDirs(dirs, seen))
+	defer fw.ready.Store(false)
+
+	ticker := time.NewTicker(fw.cfg.WatchPollInterval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(pendingCleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("File watcher shutting down")
+			return nil
+
+		case <-cleanupTicker.C:
+			fw.retryPendingCleanups()
+
+		case <-ticker.C:
+			// Not ready while any directory can't be scanned
+			fw.ready.Store(fw.pollDirs(dirs, seen))
+		}
+	}
+}
+
+// pollDirs scans dirs, updates seen and starts processing the videos that have
+// stopped changing. It reports whether every directory could be scanned.
+func (fw *FileWatcher) pollDirs(dirs []string, seen map[string]*polledFile) bool {
+	current := make(map[string]fs.FileInfo)
+	healthy := true
+	for _, dir := range dirs {
+		if err := fw.scanVideos(dir, current); err != nil {
+			slog.Error("Failed to poll directory", "dir", dir, "error", err)
+			healthy = false
+		}
+	}
+
+	var stable []string
+	for path, info := range current {
+		prev, ok := seen[path]
+		if !ok || prev.size != info.Size() || !prev.modTime.Equal(info.ModTime()) {
+			seen[path] = &polledFile{size: info.Size(), modTime: info.ModTime()}
+			continue
+		}
+		if !prev.started {
+			stable = append(stable, path)
+		}
+	}
+	// Forget videos that are gone, so one written again at the same path is new.
+	// A directory that couldn't be scanned keeps its videos' state.
+	for path := range seen {
+		if _, ok := current[path]; !ok && healthy {
+			delete(seen, path)
+		}
+	}
+
+	if fw.cfg.ProcessOrderPerSource {
+		sortByCaptureTime(stable)
+	}
+	for _, path := range stable {
+		slog.Info("New video detected", "filename", filepath.Base(path), "path", path)
+		metrics.Default.Inc(metrics.VideosDetected)
+		// A video dropped for a full queue is offered again on the next poll
+		seen[path].started = fw.startProcessing(path)
+	}
+	return healthy
+}
+
+// scanVideos adds the video files in dir and, when watching recursively, the
+// directories beneath it to found
+func (fw *FileWatcher) scanVideos(dir string, found map[string]fs.FileInfo) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			slog.Warn("Failed to poll directory", "dir", path, "error", err)
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if path != dir && !fw.cfg.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !fw.isVideoFile(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			return nil
+		}
+		found[path] = info
+		return nil
+	})
+}
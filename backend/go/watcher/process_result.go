@@ -0,0 +1,33 @@
+package watcher
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// ProcessResult is the outcome of processing one video
+type ProcessResult struct {
+	// S3Key is the uploaded object's key; empty if the video wasn't uploaded
+	S3Key string
+	// Published is true once the SNS notification was published
+	Published bool
+	// Outcome is one of the Outcome constants
+	Outcome string
+	// Sinks records each notification sink's result, for videos that reached S3
+	Sinks SinkResults
+	// Err holds whatever went wrong, including failures that still count as success
+	// under the sink success policy; nil if everything succeeded
+	Err error
+	// Duration is the time spent processing, excluding the initial settle delay
+	Duration time.Duration
+}
+
+// recordResult adds a processing result to the recent events buffer, keyed by
+// its S3 key or, if it wasn't uploaded, its filename
+func (fw *FileWatcher) recordResult(filePath string, result ProcessResult) {
+	key := result.S3Key
+	if key == "" {
+		key = filepath.Base(filePath)
+	}
+	fw.recordSinkEvent(key, result.Outcome, result.Err, result.Sinks)
+}
@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Outcomes recorded for processed videos
+const (
+	OutcomeSucceeded      = "succeeded"
+	OutcomeUploadFailed   = "upload_failed"
+	OutcomePublishFailed  = "publish_failed"
+	OutcomePanicked       = "panicked"
+	OutcomeSidecarInvalid = "sidecar_invalid"
+	OutcomeSinkFailed     = "sink_failed"
+	OutcomeEmpty          = "empty"
+	OutcomeOversized      = "oversized"
+	OutcomeInvalid        = "invalid"
+	OutcomeCancelled      = "cancelled"
+	// OutcomeVanished is a video renamed or removed before it was processed
+	OutcomeVanished = "vanished"
+)
+
+// RecentEvent records the outcome of processing a single video
+type RecentEvent struct {
+	Key     string    `json:"key"`
+	Time    time.Time `json:"time"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+	// Whether each notification sink succeeded, for videos that reached S3
+	Sinks SinkResults `json:"sinks,omitempty"`
+}
+
+// RecentEvents is a fixed-size, concurrency-safe ring buffer of processed events
+type RecentEvents struct {
+	mu     sync.Mutex
+	events []RecentEvent
+	next   int
+	full   bool
+}
+
+// NewRecentEvents creates a ring buffer holding at most size events
+func NewRecentEvents(size int) *RecentEvents {
+	if size < 1 {
+		size = 1
+	}
+	return &RecentEvents{
+		events: make([]RecentEvent, size),
+	}
+}
+
+// Add records an event, overwriting the oldest one once the buffer is full
+func (r *RecentEvents) Add(event RecentEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events[r.next] = event
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshot returns a copy of the recorded events, oldest first
+func (r *RecentEvents) Snapshot() []RecentEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]RecentEvent(nil), r.events[:r.next]...)
+	}
+
+	snapshot := make([]RecentEvent, 0, len(r.events))
+	snapshot = append(snapshot, r.events[r.next:]...)
+	snapshot = append(snapshot, r.events[:r.next]...)
+	return snapshot
+}
+
+// Len returns the number of events currently held
+func (r *RecentEvents) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.full {
+		return len(r.events)
+	}
+	return r.next
+}
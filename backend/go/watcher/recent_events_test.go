@@ -0,0 +1,144 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// eventKeys returns the keys of events, in order
+func eventKeys(events []RecentEvent) []string {
+	keys := make([]string, len(events))
+	for i, event := range events {
+		keys[i] = event.Key
+	}
+	return keys
+}
+
+func TestRecentEventsWraparound(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		added int
+		want  []string
+	}{
+		{"empty", 3, 0, []string{}},
+		{"partly full", 3, 2, []string{"0", "1"}},
+		{"exactly full", 3, 3, []string{"0", "1", "2"}},
+		{"wrapped once", 3, 4, []string{"1", "2", "3"}},
+		{"wrapped twice", 3, 7, []string{"4", "5", "6"}},
+		{"size one", 1, 5, []string{"4"}},
+		{"size below one", 0, 2, []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recent := NewRecentEvents(tt.size)
+			for i := 0; i < tt.added; i++ {
+				recent.Add(RecentEvent{Key: fmt.Sprint(i)})
+			}
+			if got := eventKeys(recent.Snapshot()); !slices.Equal(got, tt.want) {
+				t.Errorf("Snapshot() = %v, want %v", got, tt.want)
+			}
+			if got := recent.Len(); got != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestRecentEventsSnapshotIsACopy(t *testing.T) {
+	recent := NewRecentEvents(2)
+	recent.Add(RecentEvent{Key: "a"})
+	snapshot := recent.Snapshot()
+	recent.Add(RecentEvent{Key: "b"})
+	recent.Add(RecentEvent{Key: "c"})
+	if snapshot[0].Key != "a" {
+		t.Errorf("snapshot changed after later adds: %v", eventKeys(snapshot))
+	}
+}
+
+func TestRecentEventsConcurrentWrites(t *testing.T) {
+	const writers, perWriter, size = 8, 200, 50
+	recent := NewRecentEvents(size)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				recent.Add(RecentEvent{Key: fmt.Sprintf("%d-%d", w, i)})
+				// Readers run alongside, as /recent does under the worker pool
+				if i%20 == 0 {
+					recent.Snapshot()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	snapshot := recent.Snapshot()
+	if len(snapshot) != size {
+		t.Fatalf("Snapshot() holds %d events, want %d", len(snapshot), size)
+	}
+	// Each writer's events stay in the order it added them
+	last := make(map[int]int)
+	for _, event := range snapshot {
+		var w, i int
+		if _, err := fmt.Sscanf(event.Key, "%d-%d", &w, &i); err != nil {
+			t.Fatalf("unexpected event %q", event.Key)
+		}
+		if prev, ok := last[w]; ok && i <= prev {
+			t.Errorf("writer %d's events out of order: %d after %d", w, i, prev)
+		}
+		last[w] = i
+	}
+}
+
+func TestProcessVideoRecordsEvents(t *testing.T) {
+	env := newTestEnv(t, nil)
+	record := func(path string) {
+		env.fw.recordResult(path, env.fw.processWithTimeout(context.Background(), path))
+	}
+	record(writeVideo(t, env.videoDir, "clip.mp4"))
+	empty := filepath.Join(env.videoDir, "empty.mp4")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	record(empty)
+
+	stats := env.fw.Stats()
+	if stats.Succeeded != 1 || stats.Failed != 1 || len(stats.Recent) != 2 {
+		t.Fatalf("Stats() = %+v, want one success and one failure", stats)
+	}
+	// Uploaded videos are keyed by S3 key, others by filename
+	if event := stats.Recent[0]; event.Key != "videos/clip.mp4" || event.Outcome != OutcomeSucceeded || event.Time.IsZero() {
+		t.Errorf("recorded event = %+v", event)
+	}
+	if event := stats.Recent[1]; event.Key != "empty.mp4" || event.Outcome != OutcomeEmpty {
+		t.Errorf("recorded event = %+v, want %s for empty.mp4", event, OutcomeEmpty)
+	}
+
+	rec := httptest.NewRecorder()
+	env.fw.RecentHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recent", nil))
+	var served Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("decode /recent: %v (%s)", err, rec.Body)
+	}
+	if served.Succeeded != 1 || len(served.Recent) != 2 || served.Recent[0].Key != "videos/clip.mp4" {
+		t.Errorf("/recent served %+v", served)
+	}
+
+	rec = httptest.NewRecorder()
+	env.fw.RecentHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/recent", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /recent = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fsnotify only watches a directory's direct children. With RECURSIVE set, every
+// subdirectory of a watched directory gets its own watch: existing ones when the
+// directory is watched, new ones as their Create events arrive. fw.subdirs tracks
+// them so none is added twice and removed ones are forgotten; it is only used
+// by the Watch goroutine.
+
+// watchTree ensures root exists and watches it and, when recursive, every
+// directory beneath it
+func (fw *FileWatcher) watchTree(root string) error {
+	if err := fw.addDir(root); err != nil {
+		return err
+	}
+	if fw.cfg.Recursive {
+		fw.watchSubdirs(root)
+	}
+	return nil
+}
+
+// watchSubdirs watches every directory beneath dir. Directories that can't be
+// read or watched are logged and skipped.
+func (fw *FileWatcher) watchSubdirs(dir string) {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Warn("Failed to read directory, not watching beneath it", "dir", path, "error", err)
+			if entry != nil && entry.IsDir() && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == dir || !entry.IsDir() {
+			return nil
+		}
+		fw.addSubdir(path)
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Failed to walk directory", "dir", dir, "error", err)
+	}
+}
+
+// addSubdir watches a subdirectory unless it is already watched
+func (fw *FileWatcher) addSubdir(dir string) {
+	dir = filepath.Clean(dir)
+	if fw.subdirs[dir] {
+		return
+	}
+	if err := fw.watcher.Add(dir); err != nil {
+		slog.Warn("Failed to watch subdirectory", "dir", dir, "error", err)
+		return
+	}
+	fw.subdirs[dir] = true
+	slog.Debug("Watching subdirectory", "dir", dir)
+}
+
+// watchNewDir watches a directory created beneath a watched one, along with
+// anything beneath it, then picks up videos written before its watch was added
+func (fw *FileWatcher) watchNewDir(dir string) {
+	fw.addSubdir(dir)
+	fw.watchSubdirs(dir)
+	fw.processExisting(dir)
+}
+
+// unwatchSubdirs drops the watches on dir, if it is a watched subdirectory,
+// and on every watched directory beneath it
+func (fw *FileWatcher) unwatchSubdirs(dir string) {
+	dir = filepath.Clean(dir)
+	for subdir := range fw.subdirs {
+		if subdir != dir && !withinDir(subdir, dir) {
+			continue
+		}
+		// The kernel drops the watch on a deleted directory itself, so this may fail
+		_ = fw.watcher.Remove(subdir)
+		delete(fw.subdirs, subdir)
+		slog.Debug("Stopped watching subdirectory", "dir", subdir)
+	}
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// withinDir reports whether path is dir or lies beneath it
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
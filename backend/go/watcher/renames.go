@@ -0,0 +1,51 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event patterns that lead to a video being processed, as fsnotify reports them:
+//
+//   - Written in place: Create for the video, then Writes. It is processed once
+//     the Writes stop for SETTLE_DURATION.
+//   - Written to a temporary name and renamed into place (clip.mp4.tmp to
+//     clip.mp4): Create and Writes for the temporary name, which are ignored as
+//     it isn't a video extension, then Rename for the temporary name immediately
+//     followed by Create for the video. The rename means the camera is done, so
+//     the video is processed without waiting to settle.
+//   - Moved in from an unwatched directory: Create only, handled as written in place.
+//
+// A video renamed or removed while it waits to settle (e.g. renamed from one
+// video name to another) is skipped as vanished; the Create for its new name
+// processes it, so it is still processed exactly once. Rename and Remove events
+// for videos otherwise start nothing.
+
+// renamedIntoPlace reports whether a Create event completes a rename, i.e. the
+// event before it was the Rename of the old name. inotify queues the two halves
+// of a rename back to back.
+func renamedIntoPlace(prev, event fsnotify.Event) bool {
+	return event.Op&fsnotify.Create == fsnotify.Create && prev.Op&fsnotify.Rename == fsnotify.Rename
+}
+
+// markSettled tracks a video as already finished, so it is processed without
+// waiting for SETTLE_DURATION; a later write still restarts the wait
+func (fw *FileWatcher) markSettled(filePath string) {
+	fw.writesMu.Lock()
+	defer fw.writesMu.Unlock()
+	fw.lastWrites[filePath] = time.Time{}
+}
+
+// vanished reports whether a video is no longer at its path, and logs why it is skipped
+func vanished(filePath string) bool {
+	if _, err := os.Stat(filePath); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	slog.Info("Video was renamed or removed before processing, skipping", "path", filePath)
+	return true
+}
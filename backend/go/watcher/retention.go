@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How often the processed directory is checked for videos past their retention
+const processedSweepInterval = 10 * time.Minute
+
+// keepsLocal reports whether processed videos are kept in ProcessedDir rather
+// than deleted once uploaded
+func (fw *FileWatcher) keepsLocal() bool {
+	return fw.cfg.KeepLocal || fw.cfg.LocalRetention > 0
+}
+
+// keepProcessed moves an uploaded video, and its sidecar, into the processed
+// directory. Its modification time is reset so retention counts from now.
+func (fw *FileWatcher) keepProcessed(filePath string) (string, error) {
+	dest, err := moveToDir(filePath, fw.cfg.ProcessedDir)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := os.Chtimes(dest, now, now); err != nil {
+		slog.Warn("Failed to reset modification time of processed video", "path", dest, "error", err)
+	}
+
+	if _, err := os.Stat(sidecarPath(filePath)); err == nil {
+		if _, err := moveToDir(sidecarPath(filePath), fw.cfg.ProcessedDir); err != nil {
+			slog.Warn("Failed to move sidecar of processed video", "path", sidecarPath(filePath), "error", err)
+		}
+	}
+	return dest, nil
+}
+
+// SweepProcessed deletes files in the processed directory older than
+// LOCAL_RETENTION, checking now and then periodically until ctx is cancelled.
+// It does nothing when no retention is set.
+func (fw *FileWatcher) SweepProcessed(ctx context.Context) {
+	if fw.cfg.LocalRetention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(processedSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		fw.sweepProcessed(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepProcessed deletes the regular files directly inside the processed
+// directory last modified more than LOCAL_RETENTION before now
+func (fw *FileWatcher) sweepProcessed(now time.Time) {
+	dir := fw.cfg.ProcessedDir
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Warn("Failed to read processed directory", "dir", dir, "error", err)
+		return
+	}
+
+	deleted := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) <= fw.cfg.LocalRetention {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to delete expired processed video", "path", path, "error", err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		slog.Info("Deleted expired processed videos", "dir", dir, "deleted", deleted, "retention", fw.cfg.LocalRetention.String())
+	}
+}
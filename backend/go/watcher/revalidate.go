@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// watchedDir is a directory the watcher was told to watch and the state of its watch
+type watchedDir struct {
+	// info identifies the directory watched, so a replacement at the same path
+	// (e.g. a card remounted) is noticed; nil while the watch is lost
+	info os.FileInfo
+	// failures counts consecutive revalidations that failed to recover the watch
+	failures int
+}
+
+// revalidateDirs confirms each watched directory still exists, is the one being
+// watched, and is in the watch list. fsnotify stops delivering events silently when
+// a directory is deleted or unmounted, so a lost watch is re-created and the
+// directory rescanned for clips written meanwhile. Returns an error once a
+// directory has failed to recover WatchRecoveryAttempts times in a row.
+func (fw *FileWatcher) revalidateDirs(dirs map[string]*watchedDir) error {
+	healthy := true
+	for dir, state := range dirs {
+		if fw.dirWatchValid(dir, state) {
+			continue
+		}
+		if state.info != nil {
+			slog.Error("Watched directory vanished or was replaced, re-creating watch", "dir", dir)
+			// The old watches, if fsnotify still holds them, are on directories that are gone
+			_ = fw.watcher.Remove(dir)
+			fw.unwatchSubdirs(dir)
+			state.info = nil
+		}
+
+		info, err := fw.rewatchDir(dir)
+		if err != nil {
+			state.failures++
+			healthy = false
+			slog.Error("Failed to recover directory watch", "dir", dir, "attempt", state.failures,
+				"max_attempts", fw.cfg.WatchRecoveryAttempts, "error", err)
+			if state.failures >= fw.cfg.WatchRecoveryAttempts {
+				return fmt.Errorf("lost watch on %s and failed to recover it after %d attempts: %w", dir, state.failures, err)
+			}
+			continue
+		}
+
+		state.info, state.failures = info, 0
+		metrics.Default.Inc(metrics.WatchRecoveries)
+		slog.Info("Recovered directory watch", "dir", dir)
+		fw.processExisting(dir)
+	}
+
+	// Not ready while any directory is unwatched
+	fw.ready.Store(healthy)
+	return nil
+}
+
+// dirWatchValid reports whether dir is still the directory being watched
+func (fw *FileWatcher) dirWatchValid(dir string, state *watchedDir) bool {
+	if state.info == nil {
+		return false
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() || !os.SameFile(info, state.info) {
+		return false
+	}
+	return slices.Contains(fw.watcher.WatchList(), filepath.Clean(dir))
+}
+
+// rewatchDir re-creates and watches dir, returning the directory now watched
+func (fw *FileWatcher) rewatchDir(dir string) (os.FileInfo, error) {
+	if err := fw.watchTree(dir); err != nil {
+		return nil, err
+	}
+	return os.Stat(dir)
+}
@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// Queue policies for a video arriving at a full processing queue
+const (
+	// QueueDrop drops the video at once
+	QueueDrop = "drop"
+	// QueueBlock waits up to PROCESS_QUEUE_OFFER_TIMEOUT for room, then drops it
+	QueueBlock = "block"
+)
+
+// Accepted videos wait in a bounded queue for one of PROCESS_CONCURRENCY
+// workers. The watcher never blocks on a full queue for longer than the offer
+// timeout, so it keeps reading events during an upload backlog; a video that
+// finds no room is dropped, counted and logged, and is picked up by the next
+// startup scan (or, when polling, the next poll).
+//
+// With PROCESS_ORDER_PER_SOURCE only a source's oldest waiting video is in the
+// queue; the rest wait in fw.sourceQueues until the worker that took it has
+// processed it, so a camera's clips are processed one at a time in order while
+// different cameras still run in parallel. A source has an entry exactly while
+// one of its videos is queued or processing. Videos waiting either way hold one
+// of fw.queueSlots, which bounds the queue. A worker waits for its video to
+// settle, so a long recording occupies one until the camera finishes it.
+
+// startWorkers starts the processing workers; they exit once Close closes the queue
+func (fw *FileWatcher) startWorkers() {
+	for i := 0; i < fw.cfg.ProcessConcurrency; i++ {
+		go fw.work()
+	}
+}
+
+// work processes queued videos and, when ordering per source, the videos
+// queued behind them
+func (fw *FileWatcher) work() {
+	for filePath := range fw.queue {
+		fw.runProcessing(filePath)
+		if fw.cfg.ProcessOrderPerSource {
+			fw.drainSource(sourceRoot(filePath, fw.cfg.VideoDirs))
+		}
+	}
+}
+
+// offer waits for room in the queue as the queue policy allows, reporting
+// whether there was room
+func (fw *FileWatcher) offer() bool {
+	select {
+	case fw.queueSlots <- struct{}{}:
+		metrics.ProcessQueueDepth.Set(float64(len(fw.queueSlots)))
+		return true
+	default:
+	}
+	if fw.cfg.ProcessQueuePolicy != QueueBlock {
+		return false
+	}
+
+	timer := time.NewTimer(fw.cfg.ProcessQueueOfferTimeout)
+	defer timer.Stop()
+	select {
+	case fw.queueSlots <- struct{}{}:
+		metrics.ProcessQueueDepth.Set(float64(len(fw.queueSlots)))
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// dequeued frees the queue slot of a video leaving the queue for a worker
+func (fw *FileWatcher) dequeued() {
+	<-fw.queueSlots
+	metrics.ProcessQueueDepth.Set(float64(len(fw.queueSlots)))
+}
+
+// schedule queues a video that holds a queue slot; callers must hold fw.mu.
+// It never blocks: the queue has room for every slot.
+func (fw *FileWatcher) schedule(filePath string) {
+	if fw.cfg.ProcessOrderPerSource {
+		source := sourceRoot(filePath, fw.cfg.VideoDirs)
+		if pending, busy := fw.sourceQueues[source]; busy {
+			fw.sourceQueues[source] = append(pending, filePath)
+			slog.Debug("Queued video behind earlier clips from its source", "path", filePath, "source", source, "queued", len(pending)+1)
+			return
+		}
+		fw.sourceQueues[source] = nil
+	}
+	fw.queue <- filePath
+}
+
+// drainSource processes a source's videos queued behind the one just
+// processed, one at a time, until none are left
+func (fw *FileWatcher) drainSource(source string) {
+	for {
+		fw.mu.Lock()
+		pending := fw.sourceQueues[source]
+		if len(pending) == 0 {
+			delete(fw.sourceQueues, source)
+			fw.mu.Unlock()
+			return
+		}
+		filePath := pending[0]
+		fw.sourceQueues[source] = pending[1:]
+		fw.mu.Unlock()
+
+		fw.runProcessing(filePath)
+	}
+}
+
+// runProcessing processes one queued video and records its result
+func (fw *FileWatcher) runProcessing(filePath string) {
+	fw.dequeued()
+	defer fw.inFlight.Done()
+	defer fw.finishProcessing(filePath)
+	defer fw.recoverPanic(filePath)
+	fw.recordResult(filePath, fw.process(fw.processCtx, filePath))
+}
+
+// process waits for a video to settle, then processes it. The wait doesn't
+// count towards the processing timeout.
+func (fw *FileWatcher) process(ctx context.Context, filePath string) ProcessResult {
+	if err := fw.waitForSettle(ctx, filePath); err != nil {
+		slog.Error("Processing cancelled before upload", "path", filePath, "error", err)
+		return ProcessResult{Outcome: OutcomeCancelled, Err: err}
+	}
+	if vanished(filePath) {
+		return ProcessResult{Outcome: OutcomeVanished}
+	}
+
+	fw.active.Add(1)
+	defer fw.active.Add(-1)
+	return fw.processWithTimeout(ctx, filePath)
+}
+
+// sortByCaptureTime orders videos oldest first, so a directory scan queues
+// each source's clips in the order they were recorded
+func sortByCaptureTime(paths []string) {
+	times := make(map[string]int64, len(paths))
+	for _, path := range paths {
+		times[path] = captureTime(path).UnixNano()
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return times[paths[i]] < times[paths[j]]
+	})
+}
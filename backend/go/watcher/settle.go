@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/utils"
+)
+
+// A video is processed once it has gone SETTLE_DURATION without a Write event,
+// rather than after a fixed delay: a short clip is ready almost at once, while a
+// long recording is left alone until the camera stops writing it. Only paths
+// waiting to settle are tracked, so writes to other files cost nothing.
+
+// trackWrites starts tracking writes to a newly detected video
+func (fw *FileWatcher) trackWrites(filePath string) {
+	fw.writesMu.Lock()
+	defer fw.writesMu.Unlock()
+	fw.lastWrites[filePath] = time.Now()
+}
+
+// recordWrite notes a write to a video waiting to settle; writes to untracked
+// paths are ignored
+func (fw *FileWatcher) recordWrite(filePath string) {
+	fw.writesMu.Lock()
+	defer fw.writesMu.Unlock()
+	if _, ok := fw.lastWrites[filePath]; ok {
+		fw.lastWrites[filePath] = time.Now()
+	}
+}
+
+// untrackWrites stops tracking writes to a video
+func (fw *FileWatcher) untrackWrites(filePath string) {
+	fw.writesMu.Lock()
+	defer fw.writesMu.Unlock()
+	delete(fw.lastWrites, filePath)
+}
+
+// quietFor reports how long it has been since the video was last written to.
+// A video not yet tracked (e.g. found by a directory scan) is tracked from now.
+func (fw *FileWatcher) quietFor(filePath string) time.Duration {
+	fw.writesMu.Lock()
+	defer fw.writesMu.Unlock()
+	last, ok := fw.lastWrites[filePath]
+	if !ok {
+		last = time.Now()
+		fw.lastWrites[filePath] = last
+	}
+	return time.Since(last)
+}
+
+// waitForSettle blocks until the video has seen no writes for SETTLE_DURATION
+func (fw *FileWatcher) waitForSettle(ctx context.Context, filePath string) error {
+	settle := fw.cfg.SettleDuration
+	if settle <= 0 {
+		return nil
+	}
+
+	start := time.Now()
+	for {
+		quiet := fw.quietFor(filePath)
+		if quiet >= settle {
+			break
+		}
+		if err := utils.SleepContext(ctx, settle-quiet); err != nil {
+			return err
+		}
+	}
+
+	slog.Debug("Video settled", "path", filePath, "waited", time.Since(start).Round(time.Millisecond).String())
+	return nil
+}
@@ -0,0 +1,115 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+)
+
+// errInvalidSidecar wraps sidecar files that exist but can't be used
+var errInvalidSidecar = errors.New("invalid sidecar")
+
+// sidecar is the detector's description of a clip, written next to it as
+// <name>.json, e.g.
+//
+//	{"event_type": "human_detected", "confidence": 0.87, "labels": ["person"],
+//	 "detections": [{"label": "person", "confidence": 0.87, "box": [12, 40, 220, 460]}]}
+//
+// Every field is optional.
+type sidecar struct {
+	EventType  string                 `json:"event_type"`
+	Confidence *float64               `json:"confidence"`
+	Labels     []string               `json:"labels"`
+	Detections []awspackage.Detection `json:"detections"`
+}
+
+// sidecarPath returns the sidecar path for a video
+func sidecarPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".json"
+}
+
+// readSidecar returns the detection event described by a video's sidecar.
+// A missing sidecar yields the default event. An empty or malformed one also
+// yields the default event, along with an error wrapping errInvalidSidecar.
+func readSidecar(videoPath string) (awspackage.DetectionEvent, error) {
+	event := awspackage.DefaultDetectionEvent()
+
+	data, err := os.ReadFile(sidecarPath(videoPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return event, nil
+	}
+	if err != nil {
+		return event, fmt.Errorf("%w: %v", errInvalidSidecar, err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return event, fmt.Errorf("%w: file is empty", errInvalidSidecar)
+	}
+
+	var parsed sidecar
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return event, fmt.Errorf("%w: %v", errInvalidSidecar, err)
+	}
+	if parsed.Confidence != nil && (*parsed.Confidence < 0 || *parsed.Confidence > 1) {
+		return event, fmt.Errorf("%w: confidence %v is outside 0-1", errInvalidSidecar, *parsed.Confidence)
+	}
+	for i, detection := range parsed.Detections {
+		if detection.Confidence < 0 || detection.Confidence > 1 {
+			return event, fmt.Errorf("%w: detection %d confidence %v is outside 0-1", errInvalidSidecar, i, detection.Confidence)
+		}
+		if detection.Box != nil && len(detection.Box) != 4 {
+			return event, fmt.Errorf("%w: detection %d box must be [x1, y1, x2, y2]", errInvalidSidecar, i)
+		}
+	}
+
+	if parsed.EventType != "" {
+		event.Type = parsed.EventType
+	}
+	event.Confidence = parsed.Confidence
+	event.Labels = sidecarLabels(parsed)
+	event.Detections = parsed.Detections
+
+	// Without an overall score, the most confident detection stands for the clip
+	if event.Confidence == nil {
+		for _, detection := range parsed.Detections {
+			if event.Confidence == nil || detection.Confidence > *event.Confidence {
+				confidence := detection.Confidence
+				event.Confidence = &confidence
+			}
+		}
+	}
+	return event, nil
+}
+
+// sidecarLabels returns a sidecar's labels, or when it lists none, the distinct
+// labels of its detections. Blank labels are dropped.
+func sidecarLabels(parsed sidecar) []string {
+	labels := parsed.Labels
+	if len(labels) == 0 {
+		for _, detection := range parsed.Detections {
+			labels = append(labels, detection.Label)
+		}
+	}
+
+	var distinct []string
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label != "" && !slices.Contains(distinct, label) {
+			distinct = append(distinct, label)
+		}
+	}
+	return distinct
+}
+
+// removeSidecar deletes a video's sidecar, if it has one
+func removeSidecar(videoPath string) error {
+	if err := os.Remove(sidecarPath(videoPath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
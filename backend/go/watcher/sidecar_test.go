@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/config"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+// writeSidecar writes contents as the sidecar of the video at videoPath
+func writeSidecar(t *testing.T, videoPath, contents string) {
+	t.Helper()
+	if err := os.WriteFile(sidecarPath(videoPath), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadSidecar(t *testing.T) {
+	tests := []struct {
+		name       string
+		missing    bool
+		contents   string
+		wantType   string
+		wantLabels []string
+		wantConf   float64 // 0 when no confidence is expected
+		wantErr    bool
+	}{
+		{name: "missing", missing: true, wantType: awspackage.EventTypeHumanDetected},
+		{name: "empty", contents: "", wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "whitespace", contents: " \n\t", wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "truncated", contents: `{"event_type": "vehi`, wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "not an object", contents: `["person"]`, wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "wrong field type", contents: `{"confidence": "high"}`, wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "confidence out of range", contents: `{"event_type": "vehicle", "confidence": 1.5}`, wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "bad box", contents: `{"detections": [{"label": "person", "confidence": 0.5, "box": [1, 2]}]}`, wantType: awspackage.EventTypeHumanDetected, wantErr: true},
+		{name: "empty object", contents: `{}`, wantType: awspackage.EventTypeHumanDetected},
+		{name: "event type", contents: `{"event_type": "vehicle", "confidence": 0.9, "labels": ["car"]}`,
+			wantType: "vehicle", wantLabels: []string{"car"}, wantConf: 0.9},
+		{name: "confidence from detections", contents: `{"detections": [{"label": "person", "confidence": 0.4}, {"label": "dog", "confidence": 0.7}]}`,
+			wantType: awspackage.EventTypeHumanDetected, wantLabels: []string{"person", "dog"}, wantConf: 0.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video := filepath.Join(t.TempDir(), "clip.mp4")
+			if !tt.missing {
+				writeSidecar(t, video, tt.contents)
+			}
+
+			event, err := readSidecar(video)
+			if got := errors.Is(err, errInvalidSidecar); got != tt.wantErr {
+				t.Errorf("readSidecar() error = %v, want invalid %v", err, tt.wantErr)
+			}
+			if event.Type != tt.wantType {
+				t.Errorf("event type = %q, want %q", event.Type, tt.wantType)
+			}
+			if !slices.Equal(event.Labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", event.Labels, tt.wantLabels)
+			}
+			var conf float64
+			if event.Confidence != nil {
+				conf = *event.Confidence
+			}
+			if conf != tt.wantConf {
+				t.Errorf("confidence = %v, want %v", conf, tt.wantConf)
+			}
+		})
+	}
+}
+
+func TestProcessVideoWithInvalidSidecar(t *testing.T) {
+	tests := []struct {
+		name        string
+		strict      bool
+		wantOutcome string
+		wantUpload  bool
+	}{
+		{"default on error", false, OutcomeSucceeded, true},
+		{"strict", true, OutcomeSidecarInvalid, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				cfg.SidecarStrict = tt.strict
+			})
+			invalid := counterChange(metrics.SidecarInvalid)
+			video := writeVideo(t, env.videoDir, "clip.mp4")
+			writeSidecar(t, video, `{"event_type": "vehicle",`)
+
+			result := env.fw.processWithTimeout(context.Background(), video)
+			if result.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q (%v), want %q", result.Outcome, result.Err, tt.wantOutcome)
+			}
+			if got := invalid(); got != 1 {
+				t.Errorf("%s changed by %d, want 1", metrics.SidecarInvalid, got)
+			}
+
+			uploaded := env.s3.Object(testBucket, "videos/clip.mp4") != nil
+			if uploaded != tt.wantUpload {
+				t.Errorf("uploaded = %v, want %v", uploaded, tt.wantUpload)
+			}
+			published := env.notifier.published()
+			if !tt.wantUpload {
+				if len(published) != 0 {
+					t.Errorf("published %d notifications for a rejected video", len(published))
+				}
+				// Set aside with its sidecar, so it isn't picked up again
+				for _, name := range []string{"clip.mp4", "clip.json"} {
+					if _, err := os.Stat(filepath.Join(env.videoDir, name)); !os.IsNotExist(err) {
+						t.Errorf("%s left in the watched directory: %v", name, err)
+					}
+					if _, err := os.Stat(filepath.Join(env.cfg.RejectedDir, name)); err != nil {
+						t.Errorf("%s not in the rejected directory: %v", name, err)
+					}
+				}
+				return
+			}
+			if len(published) != 1 {
+				t.Fatalf("published %d notifications, want 1", len(published))
+			}
+			if got := published[0].EventType; got != awspackage.EventTypeHumanDetected {
+				t.Errorf("event type = %q, want the default %q", got, awspackage.EventTypeHumanDetected)
+			}
+		})
+	}
+}
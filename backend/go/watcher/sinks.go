@@ -0,0 +1,77 @@
+package watcher
+
+import "sort"
+
+// Sinks an uploaded video is delivered to after S3
+const (
+	// SinkSNS is the notification, published to SNS or the configured notifier
+	SinkSNS           = "sns"
+	SinkManifest      = "manifest"
+	SinkFilenameIndex = "filename_index"
+	SinkDynamoDB      = "dynamodb"
+)
+
+// Sink success policies, deciding a video's outcome from its sink results
+const (
+	// SinkPolicyAll succeeds only if every enabled sink succeeded
+	SinkPolicyAll = "all"
+	// SinkPolicyAny succeeds if at least one sink succeeded
+	SinkPolicyAny = "any"
+	// SinkPolicyRequired succeeds if every required sink succeeded
+	SinkPolicyRequired = "required"
+)
+
+// SinkResults records whether each enabled sink succeeded for a video
+type SinkResults map[string]bool
+
+// Succeeded applies a sink policy to the results. Under SinkPolicyRequired a
+// required sink that isn't enabled counts as failed.
+func (r SinkResults) Succeeded(policy string, required []string) bool {
+	switch policy {
+	case SinkPolicyAll:
+		for _, ok := range r {
+			if !ok {
+				return false
+			}
+		}
+		return true
+	case SinkPolicyAny:
+		for _, ok := range r {
+			if ok {
+				return true
+			}
+		}
+		return len(r) == 0
+	default:
+		for _, sink := range required {
+			if !r[sink] {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Failed returns the names of the sinks that failed, sorted
+func (r SinkResults) Failed() []string {
+	var failed []string
+	for sink, ok := range r {
+		if !ok {
+			failed = append(failed, sink)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// sinkOutcome decides a video's outcome from its sink results: failures of
+// the SNS sink keep their own outcome so existing alerting still matches
+func (fw *FileWatcher) sinkOutcome(results SinkResults) string {
+	if results.Succeeded(fw.cfg.SinkSuccessPolicy, fw.cfg.RequiredSinks) {
+		return OutcomeSucceeded
+	}
+	if ok, enabled := results[SinkSNS]; enabled && !ok {
+		return OutcomePublishFailed
+	}
+	return OutcomeSinkFailed
+}
@@ -0,0 +1,297 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+	"github.com/lachiem1/eyeSeeYou/backend/go/config"
+)
+
+var errNotifierDown = errors.New("notifier unavailable")
+
+// failingNotifier rejects every notification
+type failingNotifier struct{}
+
+func (failingNotifier) PublishNotification(ctx context.Context, notification awspackage.VideoNotification) error {
+	return errNotifierDown
+}
+
+// deniedS3 refuses every request, without retries
+type deniedS3 struct{}
+
+var errS3Denied = &smithy.GenericAPIError{Code: "AccessDenied", Fault: smithy.FaultClient}
+
+func (deniedS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	return nil, errS3Denied
+}
+
+func (deniedS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return nil, errS3Denied
+}
+
+func (deniedS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, errS3Denied
+}
+
+func (deniedS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return nil, errS3Denied
+}
+
+func (deniedS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return nil, errS3Denied
+}
+
+// dynamoDBTable answers the DynamoDB PutItem requests sent to a fake S3 endpoint,
+// keeping each item's string attributes, or rejects them when reject is set
+type dynamoDBTable struct {
+	reject bool
+
+	mu    sync.Mutex
+	items []map[string]string
+}
+
+func (d *dynamoDBTable) hook(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".PutItem") {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if d.reject {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ValidationException","message":"bad item"}`)
+		return true
+	}
+
+	var input struct {
+		Item map[string]struct{ S, N string }
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return true
+	}
+	item := map[string]string{}
+	for name, value := range input.Item {
+		item[name] = value.S + value.N
+	}
+	d.mu.Lock()
+	d.items = append(d.items, item)
+	d.mu.Unlock()
+	fmt.Fprint(w, "{}")
+	return true
+}
+
+func (d *dynamoDBTable) recorded() []map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]map[string]string(nil), d.items...)
+}
+
+func TestSinkResultsSucceeded(t *testing.T) {
+	mixed := SinkResults{SinkSNS: true, SinkManifest: false, SinkDynamoDB: true}
+	snsFailed := SinkResults{SinkSNS: false, SinkManifest: true}
+	allOK := SinkResults{SinkSNS: true, SinkManifest: true}
+	allFailed := SinkResults{SinkSNS: false, SinkManifest: false}
+
+	tests := []struct {
+		name     string
+		results  SinkResults
+		policy   string
+		required []string
+		want     bool
+	}{
+		{"all: every sink succeeded", allOK, SinkPolicyAll, nil, true},
+		{"all: one sink failed", mixed, SinkPolicyAll, nil, false},
+		{"all: no sinks", SinkResults{}, SinkPolicyAll, nil, true},
+
+		{"any: one sink succeeded", snsFailed, SinkPolicyAny, nil, true},
+		{"any: every sink failed", allFailed, SinkPolicyAny, nil, false},
+		{"any: no sinks", SinkResults{}, SinkPolicyAny, nil, true},
+
+		{"required: required sinks succeeded", mixed, SinkPolicyRequired, []string{SinkSNS, SinkDynamoDB}, true},
+		{"required: a required sink failed", mixed, SinkPolicyRequired, []string{SinkSNS, SinkManifest}, false},
+		{"required: an optional sink failed", snsFailed, SinkPolicyRequired, []string{SinkManifest}, true},
+		{"required: a required sink isn't enabled", allOK, SinkPolicyRequired, []string{SinkFilenameIndex}, false},
+		{"required: nothing required", allFailed, SinkPolicyRequired, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.results.Succeeded(tt.policy, tt.required); got != tt.want {
+				t.Errorf("%v.Succeeded(%s, %v) = %v, want %v", tt.results, tt.policy, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSinkResultsFailed(t *testing.T) {
+	results := SinkResults{SinkSNS: false, SinkManifest: true, SinkDynamoDB: false, SinkFilenameIndex: false}
+	if got, want := results.Failed(), []string{SinkDynamoDB, SinkFilenameIndex, SinkSNS}; !slices.Equal(got, want) {
+		t.Errorf("Failed() = %v, want %v", got, want)
+	}
+}
+
+func TestProcessVideoSinkPolicies(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		required     []string
+		indexFails   bool
+		notifierDown bool
+		wantOutcome  string
+		wantSinks    SinkResults
+	}{
+		{"all, every sink succeeded", SinkPolicyAll, nil, false, false, OutcomeSucceeded,
+			SinkResults{SinkSNS: true, SinkManifest: true, SinkFilenameIndex: true}},
+		{"all, index failed", SinkPolicyAll, nil, true, false, OutcomeSinkFailed,
+			SinkResults{SinkSNS: true, SinkManifest: true, SinkFilenameIndex: false}},
+		{"all, notification failed", SinkPolicyAll, nil, false, true, OutcomePublishFailed,
+			SinkResults{SinkSNS: false, SinkManifest: true, SinkFilenameIndex: true}},
+		{"any, notification and index failed", SinkPolicyAny, nil, true, true, OutcomeSucceeded,
+			SinkResults{SinkSNS: false, SinkManifest: true, SinkFilenameIndex: false}},
+		{"required, optional index failed", SinkPolicyRequired, []string{SinkSNS, SinkManifest}, true, false, OutcomeSucceeded,
+			SinkResults{SinkSNS: true, SinkManifest: true, SinkFilenameIndex: false}},
+		{"required, required index failed", SinkPolicyRequired, []string{SinkSNS, SinkFilenameIndex}, true, false, OutcomeSinkFailed,
+			SinkResults{SinkSNS: true, SinkManifest: true, SinkFilenameIndex: false}},
+		{"required, required notification failed", SinkPolicyRequired, []string{SinkSNS}, false, true, OutcomePublishFailed,
+			SinkResults{SinkSNS: false, SinkManifest: true, SinkFilenameIndex: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				cfg.SinkSuccessPolicy = tt.policy
+				cfg.RequiredSinks = tt.required
+				if tt.notifierDown {
+					opts.Notifier = failingNotifier{}
+				}
+			})
+			// The sinks need the uploader, so they are wired in after it exists
+			env.fw.opts.Manifest = env.uploader.NewManifestWriter("manifests/")
+			env.fw.opts.FilenameIndex = env.uploader.NewFilenameIndex("index/")
+			if tt.indexFails {
+				env.fw.opts.FilenameIndex = awspackage.NewFilenameIndex(deniedS3{}, testBucket, "index/")
+			}
+
+			result := env.fw.processWithTimeout(context.Background(), writeVideo(t, env.videoDir, "clip.mp4"))
+			if result.Outcome != tt.wantOutcome {
+				t.Errorf("outcome = %q, want %q (%v)", result.Outcome, tt.wantOutcome, result.Err)
+			}
+			if len(result.Sinks) != len(tt.wantSinks) {
+				t.Fatalf("sinks = %v, want %v", result.Sinks, tt.wantSinks)
+			}
+			for sink, want := range tt.wantSinks {
+				if got, ok := result.Sinks[sink]; !ok || got != want {
+					t.Errorf("sink %s = %v (reported %v), want %v", sink, got, ok, want)
+				}
+			}
+			if tt.indexFails && !errors.Is(result.Err, errS3Denied) {
+				t.Errorf("result error = %v, want the index failure", result.Err)
+			}
+			if tt.notifierDown && !errors.Is(result.Err, errNotifierDown) {
+				t.Errorf("result error = %v, want the notifier failure", result.Err)
+			}
+		})
+	}
+}
+
+func TestPublishRetriedUploadRecordsSinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		indexFails bool
+	}{
+		{"every sink succeeded", false},
+		{"index failed", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, nil)
+			index := env.uploader.NewFilenameIndex("index/")
+			env.fw.opts.Manifest = env.uploader.NewManifestWriter("manifests/")
+			env.fw.opts.FilenameIndex = index
+			if tt.indexFails {
+				env.fw.opts.FilenameIndex = awspackage.NewFilenameIndex(deniedS3{}, testBucket, "index/")
+			}
+
+			// A retried upload comes from the failed upload directory
+			video := writeVideo(t, env.cfg.FailedUploadDir, "clip.mp4")
+			upload, err := env.uploader.Upload(context.Background(), video, nil)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+
+			err = env.fw.PublishRetriedUpload(context.Background(), video, upload)
+			if tt.indexFails {
+				if !errors.Is(err, errS3Denied) {
+					t.Fatalf("PublishRetriedUpload() = %v, want the index failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishRetriedUpload: %v", err)
+			}
+			entries, err := index.Lookup(context.Background(), "clip.mp4")
+			if err != nil || len(entries) != 1 || entries[0].Key != upload.Key {
+				t.Errorf("index entries = %v (%v), want %s", entries, err, upload.Key)
+			}
+			if got := env.notifier.published(); len(got) != 1 {
+				t.Errorf("%d notifications published, want 1", len(got))
+			}
+		})
+	}
+}
+
+func TestPublishRetriedUploadRecordsDynamoDB(t *testing.T) {
+	tests := []struct {
+		name   string
+		reject bool
+	}{
+		{"recorded", false},
+		{"required sink failed", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config, s3Options *awspackage.S3UploaderOptions, opts *Options) {
+				cfg.SinkSuccessPolicy = SinkPolicyRequired
+				cfg.RequiredSinks = []string{SinkDynamoDB}
+			})
+			table := &dynamoDBTable{reject: tt.reject}
+			env.s3.Hook = table.hook
+			recorder, err := awspackage.NewDynamoDBRecorder(context.Background(), "us-east-1", "uploads", awspackage.DynamoDBRecorderOptions{})
+			if err != nil {
+				t.Fatalf("NewDynamoDBRecorder: %v", err)
+			}
+			env.fw.opts.Recorder = recorder
+
+			video := writeVideo(t, env.cfg.FailedUploadDir, "clip.mp4")
+			upload, err := env.uploader.Upload(context.Background(), video, nil)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+
+			err = env.fw.PublishRetriedUpload(context.Background(), video, upload)
+			if tt.reject {
+				if err == nil || !strings.Contains(err.Error(), "dynamodb") {
+					t.Fatalf("PublishRetriedUpload() = %v, want the DynamoDB failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishRetriedUpload: %v", err)
+			}
+			items := table.recorded()
+			if len(items) != 1 || items[0]["s3_key"] != upload.Key || items[0]["filename"] != "clip.mp4" {
+				t.Fatalf("items = %v, want one for %s", items, upload.Key)
+			}
+			if camera, ok := items[0]["camera_id"]; ok {
+				t.Errorf("camera_id = %q, want none for a clip outside the watched directories", camera)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lachiem1/eyeSeeYou/backend/go/media"
+	"github.com/lachiem1/eyeSeeYou/backend/go/metrics"
+)
+
+var (
+	// errEmptyVideo is returned for videos with no content
+	errEmptyVideo = errors.New("video is empty")
+	// errOversizedVideo is returned for videos larger than MAX_VIDEO_SIZE_MB
+	errOversizedVideo = errors.New("video exceeds the maximum size")
+	// errInvalidVideo is returned for files that don't start with a video container signature
+	errInvalidVideo = errors.New("file is not a video")
+)
+
+// checkVideo runs the size check and, if enabled, the container signature check
+func (fw *FileWatcher) checkVideo(filePath string) error {
+	if err := fw.checkVideoSize(filePath); err != nil {
+		return err
+	}
+	if fw.cfg.VideoSignatureCheck {
+		return fw.checkVideoSignature(filePath)
+	}
+	return nil
+}
+
+// checkVideoSize rejects videos that are empty or larger than the configured maximum.
+// Neither would upload usefully: an empty file has nothing to watch, and an oversized
+// one (e.g. a camera stuck recording) would tie up the upload path and likely fail.
+func (fw *FileWatcher) checkVideoSize(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat video: %w", err)
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return errEmptyVideo
+	}
+	if limit := int64(fw.cfg.MaxVideoSizeMB) * 1024 * 1024; limit > 0 && size > limit {
+		return fmt.Errorf("%w: %d bytes, limit %d MB", errOversizedVideo, size, fw.cfg.MaxVideoSizeMB)
+	}
+	return nil
+}
+
+// checkVideoSignature rejects files whose first bytes aren't a known video
+// container's, e.g. a mislabelled file or one whose header was never written,
+// rather than trusting the extension and uploading garbage
+func (fw *FileWatcher) checkVideoSignature(filePath string) error {
+	container, err := media.DetectContainer(filePath)
+	if errors.Is(err, media.ErrUnknownContainer) {
+		return fmt.Errorf("%w: %v", errInvalidVideo, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read video header: %w", err)
+	}
+	slog.Debug("Detected video container", "path", filePath, "container", container)
+	return nil
+}
+
+// isRejectable reports whether a size or signature check error means the video
+// should be set aside rather than treated as a processing failure
+func isRejectable(err error) bool {
+	return errors.Is(err, errEmptyVideo) || errors.Is(err, errOversizedVideo) || errors.Is(err, errInvalidVideo)
+}
+
+// rejectVideo moves a video that failed the size or signature check, with its
+// sidecar, to the rejected directory so it is kept for inspection but never
+// retried. Returns the outcome to record.
+func (fw *FileWatcher) rejectVideo(filePath string, err error) string {
+	outcome, counter := OutcomeOversized, metrics.VideosOversized
+	switch {
+	case errors.Is(err, errEmptyVideo):
+		outcome, counter = OutcomeEmpty, metrics.VideosEmpty
+	case errors.Is(err, errInvalidVideo):
+		outcome, counter = OutcomeInvalid, metrics.VideosInvalid
+	}
+	metrics.Default.Inc(counter)
+	slog.Error("Rejected video, not uploading", "path", filePath, "reason", outcome, "error", err)
+
+	fw.moveRejected(filePath)
+	return outcome
+}
+
+// moveRejected moves a rejected video and its sidecar, if any, to the rejected directory
+func (fw *FileWatcher) moveRejected(filePath string) {
+	dest, err := moveToDir(filePath, fw.cfg.RejectedDir)
+	if err != nil {
+		slog.Error("Failed to move rejected video", "path", filePath, "dir", fw.cfg.RejectedDir, "error", err)
+		return
+	}
+	slog.Info("Moved rejected video", "path", filePath, "dest", dest)
+
+	if _, err := os.Stat(sidecarPath(filePath)); err == nil {
+		if _, err := moveToDir(sidecarPath(filePath), fw.cfg.RejectedDir); err != nil {
+			slog.Warn("Failed to move sidecar of rejected video", "path", sidecarPath(filePath), "error", err)
+		}
+	}
+}
+
+// moveToDir moves a file into dir, creating dir if needed. If the name is taken
+// a timestamp is added before the extension. Returns the new path.
+func moveToDir(filePath, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	name := filepath.Base(filePath)
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		ext := filepath.Ext(name)
+		dest = filepath.Join(dir, strings.TrimSuffix(name, ext)+"."+time.Now().UTC().Format("20060102T150405.000")+ext)
+	}
+
+	if err := os.Rename(filePath, dest); err != nil {
+		return "", fmt.Errorf("failed to move file: %w", err)
+	}
+	return dest, nil
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	awspackage "github.com/lachiem1/eyeSeeYou/backend/go/aws"
+)
+
+// attachThumbnail extracts a thumbnail for a video, uploads it next to the
+// video's key under the thumbnail prefix and adds its URL, signed by the video's
+// publisher, to the notification. Failures only log a warning: the video is
+// still notified without it.
+func (fw *FileWatcher) attachThumbnail(ctx context.Context, publisher *awspackage.SNSPublisher, videoPath, s3Key string, notification *awspackage.VideoNotification) {
+	if fw.opts.Thumbnailer == nil {
+		return
+	}
+
+	file, err := os.CreateTemp("", "eyeseeyou-thumbnail-*.jpg")
+	if err != nil {
+		slog.Warn("Failed to create thumbnail file", "path", videoPath, "error", err)
+		return
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := fw.opts.Thumbnailer.Generate(ctx, videoPath, file.Name()); err != nil {
+		slog.Warn("Failed to generate thumbnail", "path", videoPath, "error", err)
+		return
+	}
+
+	key := thumbnailKey(s3Key, fw.cfg.S3KeyPrefix, fw.cfg.ThumbnailPrefix)
+	if err := fw.s3Uploader.UploadFile(ctx, file.Name(), key, "image/jpeg"); err != nil {
+		slog.Warn("Failed to upload thumbnail", "path", videoPath, "s3_key", key, "error", err)
+		return
+	}
+
+	thumbnailURL, _, err := publisher.SignedURL(ctx, fw.cfg.CloudFrontDomain, key, awspackage.AssetThumbnail)
+	if err != nil {
+		slog.Warn("Failed to sign thumbnail URL", "s3_key", key, "error", err)
+		return
+	}
+	notification.ThumbnailURL = thumbnailURL
+}
+
+// thumbnailKey maps a video's key to its thumbnail's: the same path beneath
+// thumbnailPrefix instead of keyPrefix, with a .jpg extension
+func thumbnailKey(s3Key, keyPrefix, thumbnailPrefix string) string {
+	name := strings.TrimPrefix(s3Key, keyPrefix)
+	return thumbnailPrefix + strings.TrimSuffix(name, filepath.Ext(name)) + ".jpg"
+}